@@ -0,0 +1,494 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/store"
+	"github.com/found-cake/CacheStore/utils"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// The store.Cache methods use the CS.* commands of the server, which carry
+// values in the store's binary encoding. They use the client's context, see
+// WithContext.
+
+func (c *Client) do(args ...any) (any, error) {
+	return c.Do(c.ctx, args...)
+}
+
+func expectOK(reply any, err error) error {
+	if err != nil {
+		return err
+	}
+	if s, ok := reply.(string); !ok || s != "OK" {
+		return errors.ErrProtocol("unexpected reply, want OK")
+	}
+	return nil
+}
+
+// parseTyped decodes a [type, data, version] reply.
+func parseTyped(reply any) (types.DataType, []byte, uint64, error) {
+	elems, ok := reply.([]any)
+	if !ok || len(elems) != 3 {
+		return types.UNKNOWN, nil, 0, errors.ErrProtocol("unexpected typed value reply")
+	}
+	dataType, ok1 := elems[0].(int64)
+	data, ok2 := elems[1].([]byte)
+	version, ok3 := elems[2].(int64)
+	if !ok1 || !ok2 || !ok3 {
+		return types.UNKNOWN, nil, 0, errors.ErrProtocol("unexpected typed value reply")
+	}
+	return types.DataType(dataType), data, uint64(version), nil
+}
+
+// parseTypedTTL parses a CS.MGET item, a CS.GET reply followed by the time to
+// live.
+func parseTypedTTL(reply any) (types.DataType, []byte, uint64, time.Duration, error) {
+	elems, ok := reply.([]any)
+	if !ok || len(elems) != 4 {
+		return types.UNKNOWN, nil, 0, 0, errors.ErrProtocol("unexpected typed value reply")
+	}
+	ttl, ok := elems[3].(int64)
+	if !ok {
+		return types.UNKNOWN, nil, 0, 0, errors.ErrProtocol("unexpected typed value reply")
+	}
+	dataType, data, version, err := parseTyped(elems[:3])
+	return dataType, data, version, time.Duration(ttl), err
+}
+
+func (c *Client) Get(key string) (types.DataType, []byte, error) {
+	if key == "" {
+		return types.UNKNOWN, nil, errors.ErrKeyEmpty
+	}
+	reply, err := c.do("CS.GET", key)
+	if err != nil {
+		return types.UNKNOWN, nil, err
+	}
+	dataType, data, _, err := parseTyped(reply)
+	return dataType, data, err
+}
+
+// getTyped returns the data of key if it holds a dataType value.
+func (c *Client) getTyped(key string, dataType types.DataType) ([]byte, error) {
+	t, data, err := c.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if t != dataType {
+		return nil, errors.ErrTypeMismatch(key, dataType, t)
+	}
+	return data, nil
+}
+
+func (c *Client) Set(key string, dataType types.DataType, value []byte, expiry time.Duration) error {
+	if key == "" {
+		return errors.ErrKeyEmpty
+	}
+	if value == nil {
+		return errors.ErrValueNil
+	}
+	if !dataType.IsKnown() {
+		return errors.ErrUnknownDataType(dataType)
+	}
+	return expectOK(c.do("CS.SET", key, dataType, value, expiry))
+}
+
+func (c *Client) Delete(key string) error {
+	if key == "" {
+		return errors.ErrKeyEmpty
+	}
+	_, err := c.do("DEL", key)
+	return err
+}
+
+func (c *Client) Exists(keys ...string) int {
+	if len(keys) == 0 {
+		return 0
+	}
+	args := make([]any, 0, len(keys)+1)
+	args = append(args, "EXISTS")
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	n, _ := c.do(args...)
+	count, _ := n.(int64)
+	return int(count)
+}
+
+// Keys returns nil when the server cannot be reached; use ListKeys to see
+// the error.
+func (c *Client) Keys() []string {
+	keys, _ := c.ListKeys()
+	return keys
+}
+
+// ListKeys is Keys reporting why the keys could not be listed.
+func (c *Client) ListKeys() ([]string, error) {
+	reply, err := c.do("KEYS", "*")
+	if err != nil {
+		return nil, err
+	}
+	elems, _ := reply.([]any)
+	keys := make([]string, 0, len(elems))
+	for _, elem := range elems {
+		if b, ok := elem.([]byte); ok {
+			keys = append(keys, string(b))
+		}
+	}
+	return keys, nil
+}
+
+func (c *Client) TTL(key string) time.Duration {
+	reply, err := c.do("CS.TTL", key)
+	if err != nil {
+		return store.TTLExpired
+	}
+	ttl, ok := reply.(int64)
+	if !ok {
+		return store.TTLExpired
+	}
+	return time.Duration(ttl)
+}
+
+func (c *Client) Flush() {
+	c.do("FLUSHDB")
+}
+
+func (c *Client) MGet(keys ...string) []store.BatchResult {
+	if len(keys) == 0 {
+		return nil
+	}
+	results := make([]store.BatchResult, len(keys))
+	args := make([]any, 0, len(keys)+1)
+	args = append(args, "CS.MGET")
+	for i, key := range keys {
+		results[i].Key = key
+		args = append(args, key)
+	}
+	reply, err := c.do(args...)
+	elems, ok := reply.([]any)
+	if err == nil && (!ok || len(elems) != len(keys)) {
+		err = errors.ErrProtocol("unexpected CS.MGET reply")
+	}
+	for i := range results {
+		if err != nil {
+			results[i].Error = err
+			continue
+		}
+		if itemErr, ok := elems[i].(error); ok {
+			results[i].Error = itemErr
+			continue
+		}
+		r := &results[i]
+		r.Type, r.Value, r.Version, r.TTL, r.Error = parseTypedTTL(elems[i])
+	}
+	return results
+}
+
+// MSet sends the remaining time to live of each entry; soft expiry and
+// version are not sent, the server assigns a new version.
+func (c *Client) MSet(items ...store.BatchItem) []error {
+	if len(items) == 0 {
+		return nil
+	}
+	errs := make([]error, len(items))
+	sent := make([]int, 0, len(items))
+	args := make([]any, 0, 4*len(items)+1)
+	args = append(args, "CS.MSET")
+	for i, item := range items {
+		if item.Key == "" {
+			errs[i] = errors.ErrKeyEmpty
+			continue
+		}
+		if item.Entry == nil {
+			errs[i] = errors.ErrValueNil
+			continue
+		}
+		if !item.Entry.Type.IsKnown() {
+			errs[i] = errors.ErrUnknownDataType(item.Entry.Type)
+			continue
+		}
+		var ttl time.Duration
+		if item.Entry.Expiry > 0 {
+			// Already expired entries get the shortest time to live.
+			ttl = max(time.Until(time.UnixMilli(item.Entry.Expiry)), 1)
+		}
+		data := item.Entry.Data
+		if data == nil {
+			data = []byte{}
+		}
+		sent = append(sent, i)
+		args = append(args, item.Key, item.Entry.Type, data, ttl)
+	}
+	if len(sent) == 0 {
+		return errs
+	}
+
+	reply, err := c.do(args...)
+	elems, ok := reply.([]any)
+	if err == nil && (!ok || len(elems) != len(sent)) {
+		err = errors.ErrProtocol("unexpected CS.MSET reply")
+	}
+	for j, i := range sent {
+		if err != nil {
+			errs[i] = err
+		} else if itemErr, ok := elems[j].(error); ok {
+			errs[i] = itemErr
+		}
+	}
+	return errs
+}
+
+func (c *Client) MDelete(keys ...string) []error {
+	if len(keys) == 0 {
+		return nil
+	}
+	errs := make([]error, len(keys))
+	sent := make([]int, 0, len(keys))
+	args := make([]any, 0, len(keys)+1)
+	args = append(args, "DEL")
+	for i, key := range keys {
+		if key == "" {
+			errs[i] = errors.ErrKeyEmpty
+			continue
+		}
+		sent = append(sent, i)
+		args = append(args, key)
+	}
+	if len(sent) == 0 {
+		return errs
+	}
+	if _, err := c.do(args...); err != nil {
+		for _, i := range sent {
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
+func (c *Client) GetRaw(key string) ([]byte, error) {
+	return c.getTyped(key, types.RAW)
+}
+
+func (c *Client) SetRaw(key string, value []byte, exp time.Duration) error {
+	return c.Set(key, types.RAW, value, exp)
+}
+
+func (c *Client) GetString(key string) (string, error) {
+	data, err := c.getTyped(key, types.STRING)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (c *Client) SetString(key string, value string, exp time.Duration) error {
+	return c.Set(key, types.STRING, []byte(value), exp)
+}
+
+func (c *Client) GetBool(key string) (bool, error) {
+	data, err := c.getTyped(key, types.BOOLEAN)
+	if err != nil {
+		return false, err
+	}
+	return len(data) > 0 && data[0] == 1, nil
+}
+
+func (c *Client) SetBool(key string, value bool, exp time.Duration) error {
+	v := byte(0)
+	if value {
+		v = 1
+	}
+	return c.Set(key, types.BOOLEAN, []byte{v}, exp)
+}
+
+func (c *Client) GetTime(key string) (time.Time, error) {
+	var t time.Time
+	data, err := c.getTyped(key, types.TIME)
+	if err != nil {
+		return t, err
+	}
+	if len(data) == 0 {
+		return t, errors.ErrNoDataForKey(key)
+	}
+	err = t.UnmarshalBinary(data)
+	return t, err
+}
+
+func (c *Client) SetTime(key string, value time.Time, exp time.Duration) error {
+	b, err := value.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return c.Set(key, types.TIME, b, exp)
+}
+
+func (c *Client) GetJSON(key string, target interface{}) error {
+	data, err := c.getTyped(key, types.JSON)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return errors.ErrNoDataForKey(key)
+	}
+	return json.Unmarshal(data, target)
+}
+
+func (c *Client) SetJSON(key string, value interface{}, exp time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.Set(key, types.JSON, data, exp)
+}
+
+// counter sends CS.INCR or CS.DECR with delta in the binary encoding of
+// dataType.
+func (c *Client) counter(cmd, key string, dataType types.DataType, delta []byte, exp time.Duration) error {
+	if key == "" {
+		return errors.ErrKeyEmpty
+	}
+	return expectOK(c.do(cmd, key, dataType, delta, exp))
+}
+
+func (c *Client) GetInt16(key string) (int16, error) {
+	data, err := c.getTyped(key, types.INT16)
+	if err != nil {
+		return 0, err
+	}
+	return utils.Binary2Int16(data)
+}
+
+func (c *Client) SetInt16(key string, value int16, exp time.Duration) error {
+	return c.Set(key, types.INT16, utils.Int16toBinary(value), exp)
+}
+
+func (c *Client) IncrInt16(key string, delta int16, exp time.Duration) error {
+	return c.counter("CS.INCR", key, types.INT16, utils.Int16toBinary(delta), exp)
+}
+
+func (c *Client) GetInt32(key string) (int32, error) {
+	data, err := c.getTyped(key, types.INT32)
+	if err != nil {
+		return 0, err
+	}
+	return utils.Binary2Int32(data)
+}
+
+func (c *Client) SetInt32(key string, value int32, exp time.Duration) error {
+	return c.Set(key, types.INT32, utils.Int32toBinary(value), exp)
+}
+
+func (c *Client) IncrInt32(key string, delta int32, exp time.Duration) error {
+	return c.counter("CS.INCR", key, types.INT32, utils.Int32toBinary(delta), exp)
+}
+
+func (c *Client) GetInt64(key string) (int64, error) {
+	data, err := c.getTyped(key, types.INT64)
+	if err != nil {
+		return 0, err
+	}
+	return utils.Binary2Int64(data)
+}
+
+func (c *Client) SetInt64(key string, value int64, exp time.Duration) error {
+	return c.Set(key, types.INT64, utils.Int64toBinary(value), exp)
+}
+
+func (c *Client) IncrInt64(key string, delta int64, exp time.Duration) error {
+	return c.counter("CS.INCR", key, types.INT64, utils.Int64toBinary(delta), exp)
+}
+
+func (c *Client) GetUInt16(key string) (uint16, error) {
+	data, err := c.getTyped(key, types.UINT16)
+	if err != nil {
+		return 0, err
+	}
+	return utils.Binary2UInt16(data)
+}
+
+func (c *Client) SetUInt16(key string, value uint16, exp time.Duration) error {
+	return c.Set(key, types.UINT16, utils.UInt16toBinary(value), exp)
+}
+
+func (c *Client) IncrUInt16(key string, delta uint16, exp time.Duration) error {
+	return c.counter("CS.INCR", key, types.UINT16, utils.UInt16toBinary(delta), exp)
+}
+
+func (c *Client) DecrUInt16(key string, delta uint16, exp time.Duration) error {
+	return c.counter("CS.DECR", key, types.UINT16, utils.UInt16toBinary(delta), exp)
+}
+
+func (c *Client) GetUInt32(key string) (uint32, error) {
+	data, err := c.getTyped(key, types.UINT32)
+	if err != nil {
+		return 0, err
+	}
+	return utils.Binary2UInt32(data)
+}
+
+func (c *Client) SetUInt32(key string, value uint32, exp time.Duration) error {
+	return c.Set(key, types.UINT32, utils.UInt32toBinary(value), exp)
+}
+
+func (c *Client) IncrUInt32(key string, delta uint32, exp time.Duration) error {
+	return c.counter("CS.INCR", key, types.UINT32, utils.UInt32toBinary(delta), exp)
+}
+
+func (c *Client) DecrUInt32(key string, delta uint32, exp time.Duration) error {
+	return c.counter("CS.DECR", key, types.UINT32, utils.UInt32toBinary(delta), exp)
+}
+
+func (c *Client) GetUInt64(key string) (uint64, error) {
+	data, err := c.getTyped(key, types.UINT64)
+	if err != nil {
+		return 0, err
+	}
+	return utils.Binary2UInt64(data)
+}
+
+func (c *Client) SetUInt64(key string, value uint64, exp time.Duration) error {
+	return c.Set(key, types.UINT64, utils.UInt64toBinary(value), exp)
+}
+
+func (c *Client) IncrUInt64(key string, delta uint64, exp time.Duration) error {
+	return c.counter("CS.INCR", key, types.UINT64, utils.UInt64toBinary(delta), exp)
+}
+
+func (c *Client) DecrUInt64(key string, delta uint64, exp time.Duration) error {
+	return c.counter("CS.DECR", key, types.UINT64, utils.UInt64toBinary(delta), exp)
+}
+
+func (c *Client) GetFloat32(key string) (float32, error) {
+	data, err := c.getTyped(key, types.FLOAT32)
+	if err != nil {
+		return 0, err
+	}
+	return utils.Binary2Float32(data)
+}
+
+func (c *Client) SetFloat32(key string, value float32, exp time.Duration) error {
+	return c.Set(key, types.FLOAT32, utils.Float32toBinary(value), exp)
+}
+
+func (c *Client) IncrFloat32(key string, delta float32, exp time.Duration) error {
+	return c.counter("CS.INCR", key, types.FLOAT32, utils.Float32toBinary(delta), exp)
+}
+
+func (c *Client) GetFloat64(key string) (float64, error) {
+	data, err := c.getTyped(key, types.FLOAT64)
+	if err != nil {
+		return 0, err
+	}
+	return utils.Binary2Float64(data)
+}
+
+func (c *Client) SetFloat64(key string, value float64, exp time.Duration) error {
+	return c.Set(key, types.FLOAT64, utils.Float64toBinary(value), exp)
+}
+
+func (c *Client) IncrFloat64(key string, delta float64, exp time.Duration) error {
+	return c.counter("CS.INCR", key, types.FLOAT64, utils.Float64toBinary(delta), exp)
+}
@@ -0,0 +1,201 @@
+// Package client is a Go client for cachestore-server. *Client implements
+// store.Cache, so code written against that interface can use an embedded
+// *store.CacheStore or a remote server without changes.
+package client
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/store"
+)
+
+const (
+	DefaultPoolSize    = 10
+	DefaultDialTimeout = 5 * time.Second
+)
+
+type Config struct {
+	Network     string        // "tcp" (default) or "unix"
+	Addr        string        // host:port, or the socket path
+	PoolSize    int           // maximum number of open connections, DefaultPoolSize if <= 0
+	DialTimeout time.Duration // DefaultDialTimeout if <= 0
+	Timeout     time.Duration // per command when the context has no deadline, none if <= 0
+	// RetryCounts also retries the writes that reply with how many keys or
+	// members they changed, such as DEL and SADD. A retry of a write that was
+	// applied before the connection broke replies 0 instead of the real
+	// count.
+	RetryCounts bool
+}
+
+// Client is safe for concurrent use. Connections are opened on demand, kept
+// in a pool and replaced when they break; a command that fails on a pooled
+// connection is retried once on a new one when it is idempotent, since the
+// first attempt may already have been applied; see Config.RetryCounts.
+type Client struct {
+	cfg  Config
+	pool *pool
+	ctx  context.Context
+}
+
+var _ store.Cache = (*Client)(nil)
+
+// New creates a client and checks that the server answers PING.
+func New(cfg Config) (*Client, error) {
+	if cfg.Addr == "" {
+		return nil, errors.ErrAddrEmpty
+	}
+	if cfg.Network == "" {
+		cfg.Network = "tcp"
+	}
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = DefaultPoolSize
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = DefaultDialTimeout
+	}
+	dialer := &net.Dialer{Timeout: cfg.DialTimeout}
+	c := &Client{
+		cfg: cfg,
+		pool: newPool(cfg.PoolSize, func(ctx context.Context) (net.Conn, error) {
+			return dialer.DialContext(ctx, cfg.Network, cfg.Addr)
+		}),
+		ctx: context.Background(),
+	}
+	if _, err := c.Do(c.ctx, "PING"); err != nil {
+		c.pool.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// WithContext returns a client sharing c's connections whose store.Cache
+// methods use ctx for cancellation and deadlines.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	if ctx == nil {
+		panic("client: nil context")
+	}
+	c2 := *c
+	c2.ctx = ctx
+	return &c2
+}
+
+// Context returns the context used by the store.Cache methods.
+func (c *Client) Context() context.Context {
+	return c.ctx
+}
+
+// Close closes the connections of c and of every client made by WithContext.
+func (c *Client) Close() error {
+	c.pool.close()
+	return nil
+}
+
+// Do sends a command and returns its reply: a string for a status reply,
+// int64, []byte, nil, or []any. Error replies are returned as the error, and
+// match the store's errors with errors.Is; see server.ParseError.
+//
+// Arguments may be strings, []byte, integers, floats, types.DataType or
+// time.Duration (sent in nanoseconds).
+func (c *Client) Do(ctx context.Context, args ...any) (any, error) {
+	replies, err := c.process(ctx, [][]any{args})
+	if err != nil {
+		return nil, err
+	}
+	if err, ok := replies[0].(error); ok {
+		return nil, err
+	}
+	return replies[0], nil
+}
+
+// process sends cmds on one connection and reads their replies, retrying
+// once on a new connection when a pooled one turns out to be broken.
+func (c *Client) process(ctx context.Context, cmds [][]any) ([]any, error) {
+	for retried := false; ; retried = true {
+		cn, err := c.pool.get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		reused := cn.reused
+		replies, err := cn.roundTrip(ctx, cmds, c.cfg.Timeout)
+		c.pool.put(cn, err != nil)
+		if err == nil {
+			return replies, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if retried || !reused || !retryable(cmds, c.cfg.RetryCounts) {
+			return nil, err
+		}
+		// The server has likely restarted; the other idle connections are
+		// just as stale.
+		c.pool.drain()
+	}
+}
+
+// idempotent lists the commands that leave the same state and give the same
+// reply when applied twice. Only these are retried: the first attempt may
+// have been applied.
+var idempotent = map[string]bool{
+	"PING": true, "ECHO": true, "SELECT": true, "COMMAND": true,
+	"GET": true, "MGET": true, "EXISTS": true, "KEYS": true, "TTL": true, "PTTL": true,
+	"SET": true, "MSET": true, "FLUSHDB": true, "FLUSHALL": true,
+	"HGET": true, "HMGET": true, "HGETALL": true, "HLEN": true, "HEXISTS": true,
+	"LRANGE": true, "LLEN": true, "LINDEX": true, "LSET": true,
+	"SISMEMBER": true, "SMEMBERS": true, "SCARD": true, "SRANDMEMBER": true,
+	"SUNION": true, "SINTER": true, "SDIFF": true,
+	"ZSCORE": true, "ZRANK": true, "ZREVRANK": true, "ZCARD": true,
+	"ZRANGE": true, "ZREVRANGE": true, "ZRANGEBYSCORE": true,
+	"GETBIT": true, "BITCOUNT": true, "BITPOS": true,
+	"CS.GET": true, "CS.MGET": true, "CS.TTL": true,
+}
+
+// counting lists the commands that leave the same state when applied twice
+// but reply with what the first one changed. They are only retried with
+// Config.RetryCounts.
+var counting = map[string]bool{
+	"DEL": true, "HSET": true, "HDEL": true, "SADD": true, "SREM": true,
+	"ZADD": true, "ZREM": true,
+}
+
+func retryable(cmds [][]any, counts bool) bool {
+	for _, args := range cmds {
+		if len(args) == 0 {
+			continue
+		}
+		name, _ := args[0].(string)
+		name = strings.ToUpper(name)
+		if !idempotent[name] && !(counts && counting[name]) {
+			return false
+		}
+		// SET NX and XX reply differently once the first attempt applied.
+		if name == "SET" && len(args) > 3 && hasOption(args[3:], "NX", "XX") {
+			return false
+		}
+	}
+	return true
+}
+
+func hasOption(args []any, options ...string) bool {
+	for _, arg := range args {
+		var opt string
+		switch v := arg.(type) {
+		case string:
+			opt = v
+		case []byte:
+			opt = string(v)
+		default:
+			continue
+		}
+		for _, o := range options {
+			if strings.EqualFold(opt, o) {
+				return true
+			}
+		}
+	}
+	return false
+}
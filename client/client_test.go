@@ -0,0 +1,444 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	goerrors "errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/server"
+	"github.com/found-cake/CacheStore/store"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func newStore(t *testing.T) *store.CacheStore {
+	t.Helper()
+	cache, err := store.NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("NewCacheStore() error = %v", err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+// serve runs a server for cache on l until the test ends or stop is called.
+func serve(t *testing.T, cache *store.CacheStore, l net.Listener) (stop func()) {
+	t.Helper()
+	srv := server.New(cache)
+	done := make(chan struct{})
+	go func() {
+		srv.Serve(l)
+		close(done)
+	}()
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			srv.Close()
+			<-done
+		})
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func newTestClient(t *testing.T, cfg Config) (*store.CacheStore, *Client) {
+	t.Helper()
+	cache := newStore(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	serve(t, cache, l)
+	cfg.Addr = l.Addr().String()
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return cache, c
+}
+
+// exercise runs the same operations against any store.Cache.
+func exercise(t *testing.T, c store.Cache) {
+	t.Helper()
+	if err := c.SetInt64("i64", -5, time.Hour); err != nil {
+		t.Fatalf("SetInt64() error = %v", err)
+	}
+	if err := c.IncrInt64("i64", 7, 0); err != nil {
+		t.Fatalf("IncrInt64() error = %v", err)
+	}
+	if v, err := c.GetInt64("i64"); err != nil || v != 2 {
+		t.Errorf("GetInt64() = %d, %v, want 2", v, err)
+	}
+	if ttl := c.TTL("i64"); ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("TTL() = %v, want about an hour", ttl)
+	}
+
+	c.SetUInt32("u32", 1, 0)
+	if err := c.IncrUInt32("u32", 9, 0); err != nil {
+		t.Fatalf("IncrUInt32() error = %v", err)
+	}
+	if err := c.DecrUInt32("u32", 4, 0); err != nil {
+		t.Fatalf("DecrUInt32() error = %v", err)
+	}
+	if v, err := c.GetUInt32("u32"); err != nil || v != 6 {
+		t.Errorf("GetUInt32() = %d, %v, want 6", v, err)
+	}
+	if err := c.DecrUInt32("u32", 7, 0); !goerrors.Is(err, errors.ErrOutOfRange) {
+		t.Errorf("DecrUInt32() underflow error = %v, want ErrOutOfRange", err)
+	}
+
+	c.SetFloat64("f64", 1.5, 0)
+	c.IncrFloat64("f64", 0.25, 0)
+	if v, err := c.GetFloat64("f64"); err != nil || v != 1.75 {
+		t.Errorf("GetFloat64() = %v, %v, want 1.75", v, err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	c.SetString("s", "text", 0)
+	c.SetBool("b", true, 0)
+	c.SetTime("t", now, 0)
+	c.SetJSON("j", map[string]int{"a": 1}, 0)
+	c.SetRaw("r", []byte{0, 1, 2}, 0)
+	if v, err := c.GetString("s"); err != nil || v != "text" {
+		t.Errorf("GetString() = %q, %v", v, err)
+	}
+	if v, err := c.GetBool("b"); err != nil || !v {
+		t.Errorf("GetBool() = %v, %v", v, err)
+	}
+	if v, err := c.GetTime("t"); err != nil || !v.Equal(now) {
+		t.Errorf("GetTime() = %v, %v, want %v", v, err, now)
+	}
+	var doc map[string]int
+	if err := c.GetJSON("j", &doc); err != nil || doc["a"] != 1 {
+		t.Errorf("GetJSON() = %v, %v", doc, err)
+	}
+	if v, err := c.GetRaw("r"); err != nil || len(v) != 3 || v[2] != 2 {
+		t.Errorf("GetRaw() = %v, %v", v, err)
+	}
+	if dataType, v, err := c.Get("s"); err != nil || dataType != types.STRING || string(v) != "text" {
+		t.Errorf("Get() = %v, %q, %v", dataType, v, err)
+	}
+
+	if _, err := c.GetInt16("s"); !goerrors.Is(err, errors.ErrWrongType) {
+		t.Errorf("GetInt16(string) error = %v, want ErrWrongType", err)
+	}
+	if err := c.IncrInt16("s", 1, 0); !goerrors.Is(err, errors.ErrWrongType) {
+		t.Errorf("IncrInt16(string) error = %v, want ErrWrongType", err)
+	}
+	if _, err := c.GetString("missing"); !goerrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("GetString(missing) error = %v, want ErrNotFound", err)
+	}
+	if err := c.Set("", types.STRING, []byte("v"), 0); err != errors.ErrKeyEmpty {
+		t.Errorf("Set(\"\") error = %v, want ErrKeyEmpty", err)
+	}
+
+	if n := c.Exists("s", "b", "missing"); n != 2 {
+		t.Errorf("Exists() = %d, want 2", n)
+	}
+	if err := c.Delete("s"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if ttl := c.TTL("s"); ttl != store.TTLExpired {
+		t.Errorf("TTL(deleted) = %v, want TTLExpired", ttl)
+	}
+	if keys := c.Keys(); len(keys) != 7 {
+		t.Errorf("Keys() = %v, want 7 keys", keys)
+	}
+
+	errs := c.MSet(
+		store.NewItem("m1", types.STRING, []byte("a"), 0),
+		store.NewItem("", types.STRING, []byte("b"), 0),
+		store.NewItem("m2", types.INT32, []byte{0, 0, 0, 2}, time.Minute),
+	)
+	if len(errs) != 3 || errs[0] != nil || errs[1] != errors.ErrKeyEmpty || errs[2] != nil {
+		t.Errorf("MSet() = %v", errs)
+	}
+	results := c.MGet("m1", "missing", "m2")
+	if len(results) != 3 || string(results[0].Value) != "a" || results[0].Version == 0 {
+		t.Fatalf("MGet() = %+v", results)
+	}
+	if !goerrors.Is(results[1].Error, errors.ErrNotFound) {
+		t.Errorf("MGet(missing) error = %v, want ErrNotFound", results[1].Error)
+	}
+	if results[2].Type != types.INT32 || results[2].Key != "m2" {
+		t.Errorf("MGet(m2) = %+v", results[2])
+	}
+	if ttl := c.TTL("m2"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("TTL(m2) = %v, want about a minute", ttl)
+	}
+	if errs := c.MDelete("m1", "", "m2"); errs[0] != nil || errs[1] != errors.ErrKeyEmpty || errs[2] != nil {
+		t.Errorf("MDelete() = %v", errs)
+	}
+	if c.Exists("m1", "m2") != 0 {
+		t.Error("MDelete() kept keys")
+	}
+
+	c.Flush()
+	if keys := c.Keys(); len(keys) != 0 {
+		t.Errorf("Keys() after Flush = %v", keys)
+	}
+}
+
+func TestClient_SameAsEmbedded(t *testing.T) {
+	t.Run("embedded", func(t *testing.T) {
+		exercise(t, newStore(t))
+	})
+	t.Run("remote", func(t *testing.T) {
+		_, c := newTestClient(t, Config{})
+		exercise(t, c)
+	})
+}
+
+func TestClient_Do(t *testing.T) {
+	cache, c := newTestClient(t, Config{})
+	ctx := context.Background()
+
+	if reply, err := c.Do(ctx, "SET", "k", "v"); err != nil || reply != "OK" {
+		t.Errorf("SET = %v, %v", reply, err)
+	}
+	if reply, err := c.Do(ctx, "INCRBY", "n", 5); err != nil || reply != int64(5) {
+		t.Errorf("INCRBY = %v, %v", reply, err)
+	}
+	if reply, err := c.Do(ctx, "GET", "missing"); err != nil || reply != nil {
+		t.Errorf("GET missing = %v, %v, want nil", reply, err)
+	}
+	if _, err := c.Do(ctx, "NOPE"); err == nil {
+		t.Error("unknown command expected error")
+	}
+	if v, err := cache.GetString("k"); err != nil || v != "v" {
+		t.Errorf("store value = %q, %v", v, err)
+	}
+}
+
+func TestClient_Pipeline(t *testing.T) {
+	_, c := newTestClient(t, Config{PoolSize: 1})
+
+	p := c.Pipeline()
+	for i := 0; i < 100; i++ {
+		p.Do("INCR", "n")
+	}
+	p.Do("GET", "n")
+	p.Do("CS.GET", "missing")
+	if p.Len() != 102 {
+		t.Fatalf("Len() = %d, want 102", p.Len())
+	}
+	replies, err := p.Exec(context.Background())
+	if err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if len(replies) != 102 || replies[99] != int64(100) || string(replies[100].([]byte)) != "100" {
+		t.Errorf("Exec() replies = %v", replies[98:])
+	}
+	if err, ok := replies[101].(error); !ok || !goerrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("error reply = %v, want ErrNotFound", replies[101])
+	}
+	if p.Len() != 0 {
+		t.Error("Exec() should empty the queue")
+	}
+}
+
+func TestClient_Concurrent(t *testing.T) {
+	cache, c := newTestClient(t, Config{PoolSize: 2})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.IncrInt64("n", 1, 0); err != nil {
+				t.Errorf("IncrInt64() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if v, err := cache.GetInt64("n"); err != nil || v != 50 {
+		t.Errorf("GetInt64() = %d, %v, want 50", v, err)
+	}
+}
+
+// stall accepts connections and answers PING, but no other command.
+func stall(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	var (
+		mux   sync.Mutex
+		conns []net.Conn
+	)
+	t.Cleanup(func() {
+		l.Close()
+		mux.Lock()
+		defer mux.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			mux.Lock()
+			conns = append(conns, conn)
+			mux.Unlock()
+			go func() {
+				r := bufio.NewReader(conn)
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == "PING\r\n" {
+						conn.Write([]byte("+PONG\r\n"))
+					}
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestClient_Timeouts(t *testing.T) {
+	addr := stall(t)
+
+	c, err := New(Config{Addr: addr})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.WithContext(ctx).GetString("k"); !goerrors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetString() with deadline error = %v, want DeadlineExceeded", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := c.Do(ctx, "GET", "k"); !goerrors.Is(err, context.Canceled) {
+		t.Errorf("Do() canceled error = %v, want Canceled", err)
+	}
+
+	c2, err := New(Config{Addr: addr, Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer c2.Close()
+	if err := c2.SetString("k", "v", 0); !goerrors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("SetString() error = %v, want a timeout", err)
+	}
+}
+
+func TestClient_Reconnect(t *testing.T) {
+	cache := newStore(t)
+	sock := filepath.Join(t.TempDir(), "cs.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	stop := serve(t, cache, l)
+
+	c, err := New(Config{Network: "unix", Addr: sock})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer c.Close()
+	if err := c.SetString("k", "v", 0); err != nil {
+		t.Fatalf("SetString() error = %v", err)
+	}
+
+	// The pooled connection is stale after a restart and is replaced.
+	stop()
+	stop = restart(t, cache, sock)
+	if v, err := c.GetString("k"); err != nil || v != "v" {
+		t.Errorf("GetString() after restart = %q, %v", v, err)
+	}
+
+	stop()
+	if _, err := c.GetString("k"); err == nil {
+		t.Fatal("GetString() with the server down expected error")
+	}
+	restart(t, cache, sock)
+	if v, err := c.GetString("k"); err != nil || v != "v" {
+		t.Errorf("GetString() after the server is back = %q, %v", v, err)
+	}
+}
+
+func restart(t *testing.T, cache *store.CacheStore, sock string) func() {
+	t.Helper()
+	os.Remove(sock)
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	return serve(t, cache, l)
+}
+
+func TestClient_Retryable(t *testing.T) {
+	tests := []struct {
+		cmd  []any
+		want bool
+	}{
+		{[]any{"GET", "k"}, true},
+		{[]any{"mget", "a", "b"}, true},
+		{[]any{"SET", "k", "v", "EX", "10"}, true},
+		{[]any{"SET", "k", "v", []byte("nx")}, false},
+		{[]any{"SET", "k", "v", "XX"}, false},
+		{[]any{"DEL", "k"}, false},
+		{[]any{"HSET", "h", "f", "v"}, false},
+		{[]any{"HDEL", "h", "f"}, false},
+		{[]any{"SADD", "s", "m"}, false},
+		{[]any{"SREM", "s", "m"}, false},
+		{[]any{"ZADD", "z", "1", "m"}, false},
+		{[]any{"ZREM", "z", "m"}, false},
+		{[]any{"INCR", "k"}, false},
+		{[]any{"LPUSH", "l", "x"}, false},
+		{[]any{"RPUSH", "l", "x"}, false},
+		{[]any{"LPOP", "l"}, false},
+		{[]any{"SPOP", "s"}, false},
+		{[]any{"HINCRBY", "h", "f", "1"}, false},
+		{[]any{"ZINCRBY", "z", "1", "m"}, false},
+		{[]any{"SETBIT", "b", "7", "1"}, false},
+		{[]any{"BITOP", "AND", "d", "a"}, false},
+		{[]any{"CS.SET", "k", "int64", "0", "1"}, false},
+		{[]any{"CS.INCR", "k", "int64", "1", "0"}, false},
+		{[]any{"CS.GET", "k"}, true},
+	}
+	for _, tt := range tests {
+		if got := retryable([][]any{tt.cmd}, false); got != tt.want {
+			t.Errorf("retryable(%v) = %v, want %v", tt.cmd, got, tt.want)
+		}
+	}
+	if retryable([][]any{{"GET", "k"}, {"INCR", "k"}}, false) {
+		t.Error("retryable() of a pipeline with INCR = true, want false")
+	}
+	for _, cmd := range [][]any{{"DEL", "k"}, {"sadd", "s", "m"}, {"ZREM", "z", "m"}} {
+		if !retryable([][]any{cmd}, true) {
+			t.Errorf("retryable(%v) with RetryCounts = false, want true", cmd)
+		}
+	}
+	if retryable([][]any{{"DEL", "k"}, {"INCR", "k"}}, true) {
+		t.Error("retryable() of a pipeline with INCR and RetryCounts = true, want false")
+	}
+}
+
+func TestClient_Closed(t *testing.T) {
+	_, c := newTestClient(t, Config{})
+	c.Close()
+	if _, err := c.Do(context.Background(), "PING"); err != errors.ErrClientClosed {
+		t.Errorf("Do() after Close error = %v, want ErrClientClosed", err)
+	}
+	if _, err := New(Config{}); err != errors.ErrAddrEmpty {
+		t.Errorf("New() without address error = %v, want ErrAddrEmpty", err)
+	}
+}
@@ -0,0 +1,187 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/server"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+const maxBulkLen = 512 << 20
+
+// conn is one RESP2 connection.
+type conn struct {
+	nc     net.Conn
+	r      *bufio.Reader
+	w      *bufio.Writer
+	reused bool // taken from the pool, so it may have gone stale
+}
+
+func newConn(nc net.Conn) *conn {
+	return &conn{nc: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+}
+
+func (cn *conn) close() {
+	cn.nc.Close()
+}
+
+// roundTrip writes cmds and reads one reply per command. Error replies are
+// returned among the replies; the error is for a failure of the connection,
+// which must not be reused afterwards.
+func (cn *conn) roundTrip(ctx context.Context, cmds [][]any, timeout time.Duration) ([]any, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok && timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if err := cn.nc.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		cn.nc.SetDeadline(time.Unix(1, 0))
+	})
+
+	replies, err := cn.exchange(cmds)
+	if !stop() {
+		// The deadline has been moved to the past.
+		return nil, ctx.Err()
+	}
+	return replies, err
+}
+
+func (cn *conn) exchange(cmds [][]any) ([]any, error) {
+	for _, args := range cmds {
+		writeCommand(cn.w, args)
+	}
+	if err := cn.w.Flush(); err != nil {
+		return nil, err
+	}
+	replies := make([]any, len(cmds))
+	for i := range replies {
+		reply, err := readReply(cn.r)
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+func writeCommand(w *bufio.Writer, args []any) {
+	w.WriteByte('*')
+	w.WriteString(strconv.Itoa(len(args)))
+	w.WriteString("\r\n")
+	var buf []byte
+	for _, arg := range args {
+		buf = appendArg(buf[:0], arg)
+		w.WriteByte('$')
+		w.WriteString(strconv.Itoa(len(buf)))
+		w.WriteString("\r\n")
+		w.Write(buf)
+		w.WriteString("\r\n")
+	}
+}
+
+func appendArg(b []byte, arg any) []byte {
+	switch v := arg.(type) {
+	case string:
+		return append(b, v...)
+	case []byte:
+		return append(b, v...)
+	case int:
+		return strconv.AppendInt(b, int64(v), 10)
+	case int64:
+		return strconv.AppendInt(b, v, 10)
+	case uint64:
+		return strconv.AppendUint(b, v, 10)
+	case float64:
+		return strconv.AppendFloat(b, v, 'f', -1, 64)
+	case types.DataType:
+		return strconv.AppendUint(b, uint64(v), 10)
+	case time.Duration:
+		return strconv.AppendInt(b, int64(v), 10)
+	default:
+		return fmt.Append(b, v)
+	}
+}
+
+// readReply reads one reply. Error replies are returned as the reply, built
+// by server.ParseError.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.ErrProtocol("empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return server.ParseError(string(line[1:])), nil
+	case ':':
+		n, err := strconv.ParseInt(string(line[1:]), 10, 64)
+		if err != nil {
+			return nil, errors.ErrProtocol("invalid integer reply")
+		}
+		return n, nil
+	case '$':
+		n, err := parseLen(line[1:], maxBulkLen)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		if b[n] != '\r' || b[n+1] != '\n' {
+			return nil, errors.ErrProtocol("bulk string not terminated by CRLF")
+		}
+		return b[:n], nil
+	case '*':
+		n, err := parseLen(line[1:], math.MaxInt32)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		elems := make([]any, n)
+		for i := range elems {
+			if elems[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return elems, nil
+	default:
+		return nil, errors.ErrProtocol(fmt.Sprintf("unexpected reply type %q", line[0]))
+	}
+}
+
+// parseLen parses a bulk or array length; -1 is a null reply.
+func parseLen(b []byte, max int) (int, error) {
+	n, err := strconv.Atoi(string(b))
+	if err != nil || n < -1 || n > max {
+		return 0, errors.ErrProtocol("invalid length")
+	}
+	return n, nil
+}
+
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		if err == bufio.ErrBufferFull {
+			return nil, errors.ErrProtocol("reply line too long")
+		}
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, errors.ErrProtocol("line not terminated by CRLF")
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,36 @@
+package client
+
+import "context"
+
+// Pipeline queues commands and sends them together, on one connection and
+// in one write, when Exec is called.
+type Pipeline struct {
+	c    *Client
+	cmds [][]any
+}
+
+func (c *Client) Pipeline() *Pipeline {
+	return &Pipeline{c: c}
+}
+
+// Do queues a command; its arguments are those of Client.Do.
+func (p *Pipeline) Do(args ...any) {
+	p.cmds = append(p.cmds, args)
+}
+
+func (p *Pipeline) Len() int {
+	return len(p.cmds)
+}
+
+// Exec sends the queued commands and empties the queue. It returns one reply
+// per command, in order, with error replies as error values; the error is for
+// a failure to reach the server, after which it is unknown which commands
+// were applied.
+func (p *Pipeline) Exec(ctx context.Context) ([]any, error) {
+	if len(p.cmds) == 0 {
+		return nil, nil
+	}
+	cmds := p.cmds
+	p.cmds = nil
+	return p.c.process(ctx, cmds)
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/found-cake/CacheStore/errors"
+)
+
+// pool hands out at most cap(slots) connections at a time, reusing idle
+// ones before dialing.
+type pool struct {
+	dial  func(ctx context.Context) (net.Conn, error)
+	slots chan struct{}
+
+	mux    sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+func newPool(size int, dial func(ctx context.Context) (net.Conn, error)) *pool {
+	return &pool{
+		dial:  dial,
+		slots: make(chan struct{}, size),
+	}
+}
+
+// get waits for a free slot and returns an idle connection, or a new one.
+func (p *pool) get(ctx context.Context) (*conn, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	p.mux.Lock()
+	if p.closed {
+		p.mux.Unlock()
+		<-p.slots
+		return nil, errors.ErrClientClosed
+	}
+	if n := len(p.idle); n > 0 {
+		cn := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mux.Unlock()
+		return cn, nil
+	}
+	p.mux.Unlock()
+
+	nc, err := p.dial(ctx)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return newConn(nc), nil
+}
+
+// put returns cn to the pool, or closes it if it is broken.
+func (p *pool) put(cn *conn, broken bool) {
+	p.mux.Lock()
+	if broken || p.closed {
+		p.mux.Unlock()
+		cn.close()
+	} else {
+		cn.reused = true
+		p.idle = append(p.idle, cn)
+		p.mux.Unlock()
+	}
+	<-p.slots
+}
+
+// drain closes the idle connections.
+func (p *pool) drain() {
+	p.mux.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.mux.Unlock()
+	for _, cn := range idle {
+		cn.close()
+	}
+}
+
+func (p *pool) close() {
+	p.mux.Lock()
+	p.closed = true
+	p.mux.Unlock()
+	p.drain()
+}
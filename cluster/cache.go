@@ -0,0 +1,319 @@
+package cluster
+
+import (
+	goerrors "errors"
+	"sync"
+	"time"
+
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/store"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// The store.Cache methods route single-key calls to the owner of the key.
+// Batch calls are split by owner, sent to the nodes concurrently and merged
+// back in the order of the arguments.
+
+func (c *Cluster) Get(key string) (types.DataType, []byte, error) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	node, ok := c.nodes[c.ring.owner(key)]
+	if !ok {
+		return types.UNKNOWN, nil, errors.ErrNoNodes
+	}
+	return node.Get(key)
+}
+
+func (c *Cluster) Set(key string, dataType types.DataType, value []byte, expiry time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.Set(key, dataType, value, expiry) })
+}
+
+func (c *Cluster) Delete(key string) error {
+	return c.exec(key, func(node store.Cache) error { return node.Delete(key) })
+}
+
+// TTL returns TTLExpired when the cluster has no nodes.
+func (c *Cluster) TTL(key string) time.Duration {
+	ttl, err := route(c, key, func(node store.Cache) (time.Duration, error) { return node.TTL(key), nil })
+	if err != nil {
+		return store.TTLExpired
+	}
+	return ttl
+}
+
+func (c *Cluster) Exists(keys ...string) int {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	counts := make(chan int, len(c.nodes))
+	fanOut(c.group(keys), func(owner string, idx []int) {
+		if node, ok := c.nodes[owner]; ok {
+			counts <- node.Exists(pick(keys, idx)...)
+		}
+	})
+	close(counts)
+	total := 0
+	for n := range counts {
+		total += n
+	}
+	return total
+}
+
+// Keys returns the keys of every reachable node.
+func (c *Cluster) Keys() []string {
+	keys, _ := c.ListKeys()
+	return keys
+}
+
+// ListKeys is Keys also reporting the nodes whose keys could not be listed.
+func (c *Cluster) ListKeys() ([]string, error) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	var mu sync.Mutex
+	var keys []string
+	var errs []error
+	c.eachNode(func(node store.Cache) {
+		k, err := listKeys(node)
+		mu.Lock()
+		keys = append(keys, k...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		mu.Unlock()
+	})
+	return keys, goerrors.Join(errs...)
+}
+
+// Flush flushes every node.
+func (c *Cluster) Flush() {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	c.eachNode(func(node store.Cache) { node.Flush() })
+}
+
+func (c *Cluster) MGet(keys ...string) []store.BatchResult {
+	if len(keys) == 0 {
+		return nil
+	}
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	results := make([]store.BatchResult, len(keys))
+	fanOut(c.group(keys), func(owner string, idx []int) {
+		node, ok := c.nodes[owner]
+		if !ok {
+			for _, i := range idx {
+				results[i] = store.BatchResult{Key: keys[i], Error: errors.ErrNoNodes}
+			}
+			return
+		}
+		part := node.MGet(pick(keys, idx)...)
+		for j, i := range idx {
+			if j < len(part) {
+				results[i] = part[j]
+			} else {
+				results[i] = store.BatchResult{Key: keys[i], Error: errors.ErrProtocol("node returned too few results")}
+			}
+		}
+	})
+	return results
+}
+
+func (c *Cluster) MSet(items ...store.BatchItem) []error {
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = item.Key
+	}
+	return c.batch(keys, func(node store.Cache, idx []int) []error {
+		return node.MSet(pick(items, idx)...)
+	})
+}
+
+func (c *Cluster) MDelete(keys ...string) []error {
+	return c.batch(keys, func(node store.Cache, idx []int) []error {
+		return node.MDelete(pick(keys, idx)...)
+	})
+}
+
+// batch runs fn on every owner of keys with the indexes of its keys and
+// merges the per-key errors.
+func (c *Cluster) batch(keys []string, fn func(node store.Cache, idx []int) []error) []error {
+	if len(keys) == 0 {
+		return nil
+	}
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	errs := make([]error, len(keys))
+	fanOut(c.group(keys), func(owner string, idx []int) {
+		node, ok := c.nodes[owner]
+		var part []error
+		if ok {
+			part = fn(node, idx)
+		}
+		for j, i := range idx {
+			switch {
+			case !ok:
+				errs[i] = errors.ErrNoNodes
+			case j < len(part):
+				errs[i] = part[j]
+			default:
+				errs[i] = errors.ErrProtocol("node returned too few results")
+			}
+		}
+	})
+	return errs
+}
+
+func pick[T any](s []T, idx []int) []T {
+	out := make([]T, len(idx))
+	for j, i := range idx {
+		out[j] = s[i]
+	}
+	return out
+}
+
+func (c *Cluster) GetJSON(key string, target interface{}) error {
+	return c.exec(key, func(node store.Cache) error { return node.GetJSON(key, target) })
+}
+
+func (c *Cluster) SetJSON(key string, value interface{}, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.SetJSON(key, value, exp) })
+}
+
+func (c *Cluster) GetRaw(key string) ([]byte, error) {
+	return route(c, key, func(node store.Cache) ([]byte, error) { return node.GetRaw(key) })
+}
+
+func (c *Cluster) SetRaw(key string, value []byte, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.SetRaw(key, value, exp) })
+}
+
+func (c *Cluster) GetString(key string) (string, error) {
+	return route(c, key, func(node store.Cache) (string, error) { return node.GetString(key) })
+}
+
+func (c *Cluster) SetString(key string, value string, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.SetString(key, value, exp) })
+}
+
+func (c *Cluster) GetBool(key string) (bool, error) {
+	return route(c, key, func(node store.Cache) (bool, error) { return node.GetBool(key) })
+}
+
+func (c *Cluster) SetBool(key string, value bool, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.SetBool(key, value, exp) })
+}
+
+func (c *Cluster) GetTime(key string) (time.Time, error) {
+	return route(c, key, func(node store.Cache) (time.Time, error) { return node.GetTime(key) })
+}
+
+func (c *Cluster) SetTime(key string, value time.Time, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.SetTime(key, value, exp) })
+}
+
+func (c *Cluster) GetInt16(key string) (int16, error) {
+	return route(c, key, func(node store.Cache) (int16, error) { return node.GetInt16(key) })
+}
+
+func (c *Cluster) SetInt16(key string, value int16, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.SetInt16(key, value, exp) })
+}
+
+func (c *Cluster) IncrInt16(key string, delta int16, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.IncrInt16(key, delta, exp) })
+}
+
+func (c *Cluster) GetInt32(key string) (int32, error) {
+	return route(c, key, func(node store.Cache) (int32, error) { return node.GetInt32(key) })
+}
+
+func (c *Cluster) SetInt32(key string, value int32, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.SetInt32(key, value, exp) })
+}
+
+func (c *Cluster) IncrInt32(key string, delta int32, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.IncrInt32(key, delta, exp) })
+}
+
+func (c *Cluster) GetInt64(key string) (int64, error) {
+	return route(c, key, func(node store.Cache) (int64, error) { return node.GetInt64(key) })
+}
+
+func (c *Cluster) SetInt64(key string, value int64, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.SetInt64(key, value, exp) })
+}
+
+func (c *Cluster) IncrInt64(key string, delta int64, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.IncrInt64(key, delta, exp) })
+}
+
+func (c *Cluster) GetUInt16(key string) (uint16, error) {
+	return route(c, key, func(node store.Cache) (uint16, error) { return node.GetUInt16(key) })
+}
+
+func (c *Cluster) SetUInt16(key string, value uint16, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.SetUInt16(key, value, exp) })
+}
+
+func (c *Cluster) IncrUInt16(key string, delta uint16, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.IncrUInt16(key, delta, exp) })
+}
+
+func (c *Cluster) DecrUInt16(key string, delta uint16, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.DecrUInt16(key, delta, exp) })
+}
+
+func (c *Cluster) GetUInt32(key string) (uint32, error) {
+	return route(c, key, func(node store.Cache) (uint32, error) { return node.GetUInt32(key) })
+}
+
+func (c *Cluster) SetUInt32(key string, value uint32, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.SetUInt32(key, value, exp) })
+}
+
+func (c *Cluster) IncrUInt32(key string, delta uint32, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.IncrUInt32(key, delta, exp) })
+}
+
+func (c *Cluster) DecrUInt32(key string, delta uint32, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.DecrUInt32(key, delta, exp) })
+}
+
+func (c *Cluster) GetUInt64(key string) (uint64, error) {
+	return route(c, key, func(node store.Cache) (uint64, error) { return node.GetUInt64(key) })
+}
+
+func (c *Cluster) SetUInt64(key string, value uint64, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.SetUInt64(key, value, exp) })
+}
+
+func (c *Cluster) IncrUInt64(key string, delta uint64, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.IncrUInt64(key, delta, exp) })
+}
+
+func (c *Cluster) DecrUInt64(key string, delta uint64, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.DecrUInt64(key, delta, exp) })
+}
+
+func (c *Cluster) GetFloat32(key string) (float32, error) {
+	return route(c, key, func(node store.Cache) (float32, error) { return node.GetFloat32(key) })
+}
+
+func (c *Cluster) SetFloat32(key string, value float32, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.SetFloat32(key, value, exp) })
+}
+
+func (c *Cluster) IncrFloat32(key string, delta float32, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.IncrFloat32(key, delta, exp) })
+}
+
+func (c *Cluster) GetFloat64(key string) (float64, error) {
+	return route(c, key, func(node store.Cache) (float64, error) { return node.GetFloat64(key) })
+}
+
+func (c *Cluster) SetFloat64(key string, value float64, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.SetFloat64(key, value, exp) })
+}
+
+func (c *Cluster) IncrFloat64(key string, delta float64, exp time.Duration) error {
+	return c.exec(key, func(node store.Cache) error { return node.IncrFloat64(key, delta, exp) })
+}
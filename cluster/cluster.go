@@ -0,0 +1,288 @@
+// Package cluster spreads keys over several caches, embedded or remote,
+// with a consistent-hash ring.
+//
+// Every node is placed on the ring many times (virtual nodes), and a key
+// belongs to the node of the first point at or after its hash. Adding or
+// removing a node therefore only moves the keys of the ring segments it
+// gains or loses, about 1/n of the keys for n nodes.
+//
+// A Cluster implements store.Cache. Calls for a key that lives on an
+// unreachable node fail as the node fails; keys on the other nodes are
+// unaffected.
+package cluster
+
+import (
+	goerrors "errors"
+	"slices"
+	"sync"
+
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/store"
+)
+
+// DefaultVirtualNodes is the number of ring points per node when New is
+// given 0.
+const DefaultVirtualNodes = 160
+
+// migrateBatch is the number of keys copied per MGet/MSet when a membership
+// change moves keys between nodes.
+const migrateBatch = 256
+
+type Cluster struct {
+	mux    sync.RWMutex
+	vnodes int
+	nodes  map[string]store.Cache
+	ring   ring
+}
+
+var _ store.Cache = (*Cluster)(nil)
+
+// New creates a cluster of the given nodes, keyed by name. Names place the
+// nodes on the ring, so clients that share nodes must use the same names and
+// the same vnodes to agree on where each key lives. No keys are moved; use
+// AddNode to bring a node in with migration.
+func New(vnodes int, nodes map[string]store.Cache) (*Cluster, error) {
+	if vnodes < 0 {
+		return nil, errors.ErrInvalidVirtualNodes
+	}
+	if vnodes == 0 {
+		vnodes = DefaultVirtualNodes
+	}
+	c := &Cluster{vnodes: vnodes, nodes: make(map[string]store.Cache, len(nodes))}
+	for name, node := range nodes {
+		if err := checkNode(name, node); err != nil {
+			return nil, err
+		}
+		c.nodes[name] = node
+	}
+	c.ring = newRing(c.unsafeNames(), vnodes)
+	return c, nil
+}
+
+func checkNode(name string, node store.Cache) error {
+	if name == "" {
+		return errors.ErrNodeNameEmpty
+	}
+	if node == nil {
+		return errors.ErrValueNil
+	}
+	return nil
+}
+
+// Nodes returns the names of the nodes, sorted.
+func (c *Cluster) Nodes() []string {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.unsafeNames()
+}
+
+func (c *Cluster) unsafeNames() []string {
+	names := make([]string, 0, len(c.nodes))
+	for name := range c.nodes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// NodeFor returns the name of the node that owns key, or "" when the
+// cluster has no nodes.
+func (c *Cluster) NodeFor(key string) string {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return c.ring.owner(key)
+}
+
+// AddNode adds a node and moves to it the keys it now owns from the other
+// nodes. Other calls on c wait until the move is done.
+//
+// Moved keys keep their type, value and remaining TTL, but get new versions
+// on the new node. Keys that fail to move stay where they are, unreachable
+// through c, and are reported in the returned error; the node is added
+// either way. The keys of a node that cannot be listed are not moved, and
+// the failure is reported too.
+func (c *Cluster) AddNode(name string, node store.Cache) error {
+	if err := checkNode(name, node); err != nil {
+		return err
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if _, ok := c.nodes[name]; ok {
+		return errors.ErrNodeExists
+	}
+	c.nodes[name] = node
+	c.ring = newRing(c.unsafeNames(), c.vnodes)
+
+	var errs []error
+	for from, cache := range c.nodes {
+		if from != name {
+			errs = append(errs, c.unsafeMigrate(from, cache))
+		}
+	}
+	return goerrors.Join(errs...)
+}
+
+// RemoveNode removes a node and moves its keys to their new owners. Other
+// calls on c wait until the move is done. The node is not closed.
+//
+// As with AddNode, keys that fail to move are reported in the returned
+// error and the node is removed either way.
+func (c *Cluster) RemoveNode(name string) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	node, ok := c.nodes[name]
+	if !ok {
+		return errors.ErrNodeNotFound
+	}
+	delete(c.nodes, name)
+	c.ring = newRing(c.unsafeNames(), c.vnodes)
+	if len(c.nodes) == 0 {
+		return nil
+	}
+	return c.unsafeMigrate(name, node)
+}
+
+// unsafeMigrate moves every key of from that the ring assigns to another
+// node. The caller must hold the write lock.
+func (c *Cluster) unsafeMigrate(name string, from store.Cache) error {
+	keys, err := listKeys(from)
+	if err != nil {
+		return errors.ErrMigrateNode(name, err)
+	}
+	moves := make(map[string][]string)
+	for _, key := range keys {
+		if owner := c.ring.owner(key); owner != name {
+			moves[owner] = append(moves[owner], key)
+		}
+	}
+
+	var errs []error
+	for owner, keys := range moves {
+		to := c.nodes[owner]
+		for len(keys) > 0 {
+			n := min(len(keys), migrateBatch)
+			errs = append(errs, migrate(from, to, keys[:n])...)
+			keys = keys[n:]
+		}
+	}
+	return goerrors.Join(errs...)
+}
+
+// keyLister is implemented by nodes whose Keys can fail, such as
+// *client.Client and *Cluster; store.Cache.Keys cannot report the error.
+type keyLister interface {
+	ListKeys() ([]string, error)
+}
+
+func listKeys(node store.Cache) ([]string, error) {
+	if l, ok := node.(keyLister); ok {
+		return l.ListKeys()
+	}
+	return node.Keys(), nil
+}
+
+// migrate copies keys from one node to another, with the time to live
+// returned by MGet, and deletes the copied keys from the source. Keys that
+// vanish in between are skipped.
+func migrate(from, to store.Cache, keys []string) []error {
+	var errs []error
+	items := make([]store.BatchItem, 0, len(keys))
+	for _, r := range from.MGet(keys...) {
+		if r.Error != nil {
+			if !goerrors.Is(r.Error, errors.ErrNotFound) {
+				errs = append(errs, errors.ErrMigrateKey(r.Key, r.Error))
+			}
+			continue
+		}
+		ttl := r.TTL
+		switch {
+		case ttl == store.TTLNoExpiry:
+			ttl = 0
+		case ttl <= 0:
+			continue
+		}
+		items = append(items, store.NewItem(r.Key, r.Type, r.Value, ttl))
+	}
+
+	moved := make([]string, 0, len(items))
+	for i, err := range to.MSet(items...) {
+		if err != nil {
+			errs = append(errs, errors.ErrMigrateKey(items[i].Key, err))
+			continue
+		}
+		moved = append(moved, items[i].Key)
+	}
+	for i, err := range from.MDelete(moved...) {
+		if err != nil && !goerrors.Is(err, errors.ErrNotFound) {
+			errs = append(errs, errors.ErrMigrateKey(moved[i], err))
+		}
+	}
+	return errs
+}
+
+// route calls fn with the node that owns key.
+func route[T any](c *Cluster, key string, fn func(store.Cache) (T, error)) (T, error) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	node, ok := c.nodes[c.ring.owner(key)]
+	if !ok {
+		var zero T
+		return zero, errors.ErrNoNodes
+	}
+	return fn(node)
+}
+
+// exec is route for calls that only return an error.
+func (c *Cluster) exec(key string, fn func(store.Cache) error) error {
+	_, err := route(c, key, func(node store.Cache) (struct{}, error) {
+		return struct{}{}, fn(node)
+	})
+	return err
+}
+
+// group splits the indexes of keys by the node that owns them.
+func (c *Cluster) group(keys []string) map[string][]int {
+	groups := make(map[string][]int)
+	for i, key := range keys {
+		owner := c.ring.owner(key)
+		groups[owner] = append(groups[owner], i)
+	}
+	return groups
+}
+
+// fanOut calls fn for every group concurrently and waits for all of them.
+func fanOut(groups map[string][]int, fn func(owner string, idx []int)) {
+	var wg sync.WaitGroup
+	for owner, idx := range groups {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(owner, idx)
+		}()
+	}
+	wg.Wait()
+}
+
+// eachNode calls fn for every node concurrently and waits for all of them.
+func (c *Cluster) eachNode(fn func(node store.Cache)) {
+	var wg sync.WaitGroup
+	for _, node := range c.nodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(node)
+		}()
+	}
+	wg.Wait()
+}
+
+// Close closes every node.
+func (c *Cluster) Close() error {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	var errs []error
+	for _, name := range c.unsafeNames() {
+		errs = append(errs, c.nodes[name].Close())
+	}
+	return goerrors.Join(errs...)
+}
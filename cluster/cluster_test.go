@@ -0,0 +1,310 @@
+package cluster
+
+import (
+	goerrors "errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/client"
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/server"
+	"github.com/found-cake/CacheStore/store"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func newStore(t *testing.T) *store.CacheStore {
+	t.Helper()
+	s, err := store.NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func newCluster(t *testing.T, names ...string) (*Cluster, map[string]*store.CacheStore) {
+	t.Helper()
+	stores := make(map[string]*store.CacheStore, len(names))
+	nodes := make(map[string]store.Cache, len(names))
+	for _, name := range names {
+		stores[name] = newStore(t)
+		nodes[name] = stores[name]
+	}
+	c, err := New(0, nodes)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return c, stores
+}
+
+func testKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key:%d", i)
+	}
+	return keys
+}
+
+// checkPlacement verifies that every key lives on its owner only.
+func checkPlacement(t *testing.T, c *Cluster, stores map[string]*store.CacheStore, keys []string) {
+	t.Helper()
+	for _, key := range keys {
+		owner := c.NodeFor(key)
+		for name, s := range stores {
+			if has := s.Exists(key) == 1; has != (name == owner) {
+				t.Fatalf("key %s on node %s = %v, owner is %s", key, name, has, owner)
+			}
+		}
+	}
+}
+
+func TestCluster_RoutesAndSpreads(t *testing.T) {
+	c, stores := newCluster(t, "a", "b", "c")
+	keys := testKeys(300)
+	for _, key := range keys {
+		if err := c.SetString(key, "v:"+key, 0); err != nil {
+			t.Fatalf("SetString() error = %v", err)
+		}
+	}
+	checkPlacement(t, c, stores, keys)
+	for name, s := range stores {
+		if n := len(s.Keys()); n < 50 {
+			t.Errorf("node %s holds %d of 300 keys, want an even spread", name, n)
+		}
+	}
+
+	for _, key := range keys {
+		if v, err := c.GetString(key); err != nil || v != "v:"+key {
+			t.Fatalf("GetString(%s) = %q, %v", key, v, err)
+		}
+	}
+	if n := len(c.Keys()); n != len(keys) {
+		t.Errorf("Keys() returned %d keys, want %d", n, len(keys))
+	}
+	if n := c.Exists(append(keys, "missing")...); n != len(keys) {
+		t.Errorf("Exists() = %d, want %d", n, len(keys))
+	}
+	if err := c.IncrInt64("counter", 5, time.Hour); err != nil {
+		t.Fatalf("IncrInt64() error = %v", err)
+	}
+	if ttl := c.TTL("counter"); ttl <= 0 {
+		t.Errorf("TTL() = %v, want a positive TTL", ttl)
+	}
+	c.Flush()
+	if n := len(c.Keys()); n != 0 {
+		t.Errorf("Keys() after Flush() returned %d keys", n)
+	}
+}
+
+func TestCluster_SameRingWhateverTheOrder(t *testing.T) {
+	a := newRing([]string{"a", "b", "c"}, DefaultVirtualNodes)
+	b := newRing([]string{"c", "a", "b"}, DefaultVirtualNodes)
+	for _, key := range testKeys(100) {
+		if a.owner(key) != b.owner(key) {
+			t.Fatalf("owner(%s) depends on the order of the nodes", key)
+		}
+	}
+}
+
+func TestCluster_Batch(t *testing.T) {
+	c, stores := newCluster(t, "a", "b", "c")
+	keys := testKeys(100)
+	items := make([]store.BatchItem, len(keys))
+	for i, key := range keys {
+		items[i] = store.NewItem(key, types.STRING, []byte(key), 0)
+	}
+	for i, err := range c.MSet(items...) {
+		if err != nil {
+			t.Fatalf("MSet() error for %s = %v", keys[i], err)
+		}
+	}
+	checkPlacement(t, c, stores, keys)
+
+	query := append([]string{"missing"}, keys...)
+	results := c.MGet(query...)
+	if len(results) != len(query) {
+		t.Fatalf("MGet() returned %d results, want %d", len(results), len(query))
+	}
+	if !goerrors.Is(results[0].Error, errors.ErrNotFound) {
+		t.Errorf("MGet() error for a missing key = %v, want ErrNotFound", results[0].Error)
+	}
+	for i, r := range results[1:] {
+		if r.Key != keys[i] || r.Error != nil || string(r.Value) != keys[i] {
+			t.Fatalf("MGet() result %d = %+v, want %s", i+1, r, keys[i])
+		}
+	}
+
+	for i, err := range c.MDelete(keys[:50]...) {
+		if err != nil {
+			t.Fatalf("MDelete() error for %s = %v", keys[i], err)
+		}
+	}
+	if n := c.Exists(keys...); n != 50 {
+		t.Errorf("Exists() after MDelete() = %d, want 50", n)
+	}
+}
+
+func TestCluster_AddAndRemoveNode(t *testing.T) {
+	c, stores := newCluster(t, "a", "b", "c")
+	keys := testKeys(400)
+	for _, key := range keys {
+		c.SetString(key, key, time.Hour)
+	}
+	c.SetString("persistent", "p", 0)
+	keys = append(keys, "persistent")
+
+	stores["d"] = newStore(t)
+	if err := c.AddNode("d", stores["d"]); err != nil {
+		t.Fatalf("AddNode() error = %v", err)
+	}
+	checkPlacement(t, c, stores, keys)
+	if moved := len(stores["d"].Keys()); moved < len(keys)/10 || moved > len(keys)/2 {
+		t.Errorf("AddNode() moved %d of %d keys, want about a quarter", moved, len(keys))
+	}
+	for _, key := range keys {
+		if _, err := c.GetString(key); err != nil {
+			t.Fatalf("GetString(%s) after AddNode() error = %v", key, err)
+		}
+	}
+	if ttl := c.TTL(keys[0]); ttl <= 0 || ttl > time.Hour {
+		t.Errorf("TTL() after migration = %v, want the remaining TTL", ttl)
+	}
+	if ttl := c.TTL("persistent"); ttl != store.TTLNoExpiry {
+		t.Errorf("TTL() of a key without expiry = %v, want TTLNoExpiry", ttl)
+	}
+
+	if err := c.RemoveNode("b"); err != nil {
+		t.Fatalf("RemoveNode() error = %v", err)
+	}
+	removed := stores["b"]
+	delete(stores, "b")
+	if n := len(removed.Keys()); n != 0 {
+		t.Errorf("removed node still holds %d keys", n)
+	}
+	checkPlacement(t, c, stores, keys)
+	if n := c.Exists(keys...); n != len(keys) {
+		t.Errorf("Exists() after RemoveNode() = %d, want %d", n, len(keys))
+	}
+	if got := c.Nodes(); fmt.Sprint(got) != "[a c d]" {
+		t.Errorf("Nodes() = %v, want [a c d]", got)
+	}
+}
+
+func TestCluster_NodeDown(t *testing.T) {
+	remote := newStore(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	srv := server.New(remote)
+	done := make(chan struct{})
+	go func() {
+		srv.Serve(l)
+		close(done)
+	}()
+	cl, err := client.New(client.Config{Addr: l.Addr().String(), Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("client.New() error = %v", err)
+	}
+
+	c, err := New(0, map[string]store.Cache{"a": newStore(t), "b": newStore(t), "remote": cl})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer c.Close()
+	keys := testKeys(100)
+	for _, key := range keys {
+		if err := c.SetString(key, key, 0); err != nil {
+			t.Fatalf("SetString() error = %v", err)
+		}
+	}
+
+	srv.Close()
+	<-done
+
+	down := 0
+	for i, r := range c.MGet(keys...) {
+		onRemote := c.NodeFor(keys[i]) == "remote"
+		if onRemote {
+			down++
+		}
+		if (r.Error != nil) != onRemote {
+			t.Errorf("MGet(%s) error = %v, key on the down node: %v", keys[i], r.Error, onRemote)
+		}
+		if r.Key != keys[i] {
+			t.Errorf("MGet() result %d is for %s, want %s", i, r.Key, keys[i])
+		}
+	}
+	if down == 0 {
+		t.Fatal("no key lives on the down node")
+	}
+	for i, err := range c.MDelete(keys...) {
+		if (err != nil) != (c.NodeFor(keys[i]) == "remote") {
+			t.Errorf("MDelete(%s) error = %v", keys[i], err)
+		}
+	}
+	if n := len(c.Keys()); n != 0 {
+		t.Errorf("Keys() with a node down = %d keys, want the reachable ones", n)
+	}
+	if _, err := c.ListKeys(); err == nil {
+		t.Error("ListKeys() with a node down expected error")
+	}
+
+	// Moving keys off a node whose keys cannot be listed is a failed migration.
+	if err := c.AddNode("c", newStore(t)); err == nil {
+		t.Error("AddNode() with a node down expected error")
+	}
+	if err := c.RemoveNode("remote"); err == nil {
+		t.Error("RemoveNode() of a down node expected error")
+	}
+}
+
+func TestCluster_Errors(t *testing.T) {
+	if _, err := New(-1, nil); err != errors.ErrInvalidVirtualNodes {
+		t.Errorf("New(-1) error = %v, want ErrInvalidVirtualNodes", err)
+	}
+	if _, err := New(0, map[string]store.Cache{"": newStore(t)}); err != errors.ErrNodeNameEmpty {
+		t.Errorf("New() with an empty name error = %v, want ErrNodeNameEmpty", err)
+	}
+
+	c, err := New(0, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := c.SetString("k", "v", 0); err != errors.ErrNoNodes {
+		t.Errorf("SetString() on an empty cluster error = %v, want ErrNoNodes", err)
+	}
+	if _, _, err := c.Get("k"); err != errors.ErrNoNodes {
+		t.Errorf("Get() on an empty cluster error = %v, want ErrNoNodes", err)
+	}
+	if errs := c.MSet(store.NewItem("k", types.STRING, []byte("v"), 0)); errs[0] != errors.ErrNoNodes {
+		t.Errorf("MSet() on an empty cluster error = %v, want ErrNoNodes", errs[0])
+	}
+	if ttl := c.TTL("k"); ttl != store.TTLExpired {
+		t.Errorf("TTL() on an empty cluster = %v, want TTLExpired", ttl)
+	}
+
+	s := newStore(t)
+	if err := c.AddNode("a", s); err != nil {
+		t.Fatalf("AddNode() error = %v", err)
+	}
+	if err := c.AddNode("a", newStore(t)); err != errors.ErrNodeExists {
+		t.Errorf("AddNode() twice error = %v, want ErrNodeExists", err)
+	}
+	if err := c.AddNode("b", nil); err != errors.ErrValueNil {
+		t.Errorf("AddNode(nil) error = %v, want ErrValueNil", err)
+	}
+	if err := c.RemoveNode("x"); err != errors.ErrNodeNotFound {
+		t.Errorf("RemoveNode() of an unknown node error = %v, want ErrNodeNotFound", err)
+	}
+	c.SetString("k", "v", 0)
+	if err := c.RemoveNode("a"); err != nil {
+		t.Errorf("RemoveNode() of the last node error = %v", err)
+	}
+	if s.Exists("k") != 1 {
+		t.Error("RemoveNode() of the last node dropped its keys")
+	}
+}
@@ -0,0 +1,64 @@
+package cluster
+
+import (
+	"cmp"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// point is one virtual node on the ring.
+type point struct {
+	hash uint64
+	node string
+}
+
+// ring maps keys to node names. It is immutable; membership changes build a
+// new ring.
+type ring []point
+
+func newRing(nodes []string, vnodes int) ring {
+	r := make(ring, 0, len(nodes)*vnodes)
+	for _, node := range nodes {
+		for i := 0; i < vnodes; i++ {
+			r = append(r, point{hash: hashKey(node + "#" + strconv.Itoa(i)), node: node})
+		}
+	}
+	// Ties are broken by name so that every client agrees on the owner
+	// whatever order the nodes were added in.
+	slices.SortFunc(r, func(a, b point) int {
+		return cmp.Or(cmp.Compare(a.hash, b.hash), strings.Compare(a.node, b.node))
+	})
+	return r
+}
+
+// owner returns the node of the first point at or after the hash of key,
+// wrapping around the ring. It returns "" for an empty ring.
+func (r ring) owner(key string) string {
+	if len(r) == 0 {
+		return ""
+	}
+	h := hashKey(key)
+	i, _ := slices.BinarySearchFunc(r, h, func(p point, h uint64) int {
+		return cmp.Compare(p.hash, h)
+	})
+	if i == len(r) {
+		i = 0
+	}
+	return r[i].node
+}
+
+// hashKey is 64-bit FNV-1a followed by the murmur3 finalizer, which spreads
+// the similar names of virtual nodes evenly over the ring.
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
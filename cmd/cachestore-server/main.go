@@ -0,0 +1,96 @@
+// Command cachestore-server runs a CacheStore as a standalone process that
+// speaks the Redis protocol.
+//
+// Usage:
+//
+//	cachestore-server [-addr :6379] [-unix path] [-db cache.db] [-aof file]
+//	                  [-repl-addr :6380] [-follow primary:6380]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/server"
+	"github.com/found-cake/CacheStore/store"
+)
+
+func main() {
+	cfg := config.DefaultConfig()
+	addr := flag.String("addr", ":6379", "TCP address to listen on, empty to disable")
+	unix := flag.String("unix", "", "unix socket path to listen on")
+	flag.StringVar(&cfg.DBFileName, "db", cfg.DBFileName, "SQLite database file, empty to disable persistence")
+	flag.StringVar(&cfg.AOFFileName, "aof", cfg.AOFFileName, "append-only log file")
+	flag.IntVar(&cfg.Shards, "shards", cfg.Shards, "number of shards")
+	flag.IntVar(&cfg.MaxEntries, "max-entries", cfg.MaxEntries, "maximum number of entries, 0 for no limit")
+	flag.Int64Var(&cfg.MaxBytes, "max-bytes", cfg.MaxBytes, "maximum size of the entries in bytes, 0 for no limit")
+	replAddr := flag.String("repl-addr", "", "TCP address to accept followers on, empty to disable")
+	follow := flag.String("follow", "", "replication address of a primary to follow; the server is read-only")
+	flag.Parse()
+
+	if *addr == "" && *unix == "" {
+		fmt.Fprintln(os.Stderr, "cachestore-server: set -addr or -unix")
+		os.Exit(2)
+	}
+	cfg.DBSave = cfg.DBFileName != ""
+
+	cache, err := store.NewCacheStore(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	srv := server.New(cache)
+
+	failed := make(chan error, 3)
+	serve := func(network, address string) {
+		log.Printf("listening on %s %s", network, address)
+		if err := srv.ListenAndServe(network, address); err != errors.ErrServerClosed {
+			failed <- fmt.Errorf("%s %s: %w", network, address, err)
+		}
+	}
+	if *addr != "" {
+		go serve("tcp", *addr)
+	}
+	if *unix != "" {
+		go serve("unix", *unix)
+	}
+	if *replAddr != "" {
+		go func() {
+			l, err := net.Listen("tcp", *replAddr)
+			if err == nil {
+				log.Printf("accepting followers on %s", *replAddr)
+				err = cache.ServeReplicas(l)
+			}
+			if err != nil {
+				failed <- fmt.Errorf("replication %s: %w", *replAddr, err)
+			}
+		}()
+	}
+	if *follow != "" {
+		log.Printf("following %s", *follow)
+		go cache.FollowAddr(context.Background(), "tcp", *follow)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	select {
+	case <-sig:
+	case err := <-failed:
+		log.Println(err)
+	}
+
+	srv.Close()
+	if *unix != "" {
+		os.Remove(*unix)
+	}
+	if err := cache.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
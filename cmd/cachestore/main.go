@@ -0,0 +1,414 @@
+// Command cachestore inspects and edits the SQLite database file written by a
+// CacheStore.
+//
+// Usage:
+//
+//	cachestore [-db cache.db] <command> [arguments]
+//
+// The file is opened directly, so changes made while a CacheStore is running
+// on the same file are overwritten by its next full save.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/persist"
+	"github.com/found-cake/CacheStore/sqlite"
+	"github.com/found-cake/CacheStore/store"
+	"github.com/found-cake/CacheStore/utils"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+const usage = `usage: cachestore [-db file] <command> [arguments]
+
+commands:
+  keys [pattern]                        list live keys matching a glob pattern
+  get [-v] <key>                        print the decoded value of a key
+  set -type <type> [-ttl d] <key> <value>
+                                        store a value parsed as the given type
+  del <key>...                          delete keys
+  ttl <key>                             print the remaining time to live
+  stats                                 print entry counts and sizes
+  export [-format jsonl|csv] [-o file]  write every live entry
+  import [-format jsonl|csv] [file]     read entries written by export
+  vacuum                                reclaim unused space in the file
+  purge-expired                         delete expired entries
+`
+
+type command struct {
+	in     io.Reader
+	out    io.Writer
+	db     *sqlite.SqliteStore
+	dbFile string
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command line args and returns the process exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("cachestore", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	dbFile := flags.String("db", config.DefaultConfig().DBFileName, "SQLite database file")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	name, args := flags.Arg(0), flags.Args()[1:]
+	handlers := map[string]func(*command, []string) error{
+		"keys":          (*command).keys,
+		"get":           (*command).get,
+		"set":           (*command).set,
+		"del":           (*command).del,
+		"ttl":           (*command).ttl,
+		"stats":         (*command).stats,
+		"export":        (*command).export,
+		"import":        (*command).importEntries,
+		"vacuum":        (*command).vacuum,
+		"purge-expired": (*command).purgeExpired,
+	}
+	handler, ok := handlers[name]
+	if !ok {
+		fmt.Fprintf(stderr, "cachestore: unknown command %q\n\n", name)
+		flags.Usage()
+		return 2
+	}
+
+	db, err := sqlite.NewSqliteStore(*dbFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "cachestore: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	cmd := &command{in: stdin, out: stdout, db: db, dbFile: *dbFile}
+	if err := handler(cmd, args); err != nil {
+		if err == flag.ErrHelp {
+			flags.Usage()
+			return 2
+		}
+		fmt.Fprintf(stderr, "cachestore %s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// newFlags returns a flag set for a subcommand that reports errors instead of
+// exiting.
+func newFlags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	return flags
+}
+
+func wantArgs(args []string, min, max int) error {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		return fmt.Errorf("wrong number of arguments; run cachestore -h for usage")
+	}
+	return nil
+}
+
+func parseFormat(name string) (store.ExportFormat, error) {
+	switch strings.ToLower(name) {
+	case "jsonl", "json":
+		return store.ExportJSONL, nil
+	case "csv":
+		return store.ExportCSV, nil
+	default:
+		return 0, errors.ErrExportFormat
+	}
+}
+
+func formatTTL(e entry.Entry, now time.Time) string {
+	if e.Expiry == 0 {
+		return "none"
+	}
+	return time.UnixMilli(e.Expiry).Sub(now).Round(time.Millisecond).String()
+}
+
+// lookup returns the live entry of key.
+func (c *command) lookup(key string) (entry.Entry, error) {
+	e, ok, err := c.db.Get(key)
+	if err != nil {
+		return entry.Entry{}, err
+	}
+	if !ok || e.IsExpired() {
+		return entry.Entry{}, errors.ErrNoDataForKey(key)
+	}
+	return e, nil
+}
+
+func (c *command) keys(args []string) error {
+	if err := wantArgs(args, 0, 1); err != nil {
+		return err
+	}
+	pattern := ""
+	if len(args) == 1 {
+		pattern = args[0]
+	}
+	keys, err := c.db.Keys(pattern)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		fmt.Fprintln(c.out, key)
+	}
+	return nil
+}
+
+func (c *command) get(args []string) error {
+	flags := newFlags("get")
+	verbose := flags.Bool("v", false, "also print type, ttl and version")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := wantArgs(flags.Args(), 1, 1); err != nil {
+		return err
+	}
+	e, err := c.lookup(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	value, err := utils.FormatValue(e.Type, e.Data)
+	if err != nil {
+		return err
+	}
+	if *verbose {
+		fmt.Fprintf(c.out, "type:    %s\nttl:     %s\nversion: %d\nvalue:   ", e.Type, formatTTL(e, time.Now()), e.Version)
+	}
+	fmt.Fprintln(c.out, value)
+	return nil
+}
+
+func (c *command) set(args []string) error {
+	flags := newFlags("set")
+	typeName := flags.String("type", types.STRING.String(), "data type of the value")
+	ttl := flags.Duration("ttl", 0, "time to live, 0 for none")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := wantArgs(flags.Args(), 2, 2); err != nil {
+		return err
+	}
+	dataType, ok := types.ParseDataType(*typeName)
+	if !ok {
+		return errors.ErrUnknownTypeName(*typeName)
+	}
+	data, err := utils.ParseValue(dataType, flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	version, err := c.db.LoadVersion()
+	if err != nil {
+		return err
+	}
+	e := entry.NewEntry(dataType, data, *ttl)
+	e.Version = version + 1
+	if err := c.db.SaveDirtyData(map[string]entry.Entry{flags.Arg(0): e}, nil); err != nil {
+		return err
+	}
+	return c.db.SaveVersion(e.Version)
+}
+
+func (c *command) del(args []string) error {
+	if err := wantArgs(args, 1, -1); err != nil {
+		return err
+	}
+	deleted := 0
+	for _, key := range args {
+		if e, ok, err := c.db.Get(key); err != nil {
+			return err
+		} else if ok && !e.IsExpired() {
+			deleted++
+		}
+	}
+	if err := c.db.SaveDirtyData(nil, args); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, deleted)
+	return nil
+}
+
+func (c *command) ttl(args []string) error {
+	if err := wantArgs(args, 1, 1); err != nil {
+		return err
+	}
+	e, err := c.lookup(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, formatTTL(e, time.Now()))
+	return nil
+}
+
+func (c *command) stats(args []string) error {
+	if err := wantArgs(args, 0, 0); err != nil {
+		return err
+	}
+	stats, err := c.db.Stats()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "keys:    %d\nexpired: %d\nbytes:   %d\nversion: %d\n", stats.Keys, stats.Expired, stats.Bytes, stats.Version)
+	if info, err := os.Stat(c.dbFile); err == nil {
+		fmt.Fprintf(c.out, "file:    %d\n", info.Size())
+	}
+
+	dataTypes := make([]types.DataType, 0, len(stats.ByType))
+	for dataType := range stats.ByType {
+		dataTypes = append(dataTypes, dataType)
+	}
+	sort.Slice(dataTypes, func(i, j int) bool { return dataTypes[i] < dataTypes[j] })
+	for _, dataType := range dataTypes {
+		fmt.Fprintf(c.out, "  %-20s %d\n", dataType.String()+":", stats.ByType[dataType])
+	}
+	return nil
+}
+
+// readOnly loads the database into a store without ever writing it back.
+type readOnly struct {
+	persist.Nop
+	db *sqlite.SqliteStore
+}
+
+func (r readOnly) Load() (map[string]entry.Entry, uint64, error) {
+	return r.db.Load()
+}
+
+func (c *command) export(args []string) error {
+	flags := newFlags("export")
+	formatName := flags.String("format", "jsonl", "jsonl or csv")
+	output := flags.String("o", "", "output file, standard output when empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := wantArgs(flags.Args(), 0, 0); err != nil {
+		return err
+	}
+	format, err := parseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	cache, err := store.NewCacheStore(config.Config{Persister: readOnly{db: c.db}})
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	if *output == "" {
+		return cache.Export(c.out, format)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := cache.Export(f, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (c *command) importEntries(args []string) error {
+	flags := newFlags("import")
+	formatName := flags.String("format", "jsonl", "jsonl or csv")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := wantArgs(flags.Args(), 0, 1); err != nil {
+		return err
+	}
+	format, err := parseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+	in := c.in
+	if flags.NArg() == 1 {
+		f, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	// Records are decoded by an in-memory store first, so a bad record
+	// leaves the database untouched.
+	mem := persist.NewMemory()
+	cache, err := store.NewCacheStore(config.Config{Persister: mem})
+	if err != nil {
+		return err
+	}
+	n, err := cache.Import(in, format)
+	if err != nil {
+		cache.Close()
+		return err
+	}
+	if err := cache.Close(); err != nil {
+		return err
+	}
+	data, _, err := mem.Load()
+	if err != nil {
+		return err
+	}
+
+	version, err := c.db.LoadVersion()
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		e := data[key]
+		version++
+		e.Version = version
+		data[key] = e
+	}
+	if err := c.db.SaveDirtyData(data, nil); err != nil {
+		return err
+	}
+	if err := c.db.SaveVersion(version); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, n)
+	return nil
+}
+
+func (c *command) vacuum(args []string) error {
+	if err := wantArgs(args, 0, 0); err != nil {
+		return err
+	}
+	return c.db.Vacuum()
+}
+
+func (c *command) purgeExpired(args []string) error {
+	if err := wantArgs(args, 0, 0); err != nil {
+		return err
+	}
+	n, err := c.db.PurgeExpired()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, n)
+	return nil
+}
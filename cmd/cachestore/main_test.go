@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/store"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func tempDBFile(t *testing.T) string {
+	return filepath.Join(t.TempDir(), "cache.db")
+}
+
+// cli runs a command against dbFile and returns its exit code and output.
+func cli(t *testing.T, dbFile, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"-db", dbFile}, args...), strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCLI_SetGetDel(t *testing.T) {
+	db := tempDBFile(t)
+
+	if code, _, stderr := cli(t, db, "", "set", "-type", "Integer64", "n", "42"); code != 0 {
+		t.Fatalf("set exit %d: %s", code, stderr)
+	}
+	if code, _, stderr := cli(t, db, "", "set", "-ttl", "1h", "s", "hello"); code != 0 {
+		t.Fatalf("set exit %d: %s", code, stderr)
+	}
+	if _, out, _ := cli(t, db, "", "get", "n"); out != "42\n" {
+		t.Errorf("get n = %q, want 42", out)
+	}
+	_, out, _ := cli(t, db, "", "get", "-v", "s")
+	if !strings.Contains(out, "type:    String") || !strings.HasSuffix(out, "value:   hello\n") {
+		t.Errorf("get -v s = %q", out)
+	}
+	if _, out, _ := cli(t, db, "", "ttl", "s"); !strings.HasPrefix(out, "59m") && !strings.HasPrefix(out, "1h") {
+		t.Errorf("ttl s = %q, want about an hour", out)
+	}
+	if _, out, _ := cli(t, db, "", "ttl", "n"); out != "none\n" {
+		t.Errorf("ttl n = %q, want none", out)
+	}
+	if _, out, _ := cli(t, db, "", "keys"); out != "n\ns\n" {
+		t.Errorf("keys = %q", out)
+	}
+	if _, out, _ := cli(t, db, "", "del", "n", "missing"); out != "1\n" {
+		t.Errorf("del = %q, want 1", out)
+	}
+	if code, _, stderr := cli(t, db, "", "get", "n"); code != 1 || !strings.Contains(stderr, "no data found") {
+		t.Errorf("get deleted key exit %d: %s", code, stderr)
+	}
+}
+
+func TestCLI_ReadsStoreFile(t *testing.T) {
+	db := tempDBFile(t)
+	cache, err := store.NewCacheStore(config.Config{DBSave: true, DBFileName: db})
+	if err != nil {
+		t.Fatalf("NewCacheStore() error = %v", err)
+	}
+	cache.SetBool("flag", true, 0)
+	cache.SetFloat64("pi", 3.5, 0)
+	cache.Set("gone", types.STRING, []byte("x"), 20*time.Millisecond)
+	if err := cache.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	if _, out, _ := cli(t, db, "", "get", "pi"); out != "3.5\n" {
+		t.Errorf("get pi = %q, want 3.5", out)
+	}
+	_, out, _ := cli(t, db, "", "stats")
+	if !strings.Contains(out, "keys:    2\n") || !strings.Contains(out, "Boolean:") {
+		t.Errorf("stats = %q", out)
+	}
+	if _, out, _ := cli(t, db, "", "purge-expired"); out != "0\n" && out != "1\n" {
+		t.Errorf("purge-expired = %q", out)
+	}
+	if code, _, stderr := cli(t, db, "", "vacuum"); code != 0 {
+		t.Errorf("vacuum exit %d: %s", code, stderr)
+	}
+
+	cache, err = store.NewCacheStore(config.Config{DBSave: true, DBFileName: db})
+	if err != nil {
+		t.Fatalf("NewCacheStore() error = %v", err)
+	}
+	defer cache.Close()
+	if v, err := cache.GetBool("flag"); err != nil || !v {
+		t.Errorf("GetBool(flag) = %v, %v after cli commands", v, err)
+	}
+}
+
+func TestCLI_ExportImport(t *testing.T) {
+	src, dst := tempDBFile(t), tempDBFile(t)
+	if code, _, stderr := cli(t, src, "", "set", "-type", "Unsigned Integer32", "a", "7"); code != 0 {
+		t.Fatalf("set exit %d: %s", code, stderr)
+	}
+	cli(t, src, "", "set", "b", "text")
+
+	for _, format := range []string{"jsonl", "csv"} {
+		out := filepath.Join(t.TempDir(), "dump."+format)
+		if code, _, stderr := cli(t, src, "", "export", "-format", format, "-o", out); code != 0 {
+			t.Fatalf("export exit %d: %s", code, stderr)
+		}
+		dump, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+		if code, out, stderr := cli(t, dst, string(dump), "import", "-format", format); code != 0 || out != "2\n" {
+			t.Fatalf("import exit %d, out %q: %s", code, out, stderr)
+		}
+		if _, out, _ := cli(t, dst, "", "get", "a"); out != "7\n" {
+			t.Errorf("%s: get a = %q, want 7", format, out)
+		}
+	}
+
+	if code, _, _ := cli(t, dst, "{\"key\":\"bad\"}\n", "import"); code != 1 {
+		t.Errorf("import of an invalid record exit %d, want 1", code)
+	}
+	if _, out, _ := cli(t, dst, "", "keys", "b*"); out != "b\n" {
+		t.Errorf("keys b* = %q", out)
+	}
+}
+
+func TestCLI_Usage(t *testing.T) {
+	db := tempDBFile(t)
+	if code, _, _ := cli(t, db, ""); code != 2 {
+		t.Errorf("no command exit %d, want 2", code)
+	}
+	if code, _, stderr := cli(t, db, "", "nope"); code != 2 || !strings.Contains(stderr, "unknown command") {
+		t.Errorf("unknown command exit %d: %s", code, stderr)
+	}
+	if code, _, _ := cli(t, db, "", "get"); code != 1 {
+		t.Errorf("get without key exit %d, want 1", code)
+	}
+	if code, _, stderr := cli(t, db, "", "set", "-type", "Nope", "k", "v"); code != 1 || !strings.Contains(stderr, "unknown data type name") {
+		t.Errorf("set with unknown type exit %d: %s", code, stderr)
+	}
+}
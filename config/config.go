@@ -1,25 +1,92 @@
 package config
 
-import "time"
+import (
+	"time"
+
+	"github.com/found-cake/CacheStore/persist"
+)
+
+type EvictionPolicy uint8
+
+const (
+	EvictAllKeysLRU     EvictionPolicy = iota // Evict the least recently used key
+	EvictAllKeysLFU                           // Evict the least frequently used key
+	EvictAllKeysRandom                        // Evict a random key
+	EvictVolatileLRU                          // Evict the least recently used key among keys with an expiry
+	EvictVolatileLFU                          // Evict the least frequently used key among keys with an expiry
+	EvictVolatileRandom                       // Evict a random key among keys with an expiry
+	EvictVolatileTTL                          // Evict the key with the nearest expiry
+	NoEviction                                // Reject writes once the limit is reached
+)
+
+func (p EvictionPolicy) IsVolatile() bool {
+	return p >= EvictVolatileLRU && p <= EvictVolatileTTL
+}
+
+type GCStrategy uint8
+
+const (
+	GCExpiryIndex      GCStrategy = iota // Remove every expired key found in the expiry index
+	GCAdaptiveSampling                   // Redis-like probabilistic sampling of keys with a TTL
+)
+
+type AOFSyncPolicy uint8
+
+const (
+	AOFSyncEverySecond AOFSyncPolicy = iota // fsync the append-only log once per second
+	AOFSyncAlways                           // fsync after every write
+	AOFSyncNever                            // leave flushing to the operating system
+)
 
 type Config struct {
 	GCInterval          time.Duration
+	GCStrategy          GCStrategy
+	GCSampleSize        int
+	GCExpiredThreshold  float64
+	GCCycleBudget       time.Duration
 	DBSave              bool
 	DBFileName          string
 	DBSaveInterval      time.Duration
 	SaveDirtyData       bool
 	DirtyThresholdCount int
 	DirtyThresholdRatio float64
+	MaxEntries          int
+	MaxBytes            int64
+	EvictionPolicy      EvictionPolicy
+	EvictionSamples     int
+	Shards              int
+	AOFFileName         string
+	AOFSync             AOFSyncPolicy
+	// ReplBacklogSize is the number of bytes of recent writes a primary keeps
+	// so that followers can resume after a disconnect without a full sync.
+	// 1 MiB when 0.
+	ReplBacklogSize int
+	// Persister replaces the SQLite database named by DBFileName. Setting it
+	// enables persistence even when DBSave is false; the store closes it on
+	// Close.
+	Persister persist.Persister
 }
 
 func DefaultConfig() Config {
 	return Config{
 		GCInterval:          10 * time.Second,
+		GCStrategy:          GCExpiryIndex,
+		GCSampleSize:        20,
+		GCExpiredThreshold:  0.25,
+		GCCycleBudget:       25 * time.Millisecond,
 		DBSave:              true,
 		DBFileName:          "cache.db",
 		DBSaveInterval:      10 * time.Minute,
 		SaveDirtyData:       true,
 		DirtyThresholdCount: 50,
 		DirtyThresholdRatio: 0.2,
+		MaxEntries:          0,
+		MaxBytes:            0,
+		EvictionPolicy:      EvictAllKeysLRU,
+		EvictionSamples:     5,
+		Shards:              1,
+		AOFFileName:         "",
+		AOFSync:             AOFSyncEverySecond,
+		ReplBacklogSize:     1 << 20,
 	}
 }
@@ -7,9 +7,11 @@ import (
 )
 
 type Entry struct {
-	Type   types.DataType
-	Data   []byte
-	Expiry int64
+	Type       types.DataType
+	Data       []byte
+	Expiry     int64
+	SoftExpiry int64  // after this moment the value is stale but still served
+	Version    uint64 // assigned by the store on every write, increases monotonically
 }
 
 func (e Entry) IsExpired() bool {
@@ -20,6 +22,14 @@ func (e Entry) IsExpiredWithUnixMilli(now int64) bool {
 	return e.Expiry > 0 && e.Expiry <= now
 }
 
+func (e Entry) IsStale() bool {
+	return e.IsStaleWithUnixMilli(time.Now().UnixMilli())
+}
+
+func (e Entry) IsStaleWithUnixMilli(now int64) bool {
+	return e.SoftExpiry > 0 && e.SoftExpiry <= now
+}
+
 func NewEntry(dataType types.DataType, data []byte, exp time.Duration) Entry {
 	var expiry int64
 	if exp > 0 {
@@ -31,3 +41,13 @@ func NewEntry(dataType types.DataType, data []byte, exp time.Duration) Entry {
 		Expiry: expiry,
 	}
 }
+
+// NewSoftEntry creates an entry that is fresh for soft and removed after exp.
+// A soft or exp <= 0 disables the respective deadline.
+func NewSoftEntry(dataType types.DataType, data []byte, soft, exp time.Duration) Entry {
+	e := NewEntry(dataType, data, exp)
+	if soft > 0 {
+		e.SoftExpiry = time.Now().Add(soft).UnixMilli()
+	}
+	return e
+}
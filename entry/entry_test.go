@@ -57,3 +57,24 @@ func TestIsExpiredWithTime(t *testing.T) {
 		t.Error("should be expired after expiry")
 	}
 }
+
+func TestNewSoftEntry(t *testing.T) {
+	entry := NewSoftEntry(types.STRING, []byte("test"), 100*time.Millisecond, time.Hour)
+	if entry.SoftExpiry == 0 || entry.Expiry == 0 {
+		t.Fatal("soft and hard expiry should be set")
+	}
+	if entry.IsStale() {
+		t.Error("entry should be fresh immediately after creation")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if !entry.IsStale() {
+		t.Error("entry should be stale after soft duration")
+	}
+	if entry.IsExpired() {
+		t.Error("stale entry should not be expired before hard expiry")
+	}
+
+	if e := NewSoftEntry(types.STRING, []byte("test"), 0, 0); e.SoftExpiry != 0 || e.IsStale() {
+		t.Error("entry without soft duration should never be stale")
+	}
+}
@@ -18,25 +18,186 @@ var (
 	ErrDirtyThresholdCount = errors.New("DirtyThresholdCount is greater than '0'")
 	ErrDirtyThresholdRatio = errors.New("DirtyThresholdRatio is '0 ~ 1'")
 	ErrFloatSpecial        = errors.New("Invalid Error: result is Nan(Not a Number) or Infinity")
+	ErrMaxEntries          = errors.New("MaxEntries is greater than or equal to '0'")
+	ErrMaxBytes            = errors.New("MaxBytes is greater than or equal to '0'")
+	ErrEvictionSamples     = errors.New("EvictionSamples is greater than or equal to '0'")
+	ErrEvictionPolicy      = errors.New("unknown eviction policy")
+	ErrShards              = errors.New("Shards is greater than or equal to '0'")
+	ErrGCStrategy          = errors.New("unknown gc strategy")
+	ErrGCSampleSize        = errors.New("GCSampleSize is greater than or equal to '0'")
+	ErrGCExpiredThreshold  = errors.New("GCExpiredThreshold is '0 ~ 1'")
+	ErrGCCycleBudget       = errors.New("GCCycleBudget is greater than or equal to '0'")
+	ErrLoaderNil           = errors.New("loader cannot be null")
+	ErrLoadNotFound        = errors.New("loader: no data found in backend")
+	ErrCacheFull           = errors.New("cache is full: no key available for eviction")
+	ErrConflict            = errors.New("version conflict")
+	ErrAOFSyncPolicy       = errors.New("unknown aof sync policy")
+	ErrInvalidJSON         = errors.New("invalid JSON document")
+	ErrExportFormat        = errors.New("unknown export format")
+	ErrSnapshotMagic       = errors.New("snapshot: not a snapshot file")
+	ErrSnapshotTruncated   = errors.New("snapshot: file is truncated")
+	ErrSnapshotCorrupt     = errors.New("snapshot: file is corrupted")
+	ErrSnapshotChecksum    = errors.New("snapshot: checksum mismatch")
+	ErrTxReadOnly          = errors.New("cannot write in a read-only transaction")
+	ErrTxDone              = errors.New("transaction has already been committed or rolled back")
+	ErrServerClosed        = errors.New("server: closed")
+	ErrClientClosed        = errors.New("client: closed")
+	ErrAddrEmpty           = errors.New("address cannot be empty")
+	ErrReplBacklogSize     = errors.New("ReplBacklogSize is greater than or equal to '0'")
+	ErrReadOnly            = errors.New("read-only replica: write to the primary instead")
+	ErrReplBacklogLost     = errors.New("replication: follower fell behind the backlog")
+	ErrNoNodes             = errors.New("cluster: no nodes")
+	ErrInvalidVirtualNodes = errors.New("virtual nodes is greater than or equal to '0'")
+	ErrNodeNameEmpty       = errors.New("node name cannot be empty")
+	ErrNodeExists          = errors.New("cluster: node already exists")
+	ErrNodeNotFound        = errors.New("cluster: node not found")
+	ErrCorruptValue        = errors.New("stored value is corrupted")
+	ErrFieldsEmpty         = errors.New("fields cannot be empty")
+	ErrStoreClosed         = errors.New("store: closed")
+	ErrMembersEmpty        = errors.New("members cannot be empty")
+	ErrCountNegative       = errors.New("count cannot be negative")
+	ErrScoreNaN            = errors.New("score is not a number")
+	ErrBitOffset           = errors.New("bit offset is out of range")
+	ErrBitOperation        = errors.New("unknown bit operation")
+	ErrBitNotKeys          = errors.New("bit NOT takes exactly one key")
+
+	// Kinds of the errors built by the constructors below, for errors.Is.
+	ErrNotFound   = errors.New("no data found for key")
+	ErrWrongType  = errors.New("type mismatch")
+	ErrOutOfRange = errors.New("value out of range")
 )
 
 func ErrInvalidDataLength(expected, actual int) error {
 	return fmt.Errorf("invalid data length: expected %d bytes, got %d bytes", expected, actual)
 }
 
+func ErrEntryTooLarge(key string, size, limit int64) error {
+	return fmt.Errorf("entry for key '%s' is too large: %d bytes exceeds the %d bytes limit", key, size, limit)
+}
+
+// kindError is an error with its own message that still matches its kind
+// with errors.Is.
+type kindError struct {
+	msg  string
+	kind error
+}
+
+func (e *kindError) Error() string { return e.msg }
+
+func (e *kindError) Unwrap() error { return e.kind }
+
+// ErrRemote rebuilds an error received from a server. It matches kind, when
+// not nil, with errors.Is.
+func ErrRemote(msg string, kind error) error {
+	if kind == nil {
+		return errors.New(msg)
+	}
+	return &kindError{msg: msg, kind: kind}
+}
+
+// ErrNoDataForKey wraps ErrNotFound.
 func ErrNoDataForKey(key string) error {
-	return fmt.Errorf("no data found for key: %s", key)
+	return fmt.Errorf("%w: %s", ErrNotFound, key)
+}
+
+// ErrNoDataForField wraps ErrNotFound.
+func ErrNoDataForField(key, field string) error {
+	return fmt.Errorf("%w: %s field %s", ErrNotFound, key, field)
+}
+
+// ErrNoDataForMember wraps ErrNotFound.
+func ErrNoDataForMember(key, member string) error {
+	return fmt.Errorf("%w: %s member %s", ErrNotFound, key, member)
 }
 
+// ErrNoDataForIndex wraps ErrNotFound.
+func ErrNoDataForIndex(key string, index int) error {
+	return fmt.Errorf("%w: %s index %d", ErrNotFound, key, index)
+}
+
+// ErrListIndex wraps ErrOutOfRange.
+func ErrListIndex(key string, index int) error {
+	return &kindError{kind: ErrOutOfRange, msg: fmt.Sprintf("index %d out of range for list '%s'", index, key)}
+}
+
+func ErrHashValueNotNumber(key, field string) error {
+	return fmt.Errorf("value of field '%s' in hash '%s' is not a number", field, key)
+}
+
+// ErrTypeMismatch wraps ErrWrongType.
 func ErrTypeMismatch(key string, expected, actual types.DataType) error {
-	return fmt.Errorf("type mismatch for key '%s': expected %s, got %s",
-		key, expected.String(), actual.String())
+	return fmt.Errorf("%w for key '%s': expected %s, got %s",
+		ErrWrongType, key, expected.String(), actual.String())
+}
+
+func ErrUnknownDataType(t types.DataType) error {
+	return fmt.Errorf("unknown data type %d: user defined data types must be registered first", t)
+}
+
+func ErrTxKeyNotLocked(key string) error {
+	return fmt.Errorf("key '%s' is not one of the keys locked by the transaction", key)
+}
+
+func ErrValueType(t types.DataType, value any) error {
+	return fmt.Errorf("value of type %T cannot be stored as %s", value, t.String())
+}
+
+func ErrLoaderPanic(key string, v any) error {
+	return fmt.Errorf("loader panicked for key '%s': %v", key, v)
+}
+
+// ErrVersionConflict wraps ErrConflict, so callers can test for it with errors.Is.
+// An actual version of 0 means the key does not exist.
+func ErrVersionConflict(key string, expected, actual uint64) error {
+	return fmt.Errorf("%w for key '%s': expected version %d, got %d", ErrConflict, key, expected, actual)
+}
+
+func ErrWatchConflict(key string) error {
+	return fmt.Errorf("%w: watched key '%s' changed", ErrConflict, key)
+}
+
+func ErrUnknownTypeName(name string) error {
+	return fmt.Errorf("unknown data type name '%s'", name)
+}
+
+func ErrImportRecord(record int, err error) error {
+	return fmt.Errorf("import: record %d: %w", record, err)
+}
+
+func ErrBatchItem(index int, err error) error {
+	return fmt.Errorf("item %d: %w", index, err)
+}
+
+func ErrImportHeader(got []string) error {
+	return fmt.Errorf("import: unexpected csv header %q", got)
+}
+
+func ErrMigrateKey(key string, err error) error {
+	return fmt.Errorf("cluster: moving %q: %w", key, err)
+}
+
+func ErrMigrateNode(name string, err error) error {
+	return fmt.Errorf("cluster: listing the keys of node %q: %w", name, err)
+}
+
+func ErrProtocol(msg string) error {
+	return fmt.Errorf("protocol error: %s", msg)
+}
+
+func ErrSnapshotVersion(version uint8) error {
+	return fmt.Errorf("snapshot: unsupported format version %d", version)
+}
+
+func ErrSnapshotCompression(compression uint8) error {
+	return fmt.Errorf("snapshot: unsupported compression %d", compression)
 }
 
+// ErrUnsignedUnderflow wraps ErrOutOfRange.
 func ErrUnsignedUnderflow[T generic.Unsigned](key string, current, delta T) error {
-	return fmt.Errorf("unsigned integer underflow for key '%s': current value %v is less than delta %v", key, current, delta)
+	return &kindError{kind: ErrOutOfRange, msg: fmt.Sprintf("unsigned integer underflow for key '%s': current value %v is less than delta %v", key, current, delta)}
 }
 
+// ErrValueOverflow wraps ErrOutOfRange.
 func ErrValueOverflow[T generic.Numberic](key string, data_type types.DataType, current, delta T) error {
-	return fmt.Errorf("%s overflow for key '%s': %v + %v exceeds representable range", strings.ToLower(data_type.String()), key, current, delta)
+	return &kindError{kind: ErrOutOfRange, msg: fmt.Sprintf("%s overflow for key '%s': %v + %v exceeds representable range", strings.ToLower(data_type.String()), key, current, delta)}
 }
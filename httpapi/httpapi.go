@@ -0,0 +1,439 @@
+// Package httpapi exposes a CacheStore as a JSON REST API.
+//
+// Values travel in their typed JSON form: numbers and booleans as JSON
+// numbers and booleans, JSON documents embedded as they are, and every other
+// type as the string produced by utils.FormatValue.
+package httpapi
+
+import (
+	"encoding/json"
+	goerrors "errors"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/store"
+	"github.com/found-cake/CacheStore/utils"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// TTLHeader carries the time to live of a PUT as an alternative to the ttl
+// query parameter.
+const TTLHeader = "X-Cache-TTL"
+
+// maxBodySize limits request bodies.
+const maxBodySize = 32 << 20
+
+type Handler struct {
+	store *store.CacheStore
+	mux   *http.ServeMux
+}
+
+// New returns a handler serving s:
+//
+//	GET    /keys/{key}   value, type, version and ttl of key
+//	PUT    /keys/{key}   store the request body, ?type= and ?ttl= or X-Cache-TTL
+//	DELETE /keys/{key}   delete key
+//	GET    /keys         sorted live keys, filtered by ?prefix=
+//	GET    /ttl/{key}    remaining time to live of key
+//	POST   /mget         {"keys": [...]}
+//	POST   /mset         {"items": [{"key", "type", "value", "ttl"}]}
+//	POST   /sync         Sync, or FullSync with ?full=true
+//
+// /sync is an admin endpoint; guard it with the middleware of the embedding
+// application.
+func New(s *store.CacheStore) *Handler {
+	h := &Handler{store: s, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /keys/{key...}", h.get)
+	h.mux.HandleFunc("PUT /keys/{key...}", h.put)
+	h.mux.HandleFunc("DELETE /keys/{key...}", h.delete)
+	h.mux.HandleFunc("GET /keys", h.keys)
+	h.mux.HandleFunc("GET /ttl/{key...}", h.ttl)
+	h.mux.HandleFunc("POST /mget", h.mget)
+	h.mux.HandleFunc("POST /mset", h.mset)
+	h.mux.HandleFunc("POST /sync", h.sync)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := h.mux.Handler(r); pattern == "" {
+		// Let the mux choose between 404 and 405, with its Allow header,
+		// but answer with a JSON body like every other error.
+		h.mux.ServeHTTP(&routeError{ResponseWriter: w}, r)
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// routeError replaces the plain text body of the mux's routing errors.
+type routeError struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *routeError) WriteHeader(code int) {
+	if w.written {
+		return
+	}
+	w.written = true
+	name := "not_found"
+	if code == http.StatusMethodNotAllowed {
+		name = "method_not_allowed"
+	}
+	writeJSON(w.ResponseWriter, code, struct {
+		Error *errorBody `json:"error"`
+	}{&errorBody{Code: name, Message: http.StatusText(code)}})
+}
+
+func (w *routeError) Write(b []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	return len(b), nil
+}
+
+// badRequest marks errors caused by a malformed request.
+type badRequest struct{ err error }
+
+func (e badRequest) Error() string { return e.err.Error() }
+
+func (e badRequest) Unwrap() error { return e.err }
+
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// status maps err to an HTTP status and a stable error code.
+func status(err error) (int, string) {
+	var bad badRequest
+	switch {
+	case goerrors.As(err, &bad), err == errors.ErrKeyEmpty, err == errors.ErrValueNil:
+		return http.StatusBadRequest, "bad_request"
+	case goerrors.Is(err, errors.ErrNotFound):
+		return http.StatusNotFound, "not_found"
+	case goerrors.Is(err, errors.ErrWrongType):
+		return http.StatusConflict, "type_mismatch"
+	case goerrors.Is(err, errors.ErrConflict):
+		return http.StatusConflict, "conflict"
+	case goerrors.Is(err, errors.ErrOutOfRange), err == errors.ErrFloatSpecial:
+		return http.StatusUnprocessableEntity, "out_of_range"
+	case err == errors.ErrCacheFull:
+		return http.StatusInsufficientStorage, "cache_full"
+	case err == errors.ErrReadOnly:
+		return http.StatusForbidden, "read_only"
+	default:
+		return http.StatusInternalServerError, "internal"
+	}
+}
+
+func errorOf(err error) *errorBody {
+	_, code := status(err)
+	return &errorBody{Code: code, Message: err.Error()}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	code, _ := status(err)
+	writeJSON(w, code, struct {
+		Error *errorBody `json:"error"`
+	}{errorOf(err)})
+}
+
+// encodeValue returns the JSON form of a stored value.
+func encodeValue(dataType types.DataType, data []byte) (json.RawMessage, error) {
+	if dataType == types.JSON && json.Valid(data) {
+		return data, nil
+	}
+	text, err := utils.FormatValue(dataType, data)
+	if err != nil {
+		return nil, err
+	}
+	switch dataType {
+	case types.BOOLEAN, types.INT16, types.INT32, types.INT64, types.UINT16, types.UINT32, types.UINT64, types.FLOAT32, types.FLOAT64:
+		// NaN and infinities are no JSON numbers and are sent as strings.
+		if json.Valid([]byte(text)) {
+			return json.RawMessage(text), nil
+		}
+	case types.HASH, types.LIST, types.SET, types.ZSET:
+		return json.RawMessage(text), nil
+	}
+	return json.Marshal(text)
+}
+
+// decodeValue is the inverse of encodeValue. Numbers and booleans may also
+// be given as strings.
+func decodeValue(dataType types.DataType, raw json.RawMessage) ([]byte, error) {
+	if len(raw) == 0 {
+		return nil, badRequest{errors.ErrValueNil}
+	}
+	text := string(raw)
+	if dataType != types.JSON && raw[0] == '"' {
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return nil, badRequest{err}
+		}
+	}
+	data, err := utils.ParseValue(dataType, text)
+	if err != nil {
+		return nil, badRequest{err}
+	}
+	return data, nil
+}
+
+func parseType(name string) (types.DataType, error) {
+	if name == "" {
+		return types.STRING, nil
+	}
+	dataType, ok := types.ParseDataType(name)
+	if !ok {
+		return types.UNKNOWN, badRequest{errors.ErrUnknownTypeName(name)}
+	}
+	return dataType, nil
+}
+
+// parseTTL accepts a Go duration ("90s", "1h") or a number of seconds. An
+// empty string means no expiry.
+func parseTTL(text string) (time.Duration, error) {
+	if text == "" {
+		return 0, nil
+	}
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		if n < 0 || n > math.MaxInt64/int64(time.Second) {
+			return 0, badRequest{errors.ErrOutOfRange}
+		}
+		return time.Duration(n) * time.Second, nil
+	}
+	d, err := time.ParseDuration(text)
+	if err != nil {
+		return 0, badRequest{err}
+	}
+	if d < 0 {
+		return 0, badRequest{errors.ErrOutOfRange}
+	}
+	return d, nil
+}
+
+func ttlMillis(ttl time.Duration) int64 {
+	if ttl < 0 {
+		return int64(ttl)
+	}
+	return ttl.Milliseconds()
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return badRequest{err}
+	}
+	return nil
+}
+
+type valueBody struct {
+	Key     string          `json:"key"`
+	Type    string          `json:"type,omitempty"`
+	Value   json.RawMessage `json:"value,omitempty"`
+	Version uint64          `json:"version,omitempty"`
+	TTL     *int64          `json:"ttl_ms,omitempty"`
+	Error   *errorBody      `json:"error,omitempty"`
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	dataType, data, version, err := h.store.GetWithVersion(key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if name := r.URL.Query().Get("type"); name != "" {
+		want, err := parseType(name)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if want != dataType {
+			writeError(w, errors.ErrTypeMismatch(key, want, dataType))
+			return
+		}
+	}
+	value, err := encodeValue(dataType, data)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ttl := ttlMillis(h.store.TTL(key))
+	writeJSON(w, http.StatusOK, valueBody{
+		Key:     key,
+		Type:    dataType.String(),
+		Value:   value,
+		Version: version,
+		TTL:     &ttl,
+	})
+}
+
+// put stores the request body, in the text form of utils.ParseValue, as the
+// type given by ?type= (String by default).
+func (h *Handler) put(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	dataType, err := parseType(r.URL.Query().Get("type"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ttlText := r.URL.Query().Get("ttl")
+	if ttlText == "" {
+		ttlText = r.Header.Get(TTLHeader)
+	}
+	ttl, err := parseTTL(ttlText)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		writeError(w, badRequest{err})
+		return
+	}
+	data, err := utils.ParseValue(dataType, string(body))
+	if err != nil {
+		writeError(w, badRequest{err})
+		return
+	}
+	if err := h.store.Set(key, dataType, data, ttl); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.store.Delete(r.PathValue("key")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) keys(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	keys := []string{}
+	for _, key := range h.store.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	writeJSON(w, http.StatusOK, struct {
+		Keys []string `json:"keys"`
+	}{keys})
+}
+
+// ttl returns the remaining time to live in milliseconds, -1 for keys that
+// do not expire.
+func (h *Handler) ttl(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	ttl := h.store.TTL(key)
+	if ttl == store.TTLExpired {
+		writeError(w, errors.ErrNoDataForKey(key))
+		return
+	}
+	ms := ttlMillis(ttl)
+	writeJSON(w, http.StatusOK, valueBody{Key: key, TTL: &ms})
+}
+
+type resultsBody struct {
+	Results []valueBody `json:"results"`
+}
+
+// mget returns one result per key, in order; missing keys carry an error.
+func (h *Handler) mget(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Keys []string `json:"keys"`
+	}
+	if err := decodeBody(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	results := make([]valueBody, 0, len(req.Keys))
+	for _, result := range h.store.MGet(req.Keys...) {
+		body := valueBody{Key: result.Key}
+		if result.Error == nil {
+			body.Value, result.Error = encodeValue(result.Type, result.Value)
+		}
+		if result.Error != nil {
+			body.Error = errorOf(result.Error)
+		} else {
+			body.Type = result.Type.String()
+			body.Version = result.Version
+		}
+		results = append(results, body)
+	}
+	writeJSON(w, http.StatusOK, resultsBody{results})
+}
+
+// mset validates every item before writing any. The response holds one
+// result per item, in order.
+func (h *Handler) mset(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Items []struct {
+			Key   string          `json:"key"`
+			Type  string          `json:"type"`
+			Value json.RawMessage `json:"value"`
+			TTL   string          `json:"ttl"`
+		} `json:"items"`
+	}
+	if err := decodeBody(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	items := make([]store.BatchItem, len(req.Items))
+	for i, item := range req.Items {
+		dataType, err := parseType(item.Type)
+		if err != nil {
+			writeError(w, badRequest{errors.ErrBatchItem(i, err)})
+			return
+		}
+		data, err := decodeValue(dataType, item.Value)
+		if err != nil {
+			writeError(w, badRequest{errors.ErrBatchItem(i, err)})
+			return
+		}
+		ttl, err := parseTTL(item.TTL)
+		if err != nil {
+			writeError(w, badRequest{errors.ErrBatchItem(i, err)})
+			return
+		}
+		items[i] = store.NewItem(item.Key, dataType, data, ttl)
+	}
+
+	results := make([]valueBody, len(items))
+	for i, err := range h.store.MSet(items...) {
+		results[i].Key = items[i].Key
+		if err != nil {
+			results[i].Error = errorOf(err)
+		}
+	}
+	writeJSON(w, http.StatusOK, resultsBody{results})
+}
+
+// sync starts a background save and answers 202 Accepted.
+func (h *Handler) sync(w http.ResponseWriter, r *http.Request) {
+	full, _ := strconv.ParseBool(r.URL.Query().Get("full"))
+	if full {
+		h.store.FullSync()
+	} else {
+		h.store.Sync()
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
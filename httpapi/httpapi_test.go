@@ -0,0 +1,228 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/persist"
+	"github.com/found-cake/CacheStore/store"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func newTestAPI(t *testing.T, cfg config.Config) (*store.CacheStore, *httptest.Server) {
+	t.Helper()
+	cache, err := store.NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("NewCacheStore() error = %v", err)
+	}
+	srv := httptest.NewServer(New(cache))
+	t.Cleanup(func() {
+		srv.Close()
+		cache.Close()
+	})
+	return cache, srv
+}
+
+// call sends a request and decodes the JSON response, if any, into a map.
+func call(t *testing.T, method, url, body string, header http.Header) (int, map[string]any) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s error = %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	if len(raw) == 0 {
+		return resp.StatusCode, nil
+	}
+	var out map[string]any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("invalid JSON response %q: %v", raw, err)
+	}
+	return resp.StatusCode, out
+}
+
+func errorCode(body map[string]any) string {
+	e, _ := body["error"].(map[string]any)
+	code, _ := e["code"].(string)
+	return code
+}
+
+func TestAPI_PutGetDelete(t *testing.T) {
+	_, srv := newTestAPI(t, config.Config{DBSave: false})
+
+	if code, _ := call(t, "PUT", srv.URL+"/keys/n?type=Integer64&ttl=1h", "42", nil); code != http.StatusNoContent {
+		t.Fatalf("PUT status = %d, want 204", code)
+	}
+	code, body := call(t, "GET", srv.URL+"/keys/n", "", nil)
+	if code != http.StatusOK || body["value"] != float64(42) || body["type"] != "Integer64" {
+		t.Errorf("GET = %d %v", code, body)
+	}
+	if ttl := body["ttl_ms"].(float64); ttl < float64((59 * time.Minute).Milliseconds()) {
+		t.Errorf("ttl_ms = %v, want about an hour", ttl)
+	}
+	if body["version"].(float64) == 0 {
+		t.Error("GET should return the entry version")
+	}
+
+	call(t, "PUT", srv.URL+"/keys/users/1", "alice", http.Header{TTLHeader: {"30"}})
+	if code, body := call(t, "GET", srv.URL+"/keys/users/1", "", nil); code != http.StatusOK || body["value"] != "alice" {
+		t.Errorf("GET key with slash = %d %v", code, body)
+	}
+	if _, body := call(t, "GET", srv.URL+"/ttl/users/1", "", nil); body["ttl_ms"].(float64) > 30000 {
+		t.Errorf("ttl from header = %v, want <= 30s", body["ttl_ms"])
+	}
+
+	call(t, "PUT", srv.URL+"/keys/doc?type=json", `{"a":[1,2]}`, nil)
+	if _, body := call(t, "GET", srv.URL+"/keys/doc", "", nil); body["value"].(map[string]any)["a"] == nil {
+		t.Errorf("JSON value not embedded: %v", body)
+	}
+
+	call(t, "PUT", srv.URL+"/keys/profile?type=hash", `{"name":"alice","age":"30"}`, nil)
+	if _, body := call(t, "GET", srv.URL+"/keys/profile", "", nil); body["value"].(map[string]any)["name"] != "alice" {
+		t.Errorf("hash value not embedded as an object: %v", body)
+	}
+
+	if code, _ := call(t, "DELETE", srv.URL+"/keys/n", "", nil); code != http.StatusNoContent {
+		t.Errorf("DELETE status = %d, want 204", code)
+	}
+	if code, body := call(t, "GET", srv.URL+"/keys/n", "", nil); code != http.StatusNotFound || errorCode(body) != "not_found" {
+		t.Errorf("GET deleted = %d %v, want 404 not_found", code, body)
+	}
+}
+
+func TestAPI_Errors(t *testing.T) {
+	cache, srv := newTestAPI(t, config.Config{DBSave: false})
+	cache.SetString("s", "text", 0)
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		code               string
+	}{
+		{"GET", "/keys/s?type=Integer64", "", http.StatusConflict, "type_mismatch"},
+		{"GET", "/keys/s?type=Nope", "", http.StatusBadRequest, "bad_request"},
+		{"PUT", "/keys/x?type=Integer16", "70000", http.StatusBadRequest, "bad_request"},
+		{"PUT", "/keys/x?type=json", "{", http.StatusBadRequest, "bad_request"},
+		{"PUT", "/keys/x?ttl=soon", "v", http.StatusBadRequest, "bad_request"},
+		{"GET", "/ttl/missing", "", http.StatusNotFound, "not_found"},
+		{"POST", "/mget", "{", http.StatusBadRequest, "bad_request"},
+		{"POST", "/mset", `{"items":[{"key":"a","type":"Boolean","value":3}]}`, http.StatusBadRequest, "bad_request"},
+	}
+	for _, tt := range tests {
+		code, body := call(t, tt.method, srv.URL+tt.path, tt.body, nil)
+		if code != tt.status || errorCode(body) != tt.code {
+			t.Errorf("%s %s = %d %v, want %d %s", tt.method, tt.path, code, body, tt.status, tt.code)
+		}
+	}
+	if cache.Exists("a") != 0 {
+		t.Error("a failed mset must not write any item")
+	}
+}
+
+func TestAPI_ErrorStatus(t *testing.T) {
+	cache, err := store.NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("NewCacheStore() error = %v", err)
+	}
+	defer cache.Close()
+
+	cache.SetString("s", "text", 0)
+	cache.SetUInt16("u", 1, 0)
+	cache.SetInt16("i", 32767, 0)
+	if code, name := status(cache.IncrInt64("s", 1, 0)); code != http.StatusConflict || name != "type_mismatch" {
+		t.Errorf("type mismatch = %d %s", code, name)
+	}
+	if code, name := status(cache.DecrUInt16("u", 2, 0)); code != http.StatusUnprocessableEntity || name != "out_of_range" {
+		t.Errorf("underflow = %d %s", code, name)
+	}
+	if code, name := status(cache.IncrInt16("i", 1, 0)); code != http.StatusUnprocessableEntity || name != "out_of_range" {
+		t.Errorf("overflow = %d %s", code, name)
+	}
+	if code, name := status(errors.ErrReadOnly); code != http.StatusForbidden || name != "read_only" {
+		t.Errorf("read-only = %d %s", code, name)
+	}
+	if _, err := cache.GetString("missing"); err == nil {
+		t.Fatal("GetString(missing) expected error")
+	} else if code, _ := status(err); code != http.StatusNotFound {
+		t.Errorf("not found = %d", code)
+	}
+}
+
+func TestAPI_Batch(t *testing.T) {
+	cache, srv := newTestAPI(t, config.Config{DBSave: false})
+
+	code, body := call(t, "POST", srv.URL+"/mset", `{"items":[
+		{"key":"a","type":"Integer32","value":7},
+		{"key":"b","type":"Boolean","value":"true","ttl":"1m"},
+		{"key":"c","value":"plain"}
+	]}`, nil)
+	if code != http.StatusOK || len(body["results"].([]any)) != 3 {
+		t.Fatalf("mset = %d %v", code, body)
+	}
+	if v, err := cache.GetInt32("a"); err != nil || v != 7 {
+		t.Errorf("GetInt32(a) = %d, %v", v, err)
+	}
+	if ttl := cache.TTL("b"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("TTL(b) = %v, want about a minute", ttl)
+	}
+
+	code, body = call(t, "POST", srv.URL+"/mget", `{"keys":["a","missing","b"]}`, nil)
+	results := body["results"].([]any)
+	if code != http.StatusOK || len(results) != 3 {
+		t.Fatalf("mget = %d %v", code, body)
+	}
+	if r := results[0].(map[string]any); r["value"] != float64(7) || r["type"] != "Integer32" {
+		t.Errorf("mget a = %v", r)
+	}
+	if r := results[1].(map[string]any); errorCode(r) != "not_found" {
+		t.Errorf("mget missing = %v", r)
+	}
+	if r := results[2].(map[string]any); r["value"] != true {
+		t.Errorf("mget b = %v", r)
+	}
+
+	cache.SetString("user:2", "x", 0)
+	cache.SetString("user:1", "x", 0)
+	_, body = call(t, "GET", srv.URL+"/keys?prefix=user:", "", nil)
+	if keys := body["keys"].([]any); len(keys) != 2 || keys[0] != "user:1" {
+		t.Errorf("keys?prefix = %v", keys)
+	}
+}
+
+func TestAPI_Sync(t *testing.T) {
+	mem := persist.NewMemory()
+	cache, srv := newTestAPI(t, config.Config{Persister: mem})
+	cache.Set("k", types.STRING, []byte("v"), 0)
+
+	if code, _ := call(t, "POST", srv.URL+"/sync?full=true", "", nil); code != http.StatusAccepted {
+		t.Fatalf("sync status = %d, want 202", code)
+	}
+	deadline := time.Now().Add(time.Second)
+	for mem.Len() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("FullSync did not save the entry")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if code, body := call(t, "GET", srv.URL+"/sync", "", nil); code != http.StatusMethodNotAllowed || errorCode(body) != "method_not_allowed" {
+		t.Errorf("GET /sync = %d %v, want 405 method_not_allowed", code, body)
+	}
+	if code, body := call(t, "GET", srv.URL+"/nope", "", nil); code != http.StatusNotFound || errorCode(body) != "not_found" {
+		t.Errorf("GET /nope = %d %v, want 404 not_found", code, body)
+	}
+}
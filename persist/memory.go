@@ -0,0 +1,76 @@
+package persist
+
+import (
+	"sync"
+
+	"github.com/found-cake/CacheStore/entry"
+)
+
+// Memory is a Persister that keeps the saved data in memory. Its contents
+// survive Close, so the same Memory can be handed to a new store to simulate
+// a restart in tests.
+type Memory struct {
+	mux     sync.Mutex
+	data    map[string]entry.Entry
+	version uint64
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		data: make(map[string]entry.Entry),
+	}
+}
+
+func clone(e entry.Entry) entry.Entry {
+	data := make([]byte, len(e.Data))
+	copy(data, e.Data)
+	e.Data = data
+	return e
+}
+
+func (m *Memory) Load() (map[string]entry.Entry, uint64, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	result := make(map[string]entry.Entry, len(m.data))
+	for key, e := range m.data {
+		if !e.IsExpired() {
+			result[key] = clone(e)
+		}
+	}
+	return result, m.version, nil
+}
+
+func (m *Memory) SaveFull(data map[string]entry.Entry, version uint64) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.data = make(map[string]entry.Entry, len(data))
+	for key, e := range data {
+		m.data[key] = clone(e)
+	}
+	m.version = max(m.version, version)
+	return nil
+}
+
+func (m *Memory) SaveDelta(set map[string]entry.Entry, deleted []string, version uint64) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for key, e := range set {
+		m.data[key] = clone(e)
+	}
+	for _, key := range deleted {
+		delete(m.data, key)
+	}
+	m.version = max(m.version, version)
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+// Len returns the number of saved entries.
+func (m *Memory) Len() int {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return len(m.data)
+}
@@ -0,0 +1,45 @@
+package persist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func TestMemory_SaveAndLoad(t *testing.T) {
+	m := NewMemory()
+	data := []byte("v1")
+	m.SaveFull(map[string]entry.Entry{
+		"a":       entry.NewEntry(types.STRING, data, 0),
+		"b":       entry.NewEntry(types.STRING, []byte("v2"), 0),
+		"expired": {Type: types.STRING, Data: []byte("x"), Expiry: time.Now().Add(-time.Second).UnixMilli()},
+	}, 3)
+	data[0] = 'X'
+
+	m.SaveDelta(map[string]entry.Entry{"c": entry.NewEntry(types.STRING, []byte("v3"), 0)}, []string{"b"}, 5)
+	m.SaveDelta(nil, nil, 4)
+
+	loaded, version, err := m.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if version != 5 {
+		t.Errorf("version = %d, want 5", version)
+	}
+	if len(loaded) != 2 || string(loaded["a"].Data) != "v1" || string(loaded["c"].Data) != "v3" {
+		t.Errorf("Load() = %v", loaded)
+	}
+}
+
+func TestNop(t *testing.T) {
+	var p Persister = Nop{}
+	if err := p.SaveFull(map[string]entry.Entry{"a": entry.NewEntry(types.STRING, []byte("v"), 0)}, 1); err != nil {
+		t.Fatalf("SaveFull() error = %v", err)
+	}
+	data, version, err := p.Load()
+	if err != nil || len(data) != 0 || version != 0 {
+		t.Errorf("Load() = %v, %d, %v", data, version, err)
+	}
+}
@@ -0,0 +1,32 @@
+// Package persist defines the storage backend a CacheStore saves its data to.
+package persist
+
+import "github.com/found-cake/CacheStore/entry"
+
+// Persister stores the contents of a CacheStore. The store calls SaveFull and
+// SaveDelta from background goroutines, but never two of them at the same time
+// except for a save that is still running while the next one starts, which an
+// implementation may reject with errors.ErrAlreadySave.
+type Persister interface {
+	// Load returns every persisted entry together with the last saved
+	// version counter. Expired entries may be omitted.
+	Load() (map[string]entry.Entry, uint64, error)
+	// SaveFull replaces everything persisted with data.
+	SaveFull(data map[string]entry.Entry, version uint64) error
+	// SaveDelta stores the entries in set and removes the keys in deleted.
+	SaveDelta(set map[string]entry.Entry, deleted []string, version uint64) error
+	// Close releases the backend. The store calls it once, from Close.
+	Close() error
+}
+
+// Nop is a Persister that stores nothing. It is useful to run a store with
+// persistence enabled in code paths that expect it, without touching disk.
+type Nop struct{}
+
+func (Nop) Load() (map[string]entry.Entry, uint64, error) { return nil, 0, nil }
+
+func (Nop) SaveFull(map[string]entry.Entry, uint64) error { return nil }
+
+func (Nop) SaveDelta(map[string]entry.Entry, []string, uint64) error { return nil }
+
+func (Nop) Close() error { return nil }
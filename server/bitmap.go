@@ -0,0 +1,152 @@
+package server
+
+import (
+	"math"
+	"strings"
+
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/store"
+)
+
+// Bitmap commands, working on types.RAW values. Ranges are in bytes.
+
+const (
+	errBitOffset replyError = "ERR bit offset is not an integer or out of range"
+	errBitValue  replyError = "ERR bit is not an integer or out of range"
+)
+
+var bitOperations = map[string]store.BitOperation{
+	"AND": store.BitAnd,
+	"OR":  store.BitOr,
+	"XOR": store.BitXor,
+	"NOT": store.BitNot,
+}
+
+func cmdSetBit(srv *Server, sess *session, args [][]byte) {
+	offset, err := parseBitOffset(args[2])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	value, err := parseBit(args[3])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	old, err := srv.store.SetBit(string(args[1]), offset, value, 0)
+	replyBit(sess.w, old, err)
+}
+
+func cmdGetBit(srv *Server, sess *session, args [][]byte) {
+	offset, err := parseBitOffset(args[2])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	bit, err := srv.store.GetBit(string(args[1]), offset)
+	replyBit(sess.w, bit, err)
+}
+
+// cmdBitCount implements BITCOUNT key [start end].
+func cmdBitCount(srv *Server, sess *session, args [][]byte) {
+	start, end := 0, -1
+	switch len(args) {
+	case 2:
+	case 4:
+		var err error
+		if start, end, err = parseRange(args[2], args[3]); err != nil {
+			replyErr(sess.w, err)
+			return
+		}
+	default:
+		sess.w.error("ERR syntax error")
+		return
+	}
+	n, err := srv.store.BitCount(string(args[1]), start, end)
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.integer(int64(n))
+}
+
+// cmdBitPos implements BITPOS key bit [start [end]]. Without end, the search
+// goes past the value, see store.CacheStore.BitPos.
+func cmdBitPos(srv *Server, sess *session, args [][]byte) {
+	if len(args) > 5 {
+		sess.w.error("ERR syntax error")
+		return
+	}
+	bit, err := parseBit(args[2])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	start, end := 0, math.MaxInt
+	if len(args) > 3 {
+		if start, err = parseIndex(args[3]); err != nil {
+			replyErr(sess.w, err)
+			return
+		}
+	}
+	if len(args) > 4 {
+		if end, err = parseIndex(args[4]); err != nil {
+			replyErr(sess.w, err)
+			return
+		}
+	}
+	pos, err := srv.store.BitPos(string(args[1]), bit, start, end)
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.integer(int64(pos))
+}
+
+// cmdBitOp implements BITOP AND|OR|XOR|NOT destkey key [key ...].
+func cmdBitOp(srv *Server, sess *session, args [][]byte) {
+	op, ok := bitOperations[strings.ToUpper(string(args[1]))]
+	if !ok {
+		sess.w.error("ERR syntax error")
+		return
+	}
+	n, err := srv.store.BitOp(op, string(args[2]), stringArgs(args[3:])...)
+	if err == errors.ErrBitNotKeys {
+		sess.w.error("ERR BITOP NOT must be called with a single source key")
+		return
+	}
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.integer(int64(n))
+}
+
+func replyBit(w *writer, bit bool, err error) {
+	switch {
+	case err != nil:
+		replyErr(w, err)
+	case bit:
+		w.integer(1)
+	default:
+		w.integer(0)
+	}
+}
+
+func parseBitOffset(b []byte) (int, error) {
+	n, err := parseInt(b)
+	if err != nil || n < 0 || n > math.MaxUint32 {
+		return 0, errBitOffset
+	}
+	return int(n), nil
+}
+
+func parseBit(b []byte) (bool, error) {
+	switch string(b) {
+	case "0":
+		return false, nil
+	case "1":
+		return true, nil
+	}
+	return false, errBitValue
+}
@@ -0,0 +1,597 @@
+package server
+
+import (
+	goerrors "errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/store"
+	"github.com/found-cake/CacheStore/utils"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// replyError is an error sent to the client verbatim. It starts with the
+// error code, as Redis clients expect.
+type replyError string
+
+func (e replyError) Error() string { return string(e) }
+
+const (
+	errSyntax     replyError = "ERR syntax error"
+	errNotInteger replyError = "ERR value is not an integer or out of range"
+	errNotFloat   replyError = "ERR value is not a valid float"
+	errOverflow   replyError = "ERR increment or decrement would overflow"
+	errNaN        replyError = "ERR increment would produce NaN or Infinity"
+	errWrongType  replyError = "WRONGTYPE Operation against a key holding the wrong kind of value"
+)
+
+type command struct {
+	// arity counts the command name like Redis does: a positive arity is
+	// the exact number of arguments, a negative one the minimum.
+	arity   int
+	handler func(srv *Server, sess *session, args [][]byte)
+}
+
+var commands = map[string]command{
+	"PING":        {-1, cmdPing},
+	"ECHO":        {2, cmdEcho},
+	"HELLO":       {-1, cmdHello},
+	"QUIT":        {1, cmdQuit},
+	"SELECT":      {2, cmdSelect},
+	"COMMAND":     {-1, cmdCommand},
+	"GET":         {2, cmdGet},
+	"SET":         {-3, cmdSet},
+	"DEL":         {-2, cmdDel},
+	"EXISTS":      {-2, cmdExists},
+	"KEYS":        {2, cmdKeys},
+	"TTL":         {2, cmdTTL},
+	"PTTL":        {2, cmdPTTL},
+	"EXPIRE":      {3, cmdExpire},
+	"PEXPIRE":     {3, cmdPExpire},
+	"INCR":        {2, cmdIncr},
+	"DECR":        {2, cmdDecr},
+	"INCRBY":      {3, cmdIncrBy},
+	"DECRBY":      {3, cmdDecrBy},
+	"INCRBYFLOAT": {3, cmdIncrByFloat},
+	"MGET":        {-2, cmdMGet},
+	"MSET":        {-3, cmdMSet},
+	"FLUSHDB":     {-1, cmdFlush},
+	"FLUSHALL":    {-1, cmdFlush},
+
+	// Hash commands, see hash.go.
+	"HSET":         {-4, cmdHSet},
+	"HGET":         {3, cmdHGet},
+	"HMGET":        {-3, cmdHMGet},
+	"HDEL":         {-3, cmdHDel},
+	"HGETALL":      {2, cmdHGetAll},
+	"HLEN":         {2, cmdHLen},
+	"HEXISTS":      {3, cmdHExists},
+	"HINCRBY":      {4, cmdHIncrBy},
+	"HINCRBYFLOAT": {4, cmdHIncrByFloat},
+
+	// List commands, see list.go.
+	"LPUSH":  {-3, cmdLPush},
+	"RPUSH":  {-3, cmdRPush},
+	"LPOP":   {2, cmdLPop},
+	"RPOP":   {2, cmdRPop},
+	"LRANGE": {4, cmdLRange},
+	"LLEN":   {2, cmdLLen},
+	"LTRIM":  {4, cmdLTrim},
+	"LINDEX": {3, cmdLIndex},
+	"LSET":   {4, cmdLSet},
+	"BLPOP":  {-3, cmdBLPop},
+
+	// Set commands, see set.go.
+	"SADD":        {-3, cmdSAdd},
+	"SREM":        {-3, cmdSRem},
+	"SISMEMBER":   {3, cmdSIsMember},
+	"SMEMBERS":    {2, cmdSMembers},
+	"SCARD":       {2, cmdSCard},
+	"SPOP":        {-2, cmdSPop},
+	"SRANDMEMBER": {-2, cmdSRandMember},
+	"SUNION":      {-2, cmdSUnion},
+	"SINTER":      {-2, cmdSInter},
+	"SDIFF":       {-2, cmdSDiff},
+	"SUNIONSTORE": {-3, cmdSUnionStore},
+	"SINTERSTORE": {-3, cmdSInterStore},
+	"SDIFFSTORE":  {-3, cmdSDiffStore},
+
+	// Sorted set commands, see zset.go.
+	"ZADD":             {-4, cmdZAdd},
+	"ZINCRBY":          {4, cmdZIncrBy},
+	"ZSCORE":           {3, cmdZScore},
+	"ZRANK":            {3, cmdZRank},
+	"ZREVRANK":         {3, cmdZRevRank},
+	"ZCARD":            {2, cmdZCard},
+	"ZRANGE":           {-4, cmdZRange},
+	"ZREVRANGE":        {-4, cmdZRevRange},
+	"ZRANGEBYSCORE":    {-4, cmdZRangeByScore},
+	"ZREM":             {-3, cmdZRem},
+	"ZREMRANGEBYSCORE": {4, cmdZRemRangeByScore},
+
+	// Bitmap commands, see bitmap.go.
+	"SETBIT":   {4, cmdSetBit},
+	"GETBIT":   {3, cmdGetBit},
+	"BITCOUNT": {-2, cmdBitCount},
+	"BITPOS":   {-3, cmdBitPos},
+	"BITOP":    {-4, cmdBitOp},
+
+	// Typed commands used by the client package, see typed.go.
+	"CS.GET":  {2, cmdTypedGet},
+	"CS.SET":  {5, cmdTypedSet},
+	"CS.MGET": {-2, cmdTypedMGet},
+	"CS.MSET": {-5, cmdTypedMSet},
+	"CS.INCR": {5, cmdTypedIncr},
+	"CS.DECR": {5, cmdTypedDecr},
+	"CS.TTL":  {2, cmdTypedTTL},
+}
+
+func (srv *Server) dispatch(sess *session, args [][]byte) {
+	name := strings.ToUpper(string(args[0]))
+	cmd, ok := commands[name]
+	if !ok {
+		sess.w.error("ERR unknown command '" + string(args[0]) + "'")
+		return
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || (cmd.arity < 0 && len(args) < -cmd.arity) {
+		sess.w.error("ERR wrong number of arguments for '" + strings.ToLower(name) + "' command")
+		return
+	}
+	cmd.handler(srv, sess, args)
+}
+
+// replyValue sends a value as a bulk string. Strings and raw bytes are sent
+// as is, other types in the text form of utils.FormatValue.
+func replyValue(w *writer, dataType types.DataType, data []byte) {
+	if dataType == types.STRING || dataType == types.RAW {
+		w.bulk(data)
+		return
+	}
+	text, err := utils.FormatValue(dataType, data)
+	if err != nil {
+		replyErr(w, err)
+		return
+	}
+	w.bulkString(text)
+}
+
+func parseInt(b []byte) (int64, error) {
+	n, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return 0, errNotInteger
+	}
+	return n, nil
+}
+
+func parseFloat(b []byte) (float64, error) {
+	f, err := strconv.ParseFloat(string(b), 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, errNotFloat
+	}
+	return f, nil
+}
+
+// toDuration converts n units to a duration, failing when it does not fit.
+func toDuration(n int64, unit time.Duration) (time.Duration, bool) {
+	if n > math.MaxInt64/int64(unit) || n < math.MinInt64/int64(unit) {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// remainingTTL returns the expiry to pass to Tx.Set so that key keeps its
+// current deadline. A deadline already reached gives 1ms rather than 0, which
+// would make the key persistent.
+func remainingTTL(tx *store.Tx, key string) time.Duration {
+	ttl := tx.TTL(key)
+	if ttl == store.TTLNoExpiry {
+		return 0
+	}
+	return max(ttl, time.Millisecond)
+}
+
+func cmdPing(srv *Server, sess *session, args [][]byte) {
+	switch len(args) {
+	case 1:
+		sess.w.simple("PONG")
+	case 2:
+		sess.w.bulk(args[1])
+	default:
+		sess.w.error("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+func cmdEcho(srv *Server, sess *session, args [][]byte) {
+	sess.w.bulk(args[1])
+}
+
+// cmdHello negotiates the protocol version. Authentication is not supported.
+func cmdHello(srv *Server, sess *session, args [][]byte) {
+	proto := sess.w.proto
+	if len(args) > 1 {
+		n, err := strconv.Atoi(string(args[1]))
+		if err != nil {
+			sess.w.error("ERR Protocol version is not an integer or out of range")
+			return
+		}
+		if n != 2 && n != 3 {
+			sess.w.error("NOPROTO unsupported protocol version")
+			return
+		}
+		proto = n
+		for i := 2; i < len(args); i++ {
+			switch strings.ToUpper(string(args[i])) {
+			case "SETNAME":
+				if i+1 >= len(args) {
+					sess.w.error(string(errSyntax))
+					return
+				}
+				i++
+			case "AUTH":
+				sess.w.error("ERR AUTH is not supported by this server")
+				return
+			default:
+				sess.w.error(string(errSyntax))
+				return
+			}
+		}
+	}
+	sess.w.proto = proto
+
+	sess.w.mapHeader(7)
+	sess.w.bulkString("server")
+	sess.w.bulkString("cachestore")
+	sess.w.bulkString("version")
+	sess.w.bulkString(Version)
+	sess.w.bulkString("proto")
+	sess.w.integer(int64(proto))
+	sess.w.bulkString("id")
+	sess.w.integer(sess.id)
+	sess.w.bulkString("mode")
+	sess.w.bulkString("standalone")
+	sess.w.bulkString("role")
+	sess.w.bulkString("master")
+	sess.w.bulkString("modules")
+	sess.w.array(0)
+}
+
+func cmdQuit(srv *Server, sess *session, args [][]byte) {
+	sess.w.simple("OK")
+	sess.quit = true
+}
+
+// cmdSelect accepts database 0 only; CacheStore has a single keyspace.
+func cmdSelect(srv *Server, sess *session, args [][]byte) {
+	if string(args[1]) != "0" {
+		sess.w.error("ERR DB index is out of range")
+		return
+	}
+	sess.w.simple("OK")
+}
+
+// cmdCommand answers the introspection some clients run on connect with an
+// empty command table.
+func cmdCommand(srv *Server, sess *session, args [][]byte) {
+	sess.w.array(0)
+}
+
+func cmdGet(srv *Server, sess *session, args [][]byte) {
+	dataType, data, err := srv.store.Get(string(args[1]))
+	switch {
+	case goerrors.Is(err, errors.ErrNotFound):
+		sess.w.null()
+	case err != nil:
+		replyErr(sess.w, err)
+	case dataType == types.HASH, dataType == types.LIST, dataType == types.SET, dataType == types.ZSET:
+		sess.w.error(string(errWrongType))
+	default:
+		replyValue(sess.w, dataType, data)
+	}
+}
+
+// cmdSet implements SET key value [EX seconds | PX milliseconds] [NX | XX].
+// Values are stored as types.STRING.
+func cmdSet(srv *Server, sess *session, args [][]byte) {
+	key, value := string(args[1]), args[2]
+	var expiry time.Duration
+	var nx, xx, hasExpiry bool
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); opt {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "EX", "PX":
+			if hasExpiry || i+1 >= len(args) {
+				sess.w.error(string(errSyntax))
+				return
+			}
+			i++
+			n, err := parseInt(args[i])
+			if err != nil {
+				replyErr(sess.w, err)
+				return
+			}
+			unit := time.Second
+			if opt == "PX" {
+				unit = time.Millisecond
+			}
+			d, ok := toDuration(n, unit)
+			if !ok || n <= 0 {
+				sess.w.error("ERR invalid expire time in 'set' command")
+				return
+			}
+			expiry, hasExpiry = d, true
+		default:
+			sess.w.error(string(errSyntax))
+			return
+		}
+	}
+	if nx && xx {
+		sess.w.error(string(errSyntax))
+		return
+	}
+
+	if !nx && !xx {
+		if err := srv.store.Set(key, types.STRING, value, expiry); err != nil {
+			replyErr(sess.w, err)
+			return
+		}
+		sess.w.simple("OK")
+		return
+	}
+
+	written := false
+	err := srv.store.UpdateKeys([]string{key}, func(tx *store.Tx) error {
+		exists := tx.Exists(key) > 0
+		if exists == nx {
+			return nil
+		}
+		written = true
+		return tx.Set(key, types.STRING, value, expiry)
+	})
+	switch {
+	case err != nil:
+		replyErr(sess.w, err)
+	case written:
+		sess.w.simple("OK")
+	default:
+		sess.w.null()
+	}
+}
+
+func cmdDel(srv *Server, sess *session, args [][]byte) {
+	keys := stringArgs(args[1:])
+	deleted := 0
+	err := srv.store.UpdateKeys(keys, func(tx *store.Tx) error {
+		for _, key := range keys {
+			if tx.Exists(key) == 0 {
+				continue
+			}
+			if err := tx.Delete(key); err != nil {
+				return err
+			}
+			deleted++
+		}
+		return nil
+	})
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.integer(int64(deleted))
+}
+
+func cmdExists(srv *Server, sess *session, args [][]byte) {
+	keys := make([]string, len(args)-1)
+	for i, arg := range args[1:] {
+		keys[i] = string(arg)
+	}
+	sess.w.integer(int64(srv.store.Exists(keys...)))
+}
+
+func cmdKeys(srv *Server, sess *session, args [][]byte) {
+	pattern := string(args[1])
+	var keys []string
+	for _, key := range srv.store.Keys() {
+		if matchPattern(pattern, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	sess.w.array(len(keys))
+	for _, key := range keys {
+		sess.w.bulkString(key)
+	}
+}
+
+func replyTTL(srv *Server, sess *session, key string, unit time.Duration) {
+	switch ttl := srv.store.TTL(key); ttl {
+	case store.TTLExpired:
+		sess.w.integer(-2)
+	case store.TTLNoExpiry:
+		sess.w.integer(-1)
+	default:
+		sess.w.integer(int64((ttl + unit/2) / unit))
+	}
+}
+
+func cmdTTL(srv *Server, sess *session, args [][]byte) {
+	replyTTL(srv, sess, string(args[1]), time.Second)
+}
+
+func cmdPTTL(srv *Server, sess *session, args [][]byte) {
+	replyTTL(srv, sess, string(args[1]), time.Millisecond)
+}
+
+// expire sets the time to live of key, deleting it when ttl is not
+// positive. It replies 1 when the key exists and 0 otherwise.
+func expire(srv *Server, sess *session, args [][]byte, unit time.Duration) {
+	key := string(args[1])
+	n, err := parseInt(args[2])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	ttl, ok := toDuration(n, unit)
+	if !ok {
+		sess.w.error("ERR invalid expire time in '" + strings.ToLower(string(args[0])) + "' command")
+		return
+	}
+
+	found := false
+	err = srv.store.UpdateKeys([]string{key}, func(tx *store.Tx) error {
+		dataType, data, err := tx.Get(key)
+		if err != nil {
+			return nil
+		}
+		found = true
+		if ttl <= 0 {
+			return tx.Delete(key)
+		}
+		return tx.Set(key, dataType, data, ttl)
+	})
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	if found {
+		sess.w.integer(1)
+	} else {
+		sess.w.integer(0)
+	}
+}
+
+func cmdExpire(srv *Server, sess *session, args [][]byte) {
+	expire(srv, sess, args, time.Second)
+}
+
+func cmdPExpire(srv *Server, sess *session, args [][]byte) {
+	expire(srv, sess, args, time.Millisecond)
+}
+
+func replyIncr(srv *Server, sess *session, key string, delta int64) {
+	var result int64
+	err := srv.store.UpdateKeys([]string{key}, func(tx *store.Tx) error {
+		var err error
+		result, err = incrBy(tx, key, delta)
+		return err
+	})
+	if goerrors.Is(err, errors.ErrOutOfRange) {
+		err = errOverflow
+	}
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.integer(result)
+}
+
+func cmdIncr(srv *Server, sess *session, args [][]byte) {
+	replyIncr(srv, sess, string(args[1]), 1)
+}
+
+func cmdDecr(srv *Server, sess *session, args [][]byte) {
+	replyIncr(srv, sess, string(args[1]), -1)
+}
+
+func cmdIncrBy(srv *Server, sess *session, args [][]byte) {
+	delta, err := parseInt(args[2])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	replyIncr(srv, sess, string(args[1]), delta)
+}
+
+func cmdDecrBy(srv *Server, sess *session, args [][]byte) {
+	delta, err := parseInt(args[2])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	if delta == math.MinInt64 {
+		sess.w.error("ERR decrement would overflow")
+		return
+	}
+	replyIncr(srv, sess, string(args[1]), -delta)
+}
+
+func cmdIncrByFloat(srv *Server, sess *session, args [][]byte) {
+	delta, err := parseFloat(args[2])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	key := string(args[1])
+	var result string
+	err = srv.store.UpdateKeys([]string{key}, func(tx *store.Tx) error {
+		var err error
+		result, err = incrByFloat(tx, key, delta)
+		return err
+	})
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.bulkString(result)
+}
+
+func cmdMGet(srv *Server, sess *session, args [][]byte) {
+	keys := make([]string, len(args)-1)
+	for i, arg := range args[1:] {
+		keys[i] = string(arg)
+	}
+	results := srv.store.MGet(keys...)
+	sess.w.array(len(results))
+	for _, result := range results {
+		if result.Error != nil {
+			sess.w.null()
+			continue
+		}
+		replyValue(sess.w, result.Type, result.Value)
+	}
+}
+
+func cmdMSet(srv *Server, sess *session, args [][]byte) {
+	if len(args)%2 != 1 {
+		sess.w.error("ERR wrong number of arguments for 'mset' command")
+		return
+	}
+	items := make([]store.BatchItem, 0, len(args)/2)
+	for i := 1; i < len(args); i += 2 {
+		items = append(items, store.NewItem(string(args[i]), types.STRING, args[i+1], 0))
+	}
+	for _, err := range srv.store.MSet(items...) {
+		if err != nil {
+			replyErr(sess.w, err)
+			return
+		}
+	}
+	sess.w.simple("OK")
+}
+
+// cmdFlush accepts the ASYNC and SYNC modifiers of FLUSHDB and FLUSHALL;
+// both flush synchronously.
+func cmdFlush(srv *Server, sess *session, args [][]byte) {
+	if len(args) > 2 {
+		sess.w.error(string(errSyntax))
+		return
+	}
+	if len(args) == 2 {
+		if mode := strings.ToUpper(string(args[1])); mode != "ASYNC" && mode != "SYNC" {
+			sess.w.error(string(errSyntax))
+			return
+		}
+	}
+	if srv.store.ReadOnly() {
+		replyErr(sess.w, errors.ErrReadOnly)
+		return
+	}
+	srv.store.Flush()
+	sess.w.simple("OK")
+}
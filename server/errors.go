@@ -0,0 +1,60 @@
+package server
+
+import (
+	goerrors "errors"
+	"strings"
+
+	"github.com/found-cake/CacheStore/errors"
+)
+
+// errorCodes prefix the error replies for the store's error kinds, so that
+// ParseError can restore them on the client side.
+var errorCodes = []struct {
+	code string
+	kind error
+}{
+	{"NOTFOUND", errors.ErrNotFound},
+	{"WRONGTYPE", errors.ErrWrongType},
+	{"OUTOFRANGE", errors.ErrOutOfRange},
+	{"NAN", errors.ErrFloatSpecial},
+	{"CONFLICT", errors.ErrConflict},
+	{"CACHEFULL", errors.ErrCacheFull},
+	{"KEYEMPTY", errors.ErrKeyEmpty},
+	{"VALUENIL", errors.ErrValueNil},
+	{"READONLY", errors.ErrReadOnly},
+}
+
+// replyErr sends err. Store errors get the code of their kind, or ERR.
+func replyErr(w *writer, err error) {
+	if re, ok := err.(replyError); ok {
+		w.error(string(re))
+		return
+	}
+	for _, c := range errorCodes {
+		if goerrors.Is(err, c.kind) {
+			w.error(c.code + " " + err.Error())
+			return
+		}
+	}
+	w.error("ERR " + err.Error())
+}
+
+// ParseError converts an error reply back into an error. Replies for the
+// store's error kinds match them with errors.Is, and are the sentinel itself
+// when the message is just the sentinel's.
+func ParseError(reply string) error {
+	code, msg, ok := strings.Cut(reply, " ")
+	if !ok {
+		return errors.ErrRemote(reply, nil)
+	}
+	for _, c := range errorCodes {
+		if c.code != code {
+			continue
+		}
+		if msg == c.kind.Error() {
+			return c.kind
+		}
+		return errors.ErrRemote(msg, c.kind)
+	}
+	return errors.ErrRemote(reply, nil)
+}
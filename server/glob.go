@@ -0,0 +1,85 @@
+package server
+
+// matchPattern reports whether key matches the Redis glob pattern: '*' matches
+// any sequence, '?' any single byte, '[...]' a set or range ('^' negates), and
+// '\' escapes the next byte. Unlike path.Match, '*' also matches '/'.
+func matchPattern(pattern, key string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := 0; i <= len(key); i++ {
+				if matchPattern(pattern, key[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(key) == 0 {
+				return false
+			}
+			key = key[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(key) == 0 {
+				return false
+			}
+			matched, rest, ok := matchClass(pattern[1:], key[0])
+			if !ok || !matched {
+				return false
+			}
+			key = key[1:]
+			pattern = rest
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(key) == 0 || key[0] != pattern[0] {
+				return false
+			}
+			key = key[1:]
+			pattern = pattern[1:]
+		}
+	}
+	return len(key) == 0
+}
+
+// matchClass matches c against the class that starts after '[' and returns
+// the pattern after the closing ']'. ok is false for an unterminated class.
+func matchClass(class string, c byte) (matched bool, rest string, ok bool) {
+	negate := false
+	if len(class) > 0 && class[0] == '^' {
+		negate = true
+		class = class[1:]
+	}
+	for i := 0; i < len(class); i++ {
+		switch {
+		case class[i] == ']' && i > 0:
+			return matched != negate, class[i+1:], true
+		case class[i] == '\\' && i+1 < len(class):
+			i++
+			if class[i] == c {
+				matched = true
+			}
+		case i+2 < len(class) && class[i+1] == '-' && class[i+2] != ']':
+			lo, hi := class[i], class[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if lo <= c && c <= hi {
+				matched = true
+			}
+			i += 2
+		case class[i] == c:
+			matched = true
+		}
+	}
+	return false, "", false
+}
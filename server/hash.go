@@ -0,0 +1,141 @@
+package server
+
+import (
+	"slices"
+	"strconv"
+)
+
+// Hash commands, stored as types.HASH. They keep the key's time to live, as
+// in Redis.
+
+func cmdHSet(srv *Server, sess *session, args [][]byte) {
+	if len(args)%2 != 0 {
+		sess.w.error("ERR wrong number of arguments for 'hset' command")
+		return
+	}
+	fields := make(map[string][]byte, len(args)/2-1)
+	for i := 2; i < len(args); i += 2 {
+		fields[string(args[i])] = args[i+1]
+	}
+	added, err := srv.store.HSet(string(args[1]), fields, 0)
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.integer(int64(added))
+}
+
+func cmdHGet(srv *Server, sess *session, args [][]byte) {
+	values, err := srv.store.HMGet(string(args[1]), string(args[2]))
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	if values[0] == nil {
+		sess.w.null()
+		return
+	}
+	sess.w.bulk(values[0])
+}
+
+func cmdHMGet(srv *Server, sess *session, args [][]byte) {
+	fields := make([]string, len(args)-2)
+	for i, arg := range args[2:] {
+		fields[i] = string(arg)
+	}
+	values, err := srv.store.HMGet(string(args[1]), fields...)
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.array(len(values))
+	for _, value := range values {
+		if value == nil {
+			sess.w.null()
+		} else {
+			sess.w.bulk(value)
+		}
+	}
+}
+
+func cmdHDel(srv *Server, sess *session, args [][]byte) {
+	fields := make([]string, len(args)-2)
+	for i, arg := range args[2:] {
+		fields[i] = string(arg)
+	}
+	removed, err := srv.store.HDel(string(args[1]), fields...)
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.integer(int64(removed))
+}
+
+// cmdHGetAll replies with the fields sorted by name.
+func cmdHGetAll(srv *Server, sess *session, args [][]byte) {
+	fields, err := srv.store.HGetAll(string(args[1]))
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	sess.w.mapHeader(len(names))
+	for _, name := range names {
+		sess.w.bulkString(name)
+		sess.w.bulk(fields[name])
+	}
+}
+
+func cmdHLen(srv *Server, sess *session, args [][]byte) {
+	n, err := srv.store.HLen(string(args[1]))
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.integer(int64(n))
+}
+
+func cmdHExists(srv *Server, sess *session, args [][]byte) {
+	found, err := srv.store.HExists(string(args[1]), string(args[2]))
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	if found {
+		sess.w.integer(1)
+	} else {
+		sess.w.integer(0)
+	}
+}
+
+func cmdHIncrBy(srv *Server, sess *session, args [][]byte) {
+	delta, err := parseInt(args[3])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	n, err := srv.store.HIncrBy(string(args[1]), string(args[2]), delta, 0)
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.integer(n)
+}
+
+func cmdHIncrByFloat(srv *Server, sess *session, args [][]byte) {
+	delta, err := parseFloat(args[3])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	f, err := srv.store.HIncrByFloat(string(args[1]), string(args[2]), delta, 0)
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.bulkString(strconv.FormatFloat(f, 'f', -1, 64))
+}
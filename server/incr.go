@@ -0,0 +1,150 @@
+package server
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/found-cake/CacheStore/store"
+	"github.com/found-cake/CacheStore/utils"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// incrBy adds delta to the integer stored at key and returns the result.
+// Missing keys become an INT64, typed integers are incremented in their own
+// type, and strings holding a decimal integer, as written by SET, stay
+// strings.
+func incrBy(tx *store.Tx, key string, delta int64) (int64, error) {
+	if tx.Exists(key) == 0 {
+		return delta, tx.IncrInt64(key, delta, 0)
+	}
+	dataType, data, err := tx.Get(key)
+	if err != nil {
+		return 0, err
+	}
+
+	switch dataType {
+	case types.STRING:
+		n, err := parseInt(data)
+		if err != nil {
+			return 0, err
+		}
+		if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+			return 0, errOverflow
+		}
+		n += delta
+		return n, tx.Set(key, types.STRING, []byte(strconv.FormatInt(n, 10)), remainingTTL(tx, key))
+	case types.INT16:
+		if delta < math.MinInt16 || delta > math.MaxInt16 {
+			return 0, errOverflow
+		}
+		err = tx.IncrInt16(key, int16(delta), 0)
+	case types.INT32:
+		if delta < math.MinInt32 || delta > math.MaxInt32 {
+			return 0, errOverflow
+		}
+		err = tx.IncrInt32(key, int32(delta), 0)
+	case types.INT64:
+		err = tx.IncrInt64(key, delta, 0)
+	case types.UINT16, types.UINT32, types.UINT64:
+		err = incrUnsigned(tx, key, dataType, delta)
+	default:
+		return 0, errWrongType
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// Read the result back in its text form, which is the same for every
+	// integer type.
+	dataType, data, err = tx.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	text, err := utils.FormatValue(dataType, data)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		// An unsigned value above math.MaxInt64 cannot be returned.
+		return 0, errOverflow
+	}
+	return n, nil
+}
+
+func incrUnsigned(tx *store.Tx, key string, dataType types.DataType, delta int64) error {
+	decr := delta < 0
+	abs := uint64(delta)
+	if decr {
+		abs = uint64(-(delta + 1)) + 1
+	}
+
+	switch dataType {
+	case types.UINT16:
+		if abs > math.MaxUint16 {
+			return errOverflow
+		}
+		if decr {
+			return tx.DecrUInt16(key, uint16(abs), 0)
+		}
+		return tx.IncrUInt16(key, uint16(abs), 0)
+	case types.UINT32:
+		if abs > math.MaxUint32 {
+			return errOverflow
+		}
+		if decr {
+			return tx.DecrUInt32(key, uint32(abs), 0)
+		}
+		return tx.IncrUInt32(key, uint32(abs), 0)
+	default:
+		if decr {
+			return tx.DecrUInt64(key, abs, 0)
+		}
+		return tx.IncrUInt64(key, abs, 0)
+	}
+}
+
+// incrByFloat adds delta to the float stored at key and returns the result
+// as text. Missing keys become a FLOAT64; strings holding a number stay
+// strings.
+func incrByFloat(tx *store.Tx, key string, delta float64) (string, error) {
+	if tx.Exists(key) == 0 {
+		if err := tx.IncrFloat64(key, delta, 0); err != nil {
+			return "", err
+		}
+		return strconv.FormatFloat(delta, 'f', -1, 64), nil
+	}
+	dataType, data, err := tx.Get(key)
+	if err != nil {
+		return "", err
+	}
+
+	switch dataType {
+	case types.STRING:
+		f, err := parseFloat(data)
+		if err != nil {
+			return "", err
+		}
+		f += delta
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", errNaN
+		}
+		text := strconv.FormatFloat(f, 'f', -1, 64)
+		return text, tx.Set(key, types.STRING, []byte(text), remainingTTL(tx, key))
+	case types.FLOAT32:
+		err = tx.IncrFloat32(key, float32(delta), 0)
+	case types.FLOAT64:
+		err = tx.IncrFloat64(key, delta, 0)
+	default:
+		return "", errWrongType
+	}
+	if err != nil {
+		return "", err
+	}
+
+	dataType, data, err = tx.Get(key)
+	if err != nil {
+		return "", err
+	}
+	return utils.FormatValue(dataType, data)
+}
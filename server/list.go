@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	goerrors "errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/found-cake/CacheStore/errors"
+)
+
+// List commands, stored as types.LIST. They keep the key's time to live, as
+// in Redis.
+
+func cmdLPush(srv *Server, sess *session, args [][]byte) {
+	n, err := srv.store.LPush(string(args[1]), args[2:], 0)
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.integer(int64(n))
+}
+
+func cmdRPush(srv *Server, sess *session, args [][]byte) {
+	n, err := srv.store.RPush(string(args[1]), args[2:], 0)
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.integer(int64(n))
+}
+
+func cmdLPop(srv *Server, sess *session, args [][]byte) {
+	elem, err := srv.store.LPop(string(args[1]))
+	replyElem(sess.w, elem, err)
+}
+
+func cmdRPop(srv *Server, sess *session, args [][]byte) {
+	elem, err := srv.store.RPop(string(args[1]))
+	replyElem(sess.w, elem, err)
+}
+
+// replyElem sends a list element, or null when there is none.
+func replyElem(w *writer, elem []byte, err error) {
+	switch {
+	case err == nil:
+		w.bulk(elem)
+	case goerrors.Is(err, errors.ErrNotFound):
+		w.null()
+	default:
+		replyErr(w, err)
+	}
+}
+
+func cmdLRange(srv *Server, sess *session, args [][]byte) {
+	start, stop, err := parseRange(args[2], args[3])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	elems, err := srv.store.LRange(string(args[1]), start, stop)
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.array(len(elems))
+	for _, elem := range elems {
+		sess.w.bulk(elem)
+	}
+}
+
+func cmdLLen(srv *Server, sess *session, args [][]byte) {
+	n, err := srv.store.LLen(string(args[1]))
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.integer(int64(n))
+}
+
+func cmdLTrim(srv *Server, sess *session, args [][]byte) {
+	start, stop, err := parseRange(args[2], args[3])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	if err := srv.store.LTrim(string(args[1]), start, stop); err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.simple("OK")
+}
+
+func cmdLIndex(srv *Server, sess *session, args [][]byte) {
+	index, err := parseIndex(args[2])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	elem, err := srv.store.LIndex(string(args[1]), index)
+	replyElem(sess.w, elem, err)
+}
+
+func cmdLSet(srv *Server, sess *session, args [][]byte) {
+	index, err := parseIndex(args[2])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	if err := srv.store.LSet(string(args[1]), index, args[3]); err != nil {
+		if goerrors.Is(err, errors.ErrNotFound) {
+			err = replyError("ERR no such key")
+		}
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.simple("OK")
+}
+
+// cmdBLPop implements BLPOP key [key ...] timeout, with the timeout in
+// seconds and 0 to wait forever. It replies with [key, element], or null
+// when the timeout passes first.
+func cmdBLPop(srv *Server, sess *session, args [][]byte) {
+	seconds, err := strconv.ParseFloat(string(args[len(args)-1]), 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		sess.w.error("ERR timeout is not a float or out of range")
+		return
+	}
+	if seconds < 0 {
+		sess.w.error("ERR timeout is negative")
+		return
+	}
+	keys := make([]string, len(args)-2)
+	for i, arg := range args[1 : len(args)-1] {
+		keys[i] = string(arg)
+	}
+
+	timeout := time.Duration(seconds * float64(time.Second))
+	if seconds >= math.MaxInt64/float64(time.Second) {
+		timeout = 0
+	}
+
+	ctx, stop := srv.blockingContext(sess, timeout)
+	key, elem, err := srv.store.BLPop(ctx, keys...)
+	stop()
+	switch {
+	case err == nil:
+		sess.w.array(2)
+		sess.w.bulkString(key)
+		sess.w.bulk(elem)
+	case err == context.DeadlineExceeded || err == context.Canceled:
+		sess.w.null()
+	default:
+		replyErr(sess.w, err)
+	}
+}
+
+// blockingContext returns the context of a blocking command. It ends after
+// timeout, when positive, when the server closes, and when the client hangs
+// up, so that no element is popped for a client that is gone. stop must be
+// called before the session reads its next command.
+func (srv *Server) blockingContext(sess *session, timeout time.Duration) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(srv.ctx)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(srv.ctx, timeout)
+	}
+	if sess.conn == nil || sess.r.buffered() {
+		// More commands are waiting, so the client is still there.
+		return ctx, cancel
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := sess.r.wait(); err != nil && !isTimeout(err) {
+			cancel()
+		}
+	}()
+	return ctx, func() {
+		cancel()
+		// Interrupt wait, then let the session read normally again.
+		sess.conn.SetReadDeadline(time.Now())
+		<-done
+		sess.conn.SetReadDeadline(time.Time{})
+	}
+}
+
+func isTimeout(err error) bool {
+	var ne interface{ Timeout() bool }
+	return goerrors.As(err, &ne) && ne.Timeout()
+}
+
+func parseIndex(b []byte) (int, error) {
+	n, err := parseInt(b)
+	if err != nil {
+		return 0, err
+	}
+	return int(max(min(n, math.MaxInt), math.MinInt)), nil
+}
+
+func parseRange(start, stop []byte) (int, int, error) {
+	from, err := parseIndex(start)
+	if err != nil {
+		return 0, 0, err
+	}
+	to, err := parseIndex(stop)
+	return from, to, err
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/found-cake/CacheStore/errors"
+)
+
+const (
+	maxBulkLen  = 512 << 20 // same limit as Redis
+	maxArrayLen = 1 << 20
+	maxInline   = 64 << 10
+)
+
+// reader decodes client commands: RESP arrays of bulk strings, or inline
+// commands separated by spaces as typed in a telnet session.
+type reader struct {
+	r *bufio.Reader
+}
+
+func newReader(r io.Reader) *reader {
+	return &reader{r: bufio.NewReader(r)}
+}
+
+// buffered reports whether more input is already waiting, so replies to
+// pipelined commands can be flushed together.
+func (r *reader) buffered() bool {
+	return r.r.Buffered() > 0
+}
+
+// wait blocks until more input arrives, without consuming it, or reading
+// fails.
+func (r *reader) wait() error {
+	_, err := r.r.Peek(1)
+	return err
+}
+
+func (r *reader) readLine() ([]byte, error) {
+	line, err := r.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, errors.ErrProtocol("line too long")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(line) > maxInline {
+		return nil, errors.ErrProtocol("line too long")
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+// readCommand returns the arguments of the next command. Empty inline lines
+// are skipped.
+func (r *reader) readCommand() ([][]byte, error) {
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] != '*' {
+			args := bytes.Fields(line)
+			if len(args) == 0 {
+				continue
+			}
+			return args, nil
+		}
+
+		n, err := parseLength(line[1:], maxArrayLen)
+		if err != nil {
+			return nil, err
+		}
+		if n <= 0 {
+			continue
+		}
+		args := make([][]byte, n)
+		for i := range args {
+			if args[i], err = r.readBulk(); err != nil {
+				return nil, err
+			}
+		}
+		return args, nil
+	}
+}
+
+func (r *reader) readBulk() ([]byte, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '$' {
+		return nil, errors.ErrProtocol(fmt.Sprintf("expected '$', got %q", line))
+	}
+	n, err := parseLength(line[1:], maxBulkLen)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, errors.ErrProtocol("invalid bulk length")
+	}
+	buf := make([]byte, n+2)
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		return nil, err
+	}
+	if buf[n] != '\r' || buf[n+1] != '\n' {
+		return nil, errors.ErrProtocol("bulk string not terminated by CRLF")
+	}
+	return buf[:n], nil
+}
+
+func parseLength(b []byte, limit int) (int, error) {
+	n, err := strconv.Atoi(string(b))
+	if err != nil || n > limit {
+		return 0, errors.ErrProtocol(fmt.Sprintf("invalid length %q", b))
+	}
+	return n, nil
+}
+
+// writer encodes replies for the protocol version negotiated with HELLO.
+// RESP2 and RESP3 only differ here in how nulls and maps are written.
+type writer struct {
+	w     *bufio.Writer
+	proto int
+}
+
+func newWriter(w io.Writer) *writer {
+	return &writer{w: bufio.NewWriter(w), proto: 2}
+}
+
+func (w *writer) flush() error {
+	return w.w.Flush()
+}
+
+func (w *writer) simple(s string) {
+	w.w.WriteByte('+')
+	w.w.WriteString(s)
+	w.w.WriteString("\r\n")
+}
+
+// error writes an error reply. msg starts with an error code such as ERR or
+// WRONGTYPE.
+func (w *writer) error(msg string) {
+	w.w.WriteByte('-')
+	w.w.WriteString(msg)
+	w.w.WriteString("\r\n")
+}
+
+func (w *writer) integer(n int64) {
+	w.w.WriteByte(':')
+	w.w.WriteString(strconv.FormatInt(n, 10))
+	w.w.WriteString("\r\n")
+}
+
+func (w *writer) bulk(b []byte) {
+	w.w.WriteByte('$')
+	w.w.WriteString(strconv.Itoa(len(b)))
+	w.w.WriteString("\r\n")
+	w.w.Write(b)
+	w.w.WriteString("\r\n")
+}
+
+func (w *writer) bulkString(s string) {
+	w.bulk([]byte(s))
+}
+
+func (w *writer) null() {
+	if w.proto >= 3 {
+		w.w.WriteString("_\r\n")
+	} else {
+		w.w.WriteString("$-1\r\n")
+	}
+}
+
+func (w *writer) array(n int) {
+	w.w.WriteByte('*')
+	w.w.WriteString(strconv.Itoa(n))
+	w.w.WriteString("\r\n")
+}
+
+// setHeader starts a set of n members; RESP2 clients get an array.
+func (w *writer) setHeader(n int) {
+	if w.proto >= 3 {
+		w.w.WriteByte('~')
+		w.w.WriteString(strconv.Itoa(n))
+		w.w.WriteString("\r\n")
+	} else {
+		w.array(n)
+	}
+}
+
+// mapHeader starts a map of n pairs; RESP2 clients get a flat array.
+func (w *writer) mapHeader(n int) {
+	if w.proto >= 3 {
+		w.w.WriteByte('%')
+		w.w.WriteString(strconv.Itoa(n))
+		w.w.WriteString("\r\n")
+	} else {
+		w.array(2 * n)
+	}
+}
@@ -0,0 +1,187 @@
+// Package server exposes a CacheStore over the Redis protocol (RESP2 and
+// RESP3), so existing Redis clients can use it as a standalone process.
+package server
+
+import (
+	"context"
+	goerrors "errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/store"
+)
+
+// Version is reported to clients by HELLO.
+const Version = "1.0.0"
+
+type Server struct {
+	store  *store.CacheStore
+	nextID atomic.Int64
+	// ctx ends when the server closes, which ends blocking commands.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mux       sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// New returns a server answering commands from s. The caller keeps ownership
+// of s and closes it after the server.
+func New(s *store.CacheStore) *Server {
+	srv := &Server{
+		store:     s,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+	srv.ctx, srv.cancel = context.WithCancel(context.Background())
+	return srv
+}
+
+// ListenAndServe listens on network ("tcp" or "unix") and serves until the
+// server is closed. A stale unix socket file left by a previous run is
+// removed first.
+func (srv *Server) ListenAndServe(network, addr string) error {
+	if network == "unix" {
+		if info, err := os.Stat(addr); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(addr)
+		}
+	}
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(l)
+}
+
+// Serve accepts connections on l until the server is closed, then returns
+// errors.ErrServerClosed. l is closed on return.
+func (srv *Server) Serve(l net.Listener) error {
+	srv.mux.Lock()
+	if srv.closed {
+		srv.mux.Unlock()
+		l.Close()
+		return errors.ErrServerClosed
+	}
+	srv.listeners[l] = struct{}{}
+	srv.mux.Unlock()
+
+	defer func() {
+		srv.mux.Lock()
+		delete(srv.listeners, l)
+		srv.mux.Unlock()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			srv.mux.Lock()
+			closed := srv.closed
+			srv.mux.Unlock()
+			if closed {
+				return errors.ErrServerClosed
+			}
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		if !srv.track(conn) {
+			conn.Close()
+			return errors.ErrServerClosed
+		}
+		srv.wg.Add(1)
+		go func() {
+			defer srv.wg.Done()
+			defer srv.untrack(conn)
+			srv.serveConn(conn)
+		}()
+	}
+}
+
+func (srv *Server) track(conn net.Conn) bool {
+	srv.mux.Lock()
+	defer srv.mux.Unlock()
+	if srv.closed {
+		return false
+	}
+	srv.conns[conn] = struct{}{}
+	return true
+}
+
+func (srv *Server) untrack(conn net.Conn) {
+	srv.mux.Lock()
+	delete(srv.conns, conn)
+	srv.mux.Unlock()
+	conn.Close()
+}
+
+// Close stops every listener, disconnects the clients and waits for their
+// commands to finish. It does not close the store.
+func (srv *Server) Close() error {
+	srv.mux.Lock()
+	if srv.closed {
+		srv.mux.Unlock()
+		return nil
+	}
+	srv.closed = true
+	srv.cancel()
+	for l := range srv.listeners {
+		l.Close()
+	}
+	for conn := range srv.conns {
+		conn.Close()
+	}
+	srv.mux.Unlock()
+
+	srv.wg.Wait()
+	return nil
+}
+
+// session is the state of one client connection.
+type session struct {
+	id   int64
+	conn net.Conn
+	r    *reader
+	w    *writer
+	quit bool
+}
+
+func (srv *Server) serveConn(conn net.Conn) {
+	sess := &session{
+		id:   srv.nextID.Add(1),
+		conn: conn,
+		r:    newReader(conn),
+		w:    newWriter(conn),
+	}
+	for !sess.quit {
+		args, err := sess.r.readCommand()
+		if err != nil {
+			if err != io.EOF && !isClosedConn(err) {
+				sess.w.error("ERR " + err.Error())
+				sess.w.flush()
+				log.Printf("server: %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		srv.dispatch(sess, args)
+		if !sess.r.buffered() {
+			if err := sess.w.flush(); err != nil {
+				return
+			}
+		}
+	}
+	sess.w.flush()
+}
+
+func isClosedConn(err error) bool {
+	return err == io.ErrUnexpectedEOF || goerrors.Is(err, net.ErrClosed)
+}
@@ -0,0 +1,756 @@
+package server
+
+import (
+	"bufio"
+	goerrors "errors"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/store"
+	"github.com/found-cake/CacheStore/utils"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// client is a minimal RESP client for tests. Replies are decoded into
+// strings, int64, nil, []any or map[string]any; error replies into
+// replyError.
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func newTestServer(t *testing.T) (*store.CacheStore, *Server, string) {
+	t.Helper()
+	cache, err := store.NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("NewCacheStore() error = %v", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	srv := New(cache)
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(l) }()
+	t.Cleanup(func() {
+		srv.Close()
+		if err := <-done; err != errors.ErrServerClosed {
+			t.Errorf("Serve() = %v, want ErrServerClosed", err)
+		}
+		cache.Close()
+	})
+	return cache, srv, l.Addr().String()
+}
+
+func dial(t *testing.T, network, addr string) *client {
+	t.Helper()
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return &client{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *client) send(args ...string) {
+	c.t.Helper()
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.conn.Write([]byte(b.String())); err != nil {
+		c.t.Fatalf("write error: %v", err)
+	}
+}
+
+func (c *client) do(args ...string) any {
+	c.t.Helper()
+	c.send(args...)
+	return c.read()
+}
+
+func (c *client) read() any {
+	c.t.Helper()
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("read error: %v", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	switch line[0] {
+	case '+':
+		return line[1:]
+	case '-':
+		return replyError(line[1:])
+	case ':':
+		n, _ := strconv.ParseInt(line[1:], 10, 64)
+		return n
+	case '_':
+		return nil
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			c.t.Fatalf("read error: %v", err)
+		}
+		return string(buf[:n])
+	case '*', '~':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			items[i] = c.read()
+		}
+		return items
+	case '%':
+		n, _ := strconv.Atoi(line[1:])
+		m := make(map[string]any, n)
+		for i := 0; i < n; i++ {
+			key := c.read().(string)
+			m[key] = c.read()
+		}
+		return m
+	}
+	c.t.Fatalf("unexpected reply %q", line)
+	return nil
+}
+
+func expect(t *testing.T, got, want any) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("reply = %#v, want %#v", got, want)
+	}
+}
+
+func expectError(t *testing.T, got any, prefix string) {
+	t.Helper()
+	err, ok := got.(replyError)
+	if !ok || !strings.HasPrefix(string(err), prefix) {
+		t.Errorf("reply = %#v, want error starting with %q", got, prefix)
+	}
+}
+
+func TestServer_Basics(t *testing.T) {
+	_, _, addr := newTestServer(t)
+	c := dial(t, "tcp", addr)
+
+	expect(t, c.do("PING"), "PONG")
+	expect(t, c.do("ping", "hi"), "hi")
+	expect(t, c.do("SET", "k", "v"), "OK")
+	expect(t, c.do("GET", "k"), "v")
+	expect(t, c.do("GET", "missing"), nil)
+	expect(t, c.do("EXISTS", "k", "k", "missing"), int64(2))
+	expect(t, c.do("DEL", "k", "k", "missing"), int64(1))
+	expect(t, c.do("GET", "k"), nil)
+
+	expectError(t, c.do("NOPE"), "ERR unknown command")
+	expectError(t, c.do("GET"), "ERR wrong number of arguments")
+	expectError(t, c.do("SET", "k", "v", "BOGUS"), "ERR syntax error")
+}
+
+func TestServer_SetOptions(t *testing.T) {
+	_, _, addr := newTestServer(t)
+	c := dial(t, "tcp", addr)
+
+	expect(t, c.do("SET", "k", "1", "XX"), nil)
+	expect(t, c.do("SET", "k", "1", "NX"), "OK")
+	expect(t, c.do("SET", "k", "2", "NX"), nil)
+	expect(t, c.do("SET", "k", "2", "XX", "EX", "100"), "OK")
+	expect(t, c.do("GET", "k"), "2")
+	if ttl := c.do("TTL", "k").(int64); ttl < 99 || ttl > 100 {
+		t.Errorf("TTL = %d, want about 100", ttl)
+	}
+	expect(t, c.do("SET", "p", "v", "PX", "30"), "OK")
+	if pttl := c.do("PTTL", "p").(int64); pttl <= 0 || pttl > 30 {
+		t.Errorf("PTTL = %d, want (0, 30]", pttl)
+	}
+	time.Sleep(50 * time.Millisecond)
+	expect(t, c.do("GET", "p"), nil)
+
+	expectError(t, c.do("SET", "k", "v", "EX", "0"), "ERR invalid expire time")
+	expectError(t, c.do("SET", "k", "v", "EX", "x"), "ERR value is not an integer")
+	expectError(t, c.do("SET", "k", "v", "NX", "XX"), "ERR syntax error")
+}
+
+func TestRemainingTTL(t *testing.T) {
+	cache, _, _ := newTestServer(t)
+	cache.SetString("p", "v", 0)
+	cache.SetString("k", "v", 20*time.Millisecond)
+	cache.Update(func(tx *store.Tx) error {
+		if ttl := remainingTTL(tx, "p"); ttl != 0 {
+			t.Errorf("remainingTTL() of a persistent key = %v, want 0", ttl)
+		}
+		if ttl := remainingTTL(tx, "k"); ttl <= 0 || ttl > 20*time.Millisecond {
+			t.Errorf("remainingTTL() = %v, want (0, 20ms]", ttl)
+		}
+		time.Sleep(30 * time.Millisecond)
+		if ttl := remainingTTL(tx, "k"); ttl != time.Millisecond {
+			t.Errorf("remainingTTL() past the deadline = %v, want 1ms", ttl)
+		}
+		return nil
+	})
+}
+
+func TestServer_TTLAndExpire(t *testing.T) {
+	_, _, addr := newTestServer(t)
+	c := dial(t, "tcp", addr)
+
+	expect(t, c.do("TTL", "missing"), int64(-2))
+	c.do("SET", "k", "v")
+	expect(t, c.do("TTL", "k"), int64(-1))
+	expect(t, c.do("EXPIRE", "k", "60"), int64(1))
+	expect(t, c.do("TTL", "k"), int64(60))
+	expect(t, c.do("GET", "k"), "v")
+	expect(t, c.do("PEXPIRE", "k", "5000"), int64(1))
+	if pttl := c.do("PTTL", "k").(int64); pttl <= 4900 || pttl > 5000 {
+		t.Errorf("PTTL = %d, want about 5000", pttl)
+	}
+	expect(t, c.do("EXPIRE", "missing", "60"), int64(0))
+	expect(t, c.do("EXPIRE", "k", "0"), int64(1))
+	expect(t, c.do("EXISTS", "k"), int64(0))
+}
+
+func TestServer_Incr(t *testing.T) {
+	cache, _, addr := newTestServer(t)
+	c := dial(t, "tcp", addr)
+
+	expect(t, c.do("INCR", "n"), int64(1))
+	expect(t, c.do("INCRBY", "n", "10"), int64(11))
+	expect(t, c.do("DECRBY", "n", "20"), int64(-9))
+	expect(t, c.do("DECR", "n"), int64(-10))
+	if v, err := cache.GetInt64("n"); err != nil || v != -10 {
+		t.Errorf("GetInt64() = %d, %v, want -10", v, err)
+	}
+
+	c.do("SET", "s", "41", "EX", "100")
+	expect(t, c.do("INCR", "s"), int64(42))
+	expect(t, c.do("GET", "s"), "42")
+	if ttl := c.do("TTL", "s").(int64); ttl < 99 {
+		t.Errorf("INCR should keep the ttl, got %d", ttl)
+	}
+
+	cache.SetInt32("i32", 5, 0)
+	expect(t, c.do("INCRBY", "i32", "5"), int64(10))
+	expectError(t, c.do("INCRBY", "i32", "3000000000"), "ERR increment or decrement would overflow")
+	cache.SetUInt16("u16", 5, 0)
+	expect(t, c.do("DECRBY", "u16", "2"), int64(3))
+	expectError(t, c.do("DECRBY", "u16", "4"), "ERR")
+	if v, _ := cache.GetUInt16("u16"); v != 3 {
+		t.Errorf("failed DECRBY changed the value to %d", v)
+	}
+
+	c.do("SET", "text", "abc")
+	expectError(t, c.do("INCR", "text"), "ERR value is not an integer")
+	cache.SetBool("b", true, 0)
+	expectError(t, c.do("INCR", "b"), "WRONGTYPE")
+	c.do("SET", "max", "9223372036854775807")
+	expectError(t, c.do("INCR", "max"), "ERR increment or decrement would overflow")
+	expectError(t, c.do("INCRBY", "n", "1.5"), "ERR value is not an integer")
+}
+
+func TestServer_IncrByFloat(t *testing.T) {
+	cache, _, addr := newTestServer(t)
+	c := dial(t, "tcp", addr)
+
+	expect(t, c.do("INCRBYFLOAT", "f", "1.5"), "1.5")
+	expect(t, c.do("INCRBYFLOAT", "f", "-0.25"), "1.25")
+	if v, err := cache.GetFloat64("f"); err != nil || v != 1.25 {
+		t.Errorf("GetFloat64() = %v, %v, want 1.25", v, err)
+	}
+	c.do("SET", "s", "10")
+	expect(t, c.do("INCRBYFLOAT", "s", "0.5"), "10.5")
+	expect(t, c.do("GET", "s"), "10.5")
+
+	expectError(t, c.do("INCRBYFLOAT", "f", "nan"), "ERR value is not a valid float")
+	cache.SetInt64("i", 1, 0)
+	expectError(t, c.do("INCRBYFLOAT", "i", "1"), "WRONGTYPE")
+}
+
+func TestServer_TypedValues(t *testing.T) {
+	cache, _, addr := newTestServer(t)
+	c := dial(t, "tcp", addr)
+
+	cache.SetInt64("i", -7, 0)
+	cache.SetBool("b", true, 0)
+	cache.SetRaw("r", []byte{0, 1, 2}, 0)
+	expect(t, c.do("GET", "i"), "-7")
+	expect(t, c.do("GET", "b"), "true")
+	expect(t, c.do("GET", "r"), string([]byte{0, 1, 2}))
+}
+
+func TestServer_MultiKey(t *testing.T) {
+	cache, _, addr := newTestServer(t)
+	c := dial(t, "tcp", addr)
+
+	expect(t, c.do("MSET", "a", "1", "b", "2", "user:1", "x", "user/2", "y"), "OK")
+	expect(t, c.do("MGET", "a", "missing", "b"), []any{"1", nil, "2"})
+	expect(t, c.do("KEYS", "user*"), []any{"user/2", "user:1"})
+	expect(t, c.do("KEYS", "[ab]"), []any{"a", "b"})
+	expectError(t, c.do("MSET", "a", "1", "b"), "ERR wrong number of arguments")
+
+	expect(t, c.do("FLUSHDB"), "OK")
+	if keys := cache.Keys(); len(keys) != 0 {
+		t.Errorf("Keys() = %v after FLUSHDB", keys)
+	}
+}
+
+func TestServer_Hash(t *testing.T) {
+	cache, _, addr := newTestServer(t)
+	c := dial(t, "tcp", addr)
+
+	expect(t, c.do("HSET", "h", "name", "alice", "age", "30"), int64(2))
+	expect(t, c.do("HSET", "h", "name", "bob"), int64(0))
+	expect(t, c.do("HGET", "h", "name"), "bob")
+	expect(t, c.do("HGET", "h", "missing"), nil)
+	expect(t, c.do("HGET", "missing", "name"), nil)
+	expect(t, c.do("HMGET", "h", "age", "missing", "name"), []any{"30", nil, "bob"})
+	expect(t, c.do("HGETALL", "h"), []any{"age", "30", "name", "bob"})
+	expect(t, c.do("HLEN", "h"), int64(2))
+	expect(t, c.do("HEXISTS", "h", "age"), int64(1))
+	expect(t, c.do("HEXISTS", "h", "nope"), int64(0))
+	expect(t, c.do("HINCRBY", "h", "age", "5"), int64(35))
+	expect(t, c.do("HINCRBYFLOAT", "h", "score", "1.5"), "1.5")
+	expectError(t, c.do("GET", "h"), "WRONGTYPE")
+	if v, err := cache.HGet("h", "age"); err != nil || string(v) != "35" {
+		t.Errorf("HGet() = %q, %v, want 35", v, err)
+	}
+
+	expectError(t, c.do("HINCRBY", "h", "name", "1"), "ERR")
+	expectError(t, c.do("HSET", "h", "odd"), "ERR wrong number of arguments")
+	c.do("SET", "s", "v")
+	expectError(t, c.do("HGET", "s", "f"), "WRONGTYPE")
+
+	expect(t, c.do("HDEL", "h", "name", "age", "score", "nope"), int64(3))
+	expect(t, c.do("EXISTS", "h"), int64(0))
+	expect(t, c.do("HGETALL", "h"), []any{})
+}
+
+func TestServer_List(t *testing.T) {
+	cache, _, addr := newTestServer(t)
+	c := dial(t, "tcp", addr)
+
+	expect(t, c.do("RPUSH", "l", "b", "c"), int64(2))
+	expect(t, c.do("LPUSH", "l", "a", "z"), int64(4))
+	expect(t, c.do("LRANGE", "l", "0", "-1"), []any{"z", "a", "b", "c"})
+	expect(t, c.do("LLEN", "l"), int64(4))
+	expect(t, c.do("LINDEX", "l", "-1"), "c")
+	expect(t, c.do("LINDEX", "l", "10"), nil)
+	expect(t, c.do("LSET", "l", "0", "Z"), "OK")
+	expect(t, c.do("LPOP", "l"), "Z")
+	expect(t, c.do("RPOP", "l"), "c")
+	expect(t, c.do("LTRIM", "l", "1", "-1"), "OK")
+	expect(t, c.do("LRANGE", "l", "0", "-1"), []any{"b"})
+	if v, err := cache.LPop("l"); err != nil || string(v) != "b" {
+		t.Errorf("LPop() = %q, %v, want b", v, err)
+	}
+	expect(t, c.do("EXISTS", "l"), int64(0))
+	expect(t, c.do("LPOP", "l"), nil)
+	expect(t, c.do("LRANGE", "l", "0", "-1"), []any{})
+
+	expectError(t, c.do("LSET", "l", "0", "x"), "ERR no such key")
+	c.do("RPUSH", "l", "a")
+	expectError(t, c.do("LSET", "l", "5", "x"), "OUTOFRANGE")
+	expectError(t, c.do("LRANGE", "l", "a", "1"), "ERR value is not an integer")
+	expectError(t, c.do("RPUSH", "l"), "ERR wrong number of arguments")
+	c.do("SET", "s", "v")
+	expectError(t, c.do("LPUSH", "s", "x"), "WRONGTYPE")
+}
+
+func TestServer_BLPop(t *testing.T) {
+	cache, _, addr := newTestServer(t)
+	c := dial(t, "tcp", addr)
+	other := dial(t, "tcp", addr)
+
+	other.do("RPUSH", "b", "ready")
+	expect(t, c.do("BLPOP", "a", "b", "0"), []any{"b", "ready"})
+	expect(t, c.do("BLPOP", "a", "0.01"), nil)
+	expectError(t, c.do("BLPOP", "a", "-1"), "ERR timeout is negative")
+	expectError(t, c.do("BLPOP", "a", "soon"), "ERR timeout is not a float")
+
+	// A push from another client wakes the blocked one.
+	c.send("BLPOP", "a", "b", "5")
+	time.Sleep(20 * time.Millisecond)
+	expect(t, other.do("RPUSH", "a", "job"), int64(1))
+	expect(t, c.read(), []any{"a", "job"})
+	expect(t, c.do("PING"), "PONG")
+
+	// Commands sent behind a blocking one run once it returns.
+	c.send("BLPOP", "a", "0.01")
+	c.send("PING")
+	expect(t, c.read(), nil)
+	expect(t, c.read(), "PONG")
+
+	// Nothing is popped for a client that hung up while blocked.
+	gone := dial(t, "tcp", addr)
+	gone.send("BLPOP", "q", "0")
+	time.Sleep(20 * time.Millisecond)
+	gone.conn.Close()
+	time.Sleep(20 * time.Millisecond)
+	other.do("RPUSH", "q", "kept")
+	if n, err := cache.LLen("q"); err != nil || n != 1 {
+		t.Errorf("LLen() = %d, %v, want the element to stay", n, err)
+	}
+}
+
+func TestServer_Set(t *testing.T) {
+	cache, _, addr := newTestServer(t)
+	c := dial(t, "tcp", addr)
+
+	expect(t, c.do("SADD", "a", "1", "2", "3", "2"), int64(3))
+	expect(t, c.do("SADD", "b", "3", "4"), int64(2))
+	expect(t, c.do("SMEMBERS", "a"), []any{"1", "2", "3"})
+	expect(t, c.do("SISMEMBER", "a", "2"), int64(1))
+	expect(t, c.do("SISMEMBER", "a", "9"), int64(0))
+	expect(t, c.do("SCARD", "a"), int64(3))
+	expect(t, c.do("SUNION", "a", "b"), []any{"1", "2", "3", "4"})
+	expect(t, c.do("SINTER", "a", "b"), []any{"3"})
+	expect(t, c.do("SDIFF", "a", "b"), []any{"1", "2"})
+	expect(t, c.do("SUNIONSTORE", "u", "a", "b"), int64(4))
+	expect(t, c.do("SINTERSTORE", "i", "a", "b"), int64(1))
+	expect(t, c.do("SDIFFSTORE", "d", "b", "a"), int64(1))
+	if members, err := cache.SMembers("d"); err != nil || len(members) != 1 || members[0] != "4" {
+		t.Errorf("SMembers() = %q, %v, want [4]", members, err)
+	}
+
+	expect(t, c.do("SREM", "u", "1", "9"), int64(1))
+	if n := len(c.do("SRANDMEMBER", "u", "-5").([]any)); n != 5 {
+		t.Errorf("SRANDMEMBER -5 returned %d members, want 5", n)
+	}
+	if member, ok := c.do("SRANDMEMBER", "u").(string); !ok || member < "2" || member > "4" {
+		t.Errorf("SRANDMEMBER = %q, want a member", member)
+	}
+	expect(t, c.do("SRANDMEMBER", "missing"), nil)
+	if n := len(c.do("SPOP", "u", "2").([]any)); n != 2 {
+		t.Errorf("SPOP u 2 returned %d members, want 2", n)
+	}
+	if _, ok := c.do("SPOP", "u").(string); !ok {
+		t.Error("SPOP u did not return the last member")
+	}
+	expect(t, c.do("EXISTS", "u"), int64(0))
+	expect(t, c.do("SPOP", "u"), nil)
+
+	expectError(t, c.do("SPOP", "a", "-1"), "ERR value is out of range")
+	expectError(t, c.do("SADD", "a"), "ERR wrong number of arguments")
+	c.do("SET", "s", "v")
+	expectError(t, c.do("SADD", "s", "x"), "WRONGTYPE")
+	expectError(t, c.do("SUNION", "a", "s"), "WRONGTYPE")
+
+	// RESP3 clients get set replies.
+	c.do("HELLO", "3")
+	c.send("SMEMBERS", "a")
+	if line, err := c.r.ReadString('\n'); err != nil || line != "~3\r\n" {
+		t.Fatalf("SMEMBERS reply starts with %q, %v, want a set of 3", line, err)
+	}
+	for range 3 {
+		c.read()
+	}
+}
+
+func TestServer_ZSet(t *testing.T) {
+	cache, _, addr := newTestServer(t)
+	c := dial(t, "tcp", addr)
+
+	expect(t, c.do("ZADD", "board", "30", "alice", "10", "bob", "20", "carol"), int64(3))
+	expect(t, c.do("ZADD", "board", "15", "bob", "-inf", "dave"), int64(1))
+	expect(t, c.do("ZINCRBY", "board", "2.5", "alice"), "32.5")
+	expect(t, c.do("ZSCORE", "board", "dave"), "-inf")
+	expect(t, c.do("ZSCORE", "board", "erin"), nil)
+	expect(t, c.do("ZRANK", "board", "carol"), int64(2))
+	expect(t, c.do("ZREVRANK", "board", "carol"), int64(1))
+	expect(t, c.do("ZRANK", "missing", "carol"), nil)
+	expect(t, c.do("ZCARD", "board"), int64(4))
+	expect(t, c.do("ZRANGE", "board", "0", "-1"), []any{"dave", "bob", "carol", "alice"})
+	expect(t, c.do("ZREVRANGE", "board", "0", "1", "WITHSCORES"), []any{"alice", "32.5", "carol", "20"})
+	expect(t, c.do("ZRANGEBYSCORE", "board", "(15", "+inf"), []any{"carol", "alice"})
+	expect(t, c.do("ZRANGEBYSCORE", "board", "-inf", "inf", "withscores", "LIMIT", "1", "2"), []any{"bob", "15", "carol", "20"})
+	if rank, err := cache.ZRank("board", "alice"); err != nil || rank != 3 {
+		t.Errorf("ZRank() = %d, %v, want 3", rank, err)
+	}
+
+	expect(t, c.do("ZREM", "board", "dave", "erin"), int64(1))
+	expect(t, c.do("ZREMRANGEBYSCORE", "board", "10", "(32.5"), int64(2))
+	expect(t, c.do("ZRANGE", "board", "0", "-1", "WITHSCORES"), []any{"alice", "32.5"})
+
+	expectError(t, c.do("ZADD", "board", "1", "a", "2"), "ERR syntax error")
+	expectError(t, c.do("ZADD", "board", "nan", "a"), "ERR value is not a valid float")
+	expectError(t, c.do("ZRANGEBYSCORE", "board", "x", "1"), "ERR min or max is not a float")
+	expectError(t, c.do("ZRANGEBYSCORE", "board", "0", "1", "LIMIT", "1"), "ERR syntax error")
+	expectError(t, c.do("ZRANGE", "board", "0", "1", "SCORES"), "ERR syntax error")
+	c.do("SET", "s", "v")
+	expectError(t, c.do("ZADD", "s", "1", "a"), "WRONGTYPE")
+	expectError(t, c.do("ZSCORE", "s", "a"), "WRONGTYPE")
+}
+
+func TestServer_Bitmap(t *testing.T) {
+	cache, _, addr := newTestServer(t)
+	c := dial(t, "tcp", addr)
+
+	expect(t, c.do("SETBIT", "dau", "7", "1"), int64(0))
+	expect(t, c.do("SETBIT", "dau", "7", "1"), int64(1))
+	expect(t, c.do("SETBIT", "dau", "9", "1"), int64(0))
+	expect(t, c.do("GETBIT", "dau", "9"), int64(1))
+	expect(t, c.do("GETBIT", "dau", "100"), int64(0))
+	expect(t, c.do("GET", "dau"), "\x01\x40")
+	expect(t, c.do("BITCOUNT", "dau"), int64(2))
+	expect(t, c.do("BITCOUNT", "dau", "-1", "-1"), int64(1))
+	expect(t, c.do("BITPOS", "dau", "1"), int64(7))
+	expect(t, c.do("BITPOS", "dau", "1", "1"), int64(9))
+	expect(t, c.do("BITPOS", "missing", "0"), int64(0))
+
+	cache.SetRaw("ones", []byte{0xff}, 0)
+	expect(t, c.do("BITPOS", "ones", "0"), int64(8))
+	expect(t, c.do("BITPOS", "ones", "0", "0", "-1"), int64(-1))
+
+	expect(t, c.do("BITOP", "or", "both", "dau", "ones"), int64(2))
+	if data, err := cache.GetRaw("both"); err != nil || string(data) != "\xff\x40" {
+		t.Errorf("GetRaw() = %q, %v, want \\xff\\x40", data, err)
+	}
+	expect(t, c.do("BITOP", "NOT", "inv", "ones"), int64(1))
+	expect(t, c.do("BITCOUNT", "inv"), int64(0))
+
+	expectError(t, c.do("SETBIT", "dau", "-1", "1"), "ERR bit offset is not an integer")
+	expectError(t, c.do("SETBIT", "dau", "4294967296", "1"), "ERR bit offset is not an integer")
+	expectError(t, c.do("SETBIT", "dau", "1", "2"), "ERR bit is not an integer")
+	expectError(t, c.do("BITCOUNT", "dau", "0"), "ERR syntax error")
+	expectError(t, c.do("BITOP", "NAND", "d", "dau"), "ERR syntax error")
+	expectError(t, c.do("BITOP", "NOT", "d", "dau", "ones"), "ERR BITOP NOT must be called with a single source key")
+	c.do("HSET", "h", "f", "v")
+	expectError(t, c.do("SETBIT", "h", "1", "1"), "WRONGTYPE")
+}
+
+func TestServer_TypedCommands(t *testing.T) {
+	cache, _, addr := newTestServer(t)
+	c := dial(t, "tcp", addr)
+
+	i32 := strconv.Itoa(int(types.INT32))
+	expect(t, c.do("CS.SET", "n", i32, string(utils.Int32toBinary(5)), "60000000000"), "OK")
+	if v, err := cache.GetInt32("n"); err != nil || v != 5 {
+		t.Errorf("GetInt32() = %d, %v, want 5", v, err)
+	}
+	expect(t, c.do("CS.INCR", "n", i32, string(utils.Int32toBinary(2)), "0"), "OK")
+	got := c.do("CS.GET", "n").([]any)
+	if len(got) != 3 || got[0] != int64(types.INT32) || got[1] != string(utils.Int32toBinary(7)) {
+		t.Errorf("CS.GET = %#v", got)
+	}
+	if ttl := c.do("CS.TTL", "n").(int64); ttl <= 0 || ttl > int64(time.Minute) {
+		t.Errorf("CS.TTL = %d, want about a minute in nanoseconds", ttl)
+	}
+	expect(t, c.do("CS.TTL", "missing"), int64(store.TTLExpired))
+	expectError(t, c.do("CS.DECR", "n", i32, string(utils.Int32toBinary(1)), "0"), "WRONGTYPE")
+	cache.SetUInt16("u", 1, 0)
+	expectError(t, c.do("CS.DECR", "u", strconv.Itoa(int(types.UINT16)), string(utils.UInt16toBinary(2)), "0"), "OUTOFRANGE")
+	expectError(t, c.do("CS.GET", "missing"), "NOTFOUND")
+	expectError(t, c.do("CS.SET", "x", "nope", "v", "0"), "ERR value is not an integer")
+
+	str := strconv.Itoa(int(types.STRING))
+	expect(t, c.do("CS.MSET", "a", str, "1", "0", "", str, "2", "0"), []any{"OK", replyError("KEYEMPTY key cannot be empty")})
+	if items := c.do("CS.MGET", "a", "missing").([]any); len(items) != 2 || items[0].([]any)[1] != "1" || items[0].([]any)[3] != int64(store.TTLNoExpiry) {
+		t.Errorf("CS.MGET = %#v", items)
+	} else {
+		expectError(t, items[1], "NOTFOUND")
+	}
+	expectError(t, c.do("CS.MSET", "a", str, "1"), "ERR wrong number of arguments")
+}
+
+func TestServer_ReadOnlyFollower(t *testing.T) {
+	cache, _, addr := newTestServer(t)
+	c := dial(t, "tcp", addr)
+	cache.SetString("k", "v", 0)
+
+	// A failed Follow leaves the store in follower mode.
+	p, _ := net.Pipe()
+	p.Close()
+	cache.Follow(p)
+
+	expect(t, c.do("GET", "k"), "v")
+	expectError(t, c.do("SET", "k", "w"), "READONLY")
+	expectError(t, c.do("INCR", "n"), "READONLY")
+	expectError(t, c.do("DEL", "k"), "READONLY")
+	expectError(t, c.do("FLUSHDB"), "READONLY")
+	if err := ParseError(string(c.do("SET", "k", "w").(replyError))); err != errors.ErrReadOnly {
+		t.Errorf("ParseError() = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		reply string
+		kind  error
+		msg   string
+	}{
+		{"NOTFOUND no data found for key: k", errors.ErrNotFound, "no data found for key: k"},
+		{"KEYEMPTY key cannot be empty", errors.ErrKeyEmpty, "key cannot be empty"},
+		{"CONFLICT version conflict", errors.ErrConflict, "version conflict"},
+		{"ERR unknown command 'NOPE'", nil, "ERR unknown command 'NOPE'"},
+	}
+	for _, tt := range tests {
+		err := ParseError(tt.reply)
+		if err.Error() != tt.msg {
+			t.Errorf("ParseError(%q) = %q, want %q", tt.reply, err, tt.msg)
+		}
+		if tt.kind != nil && !goerrors.Is(err, tt.kind) {
+			t.Errorf("ParseError(%q) does not match %v", tt.reply, tt.kind)
+		}
+	}
+	if ParseError("KEYEMPTY key cannot be empty") != errors.ErrKeyEmpty {
+		t.Error("ParseError should return the sentinel itself")
+	}
+}
+
+func TestServer_RESP3(t *testing.T) {
+	_, _, addr := newTestServer(t)
+	c := dial(t, "tcp", addr)
+
+	hello, ok := c.do("HELLO", "3").(map[string]any)
+	if !ok || hello["proto"] != int64(3) || hello["server"] != "cachestore" {
+		t.Fatalf("HELLO 3 = %#v", hello)
+	}
+	c.send("GET", "missing")
+	if line, _ := c.r.ReadString('\n'); line != "_\r\n" {
+		t.Errorf("RESP3 null = %q, want _", line)
+	}
+	expectError(t, c.do("HELLO", "4"), "NOPROTO")
+
+	if hello, ok := c.do("HELLO", "2").([]any); !ok || len(hello) != 14 {
+		t.Errorf("HELLO 2 = %#v, want a flat array", hello)
+	}
+	c.send("GET", "missing")
+	if line, _ := c.r.ReadString('\n'); line != "$-1\r\n" {
+		t.Errorf("RESP2 null = %q, want $-1", line)
+	}
+}
+
+func TestServer_PipelineAndInline(t *testing.T) {
+	_, _, addr := newTestServer(t)
+	c := dial(t, "tcp", addr)
+
+	var b strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&b, "*2\r\n$4\r\nINCR\r\n$1\r\nn\r\n")
+	}
+	c.conn.Write([]byte(b.String()))
+	for i := 1; i <= 100; i++ {
+		expect(t, c.read(), int64(i))
+	}
+
+	c.conn.Write([]byte("SET inline value\r\n\r\nGET inline\r\n"))
+	expect(t, c.read(), "OK")
+	expect(t, c.read(), "value")
+
+	expect(t, c.do("QUIT"), "OK")
+	if _, err := c.r.ReadByte(); err == nil {
+		t.Error("connection should be closed after QUIT")
+	}
+}
+
+func TestServer_ProtocolError(t *testing.T) {
+	_, _, addr := newTestServer(t)
+	c := dial(t, "tcp", addr)
+
+	c.conn.Write([]byte("*1\r\n+PING\r\n"))
+	expectError(t, c.read(), "ERR protocol error")
+	if _, err := c.r.ReadByte(); err == nil {
+		t.Error("connection should be closed after a protocol error")
+	}
+}
+
+func TestServer_UnixSocket(t *testing.T) {
+	cache, err := store.NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("NewCacheStore() error = %v", err)
+	}
+	defer cache.Close()
+
+	path := filepath.Join(t.TempDir(), "cache.sock")
+	srv := New(cache)
+	done := make(chan error, 1)
+	go func() { done <- srv.ListenAndServe("unix", path) }()
+
+	var conn net.Conn
+	deadline := time.Now().Add(time.Second)
+	for conn == nil {
+		conn, err = net.Dial("unix", path)
+		if err != nil && time.Now().After(deadline) {
+			t.Fatalf("Dial() error = %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	conn.Close()
+
+	c := dial(t, "unix", path)
+	expect(t, c.do("SET", "k", "v"), "OK")
+	expect(t, c.do("GET", "k"), "v")
+
+	srv.Close()
+	if err := <-done; err != errors.ErrServerClosed {
+		t.Errorf("ListenAndServe() = %v, want ErrServerClosed", err)
+	}
+}
+
+func TestServer_CloseDisconnectsClients(t *testing.T) {
+	cache, err := store.NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("NewCacheStore() error = %v", err)
+	}
+	defer cache.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	srv := New(cache)
+	go srv.Serve(l)
+
+	c := dial(t, "tcp", l.Addr().String())
+	expect(t, c.do("PING"), "PONG")
+	srv.Close()
+	if _, err := c.r.ReadByte(); err == nil {
+		t.Error("client should be disconnected by Close")
+	}
+	if err := srv.Serve(l); err != errors.ErrServerClosed {
+		t.Errorf("Serve() after Close = %v, want ErrServerClosed", err)
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, key string
+		want         bool
+	}{
+		{"*", "", true},
+		{"*", "a/b", true},
+		{"user:*", "user:1", true},
+		{"user:*", "users", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[a-c]llo", "hdllo", false},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"h[ello", "hello", false},
+		{"*b*c", "abxbc", true},
+	}
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.key); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"time"
+)
+
+// Set commands, stored as types.SET. Members are sent sorted, except for
+// SPOP and SRANDMEMBER, and sent as RESP3 sets to clients that asked for
+// RESP3.
+
+func cmdSAdd(srv *Server, sess *session, args [][]byte) {
+	added, err := srv.store.SAdd(string(args[1]), stringArgs(args[2:]), 0)
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.integer(int64(added))
+}
+
+func cmdSRem(srv *Server, sess *session, args [][]byte) {
+	removed, err := srv.store.SRem(string(args[1]), stringArgs(args[2:])...)
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.integer(int64(removed))
+}
+
+func cmdSIsMember(srv *Server, sess *session, args [][]byte) {
+	found, err := srv.store.SIsMember(string(args[1]), string(args[2]))
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	if found {
+		sess.w.integer(1)
+	} else {
+		sess.w.integer(0)
+	}
+}
+
+func cmdSMembers(srv *Server, sess *session, args [][]byte) {
+	members, err := srv.store.SMembers(string(args[1]))
+	replyMembers(sess.w, members, err)
+}
+
+func cmdSCard(srv *Server, sess *session, args [][]byte) {
+	n, err := srv.store.SCard(string(args[1]))
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.integer(int64(n))
+}
+
+// cmdSPop implements SPOP key [count]. Without count it replies with one
+// member, or null when the set is empty.
+func cmdSPop(srv *Server, sess *session, args [][]byte) {
+	if len(args) > 3 {
+		sess.w.error("ERR syntax error")
+		return
+	}
+	count := 1
+	if len(args) == 3 {
+		n, err := parseIndex(args[2])
+		if err != nil {
+			replyErr(sess.w, err)
+			return
+		}
+		if n < 0 {
+			sess.w.error("ERR value is out of range, must be positive")
+			return
+		}
+		count = n
+	}
+	members, err := srv.store.SPop(string(args[1]), count)
+	if len(args) == 3 {
+		replyMembers(sess.w, members, err)
+		return
+	}
+	replyMember(sess.w, members, err)
+}
+
+// cmdSRandMember implements SRANDMEMBER key [count], see
+// store.CacheStore.SRandMember for the meaning of count.
+func cmdSRandMember(srv *Server, sess *session, args [][]byte) {
+	if len(args) > 3 {
+		sess.w.error("ERR syntax error")
+		return
+	}
+	if len(args) == 2 {
+		members, err := srv.store.SRandMember(string(args[1]), 1)
+		replyMember(sess.w, members, err)
+		return
+	}
+	count, err := parseIndex(args[2])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	members, err := srv.store.SRandMember(string(args[1]), count)
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	// Members may repeat, so they are no set.
+	sess.w.array(len(members))
+	for _, member := range members {
+		sess.w.bulkString(member)
+	}
+}
+
+func cmdSUnion(srv *Server, sess *session, args [][]byte) {
+	members, err := srv.store.SUnion(stringArgs(args[1:])...)
+	replyMembers(sess.w, members, err)
+}
+
+func cmdSInter(srv *Server, sess *session, args [][]byte) {
+	members, err := srv.store.SInter(stringArgs(args[1:])...)
+	replyMembers(sess.w, members, err)
+}
+
+func cmdSDiff(srv *Server, sess *session, args [][]byte) {
+	members, err := srv.store.SDiff(stringArgs(args[1:])...)
+	replyMembers(sess.w, members, err)
+}
+
+func cmdSUnionStore(srv *Server, sess *session, args [][]byte) {
+	storeSets(sess, srv.store.SUnionStore, args)
+}
+
+func cmdSInterStore(srv *Server, sess *session, args [][]byte) {
+	storeSets(sess, srv.store.SInterStore, args)
+}
+
+func cmdSDiffStore(srv *Server, sess *session, args [][]byte) {
+	storeSets(sess, srv.store.SDiffStore, args)
+}
+
+// storeSets implements the *STORE commands, whose result has no time to
+// live, as in Redis.
+func storeSets(sess *session, fn func(dest string, keys []string, exp time.Duration) (int, error), args [][]byte) {
+	n, err := fn(string(args[1]), stringArgs(args[2:]), 0)
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.integer(int64(n))
+}
+
+func replyMembers(w *writer, members []string, err error) {
+	if err != nil {
+		replyErr(w, err)
+		return
+	}
+	w.setHeader(len(members))
+	for _, member := range members {
+		w.bulkString(member)
+	}
+}
+
+// replyMember sends the only member of members, or null when it is empty.
+func replyMember(w *writer, members []string, err error) {
+	switch {
+	case err != nil:
+		replyErr(w, err)
+	case len(members) == 0:
+		w.null()
+	default:
+		w.bulkString(members[0])
+	}
+}
+
+// stringArgs converts arguments such as keys or members to strings.
+func stringArgs(args [][]byte) []string {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		strs[i] = string(arg)
+	}
+	return strs
+}
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/found-cake/CacheStore/store"
+	"github.com/found-cake/CacheStore/utils"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// The CS.* commands carry values together with their types.DataType in the
+// store's binary encoding, so that the client package can offer the exact
+// CacheStore API. Types and time to live are decimal integers; a time to
+// live is a time.Duration in nanoseconds.
+//
+//	CS.GET key                          [type, data, version]
+//	CS.SET key type data ttl            OK
+//	CS.MGET key [key ...]               [type, data, version, ttl] or error per key
+//	CS.MSET key type data ttl [...]     one OK or error per item
+//	CS.INCR key type delta ttl          OK; delta is encoded like the value
+//	CS.DECR key type delta ttl          OK; unsigned types only
+//	CS.TTL key                          time to live, as returned by TTL
+
+func parseDataType(b []byte) (types.DataType, error) {
+	n, err := strconv.ParseUint(string(b), 10, 8)
+	if err != nil {
+		return types.UNKNOWN, errNotInteger
+	}
+	return types.DataType(n), nil
+}
+
+func parseTTL(b []byte) (time.Duration, error) {
+	n, err := parseInt(b)
+	return time.Duration(n), err
+}
+
+func replyTyped(w *writer, dataType types.DataType, data []byte, version uint64) {
+	w.array(3)
+	w.integer(int64(dataType))
+	w.bulk(data)
+	w.integer(int64(version))
+}
+
+func cmdTypedGet(srv *Server, sess *session, args [][]byte) {
+	dataType, data, version, err := srv.store.GetWithVersion(string(args[1]))
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	replyTyped(sess.w, dataType, data, version)
+}
+
+func cmdTypedSet(srv *Server, sess *session, args [][]byte) {
+	dataType, err := parseDataType(args[2])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	ttl, err := parseTTL(args[4])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	if err := srv.store.Set(string(args[1]), dataType, args[3], ttl); err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.simple("OK")
+}
+
+func cmdTypedMGet(srv *Server, sess *session, args [][]byte) {
+	keys := make([]string, len(args)-1)
+	for i, arg := range args[1:] {
+		keys[i] = string(arg)
+	}
+	results := srv.store.MGet(keys...)
+	sess.w.array(len(results))
+	for _, result := range results {
+		if result.Error != nil {
+			replyErr(sess.w, result.Error)
+			continue
+		}
+		sess.w.array(4)
+		sess.w.integer(int64(result.Type))
+		sess.w.bulk(result.Value)
+		sess.w.integer(int64(result.Version))
+		sess.w.integer(int64(result.TTL))
+	}
+}
+
+func cmdTypedMSet(srv *Server, sess *session, args [][]byte) {
+	if (len(args)-1)%4 != 0 {
+		sess.w.error("ERR wrong number of arguments for 'cs.mset' command")
+		return
+	}
+	items := make([]store.BatchItem, 0, (len(args)-1)/4)
+	for i := 1; i < len(args); i += 4 {
+		dataType, err := parseDataType(args[i+1])
+		if err != nil {
+			replyErr(sess.w, err)
+			return
+		}
+		ttl, err := parseTTL(args[i+3])
+		if err != nil {
+			replyErr(sess.w, err)
+			return
+		}
+		items = append(items, store.NewItem(string(args[i]), dataType, args[i+2], ttl))
+	}
+	errs := srv.store.MSet(items...)
+	sess.w.array(len(errs))
+	for _, err := range errs {
+		if err != nil {
+			replyErr(sess.w, err)
+		} else {
+			sess.w.simple("OK")
+		}
+	}
+}
+
+// incrTyped decodes delta as dataType and calls the matching Incr method.
+func incrTyped(s *store.CacheStore, key string, dataType types.DataType, delta []byte, ttl time.Duration) error {
+	switch dataType {
+	case types.INT16:
+		v, err := utils.Binary2Int16(delta)
+		if err != nil {
+			return err
+		}
+		return s.IncrInt16(key, v, ttl)
+	case types.INT32:
+		v, err := utils.Binary2Int32(delta)
+		if err != nil {
+			return err
+		}
+		return s.IncrInt32(key, v, ttl)
+	case types.INT64:
+		v, err := utils.Binary2Int64(delta)
+		if err != nil {
+			return err
+		}
+		return s.IncrInt64(key, v, ttl)
+	case types.UINT16:
+		v, err := utils.Binary2UInt16(delta)
+		if err != nil {
+			return err
+		}
+		return s.IncrUInt16(key, v, ttl)
+	case types.UINT32:
+		v, err := utils.Binary2UInt32(delta)
+		if err != nil {
+			return err
+		}
+		return s.IncrUInt32(key, v, ttl)
+	case types.UINT64:
+		v, err := utils.Binary2UInt64(delta)
+		if err != nil {
+			return err
+		}
+		return s.IncrUInt64(key, v, ttl)
+	case types.FLOAT32:
+		v, err := utils.Binary2Float32(delta)
+		if err != nil {
+			return err
+		}
+		return s.IncrFloat32(key, v, ttl)
+	case types.FLOAT64:
+		v, err := utils.Binary2Float64(delta)
+		if err != nil {
+			return err
+		}
+		return s.IncrFloat64(key, v, ttl)
+	default:
+		return errWrongType
+	}
+}
+
+func decrTyped(s *store.CacheStore, key string, dataType types.DataType, delta []byte, ttl time.Duration) error {
+	switch dataType {
+	case types.UINT16:
+		v, err := utils.Binary2UInt16(delta)
+		if err != nil {
+			return err
+		}
+		return s.DecrUInt16(key, v, ttl)
+	case types.UINT32:
+		v, err := utils.Binary2UInt32(delta)
+		if err != nil {
+			return err
+		}
+		return s.DecrUInt32(key, v, ttl)
+	case types.UINT64:
+		v, err := utils.Binary2UInt64(delta)
+		if err != nil {
+			return err
+		}
+		return s.DecrUInt64(key, v, ttl)
+	default:
+		return errWrongType
+	}
+}
+
+func typedCounter(srv *Server, sess *session, args [][]byte, apply func(*store.CacheStore, string, types.DataType, []byte, time.Duration) error) {
+	dataType, err := parseDataType(args[2])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	ttl, err := parseTTL(args[4])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	if err := apply(srv.store, string(args[1]), dataType, args[3], ttl); err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.simple("OK")
+}
+
+func cmdTypedIncr(srv *Server, sess *session, args [][]byte) {
+	typedCounter(srv, sess, args, incrTyped)
+}
+
+func cmdTypedDecr(srv *Server, sess *session, args [][]byte) {
+	typedCounter(srv, sess, args, decrTyped)
+}
+
+func cmdTypedTTL(srv *Server, sess *session, args [][]byte) {
+	sess.w.integer(int64(srv.store.TTL(string(args[1]))))
+}
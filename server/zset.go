@@ -0,0 +1,246 @@
+package server
+
+import (
+	goerrors "errors"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils"
+)
+
+// Sorted set commands, stored as types.ZSET. Scores are sent as bulk strings,
+// "inf" and "-inf" included, and WITHSCORES replies alternate members and
+// scores in one flat array.
+
+const errScoreRange replyError = "ERR min or max is not a float"
+
+// cmdZAdd implements ZADD key score member [score member ...].
+func cmdZAdd(srv *Server, sess *session, args [][]byte) {
+	if len(args)%2 != 0 {
+		sess.w.error("ERR syntax error")
+		return
+	}
+	members := make([]utils.ZMember, 0, (len(args)-2)/2)
+	for i := 2; i < len(args); i += 2 {
+		score, err := parseScore(args[i])
+		if err != nil {
+			replyErr(sess.w, err)
+			return
+		}
+		members = append(members, utils.ZMember{Member: string(args[i+1]), Score: score})
+	}
+	added, err := srv.store.ZAdd(string(args[1]), members, 0)
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.integer(int64(added))
+}
+
+func cmdZIncrBy(srv *Server, sess *session, args [][]byte) {
+	delta, err := parseScore(args[2])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	score, err := srv.store.ZIncrBy(string(args[1]), string(args[3]), delta, 0)
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.bulkString(formatScore(score))
+}
+
+func cmdZScore(srv *Server, sess *session, args [][]byte) {
+	score, err := srv.store.ZScore(string(args[1]), string(args[2]))
+	switch {
+	case goerrors.Is(err, errors.ErrNotFound):
+		sess.w.null()
+	case err != nil:
+		replyErr(sess.w, err)
+	default:
+		sess.w.bulkString(formatScore(score))
+	}
+}
+
+func cmdZRank(srv *Server, sess *session, args [][]byte) {
+	rank, err := srv.store.ZRank(string(args[1]), string(args[2]))
+	replyRank(sess.w, rank, err)
+}
+
+func cmdZRevRank(srv *Server, sess *session, args [][]byte) {
+	rank, err := srv.store.ZRevRank(string(args[1]), string(args[2]))
+	replyRank(sess.w, rank, err)
+}
+
+// replyRank sends rank, or null when the key or the member does not exist.
+func replyRank(w *writer, rank int, err error) {
+	switch {
+	case goerrors.Is(err, errors.ErrNotFound):
+		w.null()
+	case err != nil:
+		replyErr(w, err)
+	default:
+		w.integer(int64(rank))
+	}
+}
+
+func cmdZCard(srv *Server, sess *session, args [][]byte) {
+	n, err := srv.store.ZCard(string(args[1]))
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.integer(int64(n))
+}
+
+// cmdZRange implements ZRANGE key start stop [WITHSCORES].
+func cmdZRange(srv *Server, sess *session, args [][]byte) {
+	zrange(srv.store.ZRange, sess, args)
+}
+
+// cmdZRevRange implements ZREVRANGE key start stop [WITHSCORES].
+func cmdZRevRange(srv *Server, sess *session, args [][]byte) {
+	zrange(srv.store.ZRevRange, sess, args)
+}
+
+func zrange(fn func(key string, start, stop int) ([]utils.ZMember, error), sess *session, args [][]byte) {
+	withScores := false
+	switch {
+	case len(args) == 5 && strings.EqualFold(string(args[4]), "WITHSCORES"):
+		withScores = true
+	case len(args) != 4:
+		sess.w.error("ERR syntax error")
+		return
+	}
+	start, stop, err := parseRange(args[2], args[3])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	members, err := fn(string(args[1]), start, stop)
+	replyZMembers(sess.w, members, withScores, err)
+}
+
+// cmdZRangeByScore implements
+// ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count].
+func cmdZRangeByScore(srv *Server, sess *session, args [][]byte) {
+	minScore, maxScore, err := parseScoreRange(args[2], args[3])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	withScores := false
+	offset, count := 0, -1
+	for i := 4; i < len(args); i++ {
+		switch opt := strings.ToUpper(string(args[i])); {
+		case opt == "WITHSCORES":
+			withScores = true
+		case opt == "LIMIT" && i+2 < len(args):
+			if offset, err = parseIndex(args[i+1]); err == nil {
+				count, err = parseIndex(args[i+2])
+			}
+			if err != nil {
+				replyErr(sess.w, err)
+				return
+			}
+			i += 2
+		default:
+			sess.w.error("ERR syntax error")
+			return
+		}
+	}
+	members, err := srv.store.ZRangeByScore(string(args[1]), minScore, maxScore, offset, count)
+	replyZMembers(sess.w, members, withScores, err)
+}
+
+func cmdZRem(srv *Server, sess *session, args [][]byte) {
+	removed, err := srv.store.ZRem(string(args[1]), stringArgs(args[2:])...)
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.integer(int64(removed))
+}
+
+func cmdZRemRangeByScore(srv *Server, sess *session, args [][]byte) {
+	minScore, maxScore, err := parseScoreRange(args[2], args[3])
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	removed, err := srv.store.ZRemRangeByScore(string(args[1]), minScore, maxScore)
+	if err != nil {
+		replyErr(sess.w, err)
+		return
+	}
+	sess.w.integer(int64(removed))
+}
+
+func replyZMembers(w *writer, members []utils.ZMember, withScores bool, err error) {
+	if err != nil {
+		replyErr(w, err)
+		return
+	}
+	if !withScores {
+		w.array(len(members))
+		for _, m := range members {
+			w.bulkString(m.Member)
+		}
+		return
+	}
+	w.array(2 * len(members))
+	for _, m := range members {
+		w.bulkString(m.Member)
+		w.bulkString(formatScore(m.Score))
+	}
+}
+
+// parseScore parses a score, which may be infinite but not NaN.
+func parseScore(b []byte) (float64, error) {
+	f, err := strconv.ParseFloat(string(b), 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, errNotFloat
+	}
+	return f, nil
+}
+
+// parseScoreRange parses the bounds of a score range. A bound starting with
+// "(" is exclusive.
+func parseScoreRange(minArg, maxArg []byte) (float64, float64, error) {
+	minScore, err := parseScoreBound(minArg, math.Inf(1))
+	if err != nil {
+		return 0, 0, err
+	}
+	maxScore, err := parseScoreBound(maxArg, math.Inf(-1))
+	return minScore, maxScore, err
+}
+
+// parseScoreBound parses a bound. An exclusive one becomes the next float
+// toward inward, so that the range can include it.
+func parseScoreBound(b []byte, inward float64) (float64, error) {
+	exclusive := len(b) > 0 && b[0] == '('
+	if exclusive {
+		b = b[1:]
+	}
+	f, err := parseScore(b)
+	if err != nil {
+		return 0, errScoreRange
+	}
+	if exclusive {
+		f = math.Nextafter(f, inward)
+	}
+	return f, nil
+}
+
+func formatScore(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,123 @@
+package snapshot
+
+import (
+	"bufio"
+	"os"
+	"sync"
+
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/persist"
+)
+
+// FilePersister keeps the cache in a single snapshot file. The file is always
+// written whole, to a temporary file that is renamed into place, so a crash
+// during a save leaves the previous snapshot intact.
+//
+// The format has no incremental updates: SaveDelta reads the current file,
+// applies the changes and writes it back. Prefer full syncs, for example by
+// disabling config.SaveDirtyData, when the cache is large.
+type FilePersister struct {
+	path        string
+	compression Compression
+	mux         sync.Mutex
+}
+
+var _ persist.Persister = (*FilePersister)(nil)
+
+func NewFilePersister(path string, compression Compression) (*FilePersister, error) {
+	if path == "" {
+		return nil, errors.ErrFileNameEmpty
+	}
+	if compression > Gzip {
+		return nil, errors.ErrSnapshotCompression(uint8(compression))
+	}
+	return &FilePersister{
+		path:        path,
+		compression: compression,
+	}, nil
+}
+
+func (p *FilePersister) Load() (map[string]entry.Entry, uint64, error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.unsafeLoad()
+}
+
+func (p *FilePersister) unsafeLoad() (map[string]entry.Entry, uint64, error) {
+	f, err := os.Open(p.path)
+	if os.IsNotExist(err) {
+		return map[string]entry.Entry{}, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	data, version, err := Read(bufio.NewReader(f))
+	if err != nil {
+		return nil, 0, err
+	}
+	for key, e := range data {
+		if e.IsExpired() {
+			delete(data, key)
+		}
+	}
+	return data, version, nil
+}
+
+func (p *FilePersister) SaveFull(data map[string]entry.Entry, version uint64) error {
+	if !p.mux.TryLock() {
+		return errors.ErrAlreadySave
+	}
+	defer p.mux.Unlock()
+	return p.unsafeSave(data, version)
+}
+
+func (p *FilePersister) SaveDelta(set map[string]entry.Entry, deleted []string, version uint64) error {
+	if len(set) == 0 && len(deleted) == 0 {
+		return nil
+	}
+	if !p.mux.TryLock() {
+		return errors.ErrAlreadySave
+	}
+	defer p.mux.Unlock()
+
+	data, saved, err := p.unsafeLoad()
+	if err != nil {
+		return err
+	}
+	for key, e := range set {
+		data[key] = e
+	}
+	for _, key := range deleted {
+		delete(data, key)
+	}
+	return p.unsafeSave(data, max(version, saved))
+}
+
+func (p *FilePersister) unsafeSave(data map[string]entry.Entry, version uint64) error {
+	tmpPath := p.path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	if err := Write(f, data, version, p.compression); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, p.path)
+}
+
+func (p *FilePersister) Close() error {
+	return nil
+}
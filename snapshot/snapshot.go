@@ -0,0 +1,268 @@
+// Package snapshot implements a compact single-file binary format for the
+// contents of a CacheStore.
+//
+// A snapshot starts with a header that is never compressed:
+//
+//	magic "CSNP" | format version (1 byte) | compression (1 byte)
+//
+// followed by the body, compressed when the header says so:
+//
+//	store version (uvarint)
+//	records: 0x01 | key length (uvarint) | key | data type (1 byte) |
+//	         expiry (varint) | soft expiry (varint) | version (uvarint) |
+//	         data length (uvarint) | data
+//	0xFF end marker
+//	CRC-32 (IEEE, big endian) of the header and the uncompressed body
+//
+// Nothing is returned from Read unless the checksum matches, so truncated or
+// corrupted files never load partially.
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+const (
+	magic         = "CSNP"
+	formatVersion = 1
+
+	recordEntry = 0x01
+	recordEnd   = 0xFF
+)
+
+type Compression uint8
+
+const (
+	NoCompression Compression = iota
+	Gzip
+)
+
+type writer struct {
+	w   io.Writer
+	crc hash.Hash32
+	buf []byte
+}
+
+func (w *writer) write(p []byte) error {
+	w.crc.Write(p)
+	_, err := w.w.Write(p)
+	return err
+}
+
+// Write encodes data and the store version counter to w.
+func Write(w io.Writer, data map[string]entry.Entry, version uint64, compression Compression) error {
+	if compression > Gzip {
+		return errors.ErrSnapshotCompression(uint8(compression))
+	}
+	crc := crc32.NewIEEE()
+	header := append([]byte(magic), formatVersion, byte(compression))
+	crc.Write(header)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	var body io.Writer
+	var zw *gzip.Writer
+	bw := bufio.NewWriter(w)
+	if compression == Gzip {
+		zw = gzip.NewWriter(bw)
+		body = zw
+	} else {
+		body = bw
+	}
+
+	sw := &writer{w: body, crc: crc}
+	sw.buf = binary.AppendUvarint(sw.buf[:0], version)
+	if err := sw.write(sw.buf); err != nil {
+		return err
+	}
+	for key, e := range data {
+		b := append(sw.buf[:0], recordEntry)
+		b = binary.AppendUvarint(b, uint64(len(key)))
+		b = append(b, key...)
+		b = append(b, byte(e.Type))
+		b = binary.AppendVarint(b, e.Expiry)
+		b = binary.AppendVarint(b, e.SoftExpiry)
+		b = binary.AppendUvarint(b, e.Version)
+		b = binary.AppendUvarint(b, uint64(len(e.Data)))
+		sw.buf = b
+		if err := sw.write(b); err != nil {
+			return err
+		}
+		if err := sw.write(e.Data); err != nil {
+			return err
+		}
+	}
+	if err := sw.write([]byte{recordEnd}); err != nil {
+		return err
+	}
+	if _, err := body.Write(binary.BigEndian.AppendUint32(nil, crc.Sum32())); err != nil {
+		return err
+	}
+
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+type reader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+}
+
+func (r *reader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.crc.Write([]byte{b})
+	}
+	return b, err
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.crc.Write(p[:n])
+	return n, err
+}
+
+func (r *reader) uvarint() (uint64, error) {
+	v, err := binary.ReadUvarint(r)
+	return v, truncated(err)
+}
+
+func (r *reader) varint() (int64, error) {
+	v, err := binary.ReadVarint(r)
+	return v, truncated(err)
+}
+
+// bytes reads n bytes without trusting n for the allocation, so a corrupted
+// length cannot exhaust memory.
+func (r *reader) bytes(n uint64) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := io.CopyN(&buf, r, int64(n)); err != nil {
+		return nil, truncated(err)
+	}
+	return buf.Bytes(), nil
+}
+
+func truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errors.ErrSnapshotTruncated
+	}
+	return err
+}
+
+// Read decodes a snapshot written by Write and returns its entries and store
+// version counter. Expired entries are kept; callers decide what to do with
+// them.
+func Read(r io.Reader) (map[string]entry.Entry, uint64, error) {
+	crc := crc32.NewIEEE()
+	header := make([]byte, len(magic)+2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, 0, errors.ErrSnapshotMagic
+		}
+		return nil, 0, err
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, 0, errors.ErrSnapshotMagic
+	}
+	if header[len(magic)] != formatVersion {
+		return nil, 0, errors.ErrSnapshotVersion(header[len(magic)])
+	}
+	crc.Write(header)
+
+	var body io.Reader = r
+	compression := Compression(header[len(magic)+1])
+	switch compression {
+	case NoCompression:
+	case Gzip:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, 0, truncated(err)
+		}
+		defer zr.Close()
+		body = zr
+	default:
+		return nil, 0, errors.ErrSnapshotCompression(header[len(magic)+1])
+	}
+
+	sr := &reader{r: bufio.NewReader(body), crc: crc}
+	version, err := sr.uvarint()
+	if err != nil {
+		return nil, 0, err
+	}
+	data := make(map[string]entry.Entry)
+	for {
+		kind, err := sr.ReadByte()
+		if err != nil {
+			return nil, 0, truncated(err)
+		}
+		if kind == recordEnd {
+			break
+		}
+		if kind != recordEntry {
+			return nil, 0, errors.ErrSnapshotCorrupt
+		}
+
+		keyLen, err := sr.uvarint()
+		if err != nil {
+			return nil, 0, err
+		}
+		key, err := sr.bytes(keyLen)
+		if err != nil {
+			return nil, 0, err
+		}
+		var e entry.Entry
+		dataType, err := sr.ReadByte()
+		if err != nil {
+			return nil, 0, truncated(err)
+		}
+		e.Type = types.DataType(dataType)
+		if e.Expiry, err = sr.varint(); err != nil {
+			return nil, 0, err
+		}
+		if e.SoftExpiry, err = sr.varint(); err != nil {
+			return nil, 0, err
+		}
+		if e.Version, err = sr.uvarint(); err != nil {
+			return nil, 0, err
+		}
+		dataLen, err := sr.uvarint()
+		if err != nil {
+			return nil, 0, err
+		}
+		if e.Data, err = sr.bytes(dataLen); err != nil {
+			return nil, 0, err
+		}
+		data[string(key)] = e
+	}
+
+	sum := crc.Sum32()
+	trailer := make([]byte, 4)
+	if _, err := io.ReadFull(sr.r, trailer); err != nil {
+		return nil, 0, truncated(err)
+	}
+	if binary.BigEndian.Uint32(trailer) != sum {
+		return nil, 0, errors.ErrSnapshotChecksum
+	}
+	if compression == Gzip {
+		// Reach the end of the gzip stream so its own footer is verified too.
+		if _, err := io.Copy(io.Discard, sr.r); err != nil {
+			return nil, 0, truncated(err)
+		}
+	}
+	return data, version, nil
+}
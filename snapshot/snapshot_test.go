@@ -0,0 +1,108 @@
+package snapshot
+
+import (
+	"bytes"
+	goerrors "errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func testData() map[string]entry.Entry {
+	return map[string]entry.Entry{
+		"str":   {Type: types.STRING, Data: []byte("hello"), Version: 3},
+		"ttl":   {Type: types.INT64, Data: []byte{0, 0, 0, 0, 0, 0, 0, 42}, Expiry: time.Now().Add(time.Hour).UnixMilli(), SoftExpiry: time.Now().Add(time.Minute).UnixMilli(), Version: 7},
+		"empty": {Type: types.RAW, Data: []byte{}, Version: 9},
+	}
+}
+
+func TestWriteRead_RoundTrip(t *testing.T) {
+	want := testData()
+	for _, compression := range []Compression{NoCompression, Gzip} {
+		var buf bytes.Buffer
+		if err := Write(&buf, want, 10, compression); err != nil {
+			t.Fatalf("Write(%d) error = %v", compression, err)
+		}
+		data, version, err := Read(&buf)
+		if err != nil {
+			t.Fatalf("Read(%d) error = %v", compression, err)
+		}
+		if version != 10 {
+			t.Errorf("version = %d, want 10", version)
+		}
+		for key, want := range want {
+			got, ok := data[key]
+			if !ok || got.Type != want.Type || !bytes.Equal(got.Data, want.Data) ||
+				got.Expiry != want.Expiry || got.SoftExpiry != want.SoftExpiry || got.Version != want.Version {
+				t.Errorf("compression %d: %q = %+v, want %+v", compression, key, got, want)
+			}
+		}
+	}
+}
+
+func TestRead_DetectsDamage(t *testing.T) {
+	for _, compression := range []Compression{NoCompression, Gzip} {
+		var buf bytes.Buffer
+		Write(&buf, testData(), 10, compression)
+		raw := buf.Bytes()
+
+		for _, n := range []int{0, 3, 6, len(raw) / 2, len(raw) - 1} {
+			if _, _, err := Read(bytes.NewReader(raw[:n])); err == nil {
+				t.Errorf("compression %d: Read() of %d/%d bytes should fail", compression, n, len(raw))
+			}
+		}
+
+		if compression == NoCompression {
+			damaged := bytes.Clone(raw)
+			damaged[bytes.Index(damaged, []byte("hello"))] ^= 0xFF
+			if _, _, err := Read(bytes.NewReader(damaged)); !goerrors.Is(err, errors.ErrSnapshotChecksum) {
+				t.Errorf("Read() of flipped byte error = %v, want ErrSnapshotChecksum", err)
+			}
+		}
+	}
+
+	if _, _, err := Read(bytes.NewReader([]byte("SQLite format 3"))); !goerrors.Is(err, errors.ErrSnapshotMagic) {
+		t.Errorf("Read() of foreign file error = %v, want ErrSnapshotMagic", err)
+	}
+}
+
+func TestFilePersister(t *testing.T) {
+	p, err := NewFilePersister(filepath.Join(t.TempDir(), "cache.snap"), Gzip)
+	if err != nil {
+		t.Fatalf("NewFilePersister() error = %v", err)
+	}
+	data, version, err := p.Load()
+	if err != nil || len(data) != 0 || version != 0 {
+		t.Fatalf("Load() of missing file = %v, %d, %v", data, version, err)
+	}
+
+	full := testData()
+	full["expired"] = entry.Entry{Type: types.STRING, Data: []byte("x"), Expiry: time.Now().Add(-time.Second).UnixMilli()}
+	if err := p.SaveFull(full, 10); err != nil {
+		t.Fatalf("SaveFull() error = %v", err)
+	}
+	if err := p.SaveDelta(map[string]entry.Entry{"new": {Type: types.STRING, Data: []byte("v")}}, []string{"str"}, 12); err != nil {
+		t.Fatalf("SaveDelta() error = %v", err)
+	}
+
+	data, version, err = p.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if version != 12 {
+		t.Errorf("version = %d, want 12", version)
+	}
+	if _, ok := data["str"]; ok {
+		t.Error("str should be deleted by SaveDelta")
+	}
+	if _, ok := data["expired"]; ok {
+		t.Error("expired entries should not be loaded")
+	}
+	if len(data) != 3 {
+		t.Errorf("Load() returned %d entries, want 3", len(data))
+	}
+}
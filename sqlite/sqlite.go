@@ -8,6 +8,7 @@ import (
 
 	"github.com/found-cake/CacheStore/entry"
 	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/persist"
 	"github.com/found-cake/CacheStore/utils/types"
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -17,6 +18,8 @@ type SqliteStore struct {
 	mux sync.Mutex
 }
 
+var _ persist.Persister = (*SqliteStore)(nil)
+
 func initDB(filename string) (*sql.DB, error) {
 	if filename == "" {
 		return nil, errors.ErrFileNameEmpty
@@ -33,7 +36,22 @@ func initDB(filename string) (*sql.DB, error) {
 		key TEXT PRIMARY KEY,
 		data_type INTEGER,
 		data BLOB,
-		expiry INTEGER
+		expiry INTEGER,
+		soft_expiry INTEGER NOT NULL DEFAULT 0,
+		version INTEGER NOT NULL DEFAULT 0
+	)`)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureColumn(db, "soft_expiry", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	if err := ensureColumn(db, "version", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return nil, err
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS cache_meta (
+		name TEXT PRIMARY KEY,
+		value INTEGER
 	)`)
 	if err != nil {
 		return nil, err
@@ -42,6 +60,35 @@ func initDB(filename string) (*sql.DB, error) {
 	return db, nil
 }
 
+// ensureColumn adds a column to cache_data when the database was created by an
+// older version that did not have it yet.
+func ensureColumn(db *sql.DB, name, definition string) error {
+	rows, err := db.Query("PRAGMA table_info(cache_data)")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notnull, pk int
+		var column, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &column, &columnType, &notnull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if column == name {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = db.Exec("ALTER TABLE cache_data ADD COLUMN " + name + " " + definition)
+	return err
+}
+
 func NewSqliteStore(filename string) (*SqliteStore, error) {
 	db, err := initDB(filename)
 	if err != nil {
@@ -57,7 +104,7 @@ func (s *SqliteStore) LoadFromDB() (map[string]entry.Entry, error) {
 		return nil, errors.ErrDBNotInit
 	}
 
-	rows, err := s.db.Query("SELECT key, data_type, data, expiry FROM cache_data")
+	rows, err := s.db.Query("SELECT key, data_type, data, expiry, soft_expiry, version FROM cache_data")
 	if err != nil {
 		return nil, err
 	}
@@ -70,8 +117,10 @@ func (s *SqliteStore) LoadFromDB() (map[string]entry.Entry, error) {
 		var dataType types.DataType
 		var data []byte
 		var expiry int64
+		var softExpiry int64
+		var version uint64
 
-		if err := rows.Scan(&key, &dataType, &data, &expiry); err != nil {
+		if err := rows.Scan(&key, &dataType, &data, &expiry, &softExpiry, &version); err != nil {
 			log.Println(err)
 			continue
 		}
@@ -80,10 +129,16 @@ func (s *SqliteStore) LoadFromDB() (map[string]entry.Entry, error) {
 			continue
 		}
 
+		if !dataType.IsKnown() {
+			log.Printf("key '%s' has unregistered data type %d; register it before loading to decode it", key, dataType)
+		}
+
 		dbData[key] = entry.Entry{
-			Type:   dataType,
-			Data:   data,
-			Expiry: expiry,
+			Type:       dataType,
+			Data:       data,
+			Expiry:     expiry,
+			SoftExpiry: softExpiry,
+			Version:    version,
 		}
 	}
 
@@ -112,12 +167,14 @@ func (s *SqliteStore) SaveDirtyData(set_dirtys map[string]entry.Entry, delete_di
 	defer tx.Rollback()
 
 	insertStmt, err := tx.Prepare(`
-		INSERT INTO cache_data (key, data_type, data, expiry) 
-		VALUES (?, ?, ?, ?)
+		INSERT INTO cache_data (key, data_type, data, expiry, soft_expiry, version) 
+		VALUES (?, ?, ?, ?, ?, ?)
 		ON CONFLICT(key) DO UPDATE SET
 			data_type = excluded.data_type,
 			data = excluded.data,
-			expiry = excluded.expiry
+			expiry = excluded.expiry,
+			soft_expiry = excluded.soft_expiry,
+			version = excluded.version
 	`)
 	if err != nil {
 		return err
@@ -137,7 +194,7 @@ func (s *SqliteStore) SaveDirtyData(set_dirtys map[string]entry.Entry, delete_di
 			continue
 		}
 
-		if _, err := insertStmt.Exec(key, entry.Type, entry.Data, entry.Expiry); err != nil {
+		if _, err := insertStmt.Exec(key, entry.Type, entry.Data, entry.Expiry, entry.SoftExpiry, entry.Version); err != nil {
 			return err
 		}
 	}
@@ -173,7 +230,7 @@ func (s *SqliteStore) Save(data map[string]entry.Entry, force bool) error {
 	if _, err := tx.Exec("DELETE FROM cache_data"); err != nil {
 		return err
 	}
-	stmt, err := tx.Prepare("INSERT INTO cache_data (key, data_type, data, expiry) VALUES (?, ?, ?, ?)")
+	stmt, err := tx.Prepare("INSERT INTO cache_data (key, data_type, data, expiry, soft_expiry, version) VALUES (?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return err
 	}
@@ -186,7 +243,7 @@ func (s *SqliteStore) Save(data map[string]entry.Entry, force bool) error {
 			continue
 		}
 
-		if _, err := stmt.Exec(key, entry.Type, entry.Data, entry.Expiry); err != nil {
+		if _, err := stmt.Exec(key, entry.Type, entry.Data, entry.Expiry, entry.SoftExpiry, entry.Version); err != nil {
 			return err
 		}
 	}
@@ -194,6 +251,178 @@ func (s *SqliteStore) Save(data map[string]entry.Entry, force bool) error {
 	return tx.Commit()
 }
 
+// LoadVersion returns the last saved version counter of the cache, or 0 when
+// none was saved yet.
+func (s *SqliteStore) LoadVersion() (uint64, error) {
+	if s.db == nil {
+		return 0, errors.ErrDBNotInit
+	}
+	var version uint64
+	err := s.db.QueryRow("SELECT value FROM cache_meta WHERE name = 'version'").Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return version, err
+}
+
+// SaveVersion stores the version counter of the cache. The stored value never
+// decreases.
+func (s *SqliteStore) SaveVersion(version uint64) error {
+	if s.db == nil {
+		return errors.ErrDBNotInit
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO cache_meta (name, value) VALUES ('version', ?)
+		ON CONFLICT(name) DO UPDATE SET value = MAX(value, excluded.value)
+	`, version)
+	return err
+}
+
+// Load implements persist.Persister.
+func (s *SqliteStore) Load() (map[string]entry.Entry, uint64, error) {
+	data, err := s.LoadFromDB()
+	if err != nil {
+		return nil, 0, err
+	}
+	version, err := s.LoadVersion()
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, version, nil
+}
+
+// SaveFull implements persist.Persister. It fails with errors.ErrAlreadySave
+// while another save is running.
+func (s *SqliteStore) SaveFull(data map[string]entry.Entry, version uint64) error {
+	if err := s.Save(data, false); err != nil {
+		return err
+	}
+	return s.SaveVersion(version)
+}
+
+// SaveDelta implements persist.Persister. It fails with errors.ErrAlreadySave
+// while another save is running.
+func (s *SqliteStore) SaveDelta(set map[string]entry.Entry, deleted []string, version uint64) error {
+	if err := s.SaveDirtyData(set, deleted); err != nil {
+		return err
+	}
+	return s.SaveVersion(version)
+}
+
+// Get returns the stored entry of key, expired or not.
+func (s *SqliteStore) Get(key string) (entry.Entry, bool, error) {
+	if s.db == nil {
+		return entry.Entry{}, false, errors.ErrDBNotInit
+	}
+	var e entry.Entry
+	err := s.db.QueryRow("SELECT data_type, data, expiry, soft_expiry, version FROM cache_data WHERE key = ?", key).
+		Scan(&e.Type, &e.Data, &e.Expiry, &e.SoftExpiry, &e.Version)
+	if err == sql.ErrNoRows {
+		return entry.Entry{}, false, nil
+	}
+	if err != nil {
+		return entry.Entry{}, false, err
+	}
+	return e, true, nil
+}
+
+// Keys returns the sorted keys of the live entries matching the glob pattern
+// (SQLite GLOB syntax: *, ? and [...]). An empty pattern matches every key.
+func (s *SqliteStore) Keys(pattern string) ([]string, error) {
+	if s.db == nil {
+		return nil, errors.ErrDBNotInit
+	}
+	if pattern == "" {
+		pattern = "*"
+	}
+	rows, err := s.db.Query("SELECT key FROM cache_data WHERE key GLOB ? AND (expiry = 0 OR expiry > ?) ORDER BY key",
+		pattern, time.Now().UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Stats summarizes the content of the database file.
+type Stats struct {
+	Keys    int64                    // live entries
+	Expired int64                    // expired entries not purged yet
+	Bytes   int64                    // total size of live keys and values
+	ByType  map[types.DataType]int64 // live entries per data type
+	Version uint64                   // saved version counter
+}
+
+func (s *SqliteStore) Stats() (Stats, error) {
+	if s.db == nil {
+		return Stats{}, errors.ErrDBNotInit
+	}
+	stats := Stats{ByType: make(map[types.DataType]int64)}
+	rows, err := s.db.Query(`
+		SELECT data_type, expiry > 0 AND expiry <= ?, COUNT(*), COALESCE(SUM(LENGTH(key) + LENGTH(data)), 0)
+		FROM cache_data GROUP BY 1, 2`, time.Now().UnixMilli())
+	if err != nil {
+		return Stats{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var dataType types.DataType
+		var expired bool
+		var count, size int64
+		if err := rows.Scan(&dataType, &expired, &count, &size); err != nil {
+			return Stats{}, err
+		}
+		if expired {
+			stats.Expired += count
+			continue
+		}
+		stats.Keys += count
+		stats.Bytes += size
+		stats.ByType[dataType] += count
+	}
+	if err := rows.Err(); err != nil {
+		return Stats{}, err
+	}
+	stats.Version, err = s.LoadVersion()
+	return stats, err
+}
+
+// PurgeExpired deletes the expired entries and returns how many were removed.
+func (s *SqliteStore) PurgeExpired() (int64, error) {
+	if s.db == nil {
+		return 0, errors.ErrDBNotInit
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	result, err := s.db.Exec("DELETE FROM cache_data WHERE expiry > 0 AND expiry <= ?", time.Now().UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Vacuum rebuilds the database file, returning the space of deleted entries
+// to the file system.
+func (s *SqliteStore) Vacuum() error {
+	if s.db == nil {
+		return errors.ErrDBNotInit
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	_, err := s.db.Exec("VACUUM")
+	return err
+}
+
 func (s *SqliteStore) Close() error {
 	if s.db == nil {
 		return errors.ErrDBNotInit
@@ -1,6 +1,7 @@
 package sqlite
 
 import (
+	"database/sql"
 	"path/filepath"
 	"testing"
 	"time"
@@ -185,3 +186,157 @@ func TestSqliteStore_Load_FilterExpired(t *testing.T) {
 		t.Errorf("expected foo to be delete, got %v", got)
 	}
 }
+
+func TestSqliteStore_MigrateOldSchema(t *testing.T) {
+	dbfile := tempDBFile(t)
+	db, err := sql.Open("sqlite3", dbfile)
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	_, err = db.Exec(`CREATE TABLE cache_data (
+		key TEXT PRIMARY KEY,
+		data_type INTEGER,
+		data BLOB,
+		expiry INTEGER
+	)`)
+	if err != nil {
+		t.Fatalf("failed to create old schema: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO cache_data VALUES ('old', ?, 'value', 0)", types.STRING); err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+	db.Close()
+
+	store, err := NewSqliteStore(dbfile)
+	if err != nil {
+		t.Fatalf("failed to open old schema: %v", err)
+	}
+	defer store.Close()
+
+	loaded, err := store.LoadFromDB()
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if got, ok := loaded["old"]; !ok || string(got.Data) != "value" || got.SoftExpiry != 0 {
+		t.Errorf("expected migrated row, got %v", got)
+	}
+}
+
+func TestSqliteStore_SoftExpiry(t *testing.T) {
+	store, err := NewSqliteStore(tempDBFile(t))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	data := map[string]entry.Entry{
+		"full": entry.NewSoftEntry(types.RAW, []byte("a"), time.Minute, time.Hour),
+	}
+	if err := store.Save(data, true); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	dirty := map[string]entry.Entry{
+		"dirty": entry.NewSoftEntry(types.RAW, []byte("b"), time.Minute, 0),
+	}
+	if err := store.SaveDirtyData(dirty, nil); err != nil {
+		t.Fatalf("SaveDirtyData failed: %v", err)
+	}
+
+	loaded, err := store.LoadFromDB()
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if got := loaded["full"]; got.SoftExpiry != data["full"].SoftExpiry {
+		t.Errorf("full soft expiry = %d, want %d", got.SoftExpiry, data["full"].SoftExpiry)
+	}
+	if got := loaded["dirty"]; got.SoftExpiry != dirty["dirty"].SoftExpiry {
+		t.Errorf("dirty soft expiry = %d, want %d", got.SoftExpiry, dirty["dirty"].SoftExpiry)
+	}
+}
+
+func TestSqliteStore_Version(t *testing.T) {
+	store, err := NewSqliteStore(tempDBFile(t))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if v, err := store.LoadVersion(); err != nil || v != 0 {
+		t.Errorf("LoadVersion() = %d, %v, want 0", v, err)
+	}
+	store.SaveVersion(10)
+	store.SaveVersion(5)
+	if v, err := store.LoadVersion(); err != nil || v != 10 {
+		t.Errorf("LoadVersion() = %d, %v, want 10", v, err)
+	}
+
+	e := entry.NewEntry(types.RAW, []byte("v"), 0)
+	e.Version = 7
+	store.Save(map[string]entry.Entry{"k": e}, true)
+	loaded, err := store.LoadFromDB()
+	if err != nil {
+		t.Fatalf("load error: %v", err)
+	}
+	if loaded["k"].Version != 7 {
+		t.Errorf("loaded version = %d, want 7", loaded["k"].Version)
+	}
+}
+
+func TestSqliteStore_Inspect(t *testing.T) {
+	store, err := NewSqliteStore(tempDBFile(t))
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	expired := entry.NewEntry(types.STRING, []byte("old"), time.Hour)
+	expired.Expiry = time.Now().Add(-time.Minute).UnixMilli()
+	data := map[string]entry.Entry{
+		"user:1": entry.NewEntry(types.STRING, []byte("alice"), 0),
+		"user:2": entry.NewEntry(types.STRING, []byte("bob"), time.Hour),
+		"count":  entry.NewEntry(types.INT64, make([]byte, 8), 0),
+	}
+	if err := store.Save(data, true); err != nil {
+		t.Fatalf("save error: %v", err)
+	}
+	if _, err := store.db.Exec("INSERT INTO cache_data (key, data_type, data, expiry) VALUES (?, ?, ?, ?)",
+		"user:3", expired.Type, expired.Data, expired.Expiry); err != nil {
+		t.Fatalf("insert error: %v", err)
+	}
+
+	if e, ok, err := store.Get("user:1"); err != nil || !ok || string(e.Data) != "alice" {
+		t.Errorf("Get(user:1) = %+v, %v, %v", e, ok, err)
+	}
+	if _, ok, err := store.Get("user:3"); err != nil || !ok {
+		t.Errorf("Get() should return expired entries, got %v, %v", ok, err)
+	}
+	if _, ok, err := store.Get("missing"); err != nil || ok {
+		t.Errorf("Get(missing) = %v, %v, want not found", ok, err)
+	}
+
+	keys, err := store.Keys("user:*")
+	if err != nil || len(keys) != 2 || keys[0] != "user:1" || keys[1] != "user:2" {
+		t.Errorf("Keys(user:*) = %v, %v, want [user:1 user:2]", keys, err)
+	}
+	if keys, _ := store.Keys(""); len(keys) != 3 {
+		t.Errorf("Keys(\"\") = %v, want 3 live keys", keys)
+	}
+
+	stats, err := store.Stats()
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+	if stats.Keys != 3 || stats.Expired != 1 || stats.ByType[types.STRING] != 2 || stats.ByType[types.INT64] != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	if n, err := store.PurgeExpired(); err != nil || n != 1 {
+		t.Errorf("PurgeExpired() = %d, %v, want 1", n, err)
+	}
+	if _, ok, _ := store.Get("user:3"); ok {
+		t.Error("expired entry should be purged")
+	}
+	if err := store.Vacuum(); err != nil {
+		t.Errorf("Vacuum() error = %v", err)
+	}
+}
@@ -0,0 +1,428 @@
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sync"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/utils"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+const aofHeaderSize = 8
+
+// aofLog is the append-only log of the changes applied since the last full
+// save. Every record is
+//
+//	payload length (uint32) | CRC-32 of payload (uint32) | payload
+//
+// where the payload holds the op kind, the key and, for sets, the entry. A
+// change to the members of a sorted set holds the entry without its data,
+// followed by the members set and their scores, then the members removed. A
+// change to a list holds the entry without its data, followed by the element
+// replaced, if any, the counts popped from the head and the tail, then the
+// elements pushed at the head and at the tail.
+type aofLog struct {
+	mux     sync.Mutex
+	path    string
+	file    *os.File
+	policy  config.AOFSyncPolicy
+	size    int64
+	gen     uint64
+	pending bool
+	buf     []byte
+}
+
+// aofMark is a position in the log. It is invalidated by compaction.
+type aofMark struct {
+	gen    uint64
+	offset int64
+}
+
+func openAOF(path string, policy config.AOFSyncPolicy) (*aofLog, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &aofLog{
+		path:   path,
+		file:   file,
+		policy: policy,
+	}, nil
+}
+
+func encodeOp(buf []byte, o op) []byte {
+	start := len(buf)
+	buf = append(buf, make([]byte, aofHeaderSize)...)
+	buf = append(buf, byte(o.kind))
+	buf = binary.AppendUvarint(buf, uint64(len(o.key)))
+	buf = append(buf, o.key...)
+	if o.kind == opSet || o.kind == opZSet || o.kind == opList {
+		buf = append(buf, byte(o.entry.Type))
+		buf = binary.AppendVarint(buf, o.entry.Expiry)
+		buf = binary.AppendVarint(buf, o.entry.SoftExpiry)
+		buf = binary.AppendUvarint(buf, o.entry.Version)
+	}
+	switch o.kind {
+	case opSet:
+		buf = binary.AppendUvarint(buf, uint64(len(o.entry.Data)))
+		buf = append(buf, o.entry.Data...)
+	case opZSet:
+		buf = binary.AppendUvarint(buf, uint64(len(o.zadd)))
+		for _, m := range o.zadd {
+			buf = binary.AppendUvarint(buf, uint64(len(m.Member)))
+			buf = append(buf, m.Member...)
+			buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(m.Score))
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(o.zrem)))
+		for _, member := range o.zrem {
+			buf = binary.AppendUvarint(buf, uint64(len(member)))
+			buf = append(buf, member...)
+		}
+	case opList:
+		c := o.list
+		if c.value != nil {
+			buf = append(buf, 1)
+			buf = binary.AppendUvarint(buf, uint64(c.index))
+			buf = binary.AppendUvarint(buf, uint64(len(c.value)))
+			buf = append(buf, c.value...)
+		} else {
+			buf = append(buf, 0)
+		}
+		buf = binary.AppendUvarint(buf, uint64(c.lpop))
+		buf = binary.AppendUvarint(buf, uint64(c.rpop))
+		for _, elems := range [][][]byte{c.lpush, c.rpush} {
+			buf = binary.AppendUvarint(buf, uint64(len(elems)))
+			for _, elem := range elems {
+				buf = binary.AppendUvarint(buf, uint64(len(elem)))
+				buf = append(buf, elem...)
+			}
+		}
+	}
+	payload := buf[start+aofHeaderSize:]
+	binary.BigEndian.PutUint32(buf[start:], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[start+4:], crc32.ChecksumIEEE(payload))
+	return buf
+}
+
+func decodeOp(payload []byte) (op, bool) {
+	var o op
+	if len(payload) == 0 {
+		return o, false
+	}
+	o.kind = opKind(payload[0])
+	p := payload[1:]
+	keyLen, n := binary.Uvarint(p)
+	if n <= 0 || uint64(len(p)-n) < keyLen {
+		return o, false
+	}
+	o.key = string(p[n : n+int(keyLen)])
+	p = p[n+int(keyLen):]
+
+	switch o.kind {
+	case opDelete, opFlush, opPing:
+		return o, len(p) == 0
+	case opSet, opZSet, opList:
+	default:
+		return o, false
+	}
+
+	if len(p) == 0 {
+		return o, false
+	}
+	o.entry.Type = types.DataType(p[0])
+	p = p[1:]
+	if o.entry.Expiry, n = binary.Varint(p); n <= 0 {
+		return o, false
+	}
+	p = p[n:]
+	if o.entry.SoftExpiry, n = binary.Varint(p); n <= 0 {
+		return o, false
+	}
+	p = p[n:]
+	if o.entry.Version, n = binary.Uvarint(p); n <= 0 {
+		return o, false
+	}
+	p = p[n:]
+	switch o.kind {
+	case opZSet:
+		return decodeZSetOp(o, p)
+	case opList:
+		return decodeListOp(o, p)
+	}
+	dataLen, n := binary.Uvarint(p)
+	if n <= 0 || uint64(len(p)-n) != dataLen {
+		return o, false
+	}
+	o.entry.Data = p[n:]
+	return o, true
+}
+
+// decodeZSetOp decodes the members of an opZSet from p, the rest of its
+// payload.
+func decodeZSetOp(o op, p []byte) (op, bool) {
+	member := func() (string, bool) {
+		size, n := binary.Uvarint(p)
+		if n <= 0 || uint64(len(p)-n) < size {
+			return "", false
+		}
+		m := string(p[n : n+int(size)])
+		p = p[n+int(size):]
+		return m, true
+	}
+	count, n := binary.Uvarint(p)
+	if n <= 0 || uint64(len(p)-n) < count {
+		return o, false
+	}
+	p = p[n:]
+	o.zadd = make([]utils.ZMember, count)
+	for i := range o.zadd {
+		m, ok := member()
+		if !ok || len(p) < 8 {
+			return o, false
+		}
+		o.zadd[i] = utils.ZMember{Member: m, Score: math.Float64frombits(binary.BigEndian.Uint64(p))}
+		p = p[8:]
+	}
+	if count, n = binary.Uvarint(p); n <= 0 || uint64(len(p)-n) < count {
+		return o, false
+	}
+	p = p[n:]
+	o.zrem = make([]string, count)
+	for i := range o.zrem {
+		m, ok := member()
+		if !ok {
+			return o, false
+		}
+		o.zrem[i] = m
+	}
+	return o, len(p) == 0
+}
+
+// decodeListOp decodes the change of an opList from p, the rest of its
+// payload.
+func decodeListOp(o op, p []byte) (op, bool) {
+	count := func() (int, bool) {
+		v, n := binary.Uvarint(p)
+		if n <= 0 || v > math.MaxInt32 {
+			return 0, false
+		}
+		p = p[n:]
+		return int(v), true
+	}
+	elem := func() ([]byte, bool) {
+		size, ok := count()
+		if !ok || len(p) < size {
+			return nil, false
+		}
+		e := p[:size:size]
+		p = p[size:]
+		return e, true
+	}
+	if len(p) == 0 || p[0] > 1 {
+		return o, false
+	}
+	replaced := p[0] == 1
+	p = p[1:]
+	var ok bool
+	if replaced {
+		if o.list.index, ok = count(); !ok {
+			return o, false
+		}
+		if o.list.value, ok = elem(); !ok {
+			return o, false
+		}
+	}
+	if o.list.lpop, ok = count(); !ok {
+		return o, false
+	}
+	if o.list.rpop, ok = count(); !ok {
+		return o, false
+	}
+	for _, elems := range []*[][]byte{&o.list.lpush, &o.list.rpush} {
+		n, ok := count()
+		if !ok || len(p) < n {
+			return o, false
+		}
+		*elems = make([][]byte, n)
+		for i := range *elems {
+			if (*elems)[i], ok = elem(); !ok {
+				return o, false
+			}
+		}
+	}
+	return o, len(p) == 0
+}
+
+// replay passes every record to fn. A torn or corrupted record ends the log:
+// it and everything after it is truncated, as it can only be the tail of a
+// write interrupted by a crash.
+func (l *aofLog) replay(fn func(op)) error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(l.file)
+	header := make([]byte, aofHeaderSize)
+	var offset int64
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				break
+			}
+			if err == io.ErrUnexpectedEOF {
+				log.Printf("aof: truncating torn record at offset %d", offset)
+				break
+			}
+			return err
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(header))
+		if _, err := io.ReadFull(r, payload); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				log.Printf("aof: truncating torn record at offset %d", offset)
+				break
+			}
+			return err
+		}
+		o, ok := decodeOp(payload)
+		if !ok || crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+			log.Printf("aof: truncating corrupted record at offset %d", offset)
+			break
+		}
+		fn(o)
+		offset += aofHeaderSize + int64(len(payload))
+	}
+
+	if err := l.file.Truncate(offset); err != nil {
+		return err
+	}
+	l.size = offset
+	return nil
+}
+
+func (l *aofLog) append(ops ...op) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.buf = l.buf[:0]
+	for _, o := range ops {
+		l.buf = encodeOp(l.buf, o)
+	}
+	if _, err := l.file.Write(l.buf); err != nil {
+		log.Println(err)
+		// Drop a partial record so that later ones are not appended after
+		// it, where replay would stop.
+		if err := l.file.Truncate(l.size); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+	l.size += int64(len(l.buf))
+	l.pending = true
+	if l.policy == config.AOFSyncAlways {
+		l.unsafeSync()
+	}
+}
+
+func (l *aofLog) unsafeSync() {
+	if !l.pending {
+		return
+	}
+	if err := l.file.Sync(); err != nil {
+		log.Println(err)
+		return
+	}
+	l.pending = false
+}
+
+// sync flushes the log to disk; used by the every-second policy.
+func (l *aofLog) sync() {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.unsafeSync()
+}
+
+func (l *aofLog) mark() aofMark {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return aofMark{gen: l.gen, offset: l.size}
+}
+
+// compact drops the records before m, which a full save has made redundant.
+// When the save did not go to a persister, snapshot is written in their place
+// so the log alone still holds the whole cache.
+func (l *aofLog) compact(m aofMark, snapshot map[string]entry.Entry) error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if m.gen != l.gen {
+		return nil
+	}
+
+	tmpPath := l.path + ".rewrite"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	w := bufio.NewWriter(tmp)
+	var buf []byte
+	for key, e := range snapshot {
+		buf = encodeOp(buf[:0], op{kind: opSet, key: key, entry: e})
+		if _, err := w.Write(buf); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if _, err := l.file.Seek(m.offset, io.SeekStart); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := io.Copy(w, l.file); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	size, err := tmp.Seek(0, io.SeekEnd)
+	tmp.Close()
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, l.path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	l.file.Close()
+	l.file = file
+	l.size = size
+	l.gen++
+	l.pending = false
+	return nil
+}
+
+func (l *aofLog) close() error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.unsafeSync()
+	return l.file.Close()
+}
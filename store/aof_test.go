@@ -0,0 +1,211 @@
+package store
+
+import (
+	goerrors "errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/persist"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// crash drops a store without the final save done by Close. The configs used
+// with it start no background goroutines.
+func crash(s *CacheStore) {
+	s.aof.close()
+	if s.persister != nil {
+		s.persister.Close()
+	}
+}
+
+func aofConfig(t *testing.T) config.Config {
+	t.Helper()
+	dir := t.TempDir()
+	return config.Config{
+		DBSave:      true,
+		DBFileName:  filepath.Join(dir, "cache.db"),
+		AOFFileName: filepath.Join(dir, "cache.aof"),
+		AOFSync:     config.AOFSyncAlways,
+	}
+}
+
+func TestAOF_ReplayAfterCrash(t *testing.T) {
+	cfg := aofConfig(t)
+	store, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	store.SetString("flushed", "x", 0)
+	store.Flush()
+	store.SetString("a", "1", 0)
+	store.SetString("b", "2", time.Hour)
+	store.IncrInt64("n", 5, 0)
+	store.IncrInt64("n", 2, 0)
+	store.MSet(
+		NewItem("c", types.STRING, []byte("3"), 0),
+		NewItem("d", types.STRING, []byte("4"), 0),
+	)
+	store.MDelete("c")
+	store.Delete("a")
+	store.Update(func(tx *Tx) error {
+		return tx.Set("e", types.STRING, []byte("5"), 0)
+	})
+	_, _, version, _ := store.GetWithVersion("e")
+	crash(store)
+
+	store, err = NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to re-create store: %v", err)
+	}
+	defer store.Close()
+
+	if n := store.Exists("flushed", "a", "c"); n != 0 {
+		t.Errorf("%d deleted keys came back", n)
+	}
+	for key, want := range map[string]string{"b": "2", "d": "4", "e": "5"} {
+		if v, err := store.GetString(key); err != nil || v != want {
+			t.Errorf("GetString(%q) = %q, %v, want %q", key, v, err, want)
+		}
+	}
+	if n, _ := store.GetInt64("n"); n != 7 {
+		t.Errorf("n = %d, want 7", n)
+	}
+	if ttl := store.TTL("b"); ttl <= 0 {
+		t.Errorf("TTL(b) = %v, want the replayed expiry", ttl)
+	}
+	if v, _ := store.CompareAndSet("e", version, types.STRING, []byte("6"), 0); v <= version {
+		t.Errorf("version after replay = %d, want > %d", v, version)
+	}
+}
+
+func TestAOF_TruncatesTornTail(t *testing.T) {
+	cfg := aofConfig(t)
+	store, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	store.SetString("a", "1", 0)
+	store.SetString("b", "2", 0)
+	size := store.aof.mark().offset
+	crash(store)
+
+	f, err := os.OpenFile(cfg.AOFFileName, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 0, 40, 1, 2})
+	f.Close()
+
+	store, err = NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to re-create store: %v", err)
+	}
+	defer store.Close()
+	if store.Exists("a", "b") != 2 {
+		t.Error("records before the torn tail should be replayed")
+	}
+	if got := store.aof.mark().offset; got != size {
+		t.Errorf("log size after truncation = %d, want %d", got, size)
+	}
+}
+
+func TestAOF_CompactsAfterFullSync(t *testing.T) {
+	cfg := aofConfig(t)
+	store, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	store.SetString("a", "1", 0)
+	store.SetString("b", "2", 0)
+	store.FullSync()
+	waitFor(t, time.Second, func() bool { return store.aof.mark().offset == 0 })
+
+	store.SetString("c", "3", 0)
+	crash(store)
+
+	store, err = NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to re-create store: %v", err)
+	}
+	if store.Exists("a", "b", "c") != 3 {
+		t.Errorf("keys = %v, want a, b and c", store.Keys())
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if info, err := os.Stat(cfg.AOFFileName); err != nil || info.Size() != 0 {
+		t.Errorf("log should be empty after Close saved everything: %v, %v", info, err)
+	}
+}
+
+func TestAOF_WithoutPersister(t *testing.T) {
+	cfg := config.Config{
+		AOFFileName: filepath.Join(t.TempDir(), "cache.aof"),
+		AOFSync:     config.AOFSyncNever,
+	}
+	store, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		store.IncrInt64("n", 1, 0)
+	}
+	before := store.aof.mark().offset
+	store.FullSync()
+	waitFor(t, time.Second, func() bool { return store.aof.mark().offset < before })
+	crash(store)
+
+	store, err = NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to re-create store: %v", err)
+	}
+	defer store.Close()
+	if n, _ := store.GetInt64("n"); n != 10 {
+		t.Errorf("n = %d, want 10", n)
+	}
+}
+
+func TestAOF_ReplaysOnTopOfSnapshot(t *testing.T) {
+	mem := persist.NewMemory()
+	cfg := config.Config{
+		Persister:   mem,
+		AOFFileName: filepath.Join(t.TempDir(), "cache.aof"),
+		AOFSync:     config.AOFSyncAlways,
+	}
+	store, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	store.SetString("a", "old", 0)
+	store.SetString("b", "kept", 0)
+	store.FullSync()
+	waitFor(t, time.Second, func() bool { return mem.Len() == 2 && store.aof.mark().offset == 0 })
+	store.SetString("a", "new", 0)
+	crash(store)
+
+	store, err = NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to re-create store: %v", err)
+	}
+	defer store.Close()
+	if v, _ := store.GetString("a"); v != "new" {
+		t.Errorf("a = %q, want %q", v, "new")
+	}
+	if v, _ := store.GetString("b"); v != "kept" {
+		t.Errorf("b = %q, want %q", v, "kept")
+	}
+}
+
+func TestAOF_InvalidSyncPolicy(t *testing.T) {
+	_, err := NewCacheStore(config.Config{
+		AOFFileName: filepath.Join(t.TempDir(), "cache.aof"),
+		AOFSync:     config.AOFSyncNever + 1,
+	})
+	if !goerrors.Is(err, errors.ErrAOFSyncPolicy) {
+		t.Errorf("NewCacheStore() error = %v, want ErrAOFSyncPolicy", err)
+	}
+}
@@ -25,10 +25,12 @@ func NewItem(key string, dataType types.DataType, data []byte, expiry time.Durat
 }
 
 type BatchResult struct {
-	Key   string
-	Type  types.DataType
-	Value []byte
-	Error error
+	Key     string
+	Type    types.DataType
+	Value   []byte
+	Version uint64
+	TTL     time.Duration // remaining time to live, or TTLNoExpiry
+	Error   error
 }
 
 func (s *CacheStore) MGet(keys ...string) []BatchResult {
@@ -39,8 +41,9 @@ func (s *CacheStore) MGet(keys ...string) []BatchResult {
 	results := make([]BatchResult, len(keys))
 	now := time.Now().UnixMilli()
 
-	s.mux.RLock()
-	defer s.mux.RUnlock()
+	shards := s.shardsFor(keys)
+	rlockShards(shards)
+	defer runlockShards(shards)
 
 	for i, key := range keys {
 		results[i].Key = key
@@ -48,12 +51,25 @@ func (s *CacheStore) MGet(keys ...string) []BatchResult {
 			results[i].Error = errors.ErrKeyEmpty
 			continue
 		}
-		if e, ok := s.memorydb[key]; ok {
+		sh := s.shardFor(key)
+		if e, ok := sh.memorydb[key]; ok {
 			if !e.IsExpiredWithUnixMilli(now) {
+				if sh.evict != nil {
+					sh.evict.touch(key)
+				}
+				if sh.onStale != nil && e.IsStaleWithUnixMilli(now) {
+					sh.onStale(key, e.Version)
+				}
+				e = sh.unsafeEncode(key, e)
 				cData := make([]byte, len(e.Data))
 				copy(cData, e.Data)
 				results[i].Type = e.Type
 				results[i].Value = cData
+				results[i].Version = e.Version
+				results[i].TTL = TTLNoExpiry
+				if e.Expiry != 0 {
+					results[i].TTL = time.Duration(e.Expiry-now) * time.Millisecond
+				}
 			} else {
 				results[i].Error = errors.ErrNoDataForKey(key)
 			}
@@ -71,14 +87,26 @@ func (s *CacheStore) MSet(items ...BatchItem) []error {
 	}
 
 	errs := make([]error, len(items))
+	if s.readOnly.Load() {
+		for i := range errs {
+			errs[i] = errors.ErrReadOnly
+		}
+		return errs
+	}
 
-	s.mux.Lock()
-	defer s.mux.Unlock()
+	keys := make([]string, len(items))
+	for i, item := range items {
+		keys[i] = item.Key
+	}
+	shards := s.shardsFor(keys)
+	lockShards(shards)
+	defer unlockShards(shards)
 	if s.dirty != nil {
 		s.dirty.mux.Lock()
 		defer s.dirty.mux.Unlock()
 	}
 
+	var ops []op
 	for i, item := range items {
 		if item.Key == "" {
 			errs[i] = errors.ErrKeyEmpty
@@ -88,11 +116,31 @@ func (s *CacheStore) MSet(items ...BatchItem) []error {
 			errs[i] = errors.ErrValueNil
 			continue
 		}
-		s.memorydb[item.Key] = *item.Entry
+		if !item.Entry.Type.IsKnown() {
+			errs[i] = errors.ErrUnknownDataType(item.Entry.Type)
+			continue
+		}
+		e := *item.Entry
+		e.Version = s.nextVersion()
+		evicted, err := s.shardFor(item.Key).unsafePut(item.Key, e)
+		if s.dirty != nil {
+			for _, k := range evicted {
+				s.dirty.unsafeDelete(k)
+			}
+		}
+		for _, k := range evicted {
+			ops = append(ops, op{kind: opDelete, key: k})
+		}
+		if err != nil {
+			errs[i] = err
+			continue
+		}
 		if s.dirty != nil {
 			s.dirty.unsafeSet(item.Key)
 		}
+		ops = append(ops, op{kind: opSet, key: item.Key, entry: e})
 	}
+	s.logOps(ops)
 
 	return errs
 }
@@ -103,25 +151,35 @@ func (s *CacheStore) MDelete(keys ...string) []error {
 	}
 
 	errs := make([]error, len(keys))
+	if s.readOnly.Load() {
+		for i := range errs {
+			errs[i] = errors.ErrReadOnly
+		}
+		return errs
+	}
 
-	s.mux.Lock()
-	defer s.mux.Unlock()
+	shards := s.shardsFor(keys)
+	lockShards(shards)
+	defer unlockShards(shards)
 	if s.dirty != nil {
 		s.dirty.mux.Lock()
 		defer s.dirty.mux.Unlock()
 	}
 
+	ops := make([]op, 0, len(keys))
 	for i, key := range keys {
 		if key == "" {
 			errs[i] = errors.ErrKeyEmpty
 			continue
 		}
 
-		delete(s.memorydb, key)
+		s.shardFor(key).unsafeRemove(key)
 		if s.dirty != nil {
 			s.dirty.unsafeDelete(key)
 		}
+		ops = append(ops, op{kind: opDelete, key: key})
 	}
+	s.logOps(ops)
 
 	return errs
 }
@@ -103,6 +103,9 @@ func TestCacheStore_MGet(t *testing.T) {
 					if string(result.Value) != string(expectedData.value) {
 						t.Errorf("MGet() result[%d].Value = %v, want %v", i, string(result.Value), string(expectedData.value))
 					}
+					if result.TTL <= 0 || result.TTL > expectedData.expiry {
+						t.Errorf("MGet() result[%d].TTL = %v, want the remaining TTL", i, result.TTL)
+					}
 				}
 			}
 		})
@@ -225,7 +228,7 @@ func TestCacheStore_MSet(t *testing.T) {
 				if errs[i] == nil {
 					dataType, value, err := store.Get(item.Key)
 					if err != nil {
-						t.Errorf("Get() after MSet() error = %v  %v", err, store.memorydb)
+						t.Errorf("Get() after MSet() error = %v  %v", err, store.Keys())
 						continue
 					}
 					if dataType != item.Entry.Type {
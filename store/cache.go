@@ -0,0 +1,71 @@
+package store
+
+import (
+	"time"
+
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// Cache is the key-value API shared by the embedded *CacheStore and the
+// remote client in the client package, so code written against it can switch
+// between the two without changes.
+//
+// Methods without an error result cannot report a failure to reach a remote
+// cache; they return the zero value (or TTLExpired for TTL) instead.
+type Cache interface {
+	Get(key string) (types.DataType, []byte, error)
+	Set(key string, dataType types.DataType, value []byte, expiry time.Duration) error
+	Delete(key string) error
+	Exists(keys ...string) int
+	Keys() []string
+	TTL(key string) time.Duration
+	Flush()
+	Close() error
+
+	MGet(keys ...string) []BatchResult
+	MSet(items ...BatchItem) []error
+	MDelete(keys ...string) []error
+
+	GetRaw(key string) ([]byte, error)
+	SetRaw(key string, value []byte, exp time.Duration) error
+	GetString(key string) (string, error)
+	SetString(key string, value string, exp time.Duration) error
+	GetBool(key string) (bool, error)
+	SetBool(key string, value bool, exp time.Duration) error
+	GetTime(key string) (time.Time, error)
+	SetTime(key string, value time.Time, exp time.Duration) error
+	GetJSON(key string, target interface{}) error
+	SetJSON(key string, value interface{}, exp time.Duration) error
+
+	GetInt16(key string) (int16, error)
+	SetInt16(key string, value int16, exp time.Duration) error
+	IncrInt16(key string, delta int16, exp time.Duration) error
+	GetInt32(key string) (int32, error)
+	SetInt32(key string, value int32, exp time.Duration) error
+	IncrInt32(key string, delta int32, exp time.Duration) error
+	GetInt64(key string) (int64, error)
+	SetInt64(key string, value int64, exp time.Duration) error
+	IncrInt64(key string, delta int64, exp time.Duration) error
+
+	GetUInt16(key string) (uint16, error)
+	SetUInt16(key string, value uint16, exp time.Duration) error
+	IncrUInt16(key string, delta uint16, exp time.Duration) error
+	DecrUInt16(key string, delta uint16, exp time.Duration) error
+	GetUInt32(key string) (uint32, error)
+	SetUInt32(key string, value uint32, exp time.Duration) error
+	IncrUInt32(key string, delta uint32, exp time.Duration) error
+	DecrUInt32(key string, delta uint32, exp time.Duration) error
+	GetUInt64(key string) (uint64, error)
+	SetUInt64(key string, value uint64, exp time.Duration) error
+	IncrUInt64(key string, delta uint64, exp time.Duration) error
+	DecrUInt64(key string, delta uint64, exp time.Duration) error
+
+	GetFloat32(key string) (float32, error)
+	SetFloat32(key string, value float32, exp time.Duration) error
+	IncrFloat32(key string, delta float32, exp time.Duration) error
+	GetFloat64(key string) (float64, error)
+	SetFloat64(key string, value float64, exp time.Duration) error
+	IncrFloat64(key string, delta float64, exp time.Duration) error
+}
+
+var _ Cache = (*CacheStore)(nil)
@@ -1,13 +1,24 @@
 package store
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/entry"
 	"github.com/found-cake/CacheStore/utils/types"
 )
 
+// peekEntry reads the stored entry without expiry checks.
+func (s *CacheStore) peekEntry(key string) (entry.Entry, bool) {
+	sh := s.shardFor(key)
+	sh.mux.RLock()
+	defer sh.mux.RUnlock()
+	e, ok := sh.memorydb[key]
+	return e, ok
+}
+
 func TestCleanExpired(t *testing.T) {
 	store, err := NewCacheStore(config.Config{DBSave: false})
 	if err != nil {
@@ -33,13 +44,13 @@ func TestCleanExpired(t *testing.T) {
 		t.Errorf("Exists() = %v, want 0 for expired key", count)
 	}
 
-	_, ok := store.memorydb[key]
+	_, ok := store.peekEntry(key)
 	if !ok {
 		t.Error("Want it to exist because haven't called cleanExpired yet.")
 	}
 
 	store.cleanExpired()
-	_, ok = store.memorydb[key]
+	_, ok = store.peekEntry(key)
 	if ok {
 		t.Error("should not exist because cleanExpired was called.")
 	}
@@ -74,9 +85,234 @@ func TestGarbageCollector(t *testing.T) {
 		case <-timeout:
 			t.Fatal("timeout: key still exists after expected GC interval")
 		case <-tick:
-			if _, ok := store.memorydb[key]; !ok {
+			if _, ok := store.peekEntry(key); !ok {
 				return
 			}
 		}
 	}
 }
+
+func TestExpiryIndex_Maintained(t *testing.T) {
+	store, err := NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	index := store.shards[0].expires
+
+	store.SetString("a", "v", time.Hour)
+	store.SetString("b", "v", time.Minute)
+	store.SetString("c", "v", 0)
+	if index.len() != 2 {
+		t.Errorf("index len = %d, want 2", index.len())
+	}
+
+	store.SetString("a", "v", 0)
+	if index.len() != 1 {
+		t.Errorf("index len after removing expiry = %d, want 1", index.len())
+	}
+
+	store.MSet(NewItem("d", types.STRING, []byte("v"), time.Second))
+	store.Delete("b")
+	if index.len() != 1 || index.heap[0].key != "d" {
+		t.Errorf("index should only hold 'd', got %d items", index.len())
+	}
+
+	store.IncrInt32("n", 1, time.Hour)
+	store.IncrInt32("n", 1, 0)
+	if index.len() != 2 {
+		t.Errorf("index len after Incr = %d, want 2", index.len())
+	}
+
+	store.Flush()
+	if index.len() != 0 {
+		t.Errorf("index len after Flush = %d, want 0", index.len())
+	}
+}
+
+func TestExpiryIndex_PopOrder(t *testing.T) {
+	index := newExpiryIndex()
+	index.set("c", 30)
+	index.set("a", 10)
+	index.set("b", 20)
+	index.set("d", 40)
+	index.set("b", 5)
+
+	keys := index.popExpired(30, 2)
+	if len(keys) != 2 || keys[0] != "b" || keys[1] != "a" {
+		t.Errorf("popExpired() = %v, want [b a]", keys)
+	}
+	keys = index.popExpired(30, 10)
+	if len(keys) != 1 || keys[0] != "c" {
+		t.Errorf("popExpired() = %v, want [c]", keys)
+	}
+	if index.len() != 1 {
+		t.Errorf("index len = %d, want 1", index.len())
+	}
+}
+
+func TestCleanExpired_Batches(t *testing.T) {
+	store, err := NewCacheStore(config.Config{DBSave: false, Shards: 2})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	n := gcBatchSize*3 + 7
+	for i := 0; i < n; i++ {
+		store.SetInt32(fmt.Sprintf("exp:%d", i), int32(i), 50*time.Millisecond)
+	}
+	store.SetInt32("keep", 1, time.Hour)
+	time.Sleep(100 * time.Millisecond)
+
+	store.cleanExpired()
+	total := 0
+	for _, sh := range store.shards {
+		total += len(sh.memorydb)
+	}
+	if total != 1 {
+		t.Errorf("entries after cleanExpired = %d, want 1", total)
+	}
+}
+
+func TestCleanExpired_MarksDirtyDelete(t *testing.T) {
+	store, err := NewCacheStore(config.Config{
+		DBSave:              true,
+		DBFileName:          tempDBFile(t),
+		SaveDirtyData:       true,
+		DirtyThresholdCount: 100,
+		DirtyThresholdRatio: 1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	store.SetString("foo", "bar", 50*time.Millisecond)
+	store.dirty.clear()
+	time.Sleep(100 * time.Millisecond)
+
+	store.cleanExpired()
+	_, deleteKeys := store.dirty.keys()
+	if len(deleteKeys) != 1 || deleteKeys[0] != "foo" {
+		t.Errorf("dirty delete keys = %v, want [foo]", deleteKeys)
+	}
+}
+
+func TestGCConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config config.Config
+	}{
+		{"unknown strategy", config.Config{GCStrategy: config.GCAdaptiveSampling + 1}},
+		{"negative sample size", config.Config{GCSampleSize: -1}},
+		{"threshold out of range", config.Config{GCExpiredThreshold: 1}},
+		{"negative budget", config.Config{GCCycleBudget: -time.Second}},
+	}
+	for _, tcase := range tests {
+		t.Run(tcase.name, func(t *testing.T) {
+			if _, err := NewCacheStore(tcase.config); err == nil {
+				t.Error("NewCacheStore() expected error")
+			}
+		})
+	}
+}
+
+func TestAdaptiveSampling_RemovesExpired(t *testing.T) {
+	store, err := NewCacheStore(config.Config{
+		DBSave:       false,
+		Shards:       2,
+		GCStrategy:   config.GCAdaptiveSampling,
+		GCSampleSize: 10,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	for i := 0; i < 200; i++ {
+		store.SetInt32(fmt.Sprintf("exp:%d", i), int32(i), 20*time.Millisecond)
+	}
+	for i := 0; i < 5; i++ {
+		store.SetInt32(fmt.Sprintf("keep:%d", i), int32(i), time.Hour)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	store.runGC()
+	stats, ok := store.LastGCStats()
+	if !ok {
+		t.Fatal("LastGCStats() should report the cycle")
+	}
+	if stats.Strategy != config.GCAdaptiveSampling {
+		t.Errorf("stats.Strategy = %v, want GCAdaptiveSampling", stats.Strategy)
+	}
+	if stats.Expired == 0 || stats.Sampled < stats.Expired || stats.Rounds == 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	// Sampling keeps going while most samples are expired, so almost every
+	// expired key must be gone after one cycle.
+	remaining := 0
+	for _, sh := range store.shards {
+		remaining += len(sh.memorydb)
+	}
+	if remaining > 5+200/4 {
+		t.Errorf("entries after sampling cycle = %d, want close to 5", remaining)
+	}
+	if store.Exists("keep:0", "keep:1", "keep:2", "keep:3", "keep:4") != 5 {
+		t.Error("keys with a long TTL must survive")
+	}
+}
+
+func TestAdaptiveSampling_Budget(t *testing.T) {
+	store, err := NewCacheStore(config.Config{
+		DBSave:        false,
+		GCStrategy:    config.GCAdaptiveSampling,
+		GCSampleSize:  1,
+		GCCycleBudget: time.Nanosecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	for i := 0; i < 100; i++ {
+		store.SetInt32(fmt.Sprintf("exp:%d", i), int32(i), 10*time.Millisecond)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	stats := store.sampleExpired()
+	if !stats.TimedOut {
+		t.Errorf("cycle should stop on budget, got %+v", stats)
+	}
+	if stats.Expired >= 100 {
+		t.Errorf("cycle should not finish all keys within budget, got %+v", stats)
+	}
+}
+
+func TestOnGCCycle(t *testing.T) {
+	store, err := NewCacheStore(config.Config{
+		DBSave:     false,
+		GCInterval: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	cycles := make(chan GCStats, 1)
+	store.OnGCCycle(func(stats GCStats) {
+		select {
+		case cycles <- stats:
+		default:
+		}
+	})
+
+	select {
+	case stats := <-cycles:
+		if stats.Strategy != config.GCExpiryIndex {
+			t.Errorf("stats.Strategy = %v, want GCExpiryIndex", stats.Strategy)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for a gc cycle")
+	}
+}
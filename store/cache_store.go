@@ -1,57 +1,129 @@
 package store
 
 import (
+	"context"
+	"log"
 	"time"
 
 	"github.com/found-cake/CacheStore/config"
-	"github.com/found-cake/CacheStore/entry"
 	"github.com/found-cake/CacheStore/errors"
 	"github.com/found-cake/CacheStore/sqlite"
 )
 
 func NewCacheStore(cfg config.Config) (*CacheStore, error) {
+	if cfg.Shards < 0 {
+		return nil, errors.ErrShards
+	}
+	if cfg.ReplBacklogSize < 0 {
+		return nil, errors.ErrReplBacklogSize
+	}
+	gc, err := newGCSettings(cfg)
+	if err != nil {
+		return nil, err
+	}
+	persisting := cfg.DBSave || cfg.Persister != nil
+	if persisting && cfg.SaveDirtyData {
+		if cfg.DirtyThresholdCount <= 0 {
+			return nil, errors.ErrDirtyThresholdCount
+		}
+		if cfg.DirtyThresholdRatio <= 0 || cfg.DirtyThresholdRatio > 1 {
+			return nil, errors.ErrDirtyThresholdRatio
+		}
+	}
+	if cfg.AOFFileName != "" && cfg.AOFSync > config.AOFSyncNever {
+		return nil, errors.ErrAOFSyncPolicy
+	}
+	shardCount := cfg.Shards
+	if shardCount == 0 {
+		shardCount = 1
+	}
 	store := &CacheStore{
-		memorydb: make(map[string]entry.Entry),
+		shards:   make([]*shard, shardCount),
+		gc:       gc,
+		replSize: cfg.ReplBacklogSize,
 		done:     make(chan struct{}),
 	}
-	if cfg.DBSave {
-		sqlitedb, err := sqlite.NewSqliteStore(cfg.DBFileName)
+	limits, err := newEvictionLimits(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if limits != nil && shardCount > 1 {
+		limits.evictElsewhere = store.evictElsewhere
+	}
+	for i := range store.shards {
+		store.shards[i] = newShard(newEvictionManager(cfg, limits))
+		store.shards[i].onStale = store.refreshStale
+	}
+	store.ctx, store.cancel = context.WithCancel(context.Background())
+	if persisting {
+		persister := cfg.Persister
+		if persister == nil {
+			sqlitedb, err := sqlite.NewSqliteStore(cfg.DBFileName)
+			if err != nil {
+				store.closeOnError()
+				return nil, err
+			}
+			persister = sqlitedb
+		}
+		store.persister = persister
+		if cfg.SaveDirtyData {
+			store.dirty = newDirtyManager(cfg.DirtyThresholdCount, cfg.DirtyThresholdRatio)
+		}
+		data, version, err := persister.Load()
 		if err != nil {
+			store.closeOnError()
 			return nil, err
 		}
-		data, err := sqlitedb.LoadFromDB()
+		store.unsafeLoad(data, version)
+	}
+
+	if cfg.AOFFileName != "" {
+		aof, err := openAOF(cfg.AOFFileName, cfg.AOFSync)
 		if err != nil {
+			store.closeOnError()
 			return nil, err
 		}
-		store.memorydb = data
-		store.sqlitedb = sqlitedb
-		if cfg.SaveDirtyData {
-			if cfg.DirtyThresholdCount <= 0 {
-				return nil, errors.ErrDirtyThresholdCount
-			}
-			if cfg.DirtyThresholdRatio <= 0 || cfg.DirtyThresholdRatio > 1 {
-				return nil, errors.ErrDirtyThresholdRatio
-			}
-			store.dirty = newDirtyManager(cfg.DirtyThresholdCount, cfg.DirtyThresholdRatio)
-			if cfg.DBSaveInterval > 0 {
-				store.wg.Add(1)
-				go func() {
-					defer store.wg.Done()
-					ticker := time.NewTicker(cfg.DBSaveInterval)
-					defer ticker.Stop()
-					for {
-						select {
-						case <-ticker.C:
-							store.Sync()
-						case <-store.done:
-							return
-						}
+		store.aof = aof
+		now := time.Now().UnixMilli()
+		if err := aof.replay(func(o op) { store.unsafeApply(o, now) }); err != nil {
+			store.closeOnError()
+			return nil, err
+		}
+		if cfg.AOFSync == config.AOFSyncEverySecond {
+			store.wg.Add(1)
+			go func() {
+				defer store.wg.Done()
+				ticker := time.NewTicker(time.Second)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ticker.C:
+						aof.sync()
+					case <-store.done:
+						return
 					}
-				}()
-			}
+				}
+			}()
 		}
 	}
 
+	if store.persister != nil && cfg.SaveDirtyData && cfg.DBSaveInterval > 0 {
+		store.wg.Add(1)
+		go func() {
+			defer store.wg.Done()
+			ticker := time.NewTicker(cfg.DBSaveInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					store.Sync()
+				case <-store.done:
+					return
+				}
+			}
+		}()
+	}
+
 	if cfg.GCInterval > 0 {
 		store.wg.Add(1)
 		go func() {
@@ -61,7 +133,7 @@ func NewCacheStore(cfg config.Config) (*CacheStore, error) {
 			for {
 				select {
 				case <-ticker.C:
-					store.cleanExpired()
+					store.runGC()
 				case <-store.done:
 					return
 				}
@@ -71,3 +143,19 @@ func NewCacheStore(cfg config.Config) (*CacheStore, error) {
 
 	return store, nil
 }
+
+// closeOnError releases what NewCacheStore opened before it failed, without
+// saving anything.
+func (s *CacheStore) closeOnError() {
+	s.cancel()
+	if s.aof != nil {
+		if err := s.aof.close(); err != nil {
+			log.Println(err)
+		}
+	}
+	if s.persister != nil {
+		if err := s.persister.Close(); err != nil {
+			log.Println(err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"time"
+
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// GetWithVersion returns a copy of the value of key along with its version.
+// Every write assigns the entry a new, store-wide increasing version.
+func (s *CacheStore) GetWithVersion(key string) (types.DataType, []byte, uint64, error) {
+	if key == "" {
+		return types.UNKNOWN, nil, 0, errors.ErrKeyEmpty
+	}
+	sh := s.shardFor(key)
+	sh.mux.RLock()
+	defer sh.mux.RUnlock()
+	v, err := sh.unsafeGet(key)
+	if err != nil {
+		return types.UNKNOWN, nil, 0, err
+	}
+
+	result := make([]byte, len(v.Data))
+	copy(result, v.Data)
+	return v.Type, result, v.Version, nil
+}
+
+// unsafeVersion returns the version of key, or 0 when it does not exist.
+func (sh *shard) unsafeVersion(key string) uint64 {
+	e, err := sh.unsafeLookup(key)
+	if err != nil {
+		return 0
+	}
+	return e.Version
+}
+
+// CompareAndSet stores value only when the current version of key equals
+// expectedVersion; an expectedVersion of 0 requires the key to be absent.
+// It returns the new version, or an error wrapping errors.ErrConflict.
+func (s *CacheStore) CompareAndSet(key string, expectedVersion uint64, dataType types.DataType, value []byte, expiry time.Duration) (uint64, error) {
+	if key == "" {
+		return 0, errors.ErrKeyEmpty
+	}
+	if value == nil {
+		return 0, errors.ErrValueNil
+	}
+	if !dataType.IsKnown() {
+		return 0, errors.ErrUnknownDataType(dataType)
+	}
+	if s.readOnly.Load() {
+		return 0, errors.ErrReadOnly
+	}
+
+	sh := s.shardFor(key)
+	sh.mux.Lock()
+	defer sh.mux.Unlock()
+
+	if actual := sh.unsafeVersion(key); actual != expectedVersion {
+		return actual, errors.ErrVersionConflict(key, expectedVersion, actual)
+	}
+	if err := s.unsafeSet(sh, key, dataType, value, expiry); err != nil {
+		return 0, err
+	}
+	return sh.unsafeVersion(key), nil
+}
+
+// CompareAndDelete removes key only when its current version equals
+// expectedVersion, otherwise it returns an error wrapping errors.ErrConflict.
+func (s *CacheStore) CompareAndDelete(key string, expectedVersion uint64) error {
+	if key == "" {
+		return errors.ErrKeyEmpty
+	}
+	if s.readOnly.Load() {
+		return errors.ErrReadOnly
+	}
+
+	sh := s.shardFor(key)
+	sh.mux.Lock()
+	defer sh.mux.Unlock()
+
+	if actual := sh.unsafeVersion(key); actual != expectedVersion {
+		return errors.ErrVersionConflict(key, expectedVersion, actual)
+	}
+	sh.unsafeRemove(key)
+	if s.dirty != nil {
+		s.dirty.delete(key)
+	}
+	s.logDelete(key)
+	return nil
+}
+
+// GetWithVersion is the typed counterpart of CacheStore.GetWithVersion.
+func (t *Typed[T]) GetWithVersion(key string) (T, uint64, error) {
+	var zero T
+	dataType, data, version, err := t.store.GetWithVersion(key)
+	if err != nil {
+		return zero, 0, err
+	}
+	value, err := t.decode(key, entry.Entry{Type: dataType, Data: data})
+	return value, version, err
+}
+
+// CompareAndSet is the typed counterpart of CacheStore.CompareAndSet.
+func (t *Typed[T]) CompareAndSet(key string, expectedVersion uint64, value T, exp time.Duration) (uint64, error) {
+	data, err := t.codec.Encode(value)
+	if err != nil {
+		return 0, err
+	}
+	return t.store.CompareAndSet(key, expectedVersion, t.codec.DataType(), data, exp)
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	goerrors "errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func TestCompareAndSet(t *testing.T) {
+	store := newLoaderStore(t)
+
+	v1, err := store.CompareAndSet("k", 0, types.STRING, []byte("a"), 0)
+	if err != nil || v1 == 0 {
+		t.Fatalf("CompareAndSet() on missing key = %d, %v", v1, err)
+	}
+	if _, err := store.CompareAndSet("k", 0, types.STRING, []byte("b"), 0); !goerrors.Is(err, errors.ErrConflict) {
+		t.Errorf("CompareAndSet() on existing key with version 0 error = %v, want ErrConflict", err)
+	}
+
+	v2, err := store.CompareAndSet("k", v1, types.STRING, []byte("b"), 0)
+	if err != nil || v2 <= v1 {
+		t.Fatalf("CompareAndSet() = %d, %v, want version > %d", v2, err, v1)
+	}
+	if _, err := store.CompareAndSet("k", v1, types.STRING, []byte("c"), 0); !goerrors.Is(err, errors.ErrConflict) {
+		t.Errorf("CompareAndSet() with stale version error = %v, want ErrConflict", err)
+	}
+
+	_, data, version, err := store.GetWithVersion("k")
+	if err != nil || string(data) != "b" || version != v2 {
+		t.Errorf("GetWithVersion() = %q, %d, %v, want %q, %d", data, version, err, "b", v2)
+	}
+
+	store.Set("k", types.STRING, []byte("d"), 0)
+	if _, _, v3, _ := store.GetWithVersion("k"); v3 <= v2 {
+		t.Errorf("Set() should bump the version: %d <= %d", v3, v2)
+	}
+}
+
+func TestCompareAndDelete(t *testing.T) {
+	store := newLoaderStore(t)
+
+	store.Set("k", types.STRING, []byte("a"), 0)
+	_, _, version, _ := store.GetWithVersion("k")
+
+	if err := store.CompareAndDelete("k", version+1); !goerrors.Is(err, errors.ErrConflict) {
+		t.Errorf("CompareAndDelete() with wrong version error = %v, want ErrConflict", err)
+	}
+	if err := store.CompareAndDelete("k", version); err != nil {
+		t.Fatalf("CompareAndDelete() error = %v", err)
+	}
+	if store.Exists("k") != 0 {
+		t.Error("key should be deleted")
+	}
+	if err := store.CompareAndDelete("k", version); !goerrors.Is(err, errors.ErrConflict) {
+		t.Errorf("CompareAndDelete() on missing key error = %v, want ErrConflict", err)
+	}
+}
+
+func TestCompareAndSet_Concurrent(t *testing.T) {
+	store := newLoaderStore(t)
+	counter := NewTyped(store, Int64Codec)
+	counter.Set("n", 0, 0)
+
+	const workers, increments = 8, 100
+	var conflicts atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < increments; {
+				n, version, err := counter.GetWithVersion("n")
+				if err != nil {
+					t.Errorf("GetWithVersion() error = %v", err)
+					return
+				}
+				if _, err := counter.CompareAndSet("n", version, n+1, 0); err != nil {
+					if !goerrors.Is(err, errors.ErrConflict) {
+						t.Errorf("CompareAndSet() error = %v", err)
+						return
+					}
+					conflicts.Add(1)
+					continue
+				}
+				j++
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n, _ := counter.Get("n"); n != workers*increments {
+		t.Errorf("counter = %d, want %d (%d conflicts)", n, workers*increments, conflicts.Load())
+	}
+}
+
+func TestVersion_Persistence(t *testing.T) {
+	cfg := config.Config{DBSave: true, DBFileName: tempDBFile(t)}
+	store, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	store.Set("k", types.STRING, []byte("a"), time.Hour)
+	_, _, version, _ := store.GetWithVersion("k")
+	// Bump the counter past the highest stored version.
+	store.Set("gone", types.STRING, []byte("x"), 0)
+	store.Delete("gone")
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	store, err = NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to re-create store: %v", err)
+	}
+	defer store.Close()
+
+	if _, _, got, _ := store.GetWithVersion("k"); got != version {
+		t.Errorf("version after reload = %d, want %d", got, version)
+	}
+	v, err := store.CompareAndSet("k", version, types.STRING, []byte("b"), 0)
+	if err != nil {
+		t.Fatalf("CompareAndSet() after reload error = %v", err)
+	}
+	if v <= version+1 {
+		t.Errorf("new version %d should be greater than every version issued before the restart", v)
+	}
+}
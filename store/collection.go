@@ -0,0 +1,81 @@
+package store
+
+import (
+	goerrors "errors"
+	"time"
+
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// Collections (hashes, lists, ...) are stored as one encoded value per key.
+// Like in Redis, a missing key reads as an empty collection, adding to it
+// creates the key and removing the last element deletes it. Writes take an
+// expiry like the typed setters: a positive exp resets the TTL, otherwise
+// the key keeps its current one.
+
+// errUnchanged aborts an update that has nothing to write.
+var errUnchanged = goerrors.New("unchanged")
+
+// viewData calls fn with the value of key, which must be of dataType, while
+// its shard is locked. A missing key is reported to fn as nil data.
+func (s *CacheStore) viewData(key string, dataType types.DataType, fn func(data []byte) error) error {
+	if key == "" {
+		return errors.ErrKeyEmpty
+	}
+	sh := s.shardFor(key)
+	sh.mux.RLock()
+	defer sh.mux.RUnlock()
+	e, err := sh.unsafeLookup(key)
+	if err != nil {
+		if goerrors.Is(err, errors.ErrNotFound) {
+			return fn(nil)
+		}
+		return err
+	}
+	if e.Type != dataType {
+		return errors.ErrTypeMismatch(key, dataType, e.Type)
+	}
+	return fn(sh.unsafeEncode(key, e).Data)
+}
+
+// updateData passes the value of key, which must be of dataType, to fn and
+// stores what fn returns. Missing keys are passed as nil data. fn must not
+// modify data; it returns nil to delete the key or errUnchanged to leave it
+// as it is.
+func updateData(kv entryModifier, key string, dataType types.DataType, exp time.Duration, fn func(data []byte) ([]byte, error)) error {
+	err := kv.modifyOrDelete(key, func(old entry.Entry, err error) (*entry.Entry, error) {
+		exists := err == nil
+		if err != nil && !goerrors.Is(err, errors.ErrNotFound) {
+			return nil, err
+		}
+		var data []byte
+		if exists {
+			if old.Type != dataType {
+				return nil, errors.ErrTypeMismatch(key, dataType, old.Type)
+			}
+			data = old.Data
+		}
+		data, err = fn(data)
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			if !exists {
+				return nil, errUnchanged
+			}
+			return nil, nil
+		}
+		if !exists {
+			e := entry.NewEntry(dataType, data, exp)
+			return &e, nil
+		}
+		e := keepExp(old, dataType, data, exp)
+		return &e, nil
+	})
+	if err == errUnchanged {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"io"
+	"log"
+	"time"
+
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/snapshot"
+)
+
+// Dump writes a consistent snapshot of every entry to w in the native
+// snapshot format. The store is only locked while the entries are copied.
+func (s *CacheStore) Dump(w io.Writer, compression snapshot.Compression) error {
+	rlockShards(s.shards)
+	data := s.unsafeSnapshot()
+	version := s.version.Load()
+	runlockShards(s.shards)
+
+	return snapshot.Write(w, data, version, compression)
+}
+
+// Restore replaces the contents of the store with a snapshot written by Dump.
+// The snapshot is fully read and verified first; on error the store is left
+// untouched. Entries that expired since the dump are skipped, and restored
+// entries get new versions so that no compare-and-set taken before the
+// restore can succeed against them.
+func (s *CacheStore) Restore(r io.Reader) error {
+	if s.readOnly.Load() {
+		return errors.ErrReadOnly
+	}
+	data, version, err := snapshot.Read(r)
+	if err != nil {
+		return err
+	}
+	s.replace(data, version, false)
+	return nil
+}
+
+// replace swaps the contents of the store for data, skipping expired
+// entries. Entries keep their versions when keepVersions is set, as on a
+// follower, and get new ones otherwise.
+func (s *CacheStore) replace(data map[string]entry.Entry, version uint64, keepVersions bool) {
+	lockShards(s.shards)
+	defer unlockShards(s.shards)
+
+	for _, sh := range s.shards {
+		sh.unsafeFlush()
+	}
+	s.logFlush()
+
+	for _, e := range data {
+		version = max(version, e.Version)
+	}
+	s.raiseVersion(version)
+
+	now := time.Now().UnixMilli()
+	ops := make([]op, 0, len(data))
+	for key, e := range data {
+		if e.IsExpiredWithUnixMilli(now) {
+			continue
+		}
+		if !keepVersions {
+			e.Version = s.nextVersion()
+		}
+		sh := s.shardFor(key)
+		evicted, err := sh.unsafePut(key, e)
+		for _, k := range evicted {
+			ops = append(ops, op{kind: opDelete, key: k})
+		}
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		ops = append(ops, op{kind: opSet, key: key, entry: e})
+	}
+	s.logOps(ops)
+
+	if s.dirty != nil {
+		s.dirty.wantFullSync()
+	}
+}
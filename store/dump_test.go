@@ -0,0 +1,89 @@
+package store
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/snapshot"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func TestDumpRestore(t *testing.T) {
+	src := newLoaderStore(t)
+	src.SetString("a", "1", 0)
+	src.SetInt64("n", 42, time.Hour)
+	src.SetSoft("soft", types.STRING, []byte("s"), time.Minute, time.Hour)
+
+	var buf bytes.Buffer
+	if err := src.Dump(&buf, snapshot.Gzip); err != nil {
+		t.Fatalf("Dump() error = %v", err)
+	}
+
+	dst := newLoaderStore(t)
+	dst.SetString("stale", "x", 0)
+	_, _, oldVersion, _ := dst.GetWithVersion("stale")
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	if dst.Exists("stale") != 0 {
+		t.Error("Restore() should replace the previous contents")
+	}
+	if v, _ := dst.GetString("a"); v != "1" {
+		t.Errorf("a = %q, want %q", v, "1")
+	}
+	if n, _ := dst.GetInt64("n"); n != 42 || dst.TTL("n") <= 0 {
+		t.Errorf("n = %d with TTL %v, want 42 with an expiry", n, dst.TTL("n"))
+	}
+	want, _ := src.peekEntry("soft")
+	if got, _ := dst.peekEntry("soft"); got.SoftExpiry != want.SoftExpiry {
+		t.Errorf("soft expiry = %d, want %d", got.SoftExpiry, want.SoftExpiry)
+	}
+	if _, _, v, _ := dst.GetWithVersion("a"); v <= oldVersion {
+		t.Errorf("restored version %d should be newer than %d", v, oldVersion)
+	}
+}
+
+func TestRestore_RejectsDamagedSnapshot(t *testing.T) {
+	src := newLoaderStore(t)
+	src.SetString("a", "1", 0)
+	var buf bytes.Buffer
+	src.Dump(&buf, snapshot.NoCompression)
+
+	dst := newLoaderStore(t)
+	dst.SetString("keep", "x", 0)
+	if err := dst.Restore(bytes.NewReader(buf.Bytes()[:buf.Len()-2])); err == nil {
+		t.Fatal("Restore() of a truncated snapshot should fail")
+	}
+	if dst.Exists("keep") != 1 {
+		t.Error("a failed Restore() must leave the store untouched")
+	}
+}
+
+func TestSnapshotPersister(t *testing.T) {
+	p, err := snapshot.NewFilePersister(filepath.Join(t.TempDir(), "cache.snap"), snapshot.NoCompression)
+	if err != nil {
+		t.Fatalf("NewFilePersister() error = %v", err)
+	}
+	cfg := config.Config{Persister: p}
+	store, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	store.SetString("k", "v", 0)
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	store, err = NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to re-create store: %v", err)
+	}
+	defer store.Close()
+	if v, _ := store.GetString("k"); v != "v" {
+		t.Errorf("k = %q after restart, want %q", v, "v")
+	}
+}
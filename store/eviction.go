@@ -0,0 +1,316 @@
+package store
+
+import (
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/errors"
+)
+
+const defaultEvictionSamples = 5
+
+// accessMeta is shared between the write path, which owns the map entries,
+// and the read path, which only bumps the counters while holding a read lock.
+type accessMeta struct {
+	lastAccess atomic.Int64
+	hits       atomic.Uint32
+	size       int64
+	expiry     int64
+}
+
+// evictionLimits are the MaxEntries/MaxBytes limits of the whole store and
+// the totals they are checked against, shared by the managers of all shards.
+type evictionLimits struct {
+	maxEntries int64
+	maxBytes   int64
+	entries    atomic.Int64
+	bytes      atomic.Int64
+	// evictElsewhere evicts a key from a shard other than the given one, see
+	// CacheStore.evictElsewhere. It is nil for a single shard.
+	evictElsewhere func(sh *shard, now int64) (evicted, busy bool)
+}
+
+// newEvictionLimits validates the eviction settings, and returns nil when
+// the store has no limits.
+func newEvictionLimits(cfg config.Config) (*evictionLimits, error) {
+	if cfg.MaxEntries < 0 {
+		return nil, errors.ErrMaxEntries
+	}
+	if cfg.MaxBytes < 0 {
+		return nil, errors.ErrMaxBytes
+	}
+	if cfg.EvictionSamples < 0 {
+		return nil, errors.ErrEvictionSamples
+	}
+	if cfg.EvictionPolicy > config.NoEviction {
+		return nil, errors.ErrEvictionPolicy
+	}
+	if cfg.MaxEntries == 0 && cfg.MaxBytes == 0 {
+		return nil, nil
+	}
+	return &evictionLimits{maxEntries: int64(cfg.MaxEntries), maxBytes: cfg.MaxBytes}, nil
+}
+
+func (l *evictionLimits) exceeded() bool {
+	return (l.maxEntries > 0 && l.entries.Load() > l.maxEntries) ||
+		(l.maxBytes > 0 && l.bytes.Load() > l.maxBytes)
+}
+
+// evictionManager keeps the bookkeeping of a shard needed to enforce the
+// limits. Its maps are only modified while the shard is locked for writing.
+type evictionManager struct {
+	policy    config.EvictionPolicy
+	limits    *evictionLimits
+	samples   int
+	usedBytes int64
+	keys      map[string]*accessMeta
+	volatile  map[string]*accessMeta
+}
+
+// newEvictionManager builds the manager of a single shard, or returns nil
+// when limits is nil.
+func newEvictionManager(cfg config.Config, limits *evictionLimits) *evictionManager {
+	if limits == nil {
+		return nil
+	}
+	samples := cfg.EvictionSamples
+	if samples == 0 {
+		samples = defaultEvictionSamples
+	}
+	return &evictionManager{
+		policy:   cfg.EvictionPolicy,
+		limits:   limits,
+		samples:  samples,
+		keys:     make(map[string]*accessMeta),
+		volatile: make(map[string]*accessMeta),
+	}
+}
+
+func entrySize(key string, e entry.Entry) int64 {
+	return int64(len(key) + len(e.Data))
+}
+
+func (m *evictionManager) reset() {
+	m.limits.entries.Add(-int64(len(m.keys)))
+	m.limits.bytes.Add(-m.usedBytes)
+	m.usedBytes = 0
+	m.keys = make(map[string]*accessMeta)
+	m.volatile = make(map[string]*accessMeta)
+}
+
+func (m *evictionManager) touch(key string) {
+	if meta, ok := m.keys[key]; ok {
+		meta.lastAccess.Store(time.Now().UnixNano())
+		if hits := meta.hits.Load(); hits < ^uint32(0) {
+			meta.hits.CompareAndSwap(hits, hits+1)
+		}
+	}
+}
+
+// track records the metadata of key, stored as e taking size bytes. The shared
+// totals already count it, see unsafeMakeRoom.
+func (m *evictionManager) track(key string, e entry.Entry, size int64) {
+	meta, ok := m.keys[key]
+	if ok {
+		m.usedBytes -= meta.size
+	} else {
+		meta = &accessMeta{}
+		m.keys[key] = meta
+	}
+	meta.size = size
+	meta.expiry = e.Expiry
+	meta.lastAccess.Store(time.Now().UnixNano())
+	meta.hits.Add(1)
+	m.usedBytes += meta.size
+
+	if e.Expiry > 0 {
+		m.volatile[key] = meta
+	} else {
+		delete(m.volatile, key)
+	}
+}
+
+func (m *evictionManager) untrack(key string) {
+	if meta, ok := m.keys[key]; ok {
+		m.usedBytes -= meta.size
+		m.limits.entries.Add(-1)
+		m.limits.bytes.Add(-meta.size)
+		delete(m.keys, key)
+		delete(m.volatile, key)
+	}
+}
+
+// victim samples candidate keys according to the policy and returns the best
+// one to evict. Already expired keys are always preferred. The key being
+// written is never chosen.
+func (m *evictionManager) victim(exclude string, now int64) (string, bool) {
+	if m.policy == config.NoEviction {
+		return "", false
+	}
+	pool := m.keys
+	if m.policy.IsVolatile() {
+		pool = m.volatile
+	}
+
+	var best string
+	var bestMeta *accessMeta
+	sampled := 0
+	for key, meta := range pool {
+		if key == exclude {
+			continue
+		}
+		if meta.expiry > 0 && meta.expiry <= now {
+			return key, true
+		}
+		if bestMeta == nil || m.better(meta, bestMeta) {
+			best, bestMeta = key, meta
+		}
+		sampled++
+		if sampled >= m.samples {
+			break
+		}
+	}
+	return best, bestMeta != nil
+}
+
+func (m *evictionManager) better(candidate, current *accessMeta) bool {
+	switch m.policy {
+	case config.EvictAllKeysLRU, config.EvictVolatileLRU:
+		return candidate.lastAccess.Load() < current.lastAccess.Load()
+	case config.EvictAllKeysLFU, config.EvictVolatileLFU:
+		ch, cur := candidate.hits.Load(), current.hits.Load()
+		if ch != cur {
+			return ch < cur
+		}
+		return candidate.lastAccess.Load() < current.lastAccess.Load()
+	case config.EvictVolatileTTL:
+		return candidate.expiry < current.expiry
+	default:
+		// random policies: map iteration order already randomizes the pick
+		return false
+	}
+}
+
+// unsafeMakeRoom evicts keys until an entry of the given size fits under key
+// in the limits of the whole store, and counts it in the shared totals. It
+// evicts from sh, then from other shards once sh has nothing left. When the
+// other shards are locked, the entry is admitted over the limits; the next
+// writes and GC cycles evict the excess. It returns the keys evicted from sh
+// so the caller can record them as deleted.
+func (sh *shard) unsafeMakeRoom(key string, size int64) ([]string, error) {
+	m := sh.evict
+	l := m.limits
+	if l.maxBytes > 0 && size > l.maxBytes {
+		return nil, errors.ErrEntryTooLarge(key, size, l.maxBytes)
+	}
+
+	// Reserve the room first, so that concurrent writes to other shards see
+	// it taken.
+	entries, bytes := int64(1), size
+	if meta, ok := m.keys[key]; ok {
+		entries, bytes = 0, size-meta.size
+	}
+	l.entries.Add(entries)
+	l.bytes.Add(bytes)
+
+	var evicted []string
+	now := time.Now().UnixMilli()
+	for l.exceeded() {
+		if victim, ok := m.victim(key, now); ok {
+			sh.unsafeRemove(victim)
+			evicted = append(evicted, victim)
+			continue
+		}
+		var elsewhere, busy bool
+		if l.evictElsewhere != nil && m.policy != config.NoEviction {
+			elsewhere, busy = l.evictElsewhere(sh, now)
+		}
+		if elsewhere {
+			continue
+		}
+		if busy {
+			return evicted, nil
+		}
+		l.entries.Add(-entries)
+		l.bytes.Add(-bytes)
+		return evicted, errors.ErrCacheFull
+	}
+	return evicted, nil
+}
+
+// evictElsewhere evicts one key from a shard other than sh, for a write to sh
+// that has nothing left to evict. Shards that cannot be locked without
+// waiting are skipped, since the caller may hold them, and reported as busy
+// when no other shard had a key to evict. The eviction is
+// published while the victim's shard is locked, so that it is ordered before
+// any later write of the key; its dirty mark waits in the shard for the next
+// Sync, as the caller may hold the dirty manager.
+func (s *CacheStore) evictElsewhere(sh *shard, now int64) (evicted, busy bool) {
+	start := slices.Index(s.shards, sh)
+	for i := 1; i < len(s.shards); i++ {
+		other := s.shards[(start+i)%len(s.shards)]
+		if !other.mux.TryLock() {
+			busy = true
+			continue
+		}
+		victim, ok := other.evict.victim("", now)
+		if ok {
+			other.unsafeRemove(victim)
+			if s.dirty != nil {
+				if other.evicted == nil {
+					other.evicted = make(map[string]struct{})
+				}
+				other.evicted[victim] = struct{}{}
+			}
+			s.logDelete(victim)
+		}
+		other.mux.Unlock()
+		if ok {
+			return true, false
+		}
+	}
+	return false, busy
+}
+
+// evictExcess evicts keys until the store is back within its limits, after
+// unsafeMakeRoom admitted writes over them. It locks one shard at a time.
+func (s *CacheStore) evictExcess() {
+	if s.shards[0].evict == nil {
+		return
+	}
+	l := s.shards[0].evict.limits
+	now := time.Now().UnixMilli()
+	for _, sh := range s.shards {
+		if !l.exceeded() {
+			return
+		}
+		sh.mux.Lock()
+		for l.exceeded() {
+			victim, ok := sh.evict.victim("", now)
+			if !ok {
+				break
+			}
+			sh.unsafeRemove(victim)
+			if s.dirty != nil {
+				s.dirty.delete(victim)
+			}
+			s.logDelete(victim)
+		}
+		sh.mux.Unlock()
+	}
+}
+
+// unsafeCollectEvictions marks the keys evicted by evictElsewhere as deleted.
+// All shards must be locked by the caller for writing, and the dirty manager
+// too.
+func (s *CacheStore) unsafeCollectEvictions() {
+	for _, sh := range s.shards {
+		for key := range sh.evicted {
+			s.dirty.unsafeDelete(key)
+		}
+		sh.evicted = nil
+	}
+}
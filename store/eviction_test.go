@@ -0,0 +1,331 @@
+package store
+
+import (
+	goerrors "errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func newEvictionStore(t *testing.T, cfg config.Config) *CacheStore {
+	t.Helper()
+	cfg.DBSave = false
+	store, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestEviction_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config config.Config
+	}{
+		{"negative max entries", config.Config{MaxEntries: -1}},
+		{"negative max bytes", config.Config{MaxBytes: -1}},
+		{"negative samples", config.Config{MaxEntries: 1, EvictionSamples: -1}},
+		{"unknown policy", config.Config{MaxEntries: 1, EvictionPolicy: config.NoEviction + 1}},
+	}
+	for _, tcase := range tests {
+		t.Run(tcase.name, func(t *testing.T) {
+			if _, err := NewCacheStore(tcase.config); err == nil {
+				t.Error("NewCacheStore() expected error")
+			}
+		})
+	}
+}
+
+func TestEviction_AllKeysLRU(t *testing.T) {
+	store := newEvictionStore(t, config.Config{MaxEntries: 3, EvictionPolicy: config.EvictAllKeysLRU})
+
+	for i := 0; i < 3; i++ {
+		if err := store.SetString(fmt.Sprintf("k%d", i), "v", 0); err != nil {
+			t.Fatalf("SetString() error = %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := store.GetString("k0"); err != nil {
+		t.Fatalf("GetString() error = %v", err)
+	}
+	if err := store.SetString("k3", "v", 0); err != nil {
+		t.Fatalf("SetString() error = %v", err)
+	}
+
+	if store.Exists("k1") != 0 {
+		t.Error("k1 should have been evicted as least recently used")
+	}
+	if store.Exists("k0", "k2", "k3") != 3 {
+		t.Error("k0, k2 and k3 should still exist")
+	}
+}
+
+func TestEviction_AllKeysLFU(t *testing.T) {
+	store := newEvictionStore(t, config.Config{MaxEntries: 2, EvictionPolicy: config.EvictAllKeysLFU})
+
+	store.SetString("hot", "v", 0)
+	store.SetString("cold", "v", 0)
+	for i := 0; i < 5; i++ {
+		store.GetString("hot")
+	}
+	if err := store.SetString("new", "v", 0); err != nil {
+		t.Fatalf("SetString() error = %v", err)
+	}
+	if store.Exists("cold") != 0 {
+		t.Error("cold should have been evicted as least frequently used")
+	}
+	if store.Exists("hot", "new") != 2 {
+		t.Error("hot and new should still exist")
+	}
+}
+
+func TestEviction_VolatileTTL(t *testing.T) {
+	store := newEvictionStore(t, config.Config{MaxEntries: 3, EvictionPolicy: config.EvictVolatileTTL})
+
+	store.SetString("persistent", "v", 0)
+	store.SetString("long", "v", time.Hour)
+	store.SetString("short", "v", time.Minute)
+	if err := store.SetString("new", "v", 0); err != nil {
+		t.Fatalf("SetString() error = %v", err)
+	}
+	if store.Exists("short") != 0 {
+		t.Error("short should have been evicted as nearest to expiry")
+	}
+	if store.Exists("persistent", "long", "new") != 3 {
+		t.Error("persistent, long and new should still exist")
+	}
+}
+
+func TestEviction_VolatileNoCandidate(t *testing.T) {
+	store := newEvictionStore(t, config.Config{MaxEntries: 1, EvictionPolicy: config.EvictVolatileLRU})
+
+	if err := store.SetString("a", "v", 0); err != nil {
+		t.Fatalf("SetString() error = %v", err)
+	}
+	if err := store.SetString("b", "v", 0); err == nil {
+		t.Error("SetString() expected cache full error")
+	}
+	if err := store.SetString("a", "overwrite", 0); err != nil {
+		t.Errorf("overwriting an existing key should not need eviction: %v", err)
+	}
+}
+
+func TestEviction_NoEviction(t *testing.T) {
+	store := newEvictionStore(t, config.Config{MaxEntries: 1, EvictionPolicy: config.NoEviction})
+
+	store.SetString("a", "v", 0)
+	if err := store.IncrInt64("counter", 1, 0); err == nil {
+		t.Error("IncrInt64() expected cache full error")
+	}
+	errs := store.MSet(NewItem("b", types.STRING, []byte("v"), 0))
+	if errs[0] == nil {
+		t.Error("MSet() expected cache full error")
+	}
+}
+
+func TestEviction_MaxBytes(t *testing.T) {
+	store := newEvictionStore(t, config.Config{MaxBytes: 20, EvictionPolicy: config.EvictAllKeysRandom})
+
+	if err := store.SetRaw("big", make([]byte, 30), 0); err == nil {
+		t.Error("SetRaw() expected entry too large error")
+	}
+	for i := 0; i < 10; i++ {
+		if err := store.SetRaw(fmt.Sprintf("k%d", i), make([]byte, 4), 0); err != nil {
+			t.Fatalf("SetRaw() error = %v", err)
+		}
+	}
+	if store.shards[0].evict.usedBytes > 20 {
+		t.Errorf("usedBytes = %d, want <= 20", store.shards[0].evict.usedBytes)
+	}
+	if got := len(store.Keys()); got != 3 {
+		t.Errorf("len(Keys()) = %d, want 3", got)
+	}
+
+	store.Delete("k9")
+	store.Flush()
+	if store.shards[0].evict.usedBytes != 0 {
+		t.Errorf("usedBytes after Flush = %d, want 0", store.shards[0].evict.usedBytes)
+	}
+}
+
+func TestEviction_Sharded(t *testing.T) {
+	t.Run("max entries", func(t *testing.T) {
+		store := newEvictionStore(t, config.Config{Shards: 16, MaxEntries: 10, EvictionPolicy: config.EvictAllKeysLRU})
+		for i := 0; i < 16; i++ {
+			if err := store.SetString(fmt.Sprintf("k%d", i), "v", 0); err != nil {
+				t.Fatalf("SetString() error = %v", err)
+			}
+		}
+		if got := len(store.Keys()); got != 10 {
+			t.Errorf("len(Keys()) = %d, want 10", got)
+		}
+	})
+
+	t.Run("max bytes", func(t *testing.T) {
+		store := newEvictionStore(t, config.Config{Shards: 16, MaxBytes: 1000, EvictionPolicy: config.EvictAllKeysRandom})
+		if err := store.SetRaw("big", make([]byte, 200), 0); err != nil {
+			t.Fatalf("SetRaw() error = %v", err)
+		}
+		for i := 0; i < 20; i++ {
+			if err := store.SetRaw(fmt.Sprintf("k%02d", i), make([]byte, 97), 0); err != nil {
+				t.Fatalf("SetRaw() error = %v", err)
+			}
+		}
+		var used int64
+		for _, key := range store.Keys() {
+			_, value, _ := store.Get(key)
+			used += int64(len(key) + len(value))
+		}
+		if used > 1000 {
+			t.Errorf("stored bytes = %d, want <= 1000", used)
+		}
+		if used < 900 {
+			t.Errorf("stored bytes = %d, want the limit filled", used)
+		}
+	})
+}
+
+func TestEviction_DirtyTracking(t *testing.T) {
+	store, err := NewCacheStore(config.Config{
+		DBSave:              true,
+		DBFileName:          tempDBFile(t),
+		SaveDirtyData:       true,
+		DirtyThresholdCount: 100,
+		DirtyThresholdRatio: 1,
+		MaxEntries:          1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	store.SetString("a", "v", 0)
+	store.SetString("b", "v", 0)
+
+	setKeys, deleteKeys := store.dirty.keys()
+	if len(setKeys) != 1 || setKeys[0] != "b" {
+		t.Errorf("dirty set keys = %v, want [b]", setKeys)
+	}
+	if len(deleteKeys) != 1 || deleteKeys[0] != "a" {
+		t.Errorf("dirty delete keys = %v, want [a]", deleteKeys)
+	}
+}
+
+func TestEviction_ShardedDirtyTracking(t *testing.T) {
+	store, err := NewCacheStore(config.Config{
+		DBSave:              true,
+		DBFileName:          tempDBFile(t),
+		SaveDirtyData:       true,
+		DirtyThresholdCount: 100,
+		DirtyThresholdRatio: 1,
+		Shards:              2,
+		MaxEntries:          1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	b := "b"
+	for shardIndex(b, 2) == shardIndex("a", 2) {
+		b += "b"
+	}
+	store.SetString("a", "v", 0)
+	store.SetString(b, "v", 0)
+	if store.Exists("a") != 0 {
+		t.Fatal("a should have been evicted from the other shard")
+	}
+
+	store.dirty.mux.Lock()
+	store.unsafeCollectEvictions()
+	store.dirty.mux.Unlock()
+	setKeys, deleteKeys := store.dirty.keys()
+	if len(setKeys) != 1 || setKeys[0] != b {
+		t.Errorf("dirty set keys = %v, want [%s]", setKeys, b)
+	}
+	if len(deleteKeys) != 1 || deleteKeys[0] != "a" {
+		t.Errorf("dirty delete keys = %v, want [a]", deleteKeys)
+	}
+}
+
+func TestEviction_OtherShardsLocked(t *testing.T) {
+	for _, policy := range []config.EvictionPolicy{config.EvictAllKeysLRU, config.NoEviction} {
+		store := newEvictionStore(t, config.Config{Shards: 2, MaxEntries: 1, EvictionPolicy: policy})
+		b := "b"
+		for shardIndex(b, 2) == shardIndex("a", 2) {
+			b += "b"
+		}
+		store.SetString("a", "v", 0)
+
+		other := store.shards[shardIndex("a", 2)]
+		other.mux.Lock()
+		err := store.SetString(b, "v", 0)
+		other.mux.Unlock()
+		if policy == config.NoEviction {
+			if !goerrors.Is(err, errors.ErrCacheFull) {
+				t.Errorf("SetString() with %v = %v, want ErrCacheFull", policy, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("SetString() while the other shard is locked = %v, want it admitted", err)
+		}
+		if got := len(store.Keys()); got != 2 {
+			t.Fatalf("len(Keys()) = %d, want 2 over the limit", got)
+		}
+		store.runGC()
+		if got := len(store.Keys()); got != 1 {
+			t.Errorf("len(Keys()) after GC = %d, want 1", got)
+		}
+	}
+}
+
+// TestEviction_ConcurrentSync runs Sync calls, which collect the keys
+// evicted from other shards, during such evictions; run it with -race.
+func TestEviction_ConcurrentSync(t *testing.T) {
+	store, err := NewCacheStore(config.Config{
+		DBSave:              true,
+		DBFileName:          tempDBFile(t),
+		SaveDirtyData:       true,
+		DirtyThresholdCount: 1000,
+		DirtyThresholdRatio: 1,
+		Shards:              4,
+		MaxEntries:          4,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					store.Sync()
+				}
+			}
+		}()
+	}
+	for i := 0; i < 200; i++ {
+		store.SetString(fmt.Sprintf("k%d", i), "v", 0)
+	}
+	close(done)
+	wg.Wait()
+	store.runGC()
+	if got := len(store.Keys()); got > 4 {
+		t.Errorf("len(Keys()) = %d, want at most 4", got)
+	}
+}
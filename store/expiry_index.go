@@ -0,0 +1,101 @@
+package store
+
+import "container/heap"
+
+// gcBatchSize bounds how many expired keys are removed per lock acquisition,
+// so garbage collection never holds a shard lock for long.
+const gcBatchSize = 128
+
+type expiryItem struct {
+	key    string
+	expiry int64
+	index  int
+}
+
+// expiryHeap is a min-heap of keys ordered by their expiry.
+type expiryHeap []*expiryItem
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expiry < h[j].expiry }
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x any) {
+	item := x.(*expiryItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+// expiryIndex tracks every key that has an expiry. It is guarded by the lock
+// of the shard owning it.
+type expiryIndex struct {
+	heap  expiryHeap
+	items map[string]*expiryItem
+}
+
+func newExpiryIndex() *expiryIndex {
+	return &expiryIndex{
+		items: make(map[string]*expiryItem),
+	}
+}
+
+func (x *expiryIndex) len() int {
+	return len(x.heap)
+}
+
+func (x *expiryIndex) set(key string, expiry int64) {
+	if expiry <= 0 {
+		x.remove(key)
+		return
+	}
+	if item, ok := x.items[key]; ok {
+		if item.expiry != expiry {
+			item.expiry = expiry
+			heap.Fix(&x.heap, item.index)
+		}
+		return
+	}
+	item := &expiryItem{key: key, expiry: expiry}
+	heap.Push(&x.heap, item)
+	x.items[key] = item
+}
+
+func (x *expiryIndex) remove(key string) {
+	if item, ok := x.items[key]; ok {
+		heap.Remove(&x.heap, item.index)
+		delete(x.items, key)
+	}
+}
+
+func (x *expiryIndex) reset() {
+	x.heap = nil
+	x.items = make(map[string]*expiryItem)
+}
+
+// popExpired removes and returns up to limit keys whose expiry is at or
+// before now, soonest first.
+func (x *expiryIndex) popExpired(now int64, limit int) []string {
+	var keys []string
+	for len(x.heap) > 0 && len(keys) < limit {
+		item := x.heap[0]
+		if item.expiry > now {
+			break
+		}
+		heap.Pop(&x.heap)
+		delete(x.items, item.key)
+		keys = append(keys, item.key)
+	}
+	return keys
+}
@@ -0,0 +1,235 @@
+package store
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+type ExportFormat uint8
+
+const (
+	ExportJSONL ExportFormat = iota // one JSON object per line
+	ExportCSV                       // CSV with a key,type,value,expiry,soft_expiry header
+)
+
+var csvHeader = []string{"key", "type", "value", "expiry", "soft_expiry"}
+
+// exportRecord is the textual form of an entry. Deadlines are absolute RFC
+// 3339 timestamps and are left empty when not set.
+type exportRecord struct {
+	Key        string `json:"key"`
+	Type       string `json:"type"`
+	Value      string `json:"value"`
+	Expiry     string `json:"expiry,omitempty"`
+	SoftExpiry string `json:"soft_expiry,omitempty"`
+}
+
+func formatDeadline(unixMilli int64) string {
+	if unixMilli == 0 {
+		return ""
+	}
+	return time.UnixMilli(unixMilli).UTC().Format(time.RFC3339Nano)
+}
+
+func parseDeadline(text string) (int64, error) {
+	if text == "" {
+		return 0, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, text)
+	if err != nil {
+		return 0, err
+	}
+	return t.UnixMilli(), nil
+}
+
+func toRecord(key string, e entry.Entry) (exportRecord, error) {
+	value, err := utils.FormatValue(e.Type, e.Data)
+	if err != nil {
+		return exportRecord{}, err
+	}
+	return exportRecord{
+		Key:        key,
+		Type:       e.Type.String(),
+		Value:      value,
+		Expiry:     formatDeadline(e.Expiry),
+		SoftExpiry: formatDeadline(e.SoftExpiry),
+	}, nil
+}
+
+func fromRecord(r exportRecord) (entry.Entry, error) {
+	if r.Key == "" {
+		return entry.Entry{}, errors.ErrKeyEmpty
+	}
+	dataType, ok := types.ParseDataType(r.Type)
+	if !ok {
+		return entry.Entry{}, errors.ErrUnknownTypeName(r.Type)
+	}
+	data, err := utils.ParseValue(dataType, r.Value)
+	if err != nil {
+		return entry.Entry{}, err
+	}
+	e := entry.Entry{Type: dataType, Data: data}
+	if e.Expiry, err = parseDeadline(r.Expiry); err != nil {
+		return entry.Entry{}, err
+	}
+	if e.SoftExpiry, err = parseDeadline(r.SoftExpiry); err != nil {
+		return entry.Entry{}, err
+	}
+	return e, nil
+}
+
+// Export writes every live entry to w in the given format, one shard at a
+// time, so only a single shard is copied in memory. Within a shard keys are
+// sorted; entries written concurrently may or may not be included.
+func (s *CacheStore) Export(w io.Writer, format ExportFormat) error {
+	if format > ExportCSV {
+		return errors.ErrExportFormat
+	}
+
+	bw := bufio.NewWriter(w)
+	var cw *csv.Writer
+	var enc *json.Encoder
+	if format == ExportCSV {
+		cw = csv.NewWriter(bw)
+		if err := cw.Write(csvHeader); err != nil {
+			return err
+		}
+	} else {
+		enc = json.NewEncoder(bw)
+		enc.SetEscapeHTML(false)
+	}
+
+	for _, sh := range s.shards {
+		now := time.Now().UnixMilli()
+		sh.mux.RLock()
+		data := make(map[string]entry.Entry, len(sh.memorydb))
+		for key, e := range sh.memorydb {
+			if !e.IsExpiredWithUnixMilli(now) {
+				data[key] = cloneEntry(sh.unsafeEncode(key, e))
+			}
+		}
+		sh.mux.RUnlock()
+
+		keys := make([]string, 0, len(data))
+		for key := range data {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			r, err := toRecord(key, data[key])
+			if err != nil {
+				return err
+			}
+			if cw != nil {
+				err = cw.Write([]string{r.Key, r.Type, r.Value, r.Expiry, r.SoftExpiry})
+			} else {
+				err = enc.Encode(r)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if cw != nil {
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Import reads records written by Export and stores them. Every record is
+// validated against its type before it is stored; the first invalid record
+// stops the import with an error naming it, leaving the records before it
+// imported. Records whose expiry has passed are skipped. Import returns the
+// number of stored entries.
+func (s *CacheStore) Import(r io.Reader, format ExportFormat) (int, error) {
+	if format > ExportCSV {
+		return 0, errors.ErrExportFormat
+	}
+	if s.readOnly.Load() {
+		return 0, errors.ErrReadOnly
+	}
+
+	next := jsonlRecords(r)
+	if format == ExportCSV {
+		next = csvRecords(r)
+	}
+
+	imported := 0
+	for n := 1; ; n++ {
+		rec, err := next()
+		if err == io.EOF {
+			return imported, nil
+		}
+		if err != nil {
+			return imported, errors.ErrImportRecord(n, err)
+		}
+		e, err := fromRecord(rec)
+		if err != nil {
+			return imported, errors.ErrImportRecord(n, err)
+		}
+		if e.IsExpired() {
+			continue
+		}
+
+		sh := s.shardFor(rec.Key)
+		sh.mux.Lock()
+		err = s.unsafeSetEntry(sh, rec.Key, e)
+		sh.mux.Unlock()
+		if err != nil {
+			return imported, errors.ErrImportRecord(n, err)
+		}
+		imported++
+	}
+}
+
+func jsonlRecords(r io.Reader) func() (exportRecord, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	return func() (exportRecord, error) {
+		var rec exportRecord
+		err := dec.Decode(&rec)
+		return rec, err
+	}
+}
+
+func csvRecords(r io.Reader) func() (exportRecord, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(csvHeader)
+	header := true
+	return func() (exportRecord, error) {
+		if header {
+			header = false
+			row, err := cr.Read()
+			if err != nil {
+				return exportRecord{}, err
+			}
+			if !slices.Equal(row, csvHeader) {
+				return exportRecord{}, errors.ErrImportHeader(row)
+			}
+		}
+		row, err := cr.Read()
+		if err != nil {
+			return exportRecord{}, err
+		}
+		return exportRecord{
+			Key:        row[0],
+			Type:       row[1],
+			Value:      row[2],
+			Expiry:     row[3],
+			SoftExpiry: row[4],
+		}, nil
+	}
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"bytes"
+	goerrors "errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func fillExportStore(t *testing.T) *CacheStore {
+	t.Helper()
+	store := newLoaderStore(t)
+	store.SetString("str", "hello, \"world\"\nnext line", 0)
+	store.SetInt64("int", -42, time.Hour)
+	store.SetUInt16("u16", 7, 0)
+	store.SetFloat64("f", 3.25, 0)
+	store.SetBool("b", true, 0)
+	store.SetTime("t", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), 0)
+	store.SetJSON("j", map[string]int{"a": 1}, 0)
+	store.SetRaw("raw", []byte{0, 1, 2, 255}, 0)
+	store.SetSoft("soft", types.STRING, []byte("s"), time.Minute, time.Hour)
+	return store
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	for _, format := range []ExportFormat{ExportJSONL, ExportCSV} {
+		src := fillExportStore(t)
+		var buf bytes.Buffer
+		if err := src.Export(&buf, format); err != nil {
+			t.Fatalf("Export(%d) error = %v", format, err)
+		}
+
+		dst := newLoaderStore(t)
+		n, err := dst.Import(&buf, format)
+		if err != nil {
+			t.Fatalf("Import(%d) error = %v", format, err)
+		}
+		if n != len(src.Keys()) {
+			t.Errorf("Import(%d) stored %d entries, want %d", format, n, len(src.Keys()))
+		}
+		for _, key := range src.Keys() {
+			want, _ := src.peekEntry(key)
+			got, ok := dst.peekEntry(key)
+			if !ok || got.Type != want.Type || !bytes.Equal(got.Data, want.Data) ||
+				got.Expiry != want.Expiry || got.SoftExpiry != want.SoftExpiry {
+				t.Errorf("format %d: %q = %+v, want %+v", format, key, got, want)
+			}
+		}
+	}
+}
+
+func TestExport_HumanReadable(t *testing.T) {
+	store := newLoaderStore(t)
+	store.SetInt64("n", 42, 0)
+	var buf bytes.Buffer
+	store.Export(&buf, ExportJSONL)
+	if got, want := strings.TrimSpace(buf.String()), `{"key":"n","type":"Integer64","value":"42"}`; got != want {
+		t.Errorf("Export() = %s, want %s", got, want)
+	}
+}
+
+func TestImport_Validation(t *testing.T) {
+	tests := []struct {
+		name   string
+		format ExportFormat
+		input  string
+	}{
+		{"unknown type", ExportJSONL, `{"key":"a","type":"Nope","value":"1"}`},
+		{"bad number", ExportJSONL, `{"key":"a","type":"Integer16","value":"99999"}`},
+		{"empty key", ExportJSONL, `{"key":"","type":"String","value":"v"}`},
+		{"unknown field", ExportJSONL, `{"key":"a","type":"String","value":"v","ttl":1}`},
+		{"bad expiry", ExportCSV, "key,type,value,expiry,soft_expiry\na,String,v,tomorrow,\n"},
+		{"bad header", ExportCSV, "k,t,v,e,s\n"},
+		{"missing column", ExportCSV, "key,type,value,expiry,soft_expiry\na,String,v\n"},
+	}
+	for _, tt := range tests {
+		store := newLoaderStore(t)
+		if _, err := store.Import(strings.NewReader(tt.input), tt.format); err == nil {
+			t.Errorf("%s: Import() should fail", tt.name)
+		}
+		if len(store.Keys()) != 0 {
+			t.Errorf("%s: invalid record was stored", tt.name)
+		}
+	}
+}
+
+func TestImport_StopsAtInvalidRecord(t *testing.T) {
+	store := newLoaderStore(t)
+	input := `{"key":"a","type":"String","value":"1"}
+{"key":"old","type":"String","value":"x","expiry":"2000-01-01T00:00:00Z"}
+{"key":"b","type":"Boolean","value":"nope"}
+{"key":"c","type":"String","value":"3"}
+`
+	n, err := store.Import(strings.NewReader(input), ExportJSONL)
+	if err == nil || !strings.Contains(err.Error(), "record 3") {
+		t.Errorf("Import() error = %v, want an error naming record 3", err)
+	}
+	if n != 1 || store.Exists("a") != 1 || store.Exists("old", "c") != 0 {
+		t.Errorf("Import() = %d, keys %v", n, store.Keys())
+	}
+	if _, err := store.Import(strings.NewReader(""), ExportFormat(9)); !goerrors.Is(err, errors.ErrExportFormat) {
+		t.Errorf("Import() with unknown format error = %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package store
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/errors"
+)
+
+const (
+	defaultGCSampleSize       = 20
+	defaultGCExpiredThreshold = 0.25
+	defaultGCCycleBudget      = 25 * time.Millisecond
+)
+
+// GCStats describes a single garbage collection cycle.
+type GCStats struct {
+	Strategy config.GCStrategy
+	Rounds   int           // sampling rounds, or index batches for GCExpiryIndex
+	Sampled  int           // keys inspected
+	Expired  int           // keys removed
+	Duration time.Duration // wall time of the cycle
+	TimedOut bool          // the cycle stopped because GCCycleBudget was exhausted
+}
+
+type gcSettings struct {
+	strategy   config.GCStrategy
+	sampleSize int
+	threshold  float64
+	budget     time.Duration
+	lastStats  atomic.Pointer[GCStats]
+	handler    atomic.Pointer[func(GCStats)]
+	nextShard  int
+}
+
+func newGCSettings(cfg config.Config) (*gcSettings, error) {
+	if cfg.GCStrategy > config.GCAdaptiveSampling {
+		return nil, errors.ErrGCStrategy
+	}
+	if cfg.GCSampleSize < 0 {
+		return nil, errors.ErrGCSampleSize
+	}
+	if cfg.GCExpiredThreshold < 0 || cfg.GCExpiredThreshold >= 1 {
+		return nil, errors.ErrGCExpiredThreshold
+	}
+	if cfg.GCCycleBudget < 0 {
+		return nil, errors.ErrGCCycleBudget
+	}
+	gc := &gcSettings{
+		strategy:   cfg.GCStrategy,
+		sampleSize: cfg.GCSampleSize,
+		threshold:  cfg.GCExpiredThreshold,
+		budget:     cfg.GCCycleBudget,
+	}
+	if gc.sampleSize == 0 {
+		gc.sampleSize = defaultGCSampleSize
+	}
+	if gc.threshold == 0 {
+		gc.threshold = defaultGCExpiredThreshold
+	}
+	if gc.budget == 0 {
+		gc.budget = defaultGCCycleBudget
+	}
+	return gc, nil
+}
+
+// LastGCStats returns the statistics of the most recent garbage collection
+// cycle. ok is false when no cycle has run yet.
+func (s *CacheStore) LastGCStats() (stats GCStats, ok bool) {
+	if p := s.gc.lastStats.Load(); p != nil {
+		return *p, true
+	}
+	return stats, false
+}
+
+// OnGCCycle registers fn to be called with the statistics of every garbage
+// collection cycle. Passing nil removes the handler.
+func (s *CacheStore) OnGCCycle(fn func(GCStats)) {
+	if fn == nil {
+		s.gc.handler.Store(nil)
+		return
+	}
+	s.gc.handler.Store(&fn)
+}
+
+func (s *CacheStore) runGC() {
+	var stats GCStats
+	if s.gc.strategy == config.GCAdaptiveSampling {
+		stats = s.sampleExpired()
+	} else {
+		stats = s.cleanExpired()
+	}
+	s.loads.cleanNegative(time.Now().UnixMilli())
+	s.evictExcess()
+	s.gc.lastStats.Store(&stats)
+	if fn := s.gc.handler.Load(); fn != nil {
+		(*fn)(stats)
+	}
+}
+
+// sampleExpired runs a Redis-like active expiration cycle. Each round samples
+// GCSampleSize keys with a TTL from a shard, removes the expired ones and
+// repeats on the same shard while the expired fraction exceeds
+// GCExpiredThreshold. The whole cycle stops once GCCycleBudget is spent; the
+// next cycle resumes from the shard where this one stopped.
+func (s *CacheStore) sampleExpired() GCStats {
+	start := time.Now()
+	deadline := start.Add(s.gc.budget)
+	stats := GCStats{Strategy: config.GCAdaptiveSampling}
+
+	for i := 0; i < len(s.shards); i++ {
+		idx := (s.gc.nextShard + i) % len(s.shards)
+		sh := s.shards[idx]
+		for {
+			now := time.Now().UnixMilli()
+
+			sh.mux.Lock()
+			sampled, expired := 0, 0
+			for sampled < s.gc.sampleSize && sh.expires.len() > 0 {
+				item := sh.expires.heap[rand.IntN(sh.expires.len())]
+				sampled++
+				if item.expiry <= now {
+					sh.unsafeRemove(item.key)
+					if s.dirty != nil {
+						s.dirty.delete(item.key)
+					}
+					expired++
+				}
+			}
+			sh.mux.Unlock()
+
+			stats.Rounds++
+			stats.Sampled += sampled
+			stats.Expired += expired
+
+			if time.Now().After(deadline) {
+				stats.TimedOut = true
+				s.gc.nextShard = idx
+				stats.Duration = time.Since(start)
+				return stats
+			}
+			if sampled == 0 || float64(expired)/float64(sampled) <= s.gc.threshold {
+				break
+			}
+		}
+	}
+
+	stats.Duration = time.Since(start)
+	return stats
+}
+
+// cleanExpired removes expired keys using the per-shard expiry index, so only
+// keys that actually expired are touched. Each shard is processed in batches
+// of gcBatchSize, releasing its lock in between so other operations can run.
+func (s *CacheStore) cleanExpired() GCStats {
+	start := time.Now()
+	stats := GCStats{Strategy: config.GCExpiryIndex}
+
+	for _, sh := range s.shards {
+		for {
+			now := time.Now().UnixMilli()
+
+			sh.mux.Lock()
+			keys := sh.expires.popExpired(now, gcBatchSize)
+			for _, key := range keys {
+				sh.unsafeRemove(key)
+				if s.dirty != nil {
+					s.dirty.delete(key)
+				}
+			}
+			sh.mux.Unlock()
+
+			stats.Rounds++
+			stats.Sampled += len(keys)
+			stats.Expired += len(keys)
+			if len(keys) < gcBatchSize {
+				break
+			}
+		}
+	}
+
+	stats.Duration = time.Since(start)
+	return stats
+}
@@ -0,0 +1,205 @@
+package store
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/found-cake/CacheStore/utils"
+)
+
+// list is the live form of a list: a deque of its elements in a ring buffer,
+// so pushes and pops at either end take O(1). It is only encoded, see
+// utils.EncodeList, when its bytes are read, persisted or replicated.
+// Elements are never modified in place, only replaced.
+//
+// Like zset, it is guarded by the lock of its shard, and its cached encoding
+// by a lock of its own.
+type list struct {
+	elems [][]byte // ring buffer holding n elements from head
+	head  int
+	n     int
+	// body is the length of the encoded elements, without their count.
+	body int
+
+	mux     sync.Mutex
+	encoded []byte
+}
+
+// listChange is a write to a list: it replaces the element at index with
+// value when value is not nil, pops lpop elements from the head and rpop from
+// the tail, then pushes lpush at the head, one after the other, and rpush at
+// the tail. It is what an opList carries.
+type listChange struct {
+	index        int
+	value        []byte
+	lpop, rpop   int
+	lpush, rpush [][]byte
+}
+
+func newList() *list {
+	return &list{}
+}
+
+// decodeList builds the live form of an encoded list.
+func decodeList(data []byte) (*list, error) {
+	elems, err := utils.DecodeList(data)
+	if err != nil {
+		return nil, err
+	}
+	l := &list{elems: elems, n: len(elems)}
+	for _, elem := range elems {
+		l.body += elemSize(elem)
+	}
+	if len(data) == l.size() {
+		l.encoded = data
+	}
+	return l, nil
+}
+
+// encode returns the encoded form, which is kept until the next change and
+// must not be modified.
+func (l *list) encode() []byte {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	if l.encoded == nil {
+		l.encoded = utils.EncodeList(l.slice(0, l.n))
+	}
+	return l.encoded
+}
+
+// size is the length of the encoded form.
+func (l *list) size() int {
+	return uvarintLen(uint64(l.n)) + l.body
+}
+
+func (l *list) len() int {
+	return l.n
+}
+
+func uvarintLen(x uint64) int {
+	var buf [binary.MaxVarintLen64]byte
+	return binary.PutUvarint(buf[:], x)
+}
+
+// elemSize is what an element adds to the encoded form.
+func elemSize(elem []byte) int {
+	return uvarintLen(uint64(len(elem))) + len(elem)
+}
+
+// at returns the element at position i, which must be within bounds.
+func (l *list) at(i int) []byte {
+	return l.elems[(l.head+i)%len(l.elems)]
+}
+
+// slice returns the elements from position from to to, to excluded. They
+// are shared with l.
+func (l *list) slice(from, to int) [][]byte {
+	elems := make([][]byte, 0, to-from)
+	for i := from; i < to; i++ {
+		elems = append(elems, l.at(i))
+	}
+	return elems
+}
+
+func (l *list) set(i int, elem []byte) []byte {
+	p := &l.elems[(l.head+i)%len(l.elems)]
+	old := *p
+	*p = elem
+	l.body += elemSize(elem) - elemSize(old)
+	l.encoded = nil
+	return old
+}
+
+// grow makes room for one more element.
+func (l *list) grow() {
+	if l.n < len(l.elems) {
+		return
+	}
+	elems := make([][]byte, max(4, 2*len(l.elems)))
+	for i := 0; i < l.n; i++ {
+		elems[i] = l.at(i)
+	}
+	l.elems, l.head = elems, 0
+}
+
+func (l *list) pushHead(elem []byte) {
+	l.grow()
+	l.head = (l.head + len(l.elems) - 1) % len(l.elems)
+	l.elems[l.head] = elem
+	l.pushed(elem)
+}
+
+func (l *list) pushTail(elem []byte) {
+	l.grow()
+	l.elems[(l.head+l.n)%len(l.elems)] = elem
+	l.pushed(elem)
+}
+
+func (l *list) pushed(elem []byte) {
+	l.n++
+	l.body += elemSize(elem)
+	l.encoded = nil
+}
+
+// popHead and popTail remove an element from a list that is not empty.
+func (l *list) popHead() []byte {
+	elem := l.elems[l.head]
+	l.elems[l.head] = nil
+	l.head = (l.head + 1) % len(l.elems)
+	l.popped(elem)
+	return elem
+}
+
+func (l *list) popTail() []byte {
+	i := (l.head + l.n - 1) % len(l.elems)
+	elem := l.elems[i]
+	l.elems[i] = nil
+	l.popped(elem)
+	return elem
+}
+
+func (l *list) popped(elem []byte) {
+	l.n--
+	l.body -= elemSize(elem)
+	l.encoded = nil
+}
+
+// apply makes the change c, popping no more elements than there are, and
+// returns a func undoing it.
+func (l *list) apply(c listChange) (undo func()) {
+	var replaced []byte
+	if c.value != nil {
+		replaced = l.set(c.index, c.value)
+	}
+	lpopped := make([][]byte, 0, min(c.lpop, l.n))
+	for len(lpopped) < c.lpop && l.n > 0 {
+		lpopped = append(lpopped, l.popHead())
+	}
+	rpopped := make([][]byte, 0, min(c.rpop, l.n))
+	for len(rpopped) < c.rpop && l.n > 0 {
+		rpopped = append(rpopped, l.popTail())
+	}
+	for _, elem := range c.lpush {
+		l.pushHead(elem)
+	}
+	for _, elem := range c.rpush {
+		l.pushTail(elem)
+	}
+	return func() {
+		for range c.rpush {
+			l.popTail()
+		}
+		for range c.lpush {
+			l.popHead()
+		}
+		for i := len(rpopped) - 1; i >= 0; i-- {
+			l.pushTail(rpopped[i])
+		}
+		for i := len(lpopped) - 1; i >= 0; i-- {
+			l.pushHead(lpopped[i])
+		}
+		if c.value != nil {
+			l.set(c.index, replaced)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package store
+
+import (
+	"context"
+	goerrors "errors"
+	"sync"
+	"time"
+
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// LoadResult is what a Loader fetched from the backing source. The value is
+// cached for TTL; a TTL <= 0 caches it without expiry. When Fresh is set the
+// value becomes stale after Fresh and is refreshed in the background by the
+// store's refresher, see SetRefresher.
+type LoadResult struct {
+	Type  types.DataType
+	Data  []byte
+	TTL   time.Duration
+	Fresh time.Duration
+}
+
+// Loader fetches the value of a key missing from the cache. It should return
+// errors.ErrLoadNotFound (optionally wrapped) when the key does not exist in
+// the backing source, so the miss can be negatively cached.
+type Loader func(ctx context.Context, key string) (LoadResult, error)
+
+type loadOptions struct {
+	negativeTTL time.Duration
+}
+
+type LoadOption func(*loadOptions)
+
+// WithNegativeTTL remembers errors.ErrLoadNotFound results for ttl, so
+// repeated lookups of a missing key do not reach the loader.
+func WithNegativeTTL(ttl time.Duration) LoadOption {
+	return func(o *loadOptions) {
+		o.negativeTTL = ttl
+	}
+}
+
+type loadCall struct {
+	done   chan struct{}
+	result LoadResult
+	err    error
+}
+
+type negativeEntry struct {
+	err    error
+	expiry int64
+}
+
+// loadGroup deduplicates concurrent loads of the same key and keeps the
+// negative cache.
+type loadGroup struct {
+	mux      sync.Mutex
+	calls    map[string]*loadCall
+	negative map[string]negativeEntry
+}
+
+func (g *loadGroup) negativeHit(key string, now int64) error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	n, ok := g.negative[key]
+	if !ok {
+		return nil
+	}
+	if n.expiry <= now {
+		delete(g.negative, key)
+		return nil
+	}
+	return n.err
+}
+
+func (g *loadGroup) rememberMiss(key string, err error, ttl time.Duration) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	if g.negative == nil {
+		g.negative = make(map[string]negativeEntry)
+	}
+	g.negative[key] = negativeEntry{
+		err:    err,
+		expiry: time.Now().Add(ttl).UnixMilli(),
+	}
+}
+
+func (g *loadGroup) cleanNegative(now int64) {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	for key, n := range g.negative {
+		if n.expiry <= now {
+			delete(g.negative, key)
+		}
+	}
+}
+
+// do runs fn once for all concurrent callers of the same key. Each caller
+// stops waiting when its own context ends. The load itself runs on a context
+// detached from the caller that started it, keeping its values, and is only
+// canceled when parent ends. It is tracked by wg so the store can wait for it
+// on Close.
+func (g *loadGroup) do(ctx, parent context.Context, wg *sync.WaitGroup, key string, fn func(ctx context.Context) (LoadResult, error)) (LoadResult, error) {
+	g.mux.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*loadCall)
+	}
+	call, ok := g.calls[key]
+	if !ok {
+		call = &loadCall{done: make(chan struct{})}
+		g.calls[key] = call
+		wg.Add(1)
+		loadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		go func() {
+			defer wg.Done()
+			defer cancel()
+			stop := context.AfterFunc(parent, cancel)
+			defer stop()
+			defer func() {
+				if v := recover(); v != nil {
+					call.err = errors.ErrLoaderPanic(key, v)
+				}
+				g.mux.Lock()
+				delete(g.calls, key)
+				g.mux.Unlock()
+				close(call.done)
+			}()
+			call.result, call.err = fn(loadCtx)
+		}()
+	}
+	g.mux.Unlock()
+
+	select {
+	case <-call.done:
+		return call.result, call.err
+	case <-ctx.Done():
+		return LoadResult{}, ctx.Err()
+	}
+}
+
+// GetOrLoad returns the cached value of key, calling loader on a miss. The
+// loader runs once per key no matter how many goroutines miss concurrently;
+// all of them receive its result or error. The loaded value is stored with the
+// TTL returned by the loader.
+//
+// The loader receives the values of the context of the goroutine that
+// triggered the load, but not its cancellation: it keeps running for the other
+// callers when that goroutine gives up, and is only canceled on Close.
+func (s *CacheStore) GetOrLoad(ctx context.Context, key string, loader Loader, opts ...LoadOption) (types.DataType, []byte, error) {
+	if key == "" {
+		return types.UNKNOWN, nil, errors.ErrKeyEmpty
+	}
+	if loader == nil {
+		return types.UNKNOWN, nil, errors.ErrLoaderNil
+	}
+	if dataType, value, err := s.Get(key); err == nil {
+		return dataType, value, nil
+	}
+
+	var options loadOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if err := s.loads.negativeHit(key, time.Now().UnixMilli()); err != nil {
+		return types.UNKNOWN, nil, err
+	}
+
+	result, err := s.loads.do(ctx, s.ctx, &s.wg, key, func(ctx context.Context) (LoadResult, error) {
+		if dataType, value, err := s.Get(key); err == nil {
+			return LoadResult{Type: dataType, Data: value}, nil
+		}
+		result, err := loader(ctx, key)
+		if err != nil {
+			if options.negativeTTL > 0 && goerrors.Is(err, errors.ErrLoadNotFound) {
+				s.loads.rememberMiss(key, err, options.negativeTTL)
+			}
+			return LoadResult{}, err
+		}
+		// A follower returns loaded values without caching them.
+		if err := s.SetSoft(key, result.Type, result.Data, result.Fresh, result.TTL); err != nil && err != errors.ErrReadOnly {
+			return LoadResult{}, err
+		}
+		return result, nil
+	})
+	if err != nil {
+		return types.UNKNOWN, nil, err
+	}
+
+	value := make([]byte, len(result.Data))
+	copy(value, result.Data)
+	return result.Type, value, nil
+}
+
+// GetOrLoad is the typed counterpart of CacheStore.GetOrLoad. The loader
+// returns a value of T and the TTL to cache it with.
+func (t *Typed[T]) GetOrLoad(ctx context.Context, key string, loader func(ctx context.Context, key string) (T, time.Duration, error), opts ...LoadOption) (T, error) {
+	var zero T
+	if loader == nil {
+		return zero, errors.ErrLoaderNil
+	}
+	dataType, data, err := t.store.GetOrLoad(ctx, key, func(ctx context.Context, key string) (LoadResult, error) {
+		value, ttl, err := loader(ctx, key)
+		if err != nil {
+			return LoadResult{}, err
+		}
+		data, err := t.codec.Encode(value)
+		if err != nil {
+			return LoadResult{}, err
+		}
+		return LoadResult{Type: t.codec.DataType(), Data: data, TTL: ttl}, nil
+	}, opts...)
+	if err != nil {
+		return zero, err
+	}
+	return t.decode(key, entry.Entry{Type: dataType, Data: data})
+}
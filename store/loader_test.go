@@ -0,0 +1,223 @@
+package store
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func newLoaderStore(t *testing.T) *CacheStore {
+	t.Helper()
+	store, err := NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestGetOrLoad_LoadsOnceAndCaches(t *testing.T) {
+	store := newLoaderStore(t)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) (LoadResult, error) {
+		calls.Add(1)
+		<-release
+		return LoadResult{Type: types.STRING, Data: []byte("value:" + key), TTL: time.Hour}, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, 20)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, v, err := store.GetOrLoad(context.Background(), "k", loader)
+			if err != nil {
+				t.Errorf("GetOrLoad() error = %v", err)
+			}
+			results[i] = string(v)
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader called %d times, want 1", n)
+	}
+	for i, v := range results {
+		if v != "value:k" {
+			t.Errorf("results[%d] = %q", i, v)
+		}
+	}
+	if ttl := store.TTL("k"); ttl <= 0 {
+		t.Errorf("TTL() = %v, want loader TTL", ttl)
+	}
+	store.GetOrLoad(context.Background(), "k", loader)
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader called %d times after cache hit, want 1", n)
+	}
+}
+
+func TestGetOrLoad_ErrorPropagates(t *testing.T) {
+	store := newLoaderStore(t)
+
+	boom := fmt.Errorf("backend down")
+	var calls atomic.Int32
+	loader := func(ctx context.Context, key string) (LoadResult, error) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return LoadResult{}, boom
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := store.GetOrLoad(context.Background(), "k", loader); !goerrors.Is(err, boom) {
+				t.Errorf("GetOrLoad() error = %v, want %v", err, boom)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls.Load() > 2 {
+		t.Errorf("loader called %d times, want deduplicated calls", calls.Load())
+	}
+	if store.Exists("k") != 0 {
+		t.Error("failed load must not be cached")
+	}
+}
+
+func TestGetOrLoad_NegativeCache(t *testing.T) {
+	store := newLoaderStore(t)
+
+	var calls atomic.Int32
+	loader := func(ctx context.Context, key string) (LoadResult, error) {
+		calls.Add(1)
+		return LoadResult{}, fmt.Errorf("user %s: %w", key, errors.ErrLoadNotFound)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, _, err := store.GetOrLoad(context.Background(), "missing", loader, WithNegativeTTL(100*time.Millisecond))
+		if !goerrors.Is(err, errors.ErrLoadNotFound) {
+			t.Fatalf("GetOrLoad() error = %v", err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader called %d times, want 1 with negative cache", n)
+	}
+
+	store.SetString("missing", "now here", 0)
+	if _, v, err := store.GetOrLoad(context.Background(), "missing", loader); err != nil || string(v) != "now here" {
+		t.Errorf("GetOrLoad() = %q, %v, want cached value", v, err)
+	}
+	store.Delete("missing")
+
+	time.Sleep(150 * time.Millisecond)
+	store.GetOrLoad(context.Background(), "missing", loader, WithNegativeTTL(time.Second))
+	if n := calls.Load(); n != 2 {
+		t.Errorf("loader called %d times, want 2 after negative ttl", n)
+	}
+}
+
+func TestGetOrLoad_ContextAndPanic(t *testing.T) {
+	store := newLoaderStore(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, _, err := store.GetOrLoad(ctx, "slow", func(ctx context.Context, key string) (LoadResult, error) {
+		time.Sleep(200 * time.Millisecond)
+		return LoadResult{Type: types.RAW, Data: []byte{1}}, nil
+	})
+	if !goerrors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetOrLoad() error = %v, want deadline exceeded", err)
+	}
+
+	_, _, err = store.GetOrLoad(context.Background(), "panic", func(ctx context.Context, key string) (LoadResult, error) {
+		panic("boom")
+	})
+	if err == nil {
+		t.Error("GetOrLoad() expected error from panicking loader")
+	}
+
+	if _, _, err := store.GetOrLoad(context.Background(), "k", nil); err == nil {
+		t.Error("GetOrLoad() expected error for nil loader")
+	}
+}
+
+func TestGetOrLoad_DetachedContext(t *testing.T) {
+	store := newLoaderStore(t)
+
+	type ctxKey struct{}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) (LoadResult, error) {
+		close(started)
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return LoadResult{}, ctx.Err()
+		}
+		return LoadResult{Type: types.STRING, Data: []byte(ctx.Value(ctxKey{}).(string))}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "from first"))
+	first := make(chan error, 1)
+	go func() {
+		_, _, err := store.GetOrLoad(ctx, "k", loader)
+		first <- err
+	}()
+	<-started
+
+	second := make(chan string, 1)
+	go func() {
+		_, v, err := store.GetOrLoad(context.Background(), "k", loader)
+		if err != nil {
+			t.Errorf("GetOrLoad() error = %v", err)
+		}
+		second <- string(v)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	if err := <-first; !goerrors.Is(err, context.Canceled) {
+		t.Errorf("first GetOrLoad() error = %v, want canceled", err)
+	}
+	close(release)
+	if v := <-second; v != "from first" {
+		t.Errorf("second GetOrLoad() = %q, want the load to survive the first caller", v)
+	}
+}
+
+func TestTypedGetOrLoad(t *testing.T) {
+	store := newLoaderStore(t)
+	counters := NewTyped(store, Int64Codec)
+
+	v, err := counters.GetOrLoad(context.Background(), "n", func(ctx context.Context, key string) (int64, time.Duration, error) {
+		return 99, time.Minute, nil
+	})
+	if err != nil || v != 99 {
+		t.Errorf("GetOrLoad() = %v, %v, want 99", v, err)
+	}
+	if got, err := store.GetInt64("n"); err != nil || got != 99 {
+		t.Errorf("GetInt64() = %v, %v", got, err)
+	}
+
+	store.SetString("s", "text", 0)
+	if _, err := counters.GetOrLoad(context.Background(), "s", func(ctx context.Context, key string) (int64, time.Duration, error) {
+		return 1, 0, nil
+	}); err == nil {
+		t.Error("GetOrLoad() expected type mismatch for cached string")
+	}
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/utils"
+)
+
+type opKind uint8
+
+const (
+	opSet opKind = iota + 1
+	opDelete
+	opFlush
+	opPing // replication heartbeat, never logged
+	opZSet // members of an existing sorted set changed
+	opList // elements of an existing list pushed, popped or replaced
+)
+
+// op is a single change applied to the store: the same events the dirty
+// manager tracks, but carrying the written entry. An opZSet carries the
+// entry without data, and the members it sets and removes, in that order.
+// An opList carries the entry without data and the change to the list.
+type op struct {
+	kind  opKind
+	key   string
+	entry entry.Entry
+	zadd  []utils.ZMember
+	zrem  []string
+	list  listChange
+}
+
+// logDelete and logFlush publish a change, as unsafePublish does for writes.
+// They must be called while the shard owning key (or every shard, for
+// flushes) is still locked, so the log sees the changes of a key in the order
+// they were applied.
+func (s *CacheStore) logDelete(key string) {
+	s.logOps([]op{{kind: opDelete, key: key}})
+}
+
+func (s *CacheStore) logFlush() {
+	s.logOps([]op{{kind: opFlush}})
+}
+
+// logOps publishes the changes of a multi-key operation at once, to the
+// append-only log, to the replication backlog and to BLPop callers.
+func (s *CacheStore) logOps(ops []op) {
+	if len(ops) == 0 {
+		return
+	}
+	if s.aof != nil {
+		s.aof.append(ops...)
+	}
+	if b := s.repl.Load(); b != nil {
+		b.append(ops...)
+	}
+	s.lists.notify(ops)
+}
+
+// unsafeApply replays a logged change at startup, before the store is shared.
+func (s *CacheStore) unsafeApply(o op, now int64) {
+	switch o.kind {
+	case opSet:
+		if o.entry.Version > s.version.Load() {
+			s.version.Store(o.entry.Version)
+		}
+		sh := s.shardFor(o.key)
+		if o.entry.IsExpiredWithUnixMilli(now) {
+			sh.unsafeRemove(o.key)
+			if s.dirty != nil {
+				s.dirty.delete(o.key)
+			}
+			return
+		}
+		evicted, err := sh.unsafePut(o.key, o.entry)
+		if s.dirty != nil {
+			for _, k := range evicted {
+				s.dirty.delete(k)
+			}
+			if err == nil {
+				s.dirty.set(o.key)
+			}
+		}
+	case opZSet, opList:
+		if o.entry.Version > s.version.Load() {
+			s.version.Store(o.entry.Version)
+		}
+		sh := s.shardFor(o.key)
+		apply := sh.unsafeApplyZSet
+		if o.kind == opList {
+			apply = sh.unsafeApplyList
+		}
+		evicted, stored, err := apply(o, now)
+		if s.dirty != nil {
+			for _, k := range evicted {
+				s.dirty.delete(k)
+			}
+			if stored {
+				s.dirty.set(o.key)
+			} else if err == nil {
+				s.dirty.delete(o.key)
+			}
+		}
+	case opDelete:
+		s.shardFor(o.key).unsafeRemove(o.key)
+		if s.dirty != nil {
+			s.dirty.delete(o.key)
+		}
+	case opFlush:
+		for _, sh := range s.shards {
+			sh.unsafeFlush()
+		}
+		if s.dirty != nil {
+			s.dirty.wantFullSync()
+		}
+	}
+}
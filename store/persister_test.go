@@ -0,0 +1,114 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/persist"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func TestCustomPersister_Restart(t *testing.T) {
+	mem := persist.NewMemory()
+	cfg := config.Config{Persister: mem}
+
+	store, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	store.SetString("k", "v", time.Hour)
+	_, _, version, _ := store.GetWithVersion("k")
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if mem.Len() != 1 {
+		t.Fatalf("persister holds %d entries, want 1", mem.Len())
+	}
+
+	store, err = NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to re-create store: %v", err)
+	}
+	defer store.Close()
+	if _, v, got, err := store.GetWithVersion("k"); err != nil || string(v) != "v" || got != version {
+		t.Errorf("GetWithVersion() after restart = %q, %d, %v", v, got, err)
+	}
+}
+
+func TestCustomPersister_Sync(t *testing.T) {
+	mem := persist.NewMemory()
+	store, err := NewCacheStore(config.Config{
+		Persister:           mem,
+		SaveDirtyData:       true,
+		DirtyThresholdCount: 100,
+		DirtyThresholdRatio: 1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	store.Set("a", types.STRING, []byte("1"), 0)
+	store.Set("b", types.STRING, []byte("2"), 0)
+	store.Sync()
+	waitFor(t, time.Second, func() bool { return mem.Len() == 2 })
+
+	store.Delete("a")
+	store.Sync()
+	waitFor(t, time.Second, func() bool { return mem.Len() == 1 })
+
+	store.Flush()
+	store.FullSync()
+	waitFor(t, time.Second, func() bool { return mem.Len() == 0 })
+}
+
+func TestNopPersister(t *testing.T) {
+	store, err := NewCacheStore(config.Config{Persister: persist.Nop{}})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	store.SetString("k", "v", 0)
+	if err := store.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
+
+// closeCounter counts the calls to Close of the persister it wraps.
+type closeCounter struct {
+	persist.Persister
+	loads, closes int
+}
+
+func (p *closeCounter) Load() (map[string]entry.Entry, uint64, error) {
+	p.loads++
+	return p.Persister.Load()
+}
+
+func (p *closeCounter) Close() error {
+	p.closes++
+	return p.Persister.Close()
+}
+
+func TestCustomPersister_ClosedOnError(t *testing.T) {
+	p := &closeCounter{Persister: persist.NewMemory()}
+	_, err := NewCacheStore(config.Config{Persister: p, AOFFileName: t.TempDir()})
+	if err == nil {
+		t.Fatal("NewCacheStore() with a directory as AOF file succeeded")
+	}
+	if p.closes != 1 {
+		t.Errorf("persister closed %d times, want 1", p.closes)
+	}
+
+	p = &closeCounter{Persister: persist.NewMemory()}
+	_, err = NewCacheStore(config.Config{
+		Persister:   p,
+		AOFFileName: filepath.Join(t.TempDir(), "cache.aof"),
+		AOFSync:     config.AOFSyncNever + 1,
+	})
+	if err == nil || p.loads != 0 || p.closes != 0 {
+		t.Errorf("NewCacheStore() with an invalid sync policy = %v after %d loads, %d closes, want an error before loading", err, p.loads, p.closes)
+	}
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	goerrors "errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// refreshGroup runs at most one background refresh per key.
+type refreshGroup struct {
+	fn       atomic.Pointer[Loader]
+	mux      sync.Mutex
+	inflight map[string]struct{}
+}
+
+// SetRefresher registers the function used to revalidate stale entries.
+// Reading an entry after its soft expiry returns the stale value immediately
+// and triggers fn in the background; its result replaces the entry. When fn
+// returns errors.ErrLoadNotFound the entry is deleted. Either is skipped when
+// the entry was written while fn ran, as the newer value wins. A panic in fn
+// is logged. Passing nil disables background refreshes.
+func (s *CacheStore) SetRefresher(fn Loader) {
+	if fn == nil {
+		s.refresh.fn.Store(nil)
+		return
+	}
+	s.refresh.fn.Store(&fn)
+}
+
+// SetSoft stores value so that it is fresh for soft and removed after expiry.
+// Between both deadlines reads still return the value but schedule a refresh.
+func (s *CacheStore) SetSoft(key string, dataType types.DataType, value []byte, soft, expiry time.Duration) error {
+	if key == "" {
+		return errors.ErrKeyEmpty
+	}
+	if value == nil {
+		return errors.ErrValueNil
+	}
+	if !dataType.IsKnown() {
+		return errors.ErrUnknownDataType(dataType)
+	}
+	if s.readOnly.Load() {
+		return errors.ErrReadOnly
+	}
+
+	sh := s.shardFor(key)
+	sh.mux.Lock()
+	defer sh.mux.Unlock()
+
+	return s.unsafeSetEntry(sh, key, entry.NewSoftEntry(dataType, value, soft, expiry))
+}
+
+// refreshStale is called by the read paths, possibly under a shard read lock,
+// so it must never block. version is the version of the stale entry read.
+func (s *CacheStore) refreshStale(key string, version uint64) {
+	fn := s.refresh.fn.Load()
+	if fn == nil || s.IsClosed() || s.readOnly.Load() {
+		return
+	}
+
+	s.refresh.mux.Lock()
+	if _, ok := s.refresh.inflight[key]; ok {
+		s.refresh.mux.Unlock()
+		return
+	}
+	if s.refresh.inflight == nil {
+		s.refresh.inflight = make(map[string]struct{})
+	}
+	s.refresh.inflight[key] = struct{}{}
+	s.refresh.mux.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			if v := recover(); v != nil {
+				log.Println(errors.ErrLoaderPanic(key, v))
+			}
+			s.refresh.mux.Lock()
+			delete(s.refresh.inflight, key)
+			s.refresh.mux.Unlock()
+		}()
+
+		result, err := (*fn)(s.ctx, key)
+		if err == nil && result.Data == nil {
+			err = errors.ErrValueNil
+		}
+		if err != nil {
+			if !goerrors.Is(err, errors.ErrLoadNotFound) {
+				log.Println(err)
+				return
+			}
+			result.Data = nil
+		}
+		if err := s.refreshed(key, version, result); err != nil {
+			log.Println(err)
+		}
+	}()
+}
+
+// refreshed stores the result of a refresh of key, or deletes key when it has
+// no data, unless key was written since its version was read.
+func (s *CacheStore) refreshed(key string, version uint64, result LoadResult) error {
+	if result.Data != nil && !result.Type.IsKnown() {
+		return errors.ErrUnknownDataType(result.Type)
+	}
+	if s.readOnly.Load() {
+		return errors.ErrReadOnly
+	}
+	sh := s.shardFor(key)
+	sh.mux.Lock()
+	defer sh.mux.Unlock()
+	if sh.unsafeVersion(key) != version {
+		return nil
+	}
+	if result.Data == nil {
+		s.unsafeDelete(sh, key)
+		return nil
+	}
+	return s.unsafeSetEntry(sh, key, entry.NewSoftEntry(result.Type, result.Data, result.Fresh, result.TTL))
+}
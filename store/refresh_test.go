@@ -0,0 +1,211 @@
+package store
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSoftTTL_ServesStaleAndRefreshes(t *testing.T) {
+	store, err := NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	var calls atomic.Int32
+	store.SetRefresher(func(ctx context.Context, key string) (LoadResult, error) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return LoadResult{Type: types.STRING, Data: []byte("fresh"), Fresh: time.Hour, TTL: 2 * time.Hour}, nil
+	})
+
+	if err := store.SetSoft("k", types.STRING, []byte("old"), 50*time.Millisecond, time.Hour); err != nil {
+		t.Fatalf("SetSoft() error = %v", err)
+	}
+	if v, _ := store.GetString("k"); v != "old" {
+		t.Errorf("GetString() = %q, want old while fresh", v)
+	}
+	if calls.Load() != 0 {
+		t.Error("fresh entries must not be refreshed")
+	}
+
+	time.Sleep(80 * time.Millisecond)
+	for i := 0; i < 5; i++ {
+		if v, err := store.GetString("k"); err != nil || v != "old" {
+			t.Errorf("GetString() = %q, %v, want stale value", v, err)
+		}
+	}
+	waitFor(t, time.Second, func() bool {
+		v, _ := store.GetString("k")
+		return v == "fresh"
+	})
+	if n := calls.Load(); n != 1 {
+		t.Errorf("refresher called %d times, want 1", n)
+	}
+	if ttl := store.TTL("k"); ttl < time.Hour {
+		t.Errorf("TTL() = %v, want refreshed hard ttl", ttl)
+	}
+}
+
+func TestSoftTTL_HardExpiryWins(t *testing.T) {
+	store, err := NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	store.SetSoft("k", types.STRING, []byte("v"), 20*time.Millisecond, 50*time.Millisecond)
+	time.Sleep(80 * time.Millisecond)
+	if _, err := store.GetString("k"); err == nil {
+		t.Error("GetString() expected error after hard expiry")
+	}
+}
+
+func TestSoftTTL_RefresherNotFoundDeletes(t *testing.T) {
+	store, err := NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	store.SetRefresher(func(ctx context.Context, key string) (LoadResult, error) {
+		return LoadResult{}, errors.ErrLoadNotFound
+	})
+	store.SetSoft("k", types.STRING, []byte("v"), time.Millisecond, time.Hour)
+	time.Sleep(5 * time.Millisecond)
+
+	results := store.MGet("k")
+	if results[0].Error != nil {
+		t.Errorf("MGet() should serve the stale value, got %v", results[0].Error)
+	}
+	waitFor(t, time.Second, func() bool { return store.Exists("k") == 0 })
+}
+
+func TestSoftTTL_RefreshKeepsNewerWrites(t *testing.T) {
+	store, err := NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	started, release := make(chan struct{}), make(chan struct{})
+	var notFound atomic.Bool
+	store.SetRefresher(func(ctx context.Context, key string) (LoadResult, error) {
+		started <- struct{}{}
+		<-release
+		if notFound.Load() {
+			return LoadResult{}, errors.ErrLoadNotFound
+		}
+		return LoadResult{Type: types.STRING, Data: []byte("refreshed"), TTL: time.Hour}, nil
+	})
+
+	for _, deleted := range []bool{false, true} {
+		notFound.Store(deleted)
+		store.SetSoft("k", types.STRING, []byte("stale"), time.Millisecond, time.Hour)
+		time.Sleep(5 * time.Millisecond)
+		store.GetString("k")
+		<-started
+		store.SetString("k", "newer", 0)
+		release <- struct{}{}
+		store.wg.Wait()
+		if v, err := store.GetString("k"); err != nil || v != "newer" {
+			t.Errorf("GetString() after a refresh (not found: %v) = %q, %v, want the newer write", deleted, v, err)
+		}
+	}
+}
+
+func TestSoftTTL_RefresherPanic(t *testing.T) {
+	store, err := NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	var calls atomic.Int32
+	store.SetRefresher(func(ctx context.Context, key string) (LoadResult, error) {
+		calls.Add(1)
+		panic("backend exploded")
+	})
+	store.SetSoft("k", types.STRING, []byte("v"), time.Millisecond, time.Hour)
+	time.Sleep(5 * time.Millisecond)
+	store.GetString("k")
+	store.wg.Wait()
+	if v, err := store.GetString("k"); err != nil || v != "v" {
+		t.Errorf("GetString() after a panicking refresh = %q, %v, want the stale value", v, err)
+	}
+	store.wg.Wait()
+	if n := calls.Load(); n != 2 {
+		t.Errorf("refresher called %d times, want a new refresh after the panic", n)
+	}
+}
+
+func TestSoftTTL_IncrKeepsDeadlines(t *testing.T) {
+	store, err := NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	store.SetSoft("n", types.INT64, []byte{1, 0, 0, 0, 0, 0, 0, 0}, time.Minute, time.Hour)
+	before, _ := store.peekEntry("n")
+	store.IncrInt64("n", 1, 0)
+	after, _ := store.peekEntry("n")
+	if after.SoftExpiry != before.SoftExpiry || after.Expiry != before.Expiry {
+		t.Errorf("Incr changed deadlines: before %+v, after %+v", before, after)
+	}
+}
+
+func TestSoftTTL_Persistence(t *testing.T) {
+	cfg := config.Config{DBSave: true, DBFileName: tempDBFile(t)}
+	store, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	store.SetSoft("k", types.STRING, []byte("v"), time.Minute, time.Hour)
+	want, _ := store.peekEntry("k")
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	store, err = NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to re-create store: %v", err)
+	}
+	defer store.Close()
+	got, ok := store.peekEntry("k")
+	if !ok || got.SoftExpiry != want.SoftExpiry {
+		t.Errorf("soft expiry after reload = %d, want %d", got.SoftExpiry, want.SoftExpiry)
+	}
+}
+
+func TestGetOrLoad_FreshSetsSoftExpiry(t *testing.T) {
+	store, err := NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	store.GetOrLoad(context.Background(), "k", func(ctx context.Context, key string) (LoadResult, error) {
+		return LoadResult{Type: types.STRING, Data: []byte("v"), Fresh: time.Minute, TTL: time.Hour}, nil
+	})
+	if e, _ := store.peekEntry("k"); e.SoftExpiry == 0 {
+		t.Error("GetOrLoad() should store the soft expiry")
+	}
+}
@@ -0,0 +1,482 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/snapshot"
+)
+
+// Replication streams the changes of a primary to read-only followers. The
+// follower opens the connection and sends
+//
+//	magic "CSREPL01" | replication id (16 bytes) | offset (uint64)
+//
+// naming the stream it followed last and how far it got. When the id is the
+// primary's and its backlog still holds everything after offset, the primary
+// answers
+//
+//	'+' | replication id | offset
+//
+// and otherwise sends its whole content, in the format of the snapshot
+// package:
+//
+//	'*' | replication id | offset | snapshot length (uint64) | snapshot
+//
+// Both are followed by the changes made after offset, as append-only log
+// records; offsets count the bytes of these records. When there is nothing
+// to send the primary sends heartbeat records, which are not counted.
+const (
+	replMagic        = "CSREPL01"
+	replIDSize       = 16
+	replContinue     = '+'
+	replFullSync     = '*'
+	replHelloSize    = len(replMagic) + replIDSize + 8
+	replReplySize    = 1 + replIDSize + 8
+	replPingInterval = time.Second
+	replReadTimeout  = 3 * replPingInterval
+	replRetryMin     = 100 * time.Millisecond
+	replRetryMax     = 5 * time.Second
+
+	defaultReplBacklogSize = 1 << 20
+)
+
+// replBacklog keeps the most recent records of the replication stream.
+type replBacklog struct {
+	id    [replIDSize]byte
+	limit int
+
+	mux     sync.Mutex
+	buf     []byte
+	start   uint64        // offset of buf[0]
+	changed chan struct{} // closed by append when waiting is set
+	waiting bool
+}
+
+func newReplBacklog(limit int) (*replBacklog, error) {
+	b := &replBacklog{limit: limit, changed: make(chan struct{})}
+	if _, err := rand.Read(b.id[:]); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *replBacklog) append(ops ...op) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	for _, o := range ops {
+		b.buf = encodeOp(b.buf, o)
+	}
+	for len(b.buf) > b.limit {
+		n := aofHeaderSize + int(binary.BigEndian.Uint32(b.buf))
+		b.buf = b.buf[n:]
+		b.start += uint64(n)
+	}
+	if b.waiting {
+		close(b.changed)
+		b.changed = make(chan struct{})
+		b.waiting = false
+	}
+}
+
+func (b *replBacklog) unsafeEnd() uint64 {
+	return b.start + uint64(len(b.buf))
+}
+
+func (b *replBacklog) end() uint64 {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.unsafeEnd()
+}
+
+func (b *replBacklog) has(offset uint64) bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return offset >= b.start && offset <= b.unsafeEnd()
+}
+
+// read returns a copy of the records after offset or, when there are none
+// yet, a channel closed by the next append.
+func (b *replBacklog) read(offset uint64) ([]byte, <-chan struct{}, error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	end := b.unsafeEnd()
+	if offset < b.start || offset > end {
+		return nil, nil, errors.ErrReplBacklogLost
+	}
+	if offset == end {
+		b.waiting = true
+		return nil, b.changed, nil
+	}
+	return bytes.Clone(b.buf[offset-b.start:]), nil, nil
+}
+
+// backlog returns the replication backlog, creating it on first use. Until
+// then writes are not recorded for replication.
+func (s *CacheStore) backlog() (*replBacklog, error) {
+	if b := s.repl.Load(); b != nil {
+		return b, nil
+	}
+	lockShards(s.shards)
+	defer unlockShards(s.shards)
+	if b := s.repl.Load(); b != nil {
+		return b, nil
+	}
+	size := s.replSize
+	if size == 0 {
+		size = defaultReplBacklogSize
+	}
+	b, err := newReplBacklog(size)
+	if err != nil {
+		return nil, err
+	}
+	s.repl.Store(b)
+	return b, nil
+}
+
+// ServeReplica streams the changes of s to the follower at the other end of
+// rw, see Follow, starting with what the follower is missing. It returns when
+// rw fails, when the follower falls too far behind for the backlog (see
+// config.Config.ReplBacklogSize), or with nil when s is closed; rw is closed
+// with s when it is an io.Closer.
+func (s *CacheStore) ServeReplica(rw io.ReadWriter) error {
+	if c, ok := rw.(io.Closer); ok {
+		stop := context.AfterFunc(s.ctx, func() { c.Close() })
+		defer stop()
+	}
+	b, err := s.backlog()
+	if err != nil {
+		return err
+	}
+
+	hello := make([]byte, replHelloSize)
+	if _, err := io.ReadFull(rw, hello); err != nil {
+		return err
+	}
+	if string(hello[:len(replMagic)]) != replMagic {
+		return errors.ErrProtocol("replication: unexpected handshake")
+	}
+	id := hello[len(replMagic) : len(replMagic)+replIDSize]
+	offset := binary.BigEndian.Uint64(hello[len(replMagic)+replIDSize:])
+
+	w := bufio.NewWriter(rw)
+	if bytes.Equal(id, b.id[:]) && b.has(offset) {
+		w.WriteByte(replContinue)
+		w.Write(b.id[:])
+		w.Write(binary.BigEndian.AppendUint64(nil, offset))
+	} else {
+		if !s.enter() {
+			return nil
+		}
+		rlockShards(s.shards)
+		data := s.unsafeSnapshot()
+		version := s.version.Load()
+		offset = b.end()
+		runlockShards(s.shards)
+		s.wg.Done()
+
+		var snap bytes.Buffer
+		if err := snapshot.Write(&snap, data, version, snapshot.NoCompression); err != nil {
+			return err
+		}
+		w.WriteByte(replFullSync)
+		w.Write(b.id[:])
+		w.Write(binary.BigEndian.AppendUint64(nil, offset))
+		w.Write(binary.BigEndian.AppendUint64(nil, uint64(snap.Len())))
+		w.Write(snap.Bytes())
+	}
+	return s.streamReplica(w, b, offset)
+}
+
+func (s *CacheStore) streamReplica(w *bufio.Writer, b *replBacklog, offset uint64) error {
+	ping := encodeOp(nil, op{kind: opPing})
+	ticker := time.NewTicker(replPingInterval)
+	defer ticker.Stop()
+	for {
+		records, changed, err := b.read(offset)
+		if err != nil {
+			return err
+		}
+		if records != nil {
+			if _, err := w.Write(records); err != nil {
+				return err
+			}
+			offset += uint64(len(records))
+			continue
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		select {
+		case <-changed:
+		case <-ticker.C:
+			if _, err := w.Write(ping); err != nil {
+				return err
+			}
+		case <-s.done:
+			return nil
+		}
+	}
+}
+
+// ServeReplicas serves every follower that connects to l with ServeReplica.
+// It returns when l fails, or with nil when s is closed, which closes l.
+func (s *CacheStore) ServeReplicas(l net.Listener) error {
+	stop := context.AfterFunc(s.ctx, func() { l.Close() })
+	defer stop()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.IsClosed() {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := s.ServeReplica(conn); err != nil && !s.IsClosed() {
+				log.Printf("replication: %s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// followState is where a follower stopped, so that Follow can resume.
+type followState struct {
+	mux    sync.Mutex // held by Follow
+	id     [replIDSize]byte
+	offset uint64
+	reset  atomic.Bool // set by Promote: local writes diverged from the stream
+}
+
+// Follow makes s a read-only follower of the primary at the other end of rw,
+// see ServeReplica: from the first call on, writes to s fail with
+// errors.ErrReadOnly and Flush does nothing, until Promote. Follow applies the
+// primary's changes, with the primary's versions, until rw fails, or returns
+// nil when s is closed or promoted. Calling it again with a new connection
+// resumes where it stopped, or starts over with a full copy when the primary
+// no longer has the missing changes. rw is closed with s when it is an
+// io.Closer.
+func (s *CacheStore) Follow(rw io.ReadWriter) error {
+	s.follow.mux.Lock()
+	defer s.follow.mux.Unlock()
+	s.readOnly.Store(true)
+	if c, ok := rw.(io.Closer); ok {
+		stop := context.AfterFunc(s.ctx, func() { c.Close() })
+		defer stop()
+	}
+	if s.follow.reset.Swap(false) {
+		s.follow.id = [replIDSize]byte{}
+		s.follow.offset = 0
+	}
+
+	hello := make([]byte, 0, replHelloSize)
+	hello = append(hello, replMagic...)
+	hello = append(hello, s.follow.id[:]...)
+	hello = binary.BigEndian.AppendUint64(hello, s.follow.offset)
+	if _, err := rw.Write(hello); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(rw)
+	reply := make([]byte, replReplySize)
+	if _, err := io.ReadFull(r, reply); err != nil {
+		return err
+	}
+	var id [replIDSize]byte
+	copy(id[:], reply[1:])
+	offset := binary.BigEndian.Uint64(reply[1+replIDSize:])
+	switch reply[0] {
+	case replContinue:
+		if id != s.follow.id || offset != s.follow.offset {
+			return errors.ErrProtocol("replication: primary resumed another stream")
+		}
+	case replFullSync:
+		size := make([]byte, 8)
+		if _, err := io.ReadFull(r, size); err != nil {
+			return err
+		}
+		snap := io.LimitReader(r, int64(binary.BigEndian.Uint64(size)))
+		data, version, err := snapshot.Read(snap)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(io.Discard, snap); err != nil {
+			return err
+		}
+		if !s.enter() {
+			return nil
+		}
+		s.replace(data, version, true)
+		s.wg.Done()
+		s.follow.id, s.follow.offset = id, offset
+	default:
+		return errors.ErrProtocol("replication: unexpected handshake reply")
+	}
+
+	header := make([]byte, aofHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return err
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(header))
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return err
+		}
+		o, ok := decodeOp(payload)
+		if !ok || crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+			return errors.ErrProtocol("replication: corrupted record")
+		}
+		if !s.readOnly.Load() {
+			return nil
+		}
+		if o.kind == opPing {
+			continue
+		}
+		if !s.enter() {
+			return nil
+		}
+		s.applyReplicated(o)
+		s.wg.Done()
+		s.follow.offset += uint64(aofHeaderSize + len(payload))
+	}
+}
+
+// enter registers work on the shards that Close must wait for before it tears
+// them down, unless s is already closed. The caller calls s.wg.Done after.
+func (s *CacheStore) enter() bool {
+	s.wg.Add(1)
+	if s.IsClosed() {
+		s.wg.Done()
+		return false
+	}
+	return true
+}
+
+// applyReplicated applies a change received from the primary and publishes
+// it like a local write, so a follower can have an append-only log and
+// followers of its own.
+func (s *CacheStore) applyReplicated(o op) {
+	switch o.kind {
+	case opSet:
+		sh := s.shardFor(o.key)
+		sh.mux.Lock()
+		defer sh.mux.Unlock()
+		s.raiseVersion(o.entry.Version)
+		if o.entry.IsExpired() {
+			s.unsafeDelete(sh, o.key)
+			return
+		}
+		if err := s.unsafePutEntry(sh, o.key, o.entry); err != nil {
+			log.Println(err)
+		}
+	case opZSet, opList:
+		sh := s.shardFor(o.key)
+		sh.mux.Lock()
+		defer sh.mux.Unlock()
+		s.raiseVersion(o.entry.Version)
+		apply := sh.unsafeApplyZSet
+		if o.kind == opList {
+			apply = sh.unsafeApplyList
+		}
+		evicted, stored, err := apply(o, time.Now().UnixMilli())
+		if err == nil && !stored {
+			// The value expired or lost its last element.
+			s.unsafeDelete(sh, o.key)
+			return
+		}
+		if err := s.unsafePublish(o, evicted, err); err != nil {
+			log.Println(err)
+		}
+	case opDelete:
+		sh := s.shardFor(o.key)
+		sh.mux.Lock()
+		defer sh.mux.Unlock()
+		s.unsafeDelete(sh, o.key)
+	case opFlush:
+		s.flush()
+	}
+}
+
+// raiseVersion moves the version counter up to v, never down.
+func (s *CacheStore) raiseVersion(v uint64) {
+	for cur := s.version.Load(); v > cur; cur = s.version.Load() {
+		if s.version.CompareAndSwap(cur, v) {
+			return
+		}
+	}
+}
+
+// FollowAddr runs Follow against the primary whose ServeReplicas listens on
+// addr, reconnecting after failures, until ctx ends (returning its error), s
+// is closed or Promote is called. A primary silent for three heartbeats is
+// considered gone.
+func (s *CacheStore) FollowAddr(ctx context.Context, network, addr string) error {
+	s.readOnly.Store(true)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(s.ctx, cancel)
+	defer stop()
+
+	var dialer net.Dialer
+	retry := replRetryMin
+	for {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err == nil {
+			retry = replRetryMin
+			stopClose := context.AfterFunc(ctx, func() { conn.Close() })
+			err = s.Follow(&deadlineConn{Conn: conn, timeout: replReadTimeout})
+			stopClose()
+			conn.Close()
+		}
+		if !s.readOnly.Load() || s.IsClosed() {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("replication: %s: %v", addr, err)
+		select {
+		case <-time.After(retry):
+		case <-ctx.Done():
+		}
+		retry = min(2*retry, replRetryMax)
+	}
+}
+
+// deadlineConn fails reads that wait longer than timeout.
+type deadlineConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *deadlineConn) Read(p []byte) (int, error) {
+	c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Read(p)
+}
+
+// Promote ends follower mode: s accepts writes again, and a running Follow
+// returns at the next record or heartbeat. A later Follow starts over with a
+// full copy, as s may have diverged from the primary.
+func (s *CacheStore) Promote() {
+	s.follow.reset.Store(true)
+	s.readOnly.Store(false)
+}
+
+// ReadOnly reports whether s is a follower, see Follow.
+func (s *CacheStore) ReadOnly() bool {
+	return s.readOnly.Load()
+}
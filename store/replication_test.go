@@ -0,0 +1,258 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func newReplStore(t *testing.T, cfg config.Config) *CacheStore {
+	t.Helper()
+	s, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// replLink is a follower connected to a primary over an in-memory pipe.
+type replLink struct {
+	conn  net.Conn
+	first chan byte  // first byte of the primary's handshake reply
+	done  chan error // result of Follow
+}
+
+type firstByteConn struct {
+	net.Conn
+	first chan byte
+}
+
+func (c *firstByteConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 && c.first != nil {
+		c.first <- p[0]
+		c.first = nil
+	}
+	return n, err
+}
+
+func link(t *testing.T, primary, follower *CacheStore) *replLink {
+	t.Helper()
+	p, f := net.Pipe()
+	l := &replLink{conn: f, first: make(chan byte, 1), done: make(chan error, 1)}
+	go func() {
+		primary.ServeReplica(p)
+		p.Close()
+	}()
+	go func() { l.done <- follower.Follow(&firstByteConn{Conn: f, first: l.first}) }()
+	t.Cleanup(func() { f.Close() })
+	return l
+}
+
+func (l *replLink) handshake(t *testing.T) byte {
+	t.Helper()
+	select {
+	case b := <-l.first:
+		return b
+	case <-time.After(5 * time.Second):
+		t.Fatal("no handshake reply")
+		return 0
+	}
+}
+
+func (l *replLink) close(t *testing.T) {
+	t.Helper()
+	l.conn.Close()
+	select {
+	case <-l.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Follow did not return after the connection closed")
+	}
+}
+
+// eventually waits for the follower to hold the same entries, with the same
+// versions, as the primary.
+func eventually(t *testing.T, primary, follower *CacheStore) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		diff := replDiff(primary, follower)
+		if diff == "" {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("follower out of sync: %s", diff)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func replDiff(primary, follower *CacheStore) string {
+	keys := primary.Keys()
+	if n := len(follower.Keys()); n != len(keys) {
+		return "key count differs"
+	}
+	for _, key := range keys {
+		pt, pv, pver, _ := primary.GetWithVersion(key)
+		ft, fv, fver, err := follower.GetWithVersion(key)
+		if err != nil || pt != ft || !bytes.Equal(pv, fv) || pver != fver {
+			return "entry " + key + " differs"
+		}
+	}
+	return ""
+}
+
+func TestReplication_FullSyncThenStream(t *testing.T) {
+	primary := newReplStore(t, config.Config{DBSave: false, Shards: 4})
+	follower := newReplStore(t, config.Config{DBSave: false})
+	primary.SetString("a", "1", 0)
+	primary.SetInt64("n", 1, time.Hour)
+
+	l := link(t, primary, follower)
+	if b := l.handshake(t); b != replFullSync {
+		t.Errorf("first sync = %q, want a full sync", b)
+	}
+	eventually(t, primary, follower)
+
+	primary.IncrInt64("n", 41, 0)
+	primary.Delete("a")
+	primary.MSet(NewItem("b", types.STRING, []byte("2"), 0), NewItem("c", types.BOOLEAN, []byte{1}, 0))
+	primary.Update(func(tx *Tx) error {
+		tx.Delete("b")
+		return tx.Set("d", types.STRING, []byte("4"), 0)
+	})
+	eventually(t, primary, follower)
+	if v, err := follower.GetInt64("n"); err != nil || v != 42 {
+		t.Errorf("follower GetInt64() = %d, %v, want 42", v, err)
+	}
+	if ttl := follower.TTL("n"); ttl <= 0 {
+		t.Errorf("follower TTL() = %v, want the primary's expiry", ttl)
+	}
+
+	primary.Flush()
+	eventually(t, primary, follower)
+}
+
+func TestReplication_FollowerIsReadOnly(t *testing.T) {
+	primary := newReplStore(t, config.Config{DBSave: false})
+	follower := newReplStore(t, config.Config{DBSave: false})
+	primary.SetString("k", "v", 0)
+	link(t, primary, follower)
+	eventually(t, primary, follower)
+
+	if !follower.ReadOnly() || primary.ReadOnly() {
+		t.Fatal("only the follower should be read-only")
+	}
+	if err := follower.SetString("x", "v", 0); err != errors.ErrReadOnly {
+		t.Errorf("Set() error = %v, want ErrReadOnly", err)
+	}
+	if err := follower.Delete("k"); err != errors.ErrReadOnly {
+		t.Errorf("Delete() error = %v, want ErrReadOnly", err)
+	}
+	if _, _, err := NewTyped(follower, StringCodec).GetOrSet("x", "v", 0); err != errors.ErrReadOnly {
+		t.Errorf("Typed.GetOrSet() error = %v, want ErrReadOnly", err)
+	}
+	if err := follower.IncrInt64("n", 1, 0); err != errors.ErrReadOnly {
+		t.Errorf("IncrInt64() error = %v, want ErrReadOnly", err)
+	}
+	if errs := follower.MSet(NewItem("x", types.STRING, []byte("v"), 0)); errs[0] != errors.ErrReadOnly {
+		t.Errorf("MSet() error = %v, want ErrReadOnly", errs[0])
+	}
+	if errs := follower.MDelete("k"); errs[0] != errors.ErrReadOnly {
+		t.Errorf("MDelete() error = %v, want ErrReadOnly", errs[0])
+	}
+	if _, err := follower.CompareAndSet("k", 0, types.STRING, []byte("v"), 0); err != errors.ErrReadOnly {
+		t.Errorf("CompareAndSet() error = %v, want ErrReadOnly", err)
+	}
+	if err := follower.Update(func(tx *Tx) error { return nil }); err != errors.ErrReadOnly {
+		t.Errorf("Update() error = %v, want ErrReadOnly", err)
+	}
+	follower.Flush()
+	if v, err := follower.GetString("k"); err != nil || v != "v" {
+		t.Errorf("Flush() on a follower removed data: %q, %v", v, err)
+	}
+}
+
+func TestReplication_ResumesFromOffset(t *testing.T) {
+	primary := newReplStore(t, config.Config{DBSave: false})
+	follower := newReplStore(t, config.Config{DBSave: false})
+	primary.SetString("a", "1", 0)
+
+	l := link(t, primary, follower)
+	l.handshake(t)
+	primary.SetString("b", "2", 0)
+	eventually(t, primary, follower)
+	l.close(t)
+
+	primary.SetString("c", "3", 0)
+	primary.Delete("a")
+
+	l = link(t, primary, follower)
+	if b := l.handshake(t); b != replContinue {
+		t.Errorf("second sync = %q, want to resume", b)
+	}
+	eventually(t, primary, follower)
+}
+
+func TestReplication_FullSyncWhenBacklogIsLost(t *testing.T) {
+	primary := newReplStore(t, config.Config{DBSave: false, ReplBacklogSize: 64})
+	follower := newReplStore(t, config.Config{DBSave: false})
+	primary.SetString("old", "1", 0)
+
+	l := link(t, primary, follower)
+	l.handshake(t)
+	eventually(t, primary, follower)
+	l.close(t)
+
+	primary.Delete("old")
+	for _, key := range []string{"k1", "k2", "k3", "k4", "k5"} {
+		primary.SetString(key, "a value longer than the backlog allows", 0)
+	}
+
+	l = link(t, primary, follower)
+	if b := l.handshake(t); b != replFullSync {
+		t.Errorf("sync after the backlog moved on = %q, want a full sync", b)
+	}
+	eventually(t, primary, follower)
+}
+
+func TestReplication_FollowAddrAndPromote(t *testing.T) {
+	primary := newReplStore(t, config.Config{DBSave: false})
+	follower := newReplStore(t, config.Config{DBSave: false})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	go primary.ServeReplicas(ln)
+
+	done := make(chan error, 1)
+	go func() { done <- follower.FollowAddr(context.Background(), "tcp", ln.Addr().String()) }()
+	primary.SetString("k", "v", 0)
+	eventually(t, primary, follower)
+
+	follower.Promote()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("FollowAddr() after Promote = %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("FollowAddr did not return after Promote")
+	}
+	if err := follower.SetString("own", "write", 0); err != nil {
+		t.Errorf("Set() after Promote error = %v", err)
+	}
+}
+
+func TestReplication_InvalidBacklogSize(t *testing.T) {
+	if _, err := NewCacheStore(config.Config{ReplBacklogSize: -1}); err != errors.ErrReplBacklogSize {
+		t.Errorf("NewCacheStore() error = %v, want ErrReplBacklogSize", err)
+	}
+}
@@ -0,0 +1,281 @@
+package store
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// shard owns a subset of the keyspace together with the lock guarding it.
+// A non-sharded CacheStore is simply a CacheStore with a single shard.
+type shard struct {
+	mux      sync.RWMutex
+	memorydb map[string]entry.Entry
+	expires  *expiryIndex
+	evict    *evictionManager
+	onStale  func(key string, version uint64)
+	// evicted holds the keys evicted for writes to other shards that are not
+	// yet marked as deleted in the dirty manager, see evictElsewhere.
+	evicted map[string]struct{}
+	// bitmaps holds the keys whose value SetBit allocated and may modify in
+	// place. Any other write of the key gives up the buffer.
+	bitmaps map[string]struct{}
+	// zsets and lists hold the live sorted sets and lists. Their entries in
+	// memorydb have no data, see unsafeEncode.
+	zsets map[string]*zset
+	lists map[string]*list
+}
+
+func newShard(evict *evictionManager) *shard {
+	return &shard{
+		memorydb: make(map[string]entry.Entry),
+		expires:  newExpiryIndex(),
+		evict:    evict,
+	}
+}
+
+// shardIndex hashes key with 32-bit FNV-1a.
+func shardIndex(key string, n int) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return int(h % uint32(n))
+}
+
+func (s *CacheStore) shardFor(key string) *shard {
+	if len(s.shards) == 1 {
+		return s.shards[0]
+	}
+	return s.shards[shardIndex(key, len(s.shards))]
+}
+
+// shardsFor returns the shards owning keys in index order. Locking shards in
+// this order is what keeps concurrent multi-key operations from deadlocking.
+func (s *CacheStore) shardsFor(keys []string) []*shard {
+	if len(s.shards) == 1 {
+		return s.shards
+	}
+	used := make([]bool, len(s.shards))
+	count := 0
+	for _, key := range keys {
+		idx := shardIndex(key, len(s.shards))
+		if !used[idx] {
+			used[idx] = true
+			count++
+		}
+	}
+	result := make([]*shard, 0, count)
+	for i, ok := range used {
+		if ok {
+			result = append(result, s.shards[i])
+		}
+	}
+	return result
+}
+
+func lockShards(shards []*shard) {
+	for _, sh := range shards {
+		sh.mux.Lock()
+	}
+}
+
+func unlockShards(shards []*shard) {
+	for i := len(shards) - 1; i >= 0; i-- {
+		shards[i].mux.Unlock()
+	}
+}
+
+func rlockShards(shards []*shard) {
+	for _, sh := range shards {
+		sh.mux.RLock()
+	}
+}
+
+func runlockShards(shards []*shard) {
+	for i := len(shards) - 1; i >= 0; i-- {
+		shards[i].mux.RUnlock()
+	}
+}
+
+// unsafeLen returns the number of stored entries, expired ones included.
+// All shards must be locked by the caller.
+func (s *CacheStore) unsafeLen() int {
+	n := 0
+	for _, sh := range s.shards {
+		n += len(sh.memorydb)
+	}
+	return n
+}
+
+// unsafeGet returns the entry of key, with the encoded form of a live sorted
+// set or list as its data.
+func (sh *shard) unsafeGet(key string) (entry.Entry, error) {
+	v, err := sh.unsafeLookup(key)
+	if err != nil {
+		return v, err
+	}
+	return sh.unsafeEncode(key, v), nil
+}
+
+// unsafeEncode fills in the data of e, the entry of key, when it is a live
+// sorted set or list. The data is shared until the value changes and must not
+// be modified.
+func (sh *shard) unsafeEncode(key string, e entry.Entry) entry.Entry {
+	if z, ok := sh.zsets[key]; ok {
+		e.Data = z.encode()
+	} else if l, ok := sh.lists[key]; ok {
+		e.Data = l.encode()
+	}
+	return e
+}
+
+// unsafeLookup is unsafeGet leaving the data of live values out.
+func (sh *shard) unsafeLookup(key string) (entry.Entry, error) {
+	v, ok := sh.memorydb[key]
+	if !ok {
+		return v, errors.ErrNoDataForKey(key)
+	}
+	now := time.Now().UnixMilli()
+	if v.IsExpiredWithUnixMilli(now) {
+		return v, errors.ErrNoDataForKey(key)
+	}
+	if sh.evict != nil {
+		sh.evict.touch(key)
+	}
+	if sh.onStale != nil && v.IsStaleWithUnixMilli(now) {
+		sh.onStale(key, v.Version)
+	}
+	return v, nil
+}
+
+// unsafePut stores e under key and keeps the eviction bookkeeping in sync.
+// Keys evicted to make room are returned even when an error is reported.
+// Sorted sets and lists are decoded into live ones; values that fail to
+// decode are stored as they are, and the methods of their type report them as
+// corrupt.
+func (sh *shard) unsafePut(key string, e entry.Entry) ([]string, error) {
+	switch e.Type {
+	case types.ZSET:
+		if z, err := decodeZSet(e.Data); err == nil {
+			e.Data = nil
+			return sh.unsafePutZSet(key, e, z)
+		}
+	case types.LIST:
+		if l, err := decodeList(e.Data); err == nil {
+			e.Data = nil
+			return sh.unsafePutList(key, e, l)
+		}
+	}
+	evicted, err := sh.unsafeAdmit(key, e, entrySize(key, e))
+	if err != nil {
+		return evicted, err
+	}
+	sh.memorydb[key] = e
+	return evicted, nil
+}
+
+// unsafePutZSet stores the live sorted set z under key, with e, which has no
+// data, as its entry.
+func (sh *shard) unsafePutZSet(key string, e entry.Entry, z *zset) ([]string, error) {
+	evicted, err := sh.unsafeAdmit(key, e, int64(len(key)+z.size))
+	if err != nil {
+		return evicted, err
+	}
+	if sh.zsets == nil {
+		sh.zsets = make(map[string]*zset)
+	}
+	sh.zsets[key] = z
+	sh.memorydb[key] = e
+	return evicted, nil
+}
+
+// unsafePutList stores the live list l under key, with e, which has no data,
+// as its entry.
+func (sh *shard) unsafePutList(key string, e entry.Entry, l *list) ([]string, error) {
+	evicted, err := sh.unsafeAdmit(key, e, int64(len(key)+l.size()))
+	if err != nil {
+		return evicted, err
+	}
+	if sh.lists == nil {
+		sh.lists = make(map[string]*list)
+	}
+	sh.lists[key] = l
+	sh.memorydb[key] = e
+	return evicted, nil
+}
+
+// unsafeAdmit makes room for the new entry of key, which takes size bytes,
+// and drops the bookkeeping and the live form of the old one.
+func (sh *shard) unsafeAdmit(key string, e entry.Entry, size int64) ([]string, error) {
+	var evicted []string
+	if sh.evict != nil {
+		var err error
+		if evicted, err = sh.unsafeMakeRoom(key, size); err != nil {
+			return evicted, err
+		}
+		sh.evict.track(key, e, size)
+	}
+	delete(sh.evicted, key)
+	delete(sh.bitmaps, key)
+	delete(sh.zsets, key)
+	delete(sh.lists, key)
+	sh.expires.set(key, e.Expiry)
+	return evicted, nil
+}
+
+func (sh *shard) unsafeRemove(key string) {
+	delete(sh.memorydb, key)
+	delete(sh.bitmaps, key)
+	delete(sh.zsets, key)
+	delete(sh.lists, key)
+	sh.expires.remove(key)
+	if sh.evict != nil {
+		sh.evict.untrack(key)
+	}
+}
+
+func (sh *shard) unsafeFlush() {
+	sh.memorydb = make(map[string]entry.Entry)
+	sh.expires.reset()
+	sh.evicted = nil
+	sh.bitmaps = nil
+	sh.zsets = nil
+	sh.lists = nil
+	if sh.evict != nil {
+		sh.evict.reset()
+	}
+}
+
+// unsafeLoad fills the shards with persisted data. The version counter resumes
+// from the highest persisted version; entries saved before versions existed
+// get a fresh one.
+func (s *CacheStore) unsafeLoad(data map[string]entry.Entry, version uint64) {
+	for _, e := range data {
+		if e.Version > version {
+			version = e.Version
+		}
+	}
+	s.version.Store(version)
+
+	for key, e := range data {
+		if e.Version == 0 {
+			e.Version = s.nextVersion()
+		}
+		evicted, err := s.shardFor(key).unsafePut(key, e)
+		if err != nil {
+			log.Println(err)
+			evicted = append(evicted, key)
+		}
+		if s.dirty != nil {
+			for _, k := range evicted {
+				s.dirty.delete(k)
+			}
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package store
+
+import (
+	cr "crypto/rand"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func newShardedStore(t *testing.T, shards int) *CacheStore {
+	t.Helper()
+	store, err := NewCacheStore(config.Config{DBSave: false, Shards: shards})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestShard_InvalidCount(t *testing.T) {
+	if _, err := NewCacheStore(config.Config{Shards: -1}); err == nil {
+		t.Error("NewCacheStore() expected error for negative shard count")
+	}
+}
+
+func TestShard_Distribution(t *testing.T) {
+	store := newShardedStore(t, 8)
+	for i := 0; i < 1000; i++ {
+		store.SetInt64(fmt.Sprintf("key:%d", i), int64(i), 0)
+	}
+	for i, sh := range store.shards {
+		if len(sh.memorydb) == 0 {
+			t.Errorf("shard %d is empty", i)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		v, err := store.GetInt64(fmt.Sprintf("key:%d", i))
+		if err != nil || v != int64(i) {
+			t.Fatalf("GetInt64(key:%d) = %v, %v", i, v, err)
+		}
+	}
+}
+
+func TestShard_BatchAcrossShards(t *testing.T) {
+	store := newShardedStore(t, 4)
+
+	items := make([]BatchItem, 50)
+	keys := make([]string, 50)
+	for i := range items {
+		keys[i] = fmt.Sprintf("batch:%d", i)
+		items[i] = NewItem(keys[i], types.STRING, []byte(keys[i]), time.Hour)
+	}
+	for i, err := range store.MSet(items...) {
+		if err != nil {
+			t.Fatalf("MSet()[%d] error = %v", i, err)
+		}
+	}
+
+	for i, result := range store.MGet(keys...) {
+		if result.Error != nil || string(result.Value) != keys[i] {
+			t.Errorf("MGet()[%d] = %q, %v", i, result.Value, result.Error)
+		}
+	}
+
+	got := store.Keys()
+	sort.Strings(got)
+	want := append([]string(nil), keys...)
+	sort.Strings(want)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Keys() = %v, want %v", got, want)
+	}
+
+	store.MDelete(keys[:25]...)
+	if n := store.Exists(keys...); n != 25 {
+		t.Errorf("Exists() after MDelete = %d, want 25", n)
+	}
+
+	store.Flush()
+	if n := len(store.Keys()); n != 0 {
+		t.Errorf("len(Keys()) after Flush = %d, want 0", n)
+	}
+}
+
+func TestShard_SyncRoundTrip(t *testing.T) {
+	dbFile := tempDBFile(t)
+	cfg := config.Config{
+		DBSave:              true,
+		DBFileName:          dbFile,
+		SaveDirtyData:       true,
+		DirtyThresholdCount: 1000,
+		DirtyThresholdRatio: 1,
+		Shards:              4,
+	}
+	store, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		store.SetString(fmt.Sprintf("k%d", i), fmt.Sprintf("v%d", i), time.Hour)
+	}
+	store.Delete("k0")
+	store.Sync()
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	cfg.Shards = 2
+	store2, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to re-create store: %v", err)
+	}
+	defer store2.Close()
+
+	if n := len(store2.Keys()); n != 99 {
+		t.Errorf("len(Keys()) = %d, want 99", n)
+	}
+	if v, err := store2.GetString("k42"); err != nil || v != "v42" {
+		t.Errorf("GetString(k42) = %q, %v", v, err)
+	}
+}
+
+func TestShard_EvictionLimitSplit(t *testing.T) {
+	store, err := NewCacheStore(config.Config{DBSave: false, Shards: 4, MaxEntries: 10})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	for i := 0; i < 100; i++ {
+		if err := store.SetString(fmt.Sprintf("k%d", i), "v", 0); err != nil {
+			t.Fatalf("SetString() error = %v", err)
+		}
+	}
+	for i, sh := range store.shards {
+		if len(sh.memorydb) > 3 {
+			t.Errorf("shard %d holds %d entries, want <= 3", i, len(sh.memorydb))
+		}
+	}
+}
+
+func TestShard_ConcurrentAccess(t *testing.T) {
+	store := newShardedStore(t, 8)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("k%d", rand.Intn(64))
+				switch i % 4 {
+				case 0:
+					store.IncrInt64(key+":n", 1, 0)
+				case 1:
+					store.MSet(NewItem(key, types.RAW, []byte{byte(g)}, 0), NewItem(key+"x", types.RAW, []byte{1}, 0))
+				case 2:
+					store.MGet(key, key+"x")
+				default:
+					store.MDelete(key, key+"x")
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func BenchmarkSharded_GoroutineConcurrency(b *testing.B) {
+	store, err := NewCacheStore(config.Config{
+		DBSave:     false,
+		GCInterval: 500 * time.Millisecond,
+		Shards:     32,
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer store.Close()
+
+	testData := generateTestData(1000)
+	keys := make([]string, 0, len(testData))
+	for key, value := range testData {
+		store.Set(key, types.RAW, value, time.Hour)
+		keys = append(keys, key)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		numGoroutines := 100
+		operationsPerGoroutine := 100
+
+		wg.Add(numGoroutines)
+
+		for j := 0; j < numGoroutines; j++ {
+			go func() {
+				defer wg.Done()
+				for k := 0; k < operationsPerGoroutine; k++ {
+					key := keys[rand.Intn(len(keys))]
+
+					if rand.Float32() < 0.7 {
+						_, _, _ = store.Get(key)
+					} else {
+						value := make([]byte, 100)
+						cr.Read(value)
+						_ = store.Set(key, types.RAW, value, time.Hour)
+					}
+				}
+			}()
+		}
+
+		wg.Wait()
+	}
+}
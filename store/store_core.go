@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"log"
 	"sync"
 	"sync/atomic"
@@ -8,18 +9,29 @@ import (
 
 	"github.com/found-cake/CacheStore/entry"
 	"github.com/found-cake/CacheStore/errors"
-	"github.com/found-cake/CacheStore/sqlite"
+	"github.com/found-cake/CacheStore/persist"
 	"github.com/found-cake/CacheStore/utils/types"
 )
 
 type CacheStore struct {
-	mux      sync.RWMutex
-	memorydb map[string]entry.Entry
-	dirty    *dirtyManager
-	sqlitedb *sqlite.SqliteStore
-	done     chan struct{}
-	wg       sync.WaitGroup
-	closed   atomic.Bool
+	shards    []*shard
+	dirty     *dirtyManager
+	gc        *gcSettings
+	loads     loadGroup
+	refresh   refreshGroup
+	ctx       context.Context
+	cancel    context.CancelFunc
+	persister persist.Persister
+	aof       *aofLog
+	repl      atomic.Pointer[replBacklog]
+	replSize  int
+	readOnly  atomic.Bool
+	follow    followState
+	lists     listWaiters
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closed    atomic.Bool
+	version   atomic.Uint64
 }
 
 const (
@@ -27,37 +39,14 @@ const (
 	TTLExpired  time.Duration = -2 // Key does not exist or is expired
 )
 
-func (s *CacheStore) cleanExpired() {
-	now := time.Now().UnixMilli()
-
-	s.mux.Lock()
-	defer s.mux.Unlock()
-
-	for key, entry := range s.memorydb {
-		if entry.IsExpiredWithUnixMilli(now) {
-			delete(s.memorydb, key)
-		}
-	}
-}
-
-func (s *CacheStore) unsafeGet(key string) (entry.Entry, error) {
-	v, ok := s.memorydb[key]
-	if !ok {
-		return v, errors.ErrNoDataForKey(key)
-	}
-	if v.IsExpired() {
-		return v, errors.ErrNoDataForKey(key)
-	}
-	return v, nil
-}
-
 func (s *CacheStore) Get(key string) (types.DataType, []byte, error) {
 	if key == "" {
 		return types.UNKNOWN, nil, errors.ErrKeyEmpty
 	}
-	s.mux.RLock()
-	defer s.mux.RUnlock()
-	v, err := s.unsafeGet(key)
+	sh := s.shardFor(key)
+	sh.mux.RLock()
+	defer sh.mux.RUnlock()
+	v, err := sh.unsafeGet(key)
 	if err != nil {
 		return types.UNKNOWN, nil, err
 	}
@@ -74,6 +63,9 @@ func (s *CacheStore) Get(key string) (types.DataType, []byte, error) {
 // GetNoCopy is designed for performance-critical scenarios where copying is avoided.
 // However, modifying the returned value may cause unexpected behavior in concurrent environments.
 //
+// The value is only valid until the next write to key: SetBit modifies RAW
+// values in place, so reading it while SetBit may run on key is a data race.
+//
 // ✅ If you don't explicitly need zero-copy performance,
 //
 //	use Get() to avoid race conditions and data corruption.
@@ -81,9 +73,10 @@ func (s *CacheStore) GetNoCopy(key string) (types.DataType, []byte, error) {
 	if key == "" {
 		return types.UNKNOWN, nil, errors.ErrKeyEmpty
 	}
-	s.mux.RLock()
-	defer s.mux.RUnlock()
-	v, err := s.unsafeGet(key)
+	sh := s.shardFor(key)
+	sh.mux.RLock()
+	defer sh.mux.RUnlock()
+	v, err := sh.unsafeGet(key)
 	if err != nil {
 		return types.UNKNOWN, nil, err
 	}
@@ -91,12 +84,45 @@ func (s *CacheStore) GetNoCopy(key string) (types.DataType, []byte, error) {
 	return v.Type, v.Data, nil
 }
 
-func (s *CacheStore) unsafeSet(key string, dataType types.DataType, value []byte, expiry time.Duration) {
-	s.memorydb[key] = entry.NewEntry(dataType, value, expiry)
+func (s *CacheStore) unsafeSet(sh *shard, key string, dataType types.DataType, value []byte, expiry time.Duration) error {
+	return s.unsafeSetEntry(sh, key, entry.NewEntry(dataType, value, expiry))
+}
+
+func (s *CacheStore) nextVersion() uint64 {
+	return s.version.Add(1)
+}
+
+func (s *CacheStore) unsafeSetEntry(sh *shard, key string, e entry.Entry) error {
+	e.Version = s.nextVersion()
+	return s.unsafePutEntry(sh, key, e)
+}
+
+// unsafePutEntry stores e with the version it already has and publishes the
+// change.
+func (s *CacheStore) unsafePutEntry(sh *shard, key string, e entry.Entry) error {
+	evicted, err := sh.unsafePut(key, e)
+	return s.unsafePublish(op{kind: opSet, key: key, entry: e}, evicted, err)
+}
 
+// unsafePublish publishes o, a write to the locked shard of its key, along
+// with the keys evicted to make room for it. Only the evictions are published
+// when the write failed with err, which is returned.
+func (s *CacheStore) unsafePublish(o op, evicted []string, err error) error {
 	if s.dirty != nil {
-		s.dirty.set(key)
+		for _, k := range evicted {
+			s.dirty.delete(k)
+		}
+		if err == nil {
+			s.dirty.set(o.key)
+		}
+	}
+	for _, k := range evicted {
+		s.logDelete(k)
+	}
+	if err == nil {
+		s.logOps([]op{o})
 	}
+	return err
 }
 
 func (s *CacheStore) Set(key string, dataType types.DataType, value []byte, expiry time.Duration) error {
@@ -106,38 +132,60 @@ func (s *CacheStore) Set(key string, dataType types.DataType, value []byte, expi
 	if value == nil {
 		return errors.ErrValueNil
 	}
-
-	s.mux.Lock()
-	s.memorydb[key] = entry.NewEntry(dataType, value, expiry)
-	s.mux.Unlock()
-
-	if s.dirty != nil {
-		s.dirty.set(key)
+	if !dataType.IsKnown() {
+		return errors.ErrUnknownDataType(dataType)
+	}
+	if s.readOnly.Load() {
+		return errors.ErrReadOnly
 	}
 
-	return nil
+	sh := s.shardFor(key)
+	sh.mux.Lock()
+	defer sh.mux.Unlock()
+
+	return s.unsafeSet(sh, key, dataType, value, expiry)
 }
 
 func (s *CacheStore) Delete(key string) error {
 	if key == "" {
 		return errors.ErrKeyEmpty
 	}
+	if s.readOnly.Load() {
+		return errors.ErrReadOnly
+	}
+
+	sh := s.shardFor(key)
+	sh.mux.Lock()
+	defer sh.mux.Unlock()
+	s.unsafeDelete(sh, key)
 
-	s.mux.Lock()
-	delete(s.memorydb, key)
-	s.mux.Unlock()
+	return nil
+}
 
+// unsafeDelete removes key from its locked shard and publishes the change.
+func (s *CacheStore) unsafeDelete(sh *shard, key string) {
+	sh.unsafeRemove(key)
 	if s.dirty != nil {
 		s.dirty.delete(key)
 	}
-
-	return nil
+	s.logDelete(key)
 }
 
+// Flush removes every entry. It does nothing on a read-only follower.
 func (s *CacheStore) Flush() {
-	s.mux.Lock()
-	s.memorydb = make(map[string]entry.Entry)
-	s.mux.Unlock()
+	if s.readOnly.Load() {
+		return
+	}
+	s.flush()
+}
+
+func (s *CacheStore) flush() {
+	lockShards(s.shards)
+	for _, sh := range s.shards {
+		sh.unsafeFlush()
+	}
+	s.logFlush()
+	unlockShards(s.shards)
 	if s.dirty != nil {
 		s.dirty.wantFullSync()
 	}
@@ -155,19 +203,42 @@ func (s *CacheStore) Close() error {
 	s.closed.Store(true)
 
 	close(s.done)
+	s.cancel()
 	s.wg.Wait()
+	s.lists.close()
 
 	var err error
-	if s.sqlitedb != nil {
+	if s.persister != nil {
 		defer func() {
-			if err := s.sqlitedb.Close(); err != nil {
+			if err := s.persister.Close(); err != nil {
 				log.Println(err)
 			}
 		}()
-		err = s.sqlitedb.Save(s.memorydb, true)
+		data := s.shards[0].memorydb
+		if len(s.shards) > 1 || len(s.shards[0].zsets) > 0 || len(s.shards[0].lists) > 0 {
+			data = make(map[string]entry.Entry, s.unsafeLen())
+			for _, sh := range s.shards {
+				for key, e := range sh.memorydb {
+					data[key] = sh.unsafeEncode(key, e)
+				}
+			}
+		}
+		err = s.persister.SaveFull(data, s.version.Load())
+		if err == nil && s.aof != nil {
+			err = s.aof.compact(s.aof.mark(), nil)
+		}
+	}
+	if s.aof != nil {
+		if err := s.aof.close(); err != nil {
+			log.Println(err)
+		}
 	}
 
-	s.memorydb = nil
+	for _, sh := range s.shards {
+		sh.memorydb = nil
+		sh.zsets = nil
+		sh.lists = nil
+	}
 	s.dirty = nil
 
 	return err
@@ -177,38 +248,41 @@ func (s *CacheStore) Exists(keys ...string) int {
 	now := time.Now().UnixMilli()
 	count := 0
 
-	s.mux.RLock()
-	defer s.mux.RUnlock()
-
 	for _, key := range keys {
-		if e, ok := s.memorydb[key]; ok {
+		sh := s.shardFor(key)
+		sh.mux.RLock()
+		if e, ok := sh.memorydb[key]; ok {
 			if !e.IsExpiredWithUnixMilli(now) {
 				count++
 			}
 		}
+		sh.mux.RUnlock()
 	}
 	return count
 }
 
 func (s *CacheStore) Keys() []string {
 	now := time.Now().UnixMilli()
-	s.mux.RLock()
-	defer s.mux.RUnlock()
+	rlockShards(s.shards)
+	defer runlockShards(s.shards)
 
-	keys := make([]string, 0, len(s.memorydb))
-	for key, e := range s.memorydb {
-		if !e.IsExpiredWithUnixMilli(now) {
-			keys = append(keys, key)
+	keys := make([]string, 0, s.unsafeLen())
+	for _, sh := range s.shards {
+		for key, e := range sh.memorydb {
+			if !e.IsExpiredWithUnixMilli(now) {
+				keys = append(keys, key)
+			}
 		}
 	}
 	return keys
 }
 
 func (s *CacheStore) TTL(key string) time.Duration {
-	s.mux.RLock()
-	defer s.mux.RUnlock()
+	sh := s.shardFor(key)
+	sh.mux.RLock()
+	defer sh.mux.RUnlock()
 
-	e, ok := s.memorydb[key]
+	e, ok := sh.memorydb[key]
 	if !ok {
 		return TTLExpired
 	}
@@ -226,8 +300,63 @@ func (s *CacheStore) TTL(key string) time.Duration {
 	return remaining
 }
 
+// cloneEntry returns a copy of e that does not share its data.
+func cloneEntry(e entry.Entry) entry.Entry {
+	dataCopy := make([]byte, len(e.Data))
+	copy(dataCopy, e.Data)
+	e.Data = dataCopy
+	return e
+}
+
+// unsafeSnapshot deep copies every entry. All shards must be locked by the caller.
+func (s *CacheStore) unsafeSnapshot() map[string]entry.Entry {
+	snapshot := make(map[string]entry.Entry, s.unsafeLen())
+	for _, sh := range s.shards {
+		for key, e := range sh.memorydb {
+			snapshot[key] = cloneEntry(sh.unsafeEncode(key, e))
+		}
+	}
+	return snapshot
+}
+
+// saveFull persists snapshot in the background and then compacts the
+// append-only log up to mark, the log position at which snapshot was taken.
+// Without a persister the snapshot replaces the compacted part of the log.
+func (s *CacheStore) saveFull(snapshot map[string]entry.Entry, version uint64, mark aofMark) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if s.persister != nil {
+			if err := s.persister.SaveFull(snapshot, version); err != nil {
+				log.Println(err)
+				return
+			}
+			snapshot = nil
+		}
+		if s.aof != nil {
+			if err := s.aof.compact(mark, snapshot); err != nil {
+				log.Println(err)
+			}
+		}
+	}()
+}
+
+// unsafeAOFMark returns the current log position. The shards must be locked
+// by the caller so that no write slips in between.
+func (s *CacheStore) unsafeAOFMark() aofMark {
+	if s.aof == nil {
+		return aofMark{}
+	}
+	return s.aof.mark()
+}
+
+// Sync persists the keys changed since the last sync, falling back to a full
+// sync when too many keys are dirty.
+//
+// Shards are always locked before the dirty manager, the same order used by
+// the write paths.
 func (s *CacheStore) Sync() {
-	if s.sqlitedb == nil {
+	if s.persister == nil {
 		return
 	}
 	if s.dirty == nil {
@@ -235,83 +364,70 @@ func (s *CacheStore) Sync() {
 		return
 	}
 
+	lockShards(s.shards)
 	s.dirty.mux.Lock()
-	if s.dirty.needFullSync {
-		s.dirty.needFullSync = false
-		s.dirty.mux.Unlock()
-		s.FullSync()
-		return
-	}
+	s.unsafeCollectEvictions()
 
 	dirtySize := s.dirty.size()
-	if dirtySize == 0 {
+	if s.dirty.needFullSync || (dirtySize > s.dirty.ThresholdCount && dirtySize > int(float64(s.unsafeLen())*s.dirty.ThresholdRatio)) {
+		s.dirty.needFullSync = false
+		s.dirty.unsafeClear()
+		snapshot := s.unsafeSnapshot()
+		version := s.version.Load()
+		mark := s.unsafeAOFMark()
 		s.dirty.mux.Unlock()
+		unlockShards(s.shards)
+		s.saveFull(snapshot, version, mark)
 		return
 	}
 
-	s.mux.RLock()
-	if dirtySize > s.dirty.ThresholdCount && dirtySize > int(float64(len(s.memorydb))*s.dirty.ThresholdRatio) {
-		s.mux.RUnlock()
+	if dirtySize == 0 {
 		s.dirty.mux.Unlock()
-		s.FullSync()
+		unlockShards(s.shards)
 		return
 	}
 
 	set_keys, delete_keys := s.dirty.keys()
 	new_data := make(map[string]entry.Entry, len(set_keys))
 	for _, key := range set_keys {
-		if e, ok := s.memorydb[key]; ok {
-			dataCopy := make([]byte, len(e.Data))
-			copy(dataCopy, e.Data)
-
-			new_data[key] = entry.Entry{
-				Type:   e.Type,
-				Data:   dataCopy,
-				Expiry: e.Expiry,
-			}
+		sh := s.shardFor(key)
+		if e, ok := sh.memorydb[key]; ok {
+			new_data[key] = cloneEntry(sh.unsafeEncode(key, e))
 		}
 	}
 
-	s.mux.RUnlock()
+	version := s.version.Load()
 	s.dirty.unsafeClear()
 	s.dirty.mux.Unlock()
+	unlockShards(s.shards)
 
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
-		if err := s.sqlitedb.SaveDirtyData(new_data, delete_keys); err != nil {
+		if err := s.persister.SaveDelta(new_data, delete_keys, version); err != nil {
 			log.Println(err)
 		}
 	}()
 }
 
+// FullSync saves every entry and, once the save succeeded, compacts the
+// append-only log.
 func (s *CacheStore) FullSync() {
-	if s.sqlitedb == nil {
+	if s.persister == nil && s.aof == nil {
 		return
 	}
 
-	s.mux.RLock()
-	snapshot := make(map[string]entry.Entry, len(s.memorydb))
-	for key, e := range s.memorydb {
-		dataCopy := make([]byte, len(e.Data))
-		copy(dataCopy, e.Data)
-
-		snapshot[key] = entry.Entry{
-			Type:   e.Type,
-			Data:   dataCopy,
-			Expiry: e.Expiry,
-		}
-	}
-	s.mux.RUnlock()
+	lockShards(s.shards)
+	snapshot := s.unsafeSnapshot()
+	version := s.version.Load()
+	mark := s.unsafeAOFMark()
 	if s.dirty != nil {
-		s.dirty.clear()
+		s.dirty.mux.Lock()
+		s.unsafeCollectEvictions()
+		s.dirty.unsafeClear()
+		s.dirty.mux.Unlock()
 	}
+	unlockShards(s.shards)
 
-	s.wg.Add(1)
-	go func() {
-		defer s.wg.Done()
-		if err := s.sqlitedb.Save(snapshot, false); err != nil {
-			log.Println(err)
-		}
-	}()
+	s.saveFull(snapshot, version, mark)
 }
@@ -0,0 +1,519 @@
+package store
+
+import (
+	"time"
+
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// Tx is a consistent view of the store used inside View and Update. Writes
+// are buffered and applied together when the transaction commits; until then
+// they are only visible to the transaction itself.
+type Tx struct {
+	store    *CacheStore
+	writable bool
+	done     bool
+	writes   map[string]*entry.Entry // nil marks a delete
+	order    []string
+	scope    map[string]struct{} // keys of UpdateKeys, nil when unrestricted
+	// zsets and lists hold the live forms of the ZSET and LIST entries in
+	// writes, whose data is nil. They may be the values of the store, changed
+	// in place since the shards are locked; undo reverts these changes unless
+	// the transaction commits.
+	zsets map[string]*zset
+	lists map[string]*list
+	undo  []func()
+}
+
+// View runs fn in a read-only transaction. All reads see the same state.
+func (s *CacheStore) View(fn func(tx *Tx) error) error {
+	rlockShards(s.shards)
+	defer runlockShards(s.shards)
+
+	tx := &Tx{store: s}
+	defer tx.close()
+	return fn(tx)
+}
+
+// Update runs fn in a read-write transaction. When fn returns nil every write
+// is committed at once; when it returns an error nothing is written. The
+// whole store is locked while fn runs, so fn should be short.
+func (s *CacheStore) Update(fn func(tx *Tx) error) error {
+	return s.update(s.shards, nil, nil, fn)
+}
+
+// UpdateKeys runs fn like Update, but only locks the shards owning keys so
+// that writes to other keys are not blocked. fn may only use keys; any other
+// key fails with errors.ErrTxKeyNotLocked.
+func (s *CacheStore) UpdateKeys(keys []string, fn func(tx *Tx) error) error {
+	scope := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		scope[key] = struct{}{}
+	}
+	return s.update(s.shardsFor(keys), scope, nil, fn)
+}
+
+func (s *CacheStore) update(shards []*shard, scope map[string]struct{}, watched map[string]uint64, fn func(tx *Tx) error) error {
+	if s.readOnly.Load() {
+		return errors.ErrReadOnly
+	}
+	lockShards(shards)
+	defer unlockShards(shards)
+
+	for key, version := range watched {
+		if s.shardFor(key).unsafeVersion(key) != version {
+			return errors.ErrWatchConflict(key)
+		}
+	}
+
+	tx := &Tx{
+		store:    s,
+		writable: true,
+		writes:   make(map[string]*entry.Entry),
+		scope:    scope,
+		zsets:    make(map[string]*zset),
+		lists:    make(map[string]*list),
+	}
+	defer tx.close()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.commit()
+}
+
+// Watcher remembers the versions of a set of keys, see CacheStore.Watch.
+type Watcher struct {
+	store    *CacheStore
+	versions map[string]uint64
+	err      error
+}
+
+// Watch records the current versions of keys. A later Watcher.Update fails
+// with an error wrapping errors.ErrConflict if any of them was written,
+// deleted or expired in the meantime.
+func (s *CacheStore) Watch(keys ...string) *Watcher {
+	w := &Watcher{
+		store:    s,
+		versions: make(map[string]uint64, len(keys)),
+	}
+	for _, key := range keys {
+		if key == "" {
+			w.err = errors.ErrKeyEmpty
+			continue
+		}
+		sh := s.shardFor(key)
+		sh.mux.RLock()
+		w.versions[key] = sh.unsafeVersion(key)
+		sh.mux.RUnlock()
+	}
+	return w
+}
+
+// Update runs fn like CacheStore.Update, but only if none of the watched keys
+// changed since Watch was called.
+func (w *Watcher) Update(fn func(tx *Tx) error) error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.store.update(w.store.shards, nil, w.versions, fn)
+}
+
+func (tx *Tx) close() {
+	tx.rollback()
+	tx.done = true
+	tx.writes = nil
+	tx.order = nil
+	tx.zsets = nil
+	tx.lists = nil
+}
+
+// rollback reverts the changes made in place to live values, newest first.
+func (tx *Tx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+	tx.undo = nil
+}
+
+// locked reports whether the transaction holds the lock of key's shard.
+func (tx *Tx) locked(key string) bool {
+	if tx.scope == nil {
+		return true
+	}
+	_, ok := tx.scope[key]
+	return ok
+}
+
+func (tx *Tx) getEntry(key string) (entry.Entry, error) {
+	if !tx.locked(key) {
+		return entry.Entry{}, errors.ErrTxKeyNotLocked(key)
+	}
+	if e, ok := tx.writes[key]; ok {
+		if e == nil || e.IsExpired() {
+			return entry.Entry{}, errors.ErrNoDataForKey(key)
+		}
+		v := *e
+		if z, ok := tx.zsets[key]; ok {
+			v.Data = z.encode()
+		} else if l, ok := tx.lists[key]; ok {
+			v.Data = l.encode()
+		}
+		return v, nil
+	}
+	return tx.store.shardFor(key).unsafeGet(key)
+}
+
+// checkWrite reports why key cannot be written, if it cannot.
+func (tx *Tx) checkWrite(key string) error {
+	if tx.done {
+		return errors.ErrTxDone
+	}
+	if !tx.writable {
+		return errors.ErrTxReadOnly
+	}
+	if !tx.locked(key) {
+		return errors.ErrTxKeyNotLocked(key)
+	}
+	return nil
+}
+
+func (tx *Tx) putEntry(key string, e *entry.Entry) error {
+	if err := tx.checkWrite(key); err != nil {
+		return err
+	}
+	if _, ok := tx.writes[key]; !ok {
+		tx.order = append(tx.order, key)
+	}
+	tx.writes[key] = e
+	delete(tx.zsets, key)
+	delete(tx.lists, key)
+	return nil
+}
+
+func (tx *Tx) modify(key string, fn func(old entry.Entry, err error) (entry.Entry, error)) error {
+	return tx.modifyOrDelete(key, func(old entry.Entry, err error) (*entry.Entry, error) {
+		e, err := fn(old, err)
+		if err != nil {
+			return nil, err
+		}
+		return &e, nil
+	})
+}
+
+func (tx *Tx) modifyOrDelete(key string, fn func(old entry.Entry, err error) (*entry.Entry, error)) error {
+	if key == "" {
+		return errors.ErrKeyEmpty
+	}
+	if tx.done {
+		return errors.ErrTxDone
+	}
+	e, err := fn(tx.getEntry(key))
+	if err != nil {
+		return err
+	}
+	return tx.putEntry(key, e)
+}
+
+func (tx *Tx) Get(key string) (types.DataType, []byte, error) {
+	if key == "" {
+		return types.UNKNOWN, nil, errors.ErrKeyEmpty
+	}
+	if tx.done {
+		return types.UNKNOWN, nil, errors.ErrTxDone
+	}
+	e, err := tx.getEntry(key)
+	if err != nil {
+		return types.UNKNOWN, nil, err
+	}
+
+	result := make([]byte, len(e.Data))
+	copy(result, e.Data)
+	return e.Type, result, nil
+}
+
+func (tx *Tx) Exists(keys ...string) int {
+	if tx.done {
+		return 0
+	}
+	count := 0
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		if _, err := tx.getEntry(key); err == nil {
+			count++
+		}
+	}
+	return count
+}
+
+func (tx *Tx) TTL(key string) time.Duration {
+	if key == "" || tx.done {
+		return TTLExpired
+	}
+	e, err := tx.getEntry(key)
+	if err != nil {
+		return TTLExpired
+	}
+	if e.Expiry == 0 {
+		return TTLNoExpiry
+	}
+	return time.Until(time.UnixMilli(e.Expiry))
+}
+
+func (tx *Tx) Set(key string, dataType types.DataType, value []byte, expiry time.Duration) error {
+	if key == "" {
+		return errors.ErrKeyEmpty
+	}
+	if value == nil {
+		return errors.ErrValueNil
+	}
+	if !dataType.IsKnown() {
+		return errors.ErrUnknownDataType(dataType)
+	}
+	e := entry.NewEntry(dataType, value, expiry)
+	return tx.putEntry(key, &e)
+}
+
+func (tx *Tx) Delete(key string) error {
+	if key == "" {
+		return errors.ErrKeyEmpty
+	}
+	return tx.putEntry(key, nil)
+}
+
+func (tx *Tx) IncrInt16(key string, delta int16, exp time.Duration) error {
+	return incrNumber(tx, key, delta, types.INT16, exp, utils.Binary2Int16, utils.Int16toBinary, utils.Int16CheckOver, nil)
+}
+
+func (tx *Tx) IncrInt32(key string, delta int32, exp time.Duration) error {
+	return incrNumber(tx, key, delta, types.INT32, exp, utils.Binary2Int32, utils.Int32toBinary, utils.Int32CheckOver, nil)
+}
+
+func (tx *Tx) IncrInt64(key string, delta int64, exp time.Duration) error {
+	return incrNumber(tx, key, delta, types.INT64, exp, utils.Binary2Int64, utils.Int64toBinary, utils.Int64CheckOver, nil)
+}
+
+func (tx *Tx) IncrUInt16(key string, delta uint16, exp time.Duration) error {
+	return incrNumber(tx, key, delta, types.UINT16, exp, utils.Binary2UInt16, utils.UInt16toBinary, utils.UInt16CheckOverFlow, nil)
+}
+
+func (tx *Tx) IncrUInt32(key string, delta uint32, exp time.Duration) error {
+	return incrNumber(tx, key, delta, types.UINT32, exp, utils.Binary2UInt32, utils.UInt32toBinary, utils.UInt32CheckOverFlow, nil)
+}
+
+func (tx *Tx) IncrUInt64(key string, delta uint64, exp time.Duration) error {
+	return incrNumber(tx, key, delta, types.UINT64, exp, utils.Binary2UInt64, utils.UInt64toBinary, utils.UInt64CheckOverFlow, nil)
+}
+
+func (tx *Tx) DecrUInt16(key string, delta uint16, exp time.Duration) error {
+	return decrUnsigned(tx, key, delta, types.UINT16, exp, utils.Binary2UInt16, utils.UInt16toBinary, utils.UintCheckUnderFlow)
+}
+
+func (tx *Tx) DecrUInt32(key string, delta uint32, exp time.Duration) error {
+	return decrUnsigned(tx, key, delta, types.UINT32, exp, utils.Binary2UInt32, utils.UInt32toBinary, utils.UintCheckUnderFlow)
+}
+
+func (tx *Tx) DecrUInt64(key string, delta uint64, exp time.Duration) error {
+	return decrUnsigned(tx, key, delta, types.UINT64, exp, utils.Binary2UInt64, utils.UInt64toBinary, utils.UintCheckUnderFlow)
+}
+
+func (tx *Tx) IncrFloat32(key string, delta float32, exp time.Duration) error {
+	return incrNumber(tx, key, delta, types.FLOAT32, exp, utils.Binary2Float32, utils.Float32toBinary, utils.Float32CheckOver, utils.CheckFloat32Special)
+}
+
+func (tx *Tx) IncrFloat64(key string, delta float64, exp time.Duration) error {
+	return incrNumber(tx, key, delta, types.FLOAT64, exp, utils.Binary2Float64, utils.Float64toBinary, utils.Float64CheckOver, utils.CheckFloat64Special)
+}
+
+func (tx *Tx) HSet(key string, fields map[string][]byte, exp time.Duration) (int, error) {
+	return hashSet(tx, key, fields, exp)
+}
+
+func (tx *Tx) HDel(key string, fields ...string) (int, error) {
+	return hashDelete(tx, key, fields)
+}
+
+func (tx *Tx) HIncrBy(key, field string, delta int64, exp time.Duration) (int64, error) {
+	return hashIncrBy(tx, key, field, delta, exp)
+}
+
+func (tx *Tx) HIncrByFloat(key, field string, delta float64, exp time.Duration) (float64, error) {
+	return hashIncrByFloat(tx, key, field, delta, exp)
+}
+
+func (tx *Tx) LPush(key string, values [][]byte, exp time.Duration) (int, error) {
+	return listPush(tx, key, values, exp, true)
+}
+
+func (tx *Tx) RPush(key string, values [][]byte, exp time.Duration) (int, error) {
+	return listPush(tx, key, values, exp, false)
+}
+
+func (tx *Tx) LPop(key string) ([]byte, error) {
+	return listPop(tx, key, true)
+}
+
+func (tx *Tx) RPop(key string) ([]byte, error) {
+	return listPop(tx, key, false)
+}
+
+func (tx *Tx) LTrim(key string, start, stop int) error {
+	return listTrim(tx, key, start, stop)
+}
+
+func (tx *Tx) LSet(key string, index int, value []byte) error {
+	return listSet(tx, key, index, value)
+}
+
+func (tx *Tx) SAdd(key string, members []string, exp time.Duration) (int, error) {
+	return setAdd(tx, key, members, exp)
+}
+
+func (tx *Tx) SRem(key string, members ...string) (int, error) {
+	return setRemove(tx, key, members)
+}
+
+func (tx *Tx) SPop(key string, count int) ([]string, error) {
+	return setPop(tx, key, count)
+}
+
+func (tx *Tx) SUnionStore(dest string, keys []string, exp time.Duration) (int, error) {
+	return storeSets(tx, dest, keys, setUnion, exp)
+}
+
+func (tx *Tx) SInterStore(dest string, keys []string, exp time.Duration) (int, error) {
+	return storeSets(tx, dest, keys, setInter, exp)
+}
+
+func (tx *Tx) SDiffStore(dest string, keys []string, exp time.Duration) (int, error) {
+	return storeSets(tx, dest, keys, setDiff, exp)
+}
+
+func (tx *Tx) ZAdd(key string, members []utils.ZMember, exp time.Duration) (int, error) {
+	return zsetAdd(tx, key, members, exp)
+}
+
+func (tx *Tx) ZIncrBy(key, member string, delta float64, exp time.Duration) (float64, error) {
+	return zsetIncrBy(tx, key, member, delta, exp)
+}
+
+func (tx *Tx) ZRem(key string, members ...string) (int, error) {
+	return zsetRemove(tx, key, members)
+}
+
+func (tx *Tx) ZRemRangeByScore(key string, minScore, maxScore float64) (int, error) {
+	return zsetRemoveRangeByScore(tx, key, minScore, maxScore)
+}
+
+func (tx *Tx) SetBit(key string, offset int, value bool, exp time.Duration) (bool, error) {
+	return setBit(tx, key, offset, value, exp)
+}
+
+func (tx *Tx) BitOp(op BitOperation, dest string, keys ...string) (int, error) {
+	return bitOp(tx, op, dest, keys)
+}
+
+type txUndo struct {
+	key     string
+	old     entry.Entry
+	zset    *zset
+	list    *list
+	existed bool
+}
+
+// commit applies the buffered writes. If one of them cannot be stored, for
+// example because the cache is full, the ones already applied are undone.
+// Keys evicted to make room stay evicted. Either way the live values changed
+// in place are final once commit returns.
+func (tx *Tx) commit() error {
+	if len(tx.order) == 0 {
+		return nil
+	}
+	s := tx.store
+	if s.dirty != nil {
+		s.dirty.mux.Lock()
+		defer s.dirty.mux.Unlock()
+	}
+
+	undo := make([]txUndo, 0, len(tx.order))
+	var evicted []string
+	var err error
+	for _, key := range tx.order {
+		sh := s.shardFor(key)
+		old, existed := sh.memorydb[key]
+		undo = append(undo, txUndo{key: key, old: old, zset: sh.zsets[key], list: sh.lists[key], existed: existed})
+
+		e := tx.writes[key]
+		if e == nil {
+			sh.unsafeRemove(key)
+			continue
+		}
+		e.Version = s.nextVersion()
+		var ev []string
+		if z, ok := tx.zsets[key]; ok {
+			ev, err = sh.unsafePutZSet(key, *e, z)
+		} else if l, ok := tx.lists[key]; ok {
+			ev, err = sh.unsafePutList(key, *e, l)
+		} else {
+			ev, err = sh.unsafePut(key, *e)
+		}
+		evicted = append(evicted, ev...)
+		if err != nil {
+			break
+		}
+	}
+
+	if err != nil {
+		// Restore the live values first, the undone entries refer to them.
+		tx.rollback()
+		for i := len(undo) - 1; i >= 0; i-- {
+			u := undo[i]
+			sh := s.shardFor(u.key)
+			sh.unsafeRemove(u.key)
+			var ev []string
+			if u.zset != nil {
+				ev, _ = sh.unsafePutZSet(u.key, u.old, u.zset)
+			} else if u.list != nil {
+				ev, _ = sh.unsafePutList(u.key, u.old, u.list)
+			} else if u.existed {
+				ev, _ = sh.unsafePut(u.key, u.old)
+			}
+			evicted = append(evicted, ev...)
+		}
+	}
+
+	// Record the final state of every key the commit touched.
+	var ops []op
+	record := func(key string) {
+		sh := s.shardFor(key)
+		if e, ok := sh.memorydb[key]; ok {
+			if s.dirty != nil {
+				s.dirty.unsafeSet(key)
+			}
+			ops = append(ops, op{kind: opSet, key: key, entry: sh.unsafeEncode(key, e)})
+			return
+		}
+		if s.dirty != nil {
+			s.dirty.unsafeDelete(key)
+		}
+		ops = append(ops, op{kind: opDelete, key: key})
+	}
+	for _, key := range evicted {
+		if _, ok := s.shardFor(key).memorydb[key]; !ok {
+			record(key)
+		}
+	}
+	for _, u := range undo {
+		_, ok := s.shardFor(u.key).memorydb[u.key]
+		if err == nil || (u.existed && !ok) {
+			record(u.key)
+		}
+	}
+	s.logOps(ops)
+	tx.undo = nil
+	return err
+}
@@ -0,0 +1,268 @@
+package store
+
+import (
+	goerrors "errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func TestUpdate_CommitsAllWrites(t *testing.T) {
+	store := newLoaderStore(t)
+	store.SetInt64("balance:a", 100, 0)
+	store.SetString("old", "x", 0)
+
+	err := store.Update(func(tx *Tx) error {
+		if err := tx.IncrInt64("balance:a", -30, 0); err != nil {
+			return err
+		}
+		if err := tx.IncrInt64("balance:b", 30, 0); err != nil {
+			return err
+		}
+		if err := tx.Delete("old"); err != nil {
+			return err
+		}
+		// Reads inside the transaction see its own writes.
+		if n := tx.Exists("old", "balance:b"); n != 1 {
+			t.Errorf("tx.Exists() = %d, want 1", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if a, _ := store.GetInt64("balance:a"); a != 70 {
+		t.Errorf("balance:a = %d, want 70", a)
+	}
+	if b, _ := store.GetInt64("balance:b"); b != 30 {
+		t.Errorf("balance:b = %d, want 30", b)
+	}
+	if store.Exists("old") != 0 {
+		t.Error("old should be deleted")
+	}
+}
+
+func TestUpdate_ErrorDiscardsWrites(t *testing.T) {
+	store := newLoaderStore(t)
+	store.SetUInt64("stock", 1, 0)
+
+	err := store.Update(func(tx *Tx) error {
+		if err := tx.Set("order", types.STRING, []byte("pending"), 0); err != nil {
+			return err
+		}
+		return tx.DecrUInt64("stock", 2, 0)
+	})
+	if err == nil {
+		t.Fatal("Update() should fail on underflow")
+	}
+	if store.Exists("order") != 0 {
+		t.Error("writes of a failed transaction must not be applied")
+	}
+	if n, _ := store.GetUInt64("stock"); n != 1 {
+		t.Errorf("stock = %d, want 1", n)
+	}
+}
+
+func TestView_ReadOnly(t *testing.T) {
+	store := newLoaderStore(t)
+	store.SetString("k", "v", 0)
+
+	var leaked *Tx
+	err := store.View(func(tx *Tx) error {
+		leaked = tx
+		if _, v, err := tx.Get("k"); err != nil || string(v) != "v" {
+			t.Errorf("tx.Get() = %q, %v", v, err)
+		}
+		return tx.Set("k", types.STRING, []byte("w"), 0)
+	})
+	if !goerrors.Is(err, errors.ErrTxReadOnly) {
+		t.Errorf("View() error = %v, want ErrTxReadOnly", err)
+	}
+	if _, _, err := leaked.Get("k"); !goerrors.Is(err, errors.ErrTxDone) {
+		t.Errorf("Get() after View returned error = %v, want ErrTxDone", err)
+	}
+}
+
+func TestUpdate_RollsBackWhenCacheIsFull(t *testing.T) {
+	store, err := NewCacheStore(config.Config{
+		DBSave:         false,
+		MaxEntries:     2,
+		EvictionPolicy: config.NoEviction,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	store.SetString("a", "1", 0)
+
+	err = store.Update(func(tx *Tx) error {
+		tx.Set("a", types.STRING, []byte("2"), 0)
+		tx.Set("b", types.STRING, []byte("2"), 0)
+		return tx.Set("c", types.STRING, []byte("2"), 0)
+	})
+	if !goerrors.Is(err, errors.ErrCacheFull) {
+		t.Fatalf("Update() error = %v, want ErrCacheFull", err)
+	}
+	if v, _ := store.GetString("a"); v != "1" {
+		t.Errorf("a = %q, want the value from before the transaction", v)
+	}
+	if store.Exists("b", "c") != 0 {
+		t.Error("b and c should have been rolled back")
+	}
+}
+
+func TestUpdateKeys_LocksOnlyItsShards(t *testing.T) {
+	store, err := NewCacheStore(config.Config{DBSave: false, Shards: 8})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	other := "other"
+	for shardIndex(other, 8) == shardIndex("k", 8) {
+		other += "!"
+	}
+	err = store.UpdateKeys([]string{"k"}, func(tx *Tx) error {
+		// A write to another shard would deadlock if UpdateKeys held it.
+		if err := store.SetString(other, "v", 0); err != nil {
+			return err
+		}
+		if _, _, err := tx.Get(other); err == nil {
+			t.Error("tx.Get() expected error for a key outside the transaction")
+		}
+		if err := tx.Delete(other); err == nil {
+			t.Error("tx.Delete() expected error for a key outside the transaction")
+		}
+		return tx.IncrInt64("k", 5, 0)
+	})
+	if err != nil {
+		t.Fatalf("UpdateKeys() error = %v", err)
+	}
+	if v, _ := store.GetInt64("k"); v != 5 {
+		t.Errorf("k = %d, want 5", v)
+	}
+	if v, _ := store.GetString(other); v != "v" {
+		t.Errorf("%s = %q, want v", other, v)
+	}
+}
+
+func TestWatch_AbortsOnChange(t *testing.T) {
+	store := newLoaderStore(t)
+	store.SetInt64("k", 1, 0)
+
+	w := store.Watch("k", "missing")
+	store.SetInt64("k", 2, 0)
+	err := w.Update(func(tx *Tx) error {
+		return tx.IncrInt64("k", 10, 0)
+	})
+	if !goerrors.Is(err, errors.ErrConflict) {
+		t.Fatalf("Update() error = %v, want ErrConflict", err)
+	}
+	if v, _ := store.GetInt64("k"); v != 2 {
+		t.Errorf("k = %d, want 2", v)
+	}
+
+	w = store.Watch("k", "missing")
+	if err := w.Update(func(tx *Tx) error { return tx.IncrInt64("k", 10, 0) }); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if v, _ := store.GetInt64("k"); v != 12 {
+		t.Errorf("k = %d, want 12", v)
+	}
+}
+
+func TestWatch_Expiry(t *testing.T) {
+	store := newLoaderStore(t)
+	store.SetString("k", "v", 20*time.Millisecond)
+
+	w := store.Watch("k")
+	time.Sleep(40 * time.Millisecond)
+	if err := w.Update(func(tx *Tx) error { return nil }); !goerrors.Is(err, errors.ErrConflict) {
+		t.Errorf("Update() after expiry error = %v, want ErrConflict", err)
+	}
+}
+
+func TestUpdate_ConcurrentTransfers(t *testing.T) {
+	store, err := NewCacheStore(config.Config{DBSave: false, Shards: 4})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	store.SetInt64("a", 1000, 0)
+	store.SetInt64("b", 1000, 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				store.Update(func(tx *Tx) error {
+					if err := tx.IncrInt64("a", -1, 0); err != nil {
+						return err
+					}
+					return tx.IncrInt64("b", 1, 0)
+				})
+			}
+		}()
+	}
+	var violations int
+	for i := 0; i < 50; i++ {
+		store.View(func(tx *Tx) error {
+			_, a, _ := tx.Get("a")
+			_, b, _ := tx.Get("b")
+			av, _ := Int64Codec.Decode(a)
+			bv, _ := Int64Codec.Decode(b)
+			if av+bv != 2000 {
+				violations++
+			}
+			return nil
+		})
+	}
+	wg.Wait()
+
+	if violations > 0 {
+		t.Errorf("View() observed %d inconsistent states", violations)
+	}
+	a, _ := store.GetInt64("a")
+	b, _ := store.GetInt64("b")
+	if a != 600 || b != 1400 {
+		t.Errorf("a, b = %d, %d, want 600, 1400", a, b)
+	}
+}
+
+func TestUpdate_DirtyTracking(t *testing.T) {
+	store, err := NewCacheStore(config.Config{
+		DBSave:              true,
+		DBFileName:          tempDBFile(t),
+		SaveDirtyData:       true,
+		DBSaveInterval:      time.Hour,
+		DirtyThresholdCount: 100,
+		DirtyThresholdRatio: 1,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	store.SetString("gone", "x", 0)
+	store.Sync()
+
+	store.Update(func(tx *Tx) error {
+		tx.Set("new", types.STRING, []byte("v"), 0)
+		return tx.Delete("gone")
+	})
+
+	store.dirty.mux.RLock()
+	defer store.dirty.mux.RUnlock()
+	if action, ok := store.dirty.dirtyData["new"]; !ok || action != DirtySet {
+		t.Errorf("new should be marked dirty set, got %v, %v", action, ok)
+	}
+	if action, ok := store.dirty.dirtyData["gone"]; !ok || action != DirtyDelete {
+		t.Errorf("gone should be marked dirty delete, got %v, %v", action, ok)
+	}
+}
@@ -0,0 +1,323 @@
+package store
+
+import (
+	"encoding/binary"
+	goerrors "errors"
+	"math/bits"
+	"time"
+
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// Bitmaps are RAW values read as bit arrays, as in Redis: bit 0 is the most
+// significant bit of the first byte. A missing key reads as all clear bits,
+// and SetBit grows the value with zero bytes to reach its offset. Reads work
+// on the stored value in place. CacheStore.SetBit modifies the value in place
+// too, once it has copied it into a buffer of its own; it only copies again
+// when the value outgrows that buffer or was replaced by another write.
+//
+// Byte ranges follow LRange: start and end are both included, negative
+// values count from the last byte, and out of range values are clamped.
+
+// maxBitOffset bounds bitmaps to 512 MiB, as in Redis.
+const maxBitOffset = 1<<32 - 1
+
+// BitOperation is an operator for BitOp.
+type BitOperation uint8
+
+const (
+	BitAnd BitOperation = iota // bits set in every source
+	BitOr                      // bits set in any source
+	BitXor                     // bits set in an odd number of sources
+	BitNot                     // bits clear in the only source
+)
+
+// GetBit returns the bit at offset. Bits past the end of the value are clear.
+func (s *CacheStore) GetBit(key string, offset int) (bool, error) {
+	if uint64(offset) > maxBitOffset {
+		return false, errors.ErrBitOffset
+	}
+	bit := false
+	err := s.viewData(key, types.RAW, func(data []byte) error {
+		bit = getBit(data, offset)
+		return nil
+	})
+	return bit, err
+}
+
+// SetBit sets or clears the bit at offset and returns its previous value.
+// Slices returned by GetNoCopy or GetRawNoCopy for key may change.
+func (s *CacheStore) SetBit(key string, offset int, value bool, exp time.Duration) (bool, error) {
+	if key == "" {
+		return false, errors.ErrKeyEmpty
+	}
+	if uint64(offset) > maxBitOffset {
+		return false, errors.ErrBitOffset
+	}
+	if s.readOnly.Load() {
+		return false, errors.ErrReadOnly
+	}
+	sh := s.shardFor(key)
+	sh.mux.Lock()
+	defer sh.mux.Unlock()
+
+	old, err := sh.unsafeGet(key)
+	exists := err == nil
+	if err != nil && !goerrors.Is(err, errors.ErrNotFound) {
+		return false, err
+	}
+	var data []byte
+	if exists {
+		if old.Type != types.RAW {
+			return false, errors.ErrTypeMismatch(key, types.RAW, old.Type)
+		}
+		data = old.Data
+	}
+	i := offset / 8
+	prev := getBit(data, offset)
+	if prev == value && i < len(data) && exp <= 0 {
+		return prev, nil
+	}
+
+	if _, owned := sh.bitmaps[key]; !owned || i >= cap(data) {
+		data = growBitmap(data, i+1)
+	} else if i >= len(data) {
+		// The spare capacity of an owned buffer is still zeroed.
+		data = data[:i+1]
+	}
+	writeBit(data, offset, value)
+	e := entry.NewEntry(types.RAW, data, exp)
+	if exists {
+		e = keepExp(old, types.RAW, data, exp)
+	}
+	if err := s.unsafeSetEntry(sh, key, e); err != nil {
+		// data may be the stored value; only the one bit changed.
+		writeBit(data, offset, prev)
+		return false, err
+	}
+	if sh.bitmaps == nil {
+		sh.bitmaps = make(map[string]struct{})
+	}
+	sh.bitmaps[key] = struct{}{}
+	return prev, nil
+}
+
+// growBitmap copies data into a new buffer of at least n bytes. Growing
+// buffers get spare capacity, so that setting increasing offsets does not
+// copy every time.
+func growBitmap(data []byte, n int) []byte {
+	size := max(len(data), n)
+	capacity := size
+	if n > len(data) {
+		capacity = max(size, 2*len(data))
+	}
+	grown := make([]byte, size, capacity)
+	copy(grown, data)
+	return grown
+}
+
+// BitCount returns the number of set bits in the bytes from start to end.
+// BitCount(key, 0, -1) counts the whole value.
+func (s *CacheStore) BitCount(key string, start, end int) (int, error) {
+	n := 0
+	err := s.viewData(key, types.RAW, func(data []byte) error {
+		from, to := listRange(start, end, len(data))
+		n = countBits(data[from:to])
+		return nil
+	})
+	return n, err
+}
+
+// BitPos returns the offset of the first bit set to bit in the bytes from
+// start to end, or -1 when there is none. A missing key has its first clear
+// bit at 0.
+//
+// When looking for a clear bit, the bits after the value only count as clear
+// when end is past the last byte. Redis does so when BITPOS is given no end,
+// which is BitPos(key, false, start, math.MaxInt) here. An end of -1 stops at
+// the last byte like an explicit end in Redis, so a value of set bits only
+// gives -1.
+func (s *CacheStore) BitPos(key string, bit bool, start, end int) (int, error) {
+	pos := -1
+	err := s.viewData(key, types.RAW, func(data []byte) error {
+		if data == nil {
+			if !bit {
+				pos = 0
+			}
+			return nil
+		}
+		from, to := listRange(start, end, len(data))
+		if from == to {
+			return nil
+		}
+		if pos = findBit(data[from:to], bit); pos >= 0 {
+			pos += 8 * from
+		} else if !bit && end >= len(data) {
+			pos = 8 * len(data)
+		}
+		return nil
+	})
+	return pos, err
+}
+
+// BitOp stores the result of op over the bitmaps at keys in dest, replacing
+// what dest held, and returns its length in bytes. Shorter sources are padded
+// with clear bits to the length of the longest. It runs as one transaction,
+// stores the result without expiry and deletes dest when the result is
+// empty. BitNot takes exactly one key.
+func (s *CacheStore) BitOp(op BitOperation, dest string, keys ...string) (int, error) {
+	n := 0
+	err := s.Update(func(tx *Tx) error {
+		var err error
+		n, err = bitOp(tx, op, dest, keys)
+		return err
+	})
+	return n, err
+}
+
+func getBit(data []byte, offset int) bool {
+	i := offset / 8
+	return i < len(data) && data[i]&(0x80>>(offset%8)) != 0
+}
+
+func writeBit(data []byte, offset int, value bool) {
+	mask := byte(0x80 >> (offset % 8))
+	if value {
+		data[offset/8] |= mask
+	} else {
+		data[offset/8] &^= mask
+	}
+}
+
+// setBit is SetBit for transactions, which copy the value so that it can be
+// restored on rollback.
+func setBit(kv entryModifier, key string, offset int, value bool, exp time.Duration) (bool, error) {
+	if uint64(offset) > maxBitOffset {
+		return false, errors.ErrBitOffset
+	}
+	old := false
+	err := updateData(kv, key, types.RAW, exp, func(data []byte) ([]byte, error) {
+		i := offset / 8
+		old = getBit(data, offset)
+		if old == value && i < len(data) && exp <= 0 {
+			return nil, errUnchanged
+		}
+		bitmap := make([]byte, max(len(data), i+1))
+		copy(bitmap, data)
+		writeBit(bitmap, offset, value)
+		return bitmap, nil
+	})
+	return old, err
+}
+
+func countBits(data []byte) int {
+	n := 0
+	for ; len(data) >= 8; data = data[8:] {
+		n += bits.OnesCount64(binary.BigEndian.Uint64(data))
+	}
+	for _, b := range data {
+		n += bits.OnesCount8(b)
+	}
+	return n
+}
+
+// findBit returns the offset of the first bit set to bit in data, or -1.
+func findBit(data []byte, bit bool) int {
+	var flip uint64
+	if !bit {
+		flip = ^uint64(0)
+	}
+	i := 0
+	for ; i+8 <= len(data); i += 8 {
+		if w := binary.BigEndian.Uint64(data[i:]) ^ flip; w != 0 {
+			return 8*i + bits.LeadingZeros64(w)
+		}
+	}
+	for ; i < len(data); i++ {
+		if b := data[i] ^ byte(flip); b != 0 {
+			return 8*i + bits.LeadingZeros8(b)
+		}
+	}
+	return -1
+}
+
+func bitOp(tx *Tx, op BitOperation, dest string, keys []string) (int, error) {
+	if op > BitNot {
+		return 0, errors.ErrBitOperation
+	}
+	if dest == "" || len(keys) == 0 {
+		return 0, errors.ErrKeyEmpty
+	}
+	if op == BitNot && len(keys) != 1 {
+		return 0, errors.ErrBitNotKeys
+	}
+	sources := make([][]byte, len(keys))
+	size := 0
+	for i, key := range keys {
+		data, err := tx.rawData(key)
+		if err != nil {
+			return 0, err
+		}
+		sources[i] = data
+		size = max(size, len(data))
+	}
+	if size == 0 {
+		if tx.Exists(dest) == 0 {
+			return 0, nil
+		}
+		return 0, tx.Delete(dest)
+	}
+
+	result := make([]byte, size)
+	copy(result, sources[0])
+	for _, src := range sources[1:] {
+		switch op {
+		case BitAnd:
+			for i := range result {
+				if i < len(src) {
+					result[i] &= src[i]
+				} else {
+					result[i] = 0
+				}
+			}
+		case BitOr:
+			for i, b := range src {
+				result[i] |= b
+			}
+		case BitXor:
+			for i, b := range src {
+				result[i] ^= b
+			}
+		}
+	}
+	if op == BitNot {
+		for i := range result {
+			result[i] = ^result[i]
+		}
+	}
+	e := entry.NewEntry(types.RAW, result, 0)
+	return size, tx.putEntry(dest, &e)
+}
+
+// rawData returns the RAW value at key, nil when missing.
+func (tx *Tx) rawData(key string) ([]byte, error) {
+	if key == "" {
+		return nil, errors.ErrKeyEmpty
+	}
+	if tx.done {
+		return nil, errors.ErrTxDone
+	}
+	e, err := tx.getEntry(key)
+	if err != nil {
+		if goerrors.Is(err, errors.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if e.Type != types.RAW {
+		return nil, errors.ErrTypeMismatch(key, types.RAW, e.Type)
+	}
+	return e.Data, nil
+}
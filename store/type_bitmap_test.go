@@ -0,0 +1,351 @@
+package store
+
+import (
+	"bytes"
+	goerrors "errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func newBitmapStore(t *testing.T) *CacheStore {
+	t.Helper()
+	store, err := NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func expectRaw(t *testing.T, store *CacheStore, key string, want []byte) {
+	t.Helper()
+	data, err := store.GetRaw(key)
+	if err != nil || !bytes.Equal(data, want) {
+		t.Errorf("GetRaw(%s) = %08b, %v, want %08b", key, data, err, want)
+	}
+}
+
+func TestBitmap_SetGet(t *testing.T) {
+	store := newBitmapStore(t)
+
+	if old, err := store.SetBit("dau", 10, true, 0); err != nil || old {
+		t.Fatalf("SetBit(10) = %v, %v, want false", old, err)
+	}
+	expectRaw(t, store, "dau", []byte{0x00, 0x20})
+	if old, _ := store.SetBit("dau", 10, true, 0); !old {
+		t.Error("SetBit() of a set bit returned false")
+	}
+	store.SetBit("dau", 0, true, 0)
+	store.SetBit("dau", 10, false, 0)
+	expectRaw(t, store, "dau", []byte{0x80, 0x00})
+
+	for _, tt := range []struct {
+		offset int
+		want   bool
+	}{{0, true}, {1, false}, {10, false}, {1000, false}} {
+		if bit, err := store.GetBit("dau", tt.offset); err != nil || bit != tt.want {
+			t.Errorf("GetBit(%d) = %v, %v, want %v", tt.offset, bit, err, tt.want)
+		}
+	}
+	if bit, err := store.GetBit("missing", 3); err != nil || bit {
+		t.Errorf("GetBit() of a missing key = %v, %v, want false", bit, err)
+	}
+
+	// Clearing a bit past the end grows the value too, as in Redis.
+	store.SetBit("zero", 20, false, 0)
+	expectRaw(t, store, "zero", []byte{0, 0, 0})
+
+	store.SetRaw("raw", []byte("a"), 0)
+	store.SetBit("raw", 6, true, 0)
+	expectRaw(t, store, "raw", []byte("c"))
+}
+
+func TestBitmap_SetInPlace(t *testing.T) {
+	store := newBitmapStore(t)
+
+	// The first SetBit copies a value it did not allocate.
+	value := []byte{0x00, 0x00}
+	store.SetRaw("b", value, 0)
+	store.SetBit("b", 0, true, 0)
+	if value[0] != 0 {
+		t.Fatal("SetBit() modified the slice given to SetRaw")
+	}
+
+	data, _ := store.GetRawNoCopy("b")
+	store.SetBit("b", 9, true, 0)
+	store.SetBit("b", 3, true, 0)
+	if after, _ := store.GetRawNoCopy("b"); &after[0] != &data[0] {
+		t.Error("SetBit() within the value copied it")
+	}
+	expectRaw(t, store, "b", []byte{0x90, 0x40})
+
+	// Growing past the capacity copies; growing within it does not.
+	store.SetBit("b", 20, true, 0)
+	data, _ = store.GetRawNoCopy("b")
+	store.SetBit("b", 24, true, 0)
+	if after, _ := store.GetRawNoCopy("b"); &after[0] != &data[0] {
+		t.Error("SetBit() within the spare capacity copied the value")
+	}
+	expectRaw(t, store, "b", []byte{0x90, 0x40, 0x08, 0x80})
+
+	// Another write gives up the buffer.
+	store.SetRaw("b", data[:1], 0)
+	store.SetBit("b", 1, true, 0)
+	if data[0] != 0x90 {
+		t.Error("SetBit() modified a value written by SetRaw")
+	}
+	expectRaw(t, store, "b", []byte{0xd0})
+}
+
+// TestBitmap_SetInPlaceConcurrent runs SetBit against the readers that copy
+// or hold the shard lock, which must not race with it under -race. Only
+// GetNoCopy and GetRawNoCopy hand out the buffer SetBit modifies.
+func TestBitmap_SetInPlaceConcurrent(t *testing.T) {
+	store := newBitmapStore(t)
+	store.SetBit("b", 0, true, 0)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			data, err := store.GetRaw("b")
+			if err == nil && len(data) > 0 {
+				data[0] = 0 // the copy is the caller's
+			}
+			store.GetBit("b", i%64)
+			store.BitCount("b", 0, -1)
+			store.Get("b")
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		store.SetBit("b", i%64, i%2 == 0, 0)
+	}
+	close(done)
+	wg.Wait()
+
+	if n, err := store.BitCount("b", 0, -1); err != nil || n != 32 {
+		t.Errorf("BitCount() = %d, %v, want 32", n, err)
+	}
+}
+
+func TestBitmap_SetInPlaceCacheFull(t *testing.T) {
+	store, err := NewCacheStore(config.Config{DBSave: false, MaxBytes: 4, EvictionPolicy: config.NoEviction})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// The third byte leaves room for a fourth, which is too large.
+	for _, offset := range []int{0, 9, 17} {
+		if _, err := store.SetBit("b", offset, true, 0); err != nil {
+			t.Fatalf("SetBit(%d) error = %v", offset, err)
+		}
+	}
+	if _, err := store.SetBit("b", 25, true, 0); err == nil {
+		t.Fatal("SetBit() expected entry too large error")
+	}
+	expectRaw(t, store, "b", []byte{0x80, 0x40, 0x40})
+	if bit, _ := store.GetBit("b", 25); bit {
+		t.Error("failed SetBit() left its bit set")
+	}
+}
+
+func TestBitmap_Count(t *testing.T) {
+	store := newBitmapStore(t)
+	// 12 bytes, so that counting goes through a whole word and a tail.
+	store.SetRaw("b", []byte{0xff, 0x01, 0, 0, 0, 0, 0, 0x80, 0x0f, 0, 0, 0x03}, 0)
+
+	for _, tt := range []struct {
+		start, end, want int
+	}{
+		{0, -1, 16}, {0, 0, 8}, {1, 7, 2}, {-4, -1, 6}, {-1, -1, 2}, {5, 2, 0}, {0, 100, 16}, {20, 30, 0},
+	} {
+		if n, err := store.BitCount("b", tt.start, tt.end); err != nil || n != tt.want {
+			t.Errorf("BitCount(%d, %d) = %d, %v, want %d", tt.start, tt.end, n, err, tt.want)
+		}
+	}
+	if n, err := store.BitCount("missing", 0, -1); err != nil || n != 0 {
+		t.Errorf("BitCount() of a missing key = %d, %v, want 0", n, err)
+	}
+}
+
+func TestBitmap_Pos(t *testing.T) {
+	store := newBitmapStore(t)
+	store.SetRaw("b", []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0x10}, 0)
+	store.SetRaw("ones", []byte{0xff, 0xff, 0xfe}, 0)
+	store.SetRaw("full", []byte{0xff, 0xff}, 0)
+
+	for _, tt := range []struct {
+		key        string
+		bit        bool
+		start, end int
+		want       int
+	}{
+		{"b", true, 0, -1, 75},
+		{"b", true, 0, 8, -1},
+		{"b", true, -1, -1, 75},
+		{"b", false, 0, -1, 0},
+		{"b", false, 9, -1, 72},
+		{"ones", false, 0, -1, 23},
+		{"ones", false, 0, 1, -1},
+		{"full", false, 0, -1, -1},
+		{"full", false, 0, 100, 16},
+		{"full", false, 0, math.MaxInt, 16},
+		{"full", false, 5, 100, -1},
+		{"missing", true, 0, -1, -1},
+		{"missing", false, 0, -1, 0},
+	} {
+		if pos, err := store.BitPos(tt.key, tt.bit, tt.start, tt.end); err != nil || pos != tt.want {
+			t.Errorf("BitPos(%s, %v, %d, %d) = %d, %v, want %d", tt.key, tt.bit, tt.start, tt.end, pos, err, tt.want)
+		}
+	}
+}
+
+func TestBitmap_Op(t *testing.T) {
+	store := newBitmapStore(t)
+	store.SetRaw("a", []byte{0xf0, 0xff}, 0)
+	store.SetRaw("b", []byte{0x3c}, 0)
+
+	for _, tt := range []struct {
+		op   BitOperation
+		keys []string
+		want []byte
+	}{
+		{BitAnd, []string{"a", "b"}, []byte{0x30, 0x00}},
+		{BitOr, []string{"a", "b"}, []byte{0xfc, 0xff}},
+		{BitXor, []string{"a", "b"}, []byte{0xcc, 0xff}},
+		{BitXor, []string{"a", "b", "b"}, []byte{0xf0, 0xff}},
+		{BitOr, []string{"missing", "b"}, []byte{0x3c}},
+		{BitAnd, []string{"a", "missing"}, []byte{0x00, 0x00}},
+		{BitNot, []string{"b"}, []byte{0xc3}},
+	} {
+		n, err := store.BitOp(tt.op, "dest", tt.keys...)
+		if err != nil || n != len(tt.want) {
+			t.Errorf("BitOp(%d, %v) = %d, %v, want %d", tt.op, tt.keys, n, err, len(tt.want))
+		}
+		expectRaw(t, store, "dest", tt.want)
+	}
+
+	// The destination may be a source, and an empty result deletes it.
+	store.SetString("dest", "x", time.Hour)
+	store.BitOp(BitNot, "dest", "a")
+	store.BitOp(BitAnd, "dest", "dest", "a")
+	expectRaw(t, store, "dest", []byte{0x00, 0x00})
+	if ttl := store.TTL("dest"); ttl != TTLNoExpiry {
+		t.Errorf("TTL() after BitOp() = %v, want no expiry", ttl)
+	}
+	if n, err := store.BitOp(BitOr, "dest", "missing", "nothing"); err != nil || n != 0 {
+		t.Errorf("BitOp() of missing keys = %d, %v, want 0", n, err)
+	}
+	if store.Exists("dest") != 0 {
+		t.Error("an empty result should delete the destination")
+	}
+}
+
+func TestBitmap_Errors(t *testing.T) {
+	store := newBitmapStore(t)
+	store.SetString("s", "v", 0)
+	store.SetRaw("a", []byte{1}, 0)
+
+	if _, err := store.SetBit("s", 0, true, 0); !goerrors.Is(err, errors.ErrWrongType) {
+		t.Errorf("SetBit() on a string error = %v, want ErrWrongType", err)
+	}
+	if _, err := store.BitCount("s", 0, -1); !goerrors.Is(err, errors.ErrWrongType) {
+		t.Errorf("BitCount() on a string error = %v, want ErrWrongType", err)
+	}
+	if _, err := store.BitOp(BitOr, "dest", "a", "s"); !goerrors.Is(err, errors.ErrWrongType) {
+		t.Errorf("BitOp() with a string error = %v, want ErrWrongType", err)
+	}
+	if store.Exists("dest") != 0 {
+		t.Error("failed BitOp() created the destination")
+	}
+	for _, offset := range []int{-1, maxBitOffset + 1} {
+		if _, err := store.SetBit("a", offset, true, 0); err != errors.ErrBitOffset {
+			t.Errorf("SetBit(%d) error = %v, want ErrBitOffset", offset, err)
+		}
+		if _, err := store.GetBit("a", offset); err != errors.ErrBitOffset {
+			t.Errorf("GetBit(%d) error = %v, want ErrBitOffset", offset, err)
+		}
+	}
+	if _, err := store.BitOp(BitNot, "dest", "a", "a"); err != errors.ErrBitNotKeys {
+		t.Errorf("BitOp(BitNot) of two keys error = %v, want ErrBitNotKeys", err)
+	}
+	if _, err := store.BitOp(BitNot+1, "dest", "a"); err != errors.ErrBitOperation {
+		t.Errorf("BitOp() with an unknown operation error = %v, want ErrBitOperation", err)
+	}
+	if _, err := store.BitOp(BitOr, "dest"); err != errors.ErrKeyEmpty {
+		t.Errorf("BitOp() without keys error = %v, want ErrKeyEmpty", err)
+	}
+}
+
+func TestBitmap_ExpiryAndTransaction(t *testing.T) {
+	store := newBitmapStore(t)
+
+	store.SetBit("b", 1, true, time.Hour)
+	store.SetBit("b", 2, true, 0)
+	if ttl := store.TTL("b"); ttl <= 0 || ttl > time.Hour {
+		t.Errorf("TTL() after SetBit() without expiry = %v, want the original TTL", ttl)
+	}
+	store.SetBit("b", 2, true, time.Minute)
+	if ttl := store.TTL("b"); ttl > time.Minute {
+		t.Errorf("TTL() after an unchanged SetBit() with expiry = %v, want at most a minute", ttl)
+	}
+
+	err := store.Update(func(tx *Tx) error {
+		if _, err := tx.SetBit("c", 7, true, 0); err != nil {
+			return err
+		}
+		_, err := tx.BitOp(BitOr, "d", "b", "c")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	expectRaw(t, store, "d", []byte{0x61})
+
+	rollback := goerrors.New("rollback")
+	store.Update(func(tx *Tx) error {
+		tx.SetBit("d", 0, true, 0)
+		return rollback
+	})
+	expectRaw(t, store, "d", []byte{0x61})
+}
+
+func TestBitmap_Persistence(t *testing.T) {
+	dbFile := tempDBFile(t)
+	cfg := config.Config{DBSave: true, DBFileName: dbFile}
+	store, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for _, user := range []int{3, 1000, 99999} {
+		store.SetBit("dau:2024-05-01", user, true, 0)
+	}
+	store.Sync()
+	store.Close()
+
+	store2, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to re-create store: %v", err)
+	}
+	defer store2.Close()
+	if n, err := store2.BitCount("dau:2024-05-01", 0, -1); err != nil || n != 3 {
+		t.Errorf("BitCount() after restart = %d, %v, want 3", n, err)
+	}
+	if dataType, _, _ := store2.Get("dau:2024-05-01"); dataType != types.RAW {
+		t.Errorf("type after restart = %v, want Raw", dataType)
+	}
+}
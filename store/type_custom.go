@@ -0,0 +1,146 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// builtinDefinitions lets GetValue/SetValue treat built-in types the same way
+// as registered ones.
+var builtinDefinitions = map[types.DataType]types.Definition{
+	types.RAW:     definitionOf(RawCodec),
+	types.BOOLEAN: definitionOf(BoolCodec),
+	types.INT16:   definitionOf(Int16Codec),
+	types.INT32:   definitionOf(Int32Codec),
+	types.INT64:   definitionOf(Int64Codec),
+	types.UINT16:  definitionOf(UInt16Codec),
+	types.UINT32:  definitionOf(UInt32Codec),
+	types.UINT64:  definitionOf(UInt64Codec),
+	types.FLOAT32: definitionOf(Float32Codec),
+	types.FLOAT64: definitionOf(Float64Codec),
+	types.STRING:  definitionOf(StringCodec),
+	types.TIME:    definitionOf(TimeCodec),
+	types.HASH:    definitionOf(HashCodec),
+	types.LIST:    definitionOf(ListCodec),
+	types.SET:     definitionOf(SetCodec),
+	types.ZSET:    definitionOf(ZSetCodec),
+	types.JSON: {
+		Name:   types.JSON.String(),
+		Encode: func(value any) ([]byte, error) { return json.Marshal(value) },
+		Decode: func(data []byte) (any, error) {
+			var value any
+			err := json.Unmarshal(data, &value)
+			return value, err
+		},
+	},
+}
+
+func definitionOf[T any](codec Codec[T]) types.Definition {
+	return types.Definition{
+		Name: codec.DataType().String(),
+		Encode: func(value any) ([]byte, error) {
+			v, ok := value.(T)
+			if !ok {
+				return nil, errors.ErrValueType(codec.DataType(), value)
+			}
+			return codec.Encode(v)
+		},
+		Decode: func(data []byte) (any, error) {
+			return codec.Decode(data)
+		},
+	}
+}
+
+func lookupDefinition(dataType types.DataType) (types.Definition, error) {
+	if def, ok := builtinDefinitions[dataType]; ok {
+		return def, nil
+	}
+	if def, ok := types.Lookup(dataType); ok {
+		return def, nil
+	}
+	return types.Definition{}, errors.ErrUnknownDataType(dataType)
+}
+
+// RegisteredCodec adapts a registered DataType to a Codec[T] so it can be used
+// with Typed. Values of any other Go type are rejected when encoding.
+func RegisteredCodec[T any](dataType types.DataType) (Codec[T], error) {
+	def, ok := types.Lookup(dataType)
+	if !ok {
+		return nil, errors.ErrUnknownDataType(dataType)
+	}
+	return NewCodec(dataType,
+		func(value T) ([]byte, error) { return def.Encode(value) },
+		func(data []byte) (T, error) {
+			var zero T
+			value, err := def.Decode(data)
+			if err != nil {
+				return zero, err
+			}
+			v, ok := value.(T)
+			if !ok {
+				return zero, errors.ErrValueType(dataType, value)
+			}
+			return v, nil
+		},
+	), nil
+}
+
+// GetValue decodes the stored value with the codec of its DataType, whether
+// built-in or registered. JSON values are decoded into generic Go values.
+func (s *CacheStore) GetValue(key string) (types.DataType, any, error) {
+	if key == "" {
+		return types.UNKNOWN, nil, errors.ErrKeyEmpty
+	}
+	sh := s.shardFor(key)
+	sh.mux.RLock()
+	defer sh.mux.RUnlock()
+	e, err := sh.unsafeGet(key)
+	if err != nil {
+		return types.UNKNOWN, nil, err
+	}
+	def, err := lookupDefinition(e.Type)
+	if err != nil {
+		return e.Type, nil, err
+	}
+	value, err := def.Decode(e.Data)
+	return e.Type, value, err
+}
+
+// GetCustom decodes the value of a registered DataType, failing with a type
+// mismatch when key holds any other type.
+func (s *CacheStore) GetCustom(key string, dataType types.DataType) (any, error) {
+	if key == "" {
+		return nil, errors.ErrKeyEmpty
+	}
+	def, ok := types.Lookup(dataType)
+	if !ok {
+		return nil, errors.ErrUnknownDataType(dataType)
+	}
+	sh := s.shardFor(key)
+	sh.mux.RLock()
+	defer sh.mux.RUnlock()
+	e, err := sh.unsafeGet(key)
+	if err != nil {
+		return nil, err
+	}
+	if e.Type != dataType {
+		return nil, errors.ErrTypeMismatch(key, dataType, e.Type)
+	}
+	return def.Decode(e.Data)
+}
+
+// SetValue encodes value with the codec of dataType and stores it.
+func (s *CacheStore) SetValue(key string, dataType types.DataType, value any, exp time.Duration) error {
+	def, err := lookupDefinition(dataType)
+	if err != nil {
+		return err
+	}
+	data, err := def.Encode(value)
+	if err != nil {
+		return err
+	}
+	return s.Set(key, dataType, data, exp)
+}
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+type point struct {
+	X, Y int32
+}
+
+const pointType types.DataType = types.UserTypeMin + 1
+
+func registerPoint(t *testing.T) {
+	t.Helper()
+	err := types.Register(pointType, types.Definition{
+		Name: "Point",
+		Encode: func(value any) ([]byte, error) {
+			p, ok := value.(point)
+			if !ok {
+				return nil, fmt.Errorf("not a point: %T", value)
+			}
+			data := make([]byte, 8)
+			binary.LittleEndian.PutUint32(data, uint32(p.X))
+			binary.LittleEndian.PutUint32(data[4:], uint32(p.Y))
+			return data, nil
+		},
+		Decode: func(data []byte) (any, error) {
+			if len(data) != 8 {
+				return nil, fmt.Errorf("invalid point length %d", len(data))
+			}
+			return point{
+				X: int32(binary.LittleEndian.Uint32(data)),
+				Y: int32(binary.LittleEndian.Uint32(data[4:])),
+			}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	t.Cleanup(func() { types.Unregister(pointType) })
+}
+
+func TestRegister_Validation(t *testing.T) {
+	noop := types.Definition{
+		Name:   "Noop",
+		Encode: func(any) ([]byte, error) { return nil, nil },
+		Decode: func([]byte) (any, error) { return nil, nil },
+	}
+	if err := types.Register(types.JSON, noop); err == nil {
+		t.Error("Register() should reject built-in ids")
+	}
+	if err := types.Register(types.UserTypeMin, types.Definition{Name: "NoCodec"}); err == nil {
+		t.Error("Register() should reject missing codec functions")
+	}
+	if err := types.Register(types.UserTypeMin, types.Definition{Encode: noop.Encode, Decode: noop.Decode}); err == nil {
+		t.Error("Register() should reject empty names")
+	}
+	if err := types.Register(types.UserTypeMin, noop); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	defer types.Unregister(types.UserTypeMin)
+	if err := types.Register(types.UserTypeMin, noop); err == nil {
+		t.Error("Register() should reject duplicate ids")
+	}
+	if types.UserTypeMin.String() != "Noop" {
+		t.Errorf("String() = %q, want Noop", types.UserTypeMin.String())
+	}
+}
+
+func TestCustomType_SetGet(t *testing.T) {
+	registerPoint(t)
+	store, err := NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.SetValue("p", pointType, point{1, -2}, time.Hour); err != nil {
+		t.Fatalf("SetValue() error = %v", err)
+	}
+	if err := store.SetValue("bad", pointType, "nope", 0); err == nil {
+		t.Error("SetValue() expected encode error")
+	}
+
+	v, err := store.GetCustom("p", pointType)
+	if err != nil || v != (point{1, -2}) {
+		t.Errorf("GetCustom() = %v, %v", v, err)
+	}
+	dataType, v, err := store.GetValue("p")
+	if err != nil || dataType != pointType || v != (point{1, -2}) {
+		t.Errorf("GetValue() = %v, %v, %v", dataType, v, err)
+	}
+
+	store.SetInt32("n", 7, 0)
+	if _, v, err := store.GetValue("n"); err != nil || v != int32(7) {
+		t.Errorf("GetValue() for built-in = %v, %v", v, err)
+	}
+	_, err = store.GetCustom("n", pointType)
+	if err == nil || !strings.Contains(err.Error(), "expected Point") {
+		t.Errorf("GetCustom() mismatch error = %v", err)
+	}
+	_, err = store.GetString("p")
+	if err == nil || !strings.Contains(err.Error(), "got Point") {
+		t.Errorf("GetString() mismatch error = %v", err)
+	}
+
+	codec, err := RegisteredCodec[point](pointType)
+	if err != nil {
+		t.Fatalf("RegisteredCodec() error = %v", err)
+	}
+	points := NewTyped(store, codec)
+	if p, err := points.Get("p"); err != nil || p != (point{1, -2}) {
+		t.Errorf("Typed Get() = %v, %v", p, err)
+	}
+}
+
+func TestCustomType_Unregistered(t *testing.T) {
+	store, err := NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	unknown := types.UserTypeMax
+	if err := store.Set("k", unknown, []byte{1}, 0); err == nil {
+		t.Error("Set() should reject unregistered data types")
+	}
+	if errs := store.MSet(NewItem("k", unknown, []byte{1}, 0)); errs[0] == nil {
+		t.Error("MSet() should reject unregistered data types")
+	}
+	if err := store.SetValue("k", unknown, 1, 0); err == nil {
+		t.Error("SetValue() should reject unregistered data types")
+	}
+	if _, err := RegisteredCodec[int](unknown); err == nil {
+		t.Error("RegisteredCodec() should reject unregistered data types")
+	}
+}
+
+func TestCustomType_Persistence(t *testing.T) {
+	registerPoint(t)
+	cfg := config.Config{DBSave: true, DBFileName: tempDBFile(t)}
+
+	store, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	store.SetValue("p", pointType, point{3, 4}, 0)
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	store, err = NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to re-create store: %v", err)
+	}
+	defer store.Close()
+	if v, err := store.GetCustom("p", pointType); err != nil || v != (point{3, 4}) {
+		t.Errorf("GetCustom() after reload = %v, %v", v, err)
+	}
+}
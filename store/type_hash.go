@@ -0,0 +1,234 @@
+package store
+
+import (
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// A HASH maps field names to values, see utils.EncodeHash for its stored
+// form and collection.go for the rules shared by collections.
+
+// viewHash calls fn with the encoded hash stored at key, nil when missing.
+func (s *CacheStore) viewHash(key string, fn func(data []byte) error) error {
+	return s.viewData(key, types.HASH, fn)
+}
+
+// HGet returns the value of field, or an error wrapping ErrNotFound when the
+// key or the field does not exist.
+func (s *CacheStore) HGet(key, field string) ([]byte, error) {
+	var value []byte
+	err := s.viewHash(key, func(data []byte) error {
+		if data == nil {
+			return errors.ErrNoDataForKey(key)
+		}
+		v, ok, err := utils.HashField(data, field)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.ErrNoDataForField(key, field)
+		}
+		value = slices.Clone(v)
+		return nil
+	})
+	return value, err
+}
+
+// HMGet returns the values of fields in order, with nil for missing fields.
+func (s *CacheStore) HMGet(key string, fields ...string) ([][]byte, error) {
+	values := make([][]byte, len(fields))
+	err := s.viewHash(key, func(data []byte) error {
+		if data == nil {
+			return nil
+		}
+		all, err := utils.DecodeHash(data)
+		if err != nil {
+			return err
+		}
+		for i, field := range fields {
+			values[i] = all[field]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// HGetAll returns every field of the hash, or an empty map when the key does
+// not exist.
+func (s *CacheStore) HGetAll(key string) (map[string][]byte, error) {
+	var fields map[string][]byte
+	err := s.viewHash(key, func(data []byte) error {
+		if data == nil {
+			fields = make(map[string][]byte)
+			return nil
+		}
+		var err error
+		fields, err = utils.DecodeHash(data)
+		return err
+	})
+	return fields, err
+}
+
+// HLen returns the number of fields of the hash.
+func (s *CacheStore) HLen(key string) (int, error) {
+	n := 0
+	err := s.viewHash(key, func(data []byte) error {
+		if data == nil {
+			return nil
+		}
+		var err error
+		n, err = utils.HashLen(data)
+		return err
+	})
+	return n, err
+}
+
+// HExists reports whether the hash has field.
+func (s *CacheStore) HExists(key, field string) (bool, error) {
+	found := false
+	err := s.viewHash(key, func(data []byte) error {
+		if data == nil {
+			return nil
+		}
+		var err error
+		_, found, err = utils.HashField(data, field)
+		return err
+	})
+	return found, err
+}
+
+// HSet sets the given fields and returns how many of them are new.
+func (s *CacheStore) HSet(key string, fields map[string][]byte, exp time.Duration) (int, error) {
+	return hashSet(s, key, fields, exp)
+}
+
+// HDel removes fields and returns how many of them existed.
+func (s *CacheStore) HDel(key string, fields ...string) (int, error) {
+	return hashDelete(s, key, fields)
+}
+
+// HIncrBy adds delta to the decimal integer stored in field, which starts at
+// 0 when missing, and returns the result.
+func (s *CacheStore) HIncrBy(key, field string, delta int64, exp time.Duration) (int64, error) {
+	return hashIncrBy(s, key, field, delta, exp)
+}
+
+// HIncrByFloat adds delta to the decimal number stored in field, which starts
+// at 0 when missing, and returns the result.
+func (s *CacheStore) HIncrByFloat(key, field string, delta float64, exp time.Duration) (float64, error) {
+	return hashIncrByFloat(s, key, field, delta, exp)
+}
+
+// updateHash decodes the hash at key, lets fn change its fields and stores
+// the result. fn returns errUnchanged when there is nothing to write.
+func updateHash(kv entryModifier, key string, exp time.Duration, fn func(fields map[string][]byte) error) error {
+	return updateData(kv, key, types.HASH, exp, func(data []byte) ([]byte, error) {
+		fields := make(map[string][]byte)
+		if data != nil {
+			var err error
+			if fields, err = utils.DecodeHash(data); err != nil {
+				return nil, err
+			}
+		}
+		if err := fn(fields); err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 {
+			return nil, nil
+		}
+		return utils.EncodeHash(fields), nil
+	})
+}
+
+func hashSet(kv entryModifier, key string, fields map[string][]byte, exp time.Duration) (int, error) {
+	if len(fields) == 0 {
+		return 0, errors.ErrFieldsEmpty
+	}
+	for _, value := range fields {
+		if value == nil {
+			return 0, errors.ErrValueNil
+		}
+	}
+	added := 0
+	err := updateHash(kv, key, exp, func(all map[string][]byte) error {
+		added = 0
+		for field, value := range fields {
+			if _, ok := all[field]; !ok {
+				added++
+			}
+			all[field] = slices.Clone(value)
+		}
+		return nil
+	})
+	return added, err
+}
+
+func hashDelete(kv entryModifier, key string, fields []string) (int, error) {
+	removed := 0
+	err := updateHash(kv, key, 0, func(all map[string][]byte) error {
+		removed = 0
+		for _, field := range fields {
+			if _, ok := all[field]; ok {
+				delete(all, field)
+				removed++
+			}
+		}
+		if removed == 0 {
+			return errUnchanged
+		}
+		return nil
+	})
+	return removed, err
+}
+
+func hashIncrBy(kv entryModifier, key, field string, delta int64, exp time.Duration) (int64, error) {
+	var result int64
+	err := updateHash(kv, key, exp, func(all map[string][]byte) error {
+		var value int64
+		if data, ok := all[field]; ok {
+			var err error
+			if value, err = strconv.ParseInt(string(data), 10, 64); err != nil {
+				return errors.ErrHashValueNotNumber(key, field)
+			}
+		}
+		if utils.Int64CheckOver(value, delta) {
+			return errors.ErrValueOverflow(key, types.INT64, value, delta)
+		}
+		result = value + delta
+		all[field] = strconv.AppendInt(nil, result, 10)
+		return nil
+	})
+	return result, err
+}
+
+func hashIncrByFloat(kv entryModifier, key, field string, delta float64, exp time.Duration) (float64, error) {
+	var result float64
+	err := updateHash(kv, key, exp, func(all map[string][]byte) error {
+		var value float64
+		if data, ok := all[field]; ok {
+			var err error
+			value, err = strconv.ParseFloat(string(data), 64)
+			if err != nil || utils.CheckFloat64Special(value) {
+				return errors.ErrHashValueNotNumber(key, field)
+			}
+		}
+		if utils.Float64CheckOver(value, delta) {
+			return errors.ErrValueOverflow(key, types.FLOAT64, value, delta)
+		}
+		result = value + delta
+		if utils.CheckFloat64Special(result) {
+			return errors.ErrFloatSpecial
+		}
+		all[field] = strconv.AppendFloat(nil, result, 'f', -1, 64)
+		return nil
+	})
+	return result, err
+}
@@ -0,0 +1,222 @@
+package store
+
+import (
+	goerrors "errors"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func newHashStore(t *testing.T) *CacheStore {
+	t.Helper()
+	store, err := NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestHash_Basic(t *testing.T) {
+	store := newHashStore(t)
+
+	added, err := store.HSet("user:1", map[string][]byte{"name": []byte("alice"), "age": []byte("30")}, 0)
+	if err != nil || added != 2 {
+		t.Fatalf("HSet() = %d, %v, want 2 new fields", added, err)
+	}
+	if added, _ := store.HSet("user:1", map[string][]byte{"name": []byte("bob"), "city": []byte("seoul")}, 0); added != 1 {
+		t.Errorf("HSet() of one new and one existing field = %d, want 1", added)
+	}
+	if v, err := store.HGet("user:1", "name"); err != nil || string(v) != "bob" {
+		t.Errorf("HGet() = %q, %v, want bob", v, err)
+	}
+	if _, err := store.HGet("user:1", "missing"); !goerrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("HGet() of a missing field error = %v, want ErrNotFound", err)
+	}
+	if _, err := store.HGet("nobody", "name"); !goerrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("HGet() of a missing key error = %v, want ErrNotFound", err)
+	}
+
+	values, err := store.HMGet("user:1", "city", "missing", "age")
+	if err != nil || string(values[0]) != "seoul" || values[1] != nil || string(values[2]) != "30" {
+		t.Errorf("HMGet() = %q, %v", values, err)
+	}
+	if values, err := store.HMGet("nobody", "a", "b"); err != nil || len(values) != 2 || values[0] != nil {
+		t.Errorf("HMGet() of a missing key = %q, %v, want nils", values, err)
+	}
+
+	all, err := store.HGetAll("user:1")
+	if err != nil || len(all) != 3 || string(all["city"]) != "seoul" {
+		t.Errorf("HGetAll() = %q, %v", all, err)
+	}
+	if all, err := store.HGetAll("nobody"); err != nil || all == nil || len(all) != 0 {
+		t.Errorf("HGetAll() of a missing key = %v, %v, want an empty map", all, err)
+	}
+	if n, err := store.HLen("user:1"); err != nil || n != 3 {
+		t.Errorf("HLen() = %d, %v, want 3", n, err)
+	}
+	if ok, err := store.HExists("user:1", "age"); err != nil || !ok {
+		t.Errorf("HExists(age) = %v, %v, want true", ok, err)
+	}
+	if ok, err := store.HExists("user:1", "zip"); err != nil || ok {
+		t.Errorf("HExists(zip) = %v, %v, want false", ok, err)
+	}
+
+	if removed, err := store.HDel("user:1", "age", "zip"); err != nil || removed != 1 {
+		t.Errorf("HDel() = %d, %v, want 1", removed, err)
+	}
+	if removed, err := store.HDel("user:1", "name", "city"); err != nil || removed != 2 {
+		t.Errorf("HDel() = %d, %v, want 2", removed, err)
+	}
+	if store.Exists("user:1") != 0 {
+		t.Error("deleting the last field should delete the key")
+	}
+	if removed, err := store.HDel("nobody", "a"); err != nil || removed != 0 {
+		t.Errorf("HDel() of a missing key = %d, %v, want 0", removed, err)
+	}
+}
+
+func TestHash_Expiry(t *testing.T) {
+	store := newHashStore(t)
+
+	store.HSet("h", map[string][]byte{"a": []byte("1")}, time.Hour)
+	store.HSet("h", map[string][]byte{"b": []byte("2")}, 0)
+	if ttl := store.TTL("h"); ttl <= 0 || ttl > time.Hour {
+		t.Errorf("TTL() after HSet without expiry = %v, want the original TTL", ttl)
+	}
+	store.HIncrBy("h", "n", 1, time.Minute)
+	if ttl := store.TTL("h"); ttl > time.Minute {
+		t.Errorf("TTL() after HIncrBy with expiry = %v, want at most a minute", ttl)
+	}
+}
+
+func TestHash_Incr(t *testing.T) {
+	store := newHashStore(t)
+
+	if n, err := store.HIncrBy("h", "n", 5, 0); err != nil || n != 5 {
+		t.Errorf("HIncrBy() of a missing field = %d, %v, want 5", n, err)
+	}
+	if n, err := store.HIncrBy("h", "n", -8, 0); err != nil || n != -3 {
+		t.Errorf("HIncrBy() = %d, %v, want -3", n, err)
+	}
+	if v, _ := store.HGet("h", "n"); string(v) != "-3" {
+		t.Errorf("HIncrBy() stored %q, want the decimal text -3", v)
+	}
+
+	store.HSet("h", map[string][]byte{"max": []byte("9223372036854775807"), "name": []byte("x")}, 0)
+	if _, err := store.HIncrBy("h", "max", 1, 0); !goerrors.Is(err, errors.ErrOutOfRange) {
+		t.Errorf("HIncrBy() overflow error = %v, want ErrOutOfRange", err)
+	}
+	if _, err := store.HIncrBy("h", "name", 1, 0); err == nil {
+		t.Error("HIncrBy() of a non-numeric field should fail")
+	}
+
+	if f, err := store.HIncrByFloat("h", "f", 1.5, 0); err != nil || f != 1.5 {
+		t.Errorf("HIncrByFloat() = %v, %v, want 1.5", f, err)
+	}
+	if f, err := store.HIncrByFloat("h", "n", 0.5, 0); err != nil || f != -2.5 {
+		t.Errorf("HIncrByFloat() of an integer field = %v, %v, want -2.5", f, err)
+	}
+	if _, err := store.HIncrByFloat("h", "f", math.Inf(1), 0); !goerrors.Is(err, errors.ErrOutOfRange) {
+		t.Errorf("HIncrByFloat(+Inf) error = %v, want ErrOutOfRange", err)
+	}
+	if v, _ := store.HGet("h", "f"); string(v) != "1.5" {
+		t.Errorf("failed HIncrByFloat() changed the field to %q", v)
+	}
+}
+
+func TestHash_Errors(t *testing.T) {
+	store := newHashStore(t)
+	store.SetString("s", "v", 0)
+
+	if _, err := store.HGet("s", "f"); !goerrors.Is(err, errors.ErrWrongType) {
+		t.Errorf("HGet() on a string error = %v, want ErrWrongType", err)
+	}
+	if _, err := store.HSet("s", map[string][]byte{"f": []byte("v")}, 0); !goerrors.Is(err, errors.ErrWrongType) {
+		t.Errorf("HSet() on a string error = %v, want ErrWrongType", err)
+	}
+	if _, err := store.HSet("h", nil, 0); err != errors.ErrFieldsEmpty {
+		t.Errorf("HSet() without fields error = %v, want ErrFieldsEmpty", err)
+	}
+	if _, err := store.HSet("h", map[string][]byte{"f": nil}, 0); err != errors.ErrValueNil {
+		t.Errorf("HSet() with a nil value error = %v, want ErrValueNil", err)
+	}
+	if _, err := store.HSet("", map[string][]byte{"f": []byte("v")}, 0); err != errors.ErrKeyEmpty {
+		t.Errorf("HSet() with an empty key error = %v, want ErrKeyEmpty", err)
+	}
+	if store.Exists("h") != 0 {
+		t.Error("failed HSet() created the key")
+	}
+}
+
+func TestHash_Transaction(t *testing.T) {
+	store := newHashStore(t)
+	store.HSet("h", map[string][]byte{"a": []byte("1")}, 0)
+
+	err := store.Update(func(tx *Tx) error {
+		if _, err := tx.HSet("h", map[string][]byte{"b": []byte("2")}, 0); err != nil {
+			return err
+		}
+		if _, err := tx.HIncrBy("h", "a", 10, 0); err != nil {
+			return err
+		}
+		_, err := tx.HDel("h", "b")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	all, _ := store.HGetAll("h")
+	if len(all) != 1 || string(all["a"]) != "11" {
+		t.Errorf("HGetAll() after the transaction = %q", all)
+	}
+
+	rollback := goerrors.New("rollback")
+	store.Update(func(tx *Tx) error {
+		tx.HSet("h", map[string][]byte{"c": []byte("3")}, 0)
+		return rollback
+	})
+	if ok, _ := store.HExists("h", "c"); ok {
+		t.Error("a rolled back HSet() was applied")
+	}
+}
+
+func TestHash_Persistence(t *testing.T) {
+	dbFile := tempDBFile(t)
+	cfg := config.Config{
+		DBSave:              true,
+		DBFileName:          dbFile,
+		SaveDirtyData:       true,
+		DirtyThresholdCount: 100,
+		DirtyThresholdRatio: 0.5,
+	}
+	store, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	store.HSet("profile", map[string][]byte{"name": []byte("alice"), "bin": {0, 255}}, 0)
+	store.HIncrBy("profile", "visits", 3, 0)
+	if dirty, _ := store.dirty.keys(); !slices.Equal(dirty, []string{"profile"}) {
+		t.Errorf("dirty keys = %v, want the hash key", dirty)
+	}
+	store.Sync()
+	store.Close()
+
+	store2, err := NewCacheStore(config.Config{DBSave: true, DBFileName: dbFile})
+	if err != nil {
+		t.Fatalf("Failed to re-create store: %v", err)
+	}
+	defer store2.Close()
+	all, err := store2.HGetAll("profile")
+	if err != nil || string(all["name"]) != "alice" || string(all["bin"]) != string([]byte{0, 255}) || string(all["visits"]) != "3" {
+		t.Errorf("HGetAll() after restart = %q, %v", all, err)
+	}
+	if dataType, _, _ := store2.Get("profile"); dataType != types.HASH {
+		t.Errorf("type after restart = %v, want Hash", dataType)
+	}
+}
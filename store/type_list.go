@@ -0,0 +1,514 @@
+package store
+
+import (
+	"context"
+	goerrors "errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// A LIST is a sequence of values, see collection.go for the rules shared by
+// collections. The store keeps every list live, in the deque of list.go, so
+// pushes and pops at either end take O(1) and reads by index do not decode
+// anything. The append-only log and the followers receive the changes, like
+// for sorted sets, and the value is only encoded, see utils.EncodeList, when
+// it is read as bytes, persisted or sent in a snapshot.
+//
+// Indexes count from 0 at the head, or from -1 at the tail when negative.
+
+// listWaiters wakes BLPop callers when a list they wait on is written.
+type listWaiters struct {
+	mux    sync.Mutex
+	active atomic.Int32
+	closed bool
+	wg     sync.WaitGroup
+	byKey  map[string]map[chan struct{}]struct{}
+}
+
+// add registers a waiter on keys. It returns false once the store is closed.
+func (w *listWaiters) add(keys []string) (chan struct{}, bool) {
+	ch := make(chan struct{}, 1)
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.closed {
+		return nil, false
+	}
+	if w.byKey == nil {
+		w.byKey = make(map[string]map[chan struct{}]struct{})
+	}
+	for _, key := range keys {
+		if w.byKey[key] == nil {
+			w.byKey[key] = make(map[chan struct{}]struct{})
+		}
+		w.byKey[key][ch] = struct{}{}
+	}
+	w.active.Add(1)
+	w.wg.Add(1)
+	return ch, true
+}
+
+func (w *listWaiters) remove(keys []string, ch chan struct{}) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	for _, key := range keys {
+		delete(w.byKey[key], ch)
+		if len(w.byKey[key]) == 0 {
+			delete(w.byKey, key)
+		}
+	}
+	w.active.Add(-1)
+	w.wg.Done()
+}
+
+// close refuses new waiters and waits for the current ones to return, so
+// that none of them touches the store while Close tears it down.
+func (w *listWaiters) close() {
+	w.mux.Lock()
+	w.closed = true
+	w.mux.Unlock()
+	w.wg.Wait()
+}
+
+// notify wakes the waiters of the lists written by ops. It is called by
+// logOps, which sees every write, and never blocks.
+func (w *listWaiters) notify(ops []op) {
+	if w.active.Load() == 0 {
+		return
+	}
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	for _, o := range ops {
+		pushed := o.kind == opList && len(o.list.lpush)+len(o.list.rpush) > 0
+		if !pushed && (o.kind != opSet || o.entry.Type != types.LIST) {
+			continue
+		}
+		for ch := range w.byKey[o.key] {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// viewList calls fn with the live list at key, nil when missing, while its
+// shard is locked for reading.
+func (s *CacheStore) viewList(key string, fn func(l *list) error) error {
+	if key == "" {
+		return errors.ErrKeyEmpty
+	}
+	sh := s.shardFor(key)
+	sh.mux.RLock()
+	defer sh.mux.RUnlock()
+	l, err := sh.unsafeList(key)
+	if err != nil {
+		return err
+	}
+	return fn(l)
+}
+
+// unsafeList returns the live list at key, or nil when key is missing.
+func (sh *shard) unsafeList(key string) (*list, error) {
+	e, err := sh.unsafeLookup(key)
+	if err != nil {
+		if goerrors.Is(err, errors.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if e.Type != types.LIST {
+		return nil, errors.ErrTypeMismatch(key, types.LIST, e.Type)
+	}
+	l, ok := sh.lists[key]
+	if !ok {
+		// Only values that failed to decode are not live, see unsafePut.
+		return nil, errors.ErrCorruptValue
+	}
+	return l, nil
+}
+
+// LRange returns the elements from start to stop, both included. Out of range
+// indexes are clamped; a missing key is an empty list.
+func (s *CacheStore) LRange(key string, start, stop int) ([][]byte, error) {
+	elems := [][]byte{}
+	err := s.viewList(key, func(l *list) error {
+		if l == nil {
+			return nil
+		}
+		from, to := listRange(start, stop, l.len())
+		for _, elem := range l.slice(from, to) {
+			elems = append(elems, slices.Clone(elem))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return elems, nil
+}
+
+// LLen returns the number of elements of the list.
+func (s *CacheStore) LLen(key string) (int, error) {
+	n := 0
+	err := s.viewList(key, func(l *list) error {
+		if l != nil {
+			n = l.len()
+		}
+		return nil
+	})
+	return n, err
+}
+
+// LIndex returns the element at index, or an error wrapping ErrNotFound when
+// the key does not exist or index is out of range.
+func (s *CacheStore) LIndex(key string, index int) ([]byte, error) {
+	var elem []byte
+	err := s.viewList(key, func(l *list) error {
+		if l == nil {
+			return errors.ErrNoDataForKey(key)
+		}
+		i, ok := listIndex(index, l.len())
+		if !ok {
+			return errors.ErrNoDataForIndex(key, index)
+		}
+		elem = slices.Clone(l.at(i))
+		return nil
+	})
+	return elem, err
+}
+
+// LPush inserts values at the head of the list, one after the other, so the
+// last value ends up first. It returns the new length.
+func (s *CacheStore) LPush(key string, values [][]byte, exp time.Duration) (int, error) {
+	return listPush(s, key, values, exp, true)
+}
+
+// RPush appends values at the tail of the list and returns the new length.
+func (s *CacheStore) RPush(key string, values [][]byte, exp time.Duration) (int, error) {
+	return listPush(s, key, values, exp, false)
+}
+
+// LPop removes and returns the first element, or an error wrapping
+// ErrNotFound when the list is empty.
+func (s *CacheStore) LPop(key string) ([]byte, error) {
+	return listPop(s, key, true)
+}
+
+// RPop removes and returns the last element, or an error wrapping
+// ErrNotFound when the list is empty.
+func (s *CacheStore) RPop(key string) ([]byte, error) {
+	return listPop(s, key, false)
+}
+
+// LTrim keeps the elements from start to stop, both included, and deletes
+// the key when none are left.
+func (s *CacheStore) LTrim(key string, start, stop int) error {
+	return listTrim(s, key, start, stop)
+}
+
+// LSet replaces the element at index. It fails with an error wrapping
+// ErrOutOfRange when index is out of range.
+func (s *CacheStore) LSet(key string, index int, value []byte) error {
+	return listSet(s, key, index, value)
+}
+
+// BLPop pops the first element of the first non-empty list of keys, waiting
+// until one is pushed when all are empty. It returns the key and the element,
+// ctx.Err() when ctx ends first and ErrStoreClosed when the store is closed.
+func (s *CacheStore) BLPop(ctx context.Context, keys ...string) (string, []byte, error) {
+	if len(keys) == 0 {
+		return "", nil, errors.ErrKeyEmpty
+	}
+	// Register before looking, so a push between the two is not missed.
+	wake, ok := s.lists.add(keys)
+	if !ok {
+		return "", nil, errors.ErrStoreClosed
+	}
+	defer s.lists.remove(keys, wake)
+	for {
+		if s.IsClosed() {
+			return "", nil, errors.ErrStoreClosed
+		}
+		for _, key := range keys {
+			elem, err := s.LPop(key)
+			if err == nil {
+				return key, elem, nil
+			}
+			if !goerrors.Is(err, errors.ErrNotFound) {
+				return "", nil, err
+			}
+		}
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return "", nil, ctx.Err()
+		case <-s.done:
+			return "", nil, errors.ErrStoreClosed
+		}
+	}
+}
+
+// listUpdate chooses a change to a list, or returns errUnchanged when there
+// is none.
+type listUpdate func(l *list) (listChange, error)
+
+// listModifier is the target of list writes: the store, which changes its
+// live lists in place, or a transaction.
+type listModifier interface {
+	// updateList applies the change fn chooses to the list at key, empty
+	// when missing. Removing the last element deletes key.
+	updateList(key string, exp time.Duration, fn listUpdate) error
+}
+
+func (s *CacheStore) updateList(key string, exp time.Duration, fn listUpdate) error {
+	if key == "" {
+		return errors.ErrKeyEmpty
+	}
+	if s.readOnly.Load() {
+		return errors.ErrReadOnly
+	}
+	sh := s.shardFor(key)
+	sh.mux.Lock()
+	defer sh.mux.Unlock()
+	l, err := sh.unsafeList(key)
+	if err != nil {
+		return err
+	}
+	exists := l != nil
+	if !exists {
+		l = newList()
+	}
+	c, err := fn(l)
+	if err == errUnchanged {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	undo := l.apply(c)
+	if l.len() == 0 {
+		if exists {
+			s.unsafeDelete(sh, key)
+		}
+		return nil
+	}
+	e := entry.NewEntry(types.LIST, nil, exp)
+	if exists {
+		e = keepExp(sh.memorydb[key], types.LIST, nil, exp)
+	}
+	e.Version = s.nextVersion()
+	evicted, err := sh.unsafePutList(key, e, l)
+	if err != nil {
+		undo()
+		return s.unsafePublish(op{key: key}, evicted, err)
+	}
+	if !exists {
+		// A new list is published whole, like a new sorted set.
+		e.Data = l.encode()
+		return s.unsafePublish(op{kind: opSet, key: key, entry: e}, evicted, nil)
+	}
+	return s.unsafePublish(op{kind: opList, key: key, entry: e, list: c}, evicted, nil)
+}
+
+// unsafeApplyList applies an opList to the live list at its key, starting
+// from an empty list when there is none. It reports whether the list was
+// stored; it is removed instead when expired or empty.
+func (sh *shard) unsafeApplyList(o op, now int64) (evicted []string, stored bool, err error) {
+	if o.entry.IsExpiredWithUnixMilli(now) {
+		sh.unsafeRemove(o.key)
+		return nil, false, nil
+	}
+	l, ok := sh.lists[o.key]
+	if !ok {
+		l = newList()
+	}
+	if o.list.value != nil && o.list.index >= l.len() {
+		// The replaced element is gone; the follower diverged.
+		o.list.value = nil
+	}
+	undo := l.apply(o.list)
+	if l.len() == 0 {
+		sh.unsafeRemove(o.key)
+		return nil, false, nil
+	}
+	if evicted, err = sh.unsafePutList(o.key, o.entry, l); err != nil {
+		undo()
+		return evicted, false, err
+	}
+	return evicted, true, nil
+}
+
+// updateList applies the change to the live list at key, staging it in tx
+// until the commit.
+func (tx *Tx) updateList(key string, exp time.Duration, fn listUpdate) error {
+	if key == "" {
+		return errors.ErrKeyEmpty
+	}
+	if err := tx.checkWrite(key); err != nil {
+		return err
+	}
+	old, l, err := tx.list(key)
+	if err != nil {
+		return err
+	}
+	exists := l != nil
+	if !exists {
+		l = newList()
+	}
+	c, err := fn(l)
+	if err == errUnchanged {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tx.undo = append(tx.undo, l.apply(c))
+	if l.len() == 0 {
+		if exists {
+			return tx.putEntry(key, nil)
+		}
+		return nil
+	}
+	e := entry.NewEntry(types.LIST, nil, exp)
+	if exists {
+		e = keepExp(old, types.LIST, nil, exp)
+	}
+	if err := tx.putEntry(key, &e); err != nil {
+		return err
+	}
+	tx.lists[key] = l
+	return nil
+}
+
+// list returns the entry and the live form of the list at key as tx sees it,
+// or a nil list when key is missing. Lists written whole by tx are decoded.
+func (tx *Tx) list(key string) (entry.Entry, *list, error) {
+	if l, ok := tx.lists[key]; ok && !tx.writes[key].IsExpired() {
+		return *tx.writes[key], l, nil
+	}
+	sh := tx.store.shardFor(key)
+	_, written := tx.writes[key]
+	var e entry.Entry
+	var err error
+	if written {
+		e, err = tx.getEntry(key)
+	} else {
+		e, err = sh.unsafeLookup(key)
+	}
+	if err != nil {
+		if goerrors.Is(err, errors.ErrNotFound) {
+			return entry.Entry{}, nil, nil
+		}
+		return entry.Entry{}, nil, err
+	}
+	if e.Type != types.LIST {
+		return entry.Entry{}, nil, errors.ErrTypeMismatch(key, types.LIST, e.Type)
+	}
+	if written {
+		l, err := decodeList(e.Data)
+		return e, l, err
+	}
+	l, ok := sh.lists[key]
+	if !ok {
+		return entry.Entry{}, nil, errors.ErrCorruptValue
+	}
+	return e, l, nil
+}
+
+func listPush(kv listModifier, key string, values [][]byte, exp time.Duration, head bool) (int, error) {
+	if len(values) == 0 {
+		return 0, errors.ErrValueNil
+	}
+	for _, value := range values {
+		if value == nil {
+			return 0, errors.ErrValueNil
+		}
+	}
+	n := 0
+	err := kv.updateList(key, exp, func(l *list) (listChange, error) {
+		pushed := make([][]byte, len(values))
+		for i, value := range values {
+			pushed[i] = slices.Clone(value)
+		}
+		n = l.len() + len(pushed)
+		if head {
+			return listChange{lpush: pushed}, nil
+		}
+		return listChange{rpush: pushed}, nil
+	})
+	return n, err
+}
+
+func listPop(kv listModifier, key string, head bool) ([]byte, error) {
+	var elem []byte
+	err := kv.updateList(key, 0, func(l *list) (listChange, error) {
+		if l.len() == 0 {
+			return listChange{}, errors.ErrNoDataForKey(key)
+		}
+		if head {
+			elem = slices.Clone(l.at(0))
+			return listChange{lpop: 1}, nil
+		}
+		elem = slices.Clone(l.at(l.len() - 1))
+		return listChange{rpop: 1}, nil
+	})
+	return elem, err
+}
+
+func listTrim(kv listModifier, key string, start, stop int) error {
+	return kv.updateList(key, 0, func(l *list) (listChange, error) {
+		from, to := listRange(start, stop, l.len())
+		if from == 0 && to == l.len() {
+			return listChange{}, errUnchanged
+		}
+		return listChange{lpop: from, rpop: l.len() - to}, nil
+	})
+}
+
+func listSet(kv listModifier, key string, index int, value []byte) error {
+	if value == nil {
+		return errors.ErrValueNil
+	}
+	return kv.updateList(key, 0, func(l *list) (listChange, error) {
+		if l.len() == 0 {
+			return listChange{}, errors.ErrNoDataForKey(key)
+		}
+		i, ok := listIndex(index, l.len())
+		if !ok {
+			return listChange{}, errors.ErrListIndex(key, index)
+		}
+		return listChange{index: i, value: slices.Clone(value)}, nil
+	})
+}
+
+// listIndex converts index into a position in a list of n elements.
+func listIndex(index, n int) (int, bool) {
+	if index < 0 {
+		index += n
+	}
+	return index, index >= 0 && index < n
+}
+
+// listRange converts the inclusive range from start to stop into slice
+// bounds of a list of n elements, clamping out of range indexes.
+func listRange(start, stop, n int) (int, int) {
+	if start < 0 {
+		start = max(start+n, 0)
+	}
+	if stop < 0 {
+		stop += n
+	}
+	stop = min(stop, n-1)
+	if start > stop {
+		return 0, 0
+	}
+	return start, stop + 1
+}
@@ -0,0 +1,444 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	goerrors "errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func newListStore(t *testing.T) *CacheStore {
+	t.Helper()
+	store, err := NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func values(elems ...string) [][]byte {
+	out := make([][]byte, len(elems))
+	for i, elem := range elems {
+		out[i] = []byte(elem)
+	}
+	return out
+}
+
+func expectRange(t *testing.T, store *CacheStore, key string, start, stop int, want ...string) {
+	t.Helper()
+	elems, err := store.LRange(key, start, stop)
+	if err != nil {
+		t.Fatalf("LRange(%d, %d) error = %v", start, stop, err)
+	}
+	if !slices.EqualFunc(elems, values(want...), bytes.Equal) {
+		t.Errorf("LRange(%d, %d) = %q, want %q", start, stop, elems, want)
+	}
+}
+
+func TestList_PushPop(t *testing.T) {
+	store := newListStore(t)
+
+	if n, err := store.RPush("q", values("b", "c"), 0); err != nil || n != 2 {
+		t.Fatalf("RPush() = %d, %v, want 2", n, err)
+	}
+	if n, err := store.LPush("q", values("a", "z"), 0); err != nil || n != 4 {
+		t.Fatalf("LPush() = %d, %v, want 4", n, err)
+	}
+	expectRange(t, store, "q", 0, -1, "z", "a", "b", "c")
+	if n, err := store.LLen("q"); err != nil || n != 4 {
+		t.Errorf("LLen() = %d, %v, want 4", n, err)
+	}
+
+	if v, err := store.LPop("q"); err != nil || string(v) != "z" {
+		t.Errorf("LPop() = %q, %v, want z", v, err)
+	}
+	if v, err := store.RPop("q"); err != nil || string(v) != "c" {
+		t.Errorf("RPop() = %q, %v, want c", v, err)
+	}
+	store.LPop("q")
+	store.LPop("q")
+	if store.Exists("q") != 0 {
+		t.Error("popping the last element should delete the key")
+	}
+	if _, err := store.LPop("q"); !goerrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("LPop() of an empty list error = %v, want ErrNotFound", err)
+	}
+	if n, err := store.LLen("q"); err != nil || n != 0 {
+		t.Errorf("LLen() of a missing key = %d, %v, want 0", n, err)
+	}
+}
+
+func TestList_Index(t *testing.T) {
+	store := newListStore(t)
+	store.RPush("l", values("a", "b", "c", "d", "e"), 0)
+
+	expectRange(t, store, "l", 1, 2, "b", "c")
+	expectRange(t, store, "l", -2, -1, "d", "e")
+	expectRange(t, store, "l", -100, 100, "a", "b", "c", "d", "e")
+	expectRange(t, store, "l", 3, 1)
+	expectRange(t, store, "l", 5, 10)
+	expectRange(t, store, "missing", 0, -1)
+
+	if v, err := store.LIndex("l", -1); err != nil || string(v) != "e" {
+		t.Errorf("LIndex(-1) = %q, %v, want e", v, err)
+	}
+	if _, err := store.LIndex("l", 5); !goerrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("LIndex() out of range error = %v, want ErrNotFound", err)
+	}
+	if _, err := store.LIndex("missing", 0); !goerrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("LIndex() of a missing key error = %v, want ErrNotFound", err)
+	}
+
+	if err := store.LSet("l", -2, []byte("D")); err != nil {
+		t.Errorf("LSet() error = %v", err)
+	}
+	if err := store.LSet("l", 5, []byte("x")); !goerrors.Is(err, errors.ErrOutOfRange) {
+		t.Errorf("LSet() out of range error = %v, want ErrOutOfRange", err)
+	}
+	if err := store.LSet("missing", 0, []byte("x")); !goerrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("LSet() of a missing key error = %v, want ErrNotFound", err)
+	}
+
+	if err := store.LTrim("l", 1, -2); err != nil {
+		t.Errorf("LTrim() error = %v", err)
+	}
+	expectRange(t, store, "l", 0, -1, "b", "c", "D")
+	if err := store.LTrim("l", 5, 10); err != nil {
+		t.Errorf("LTrim() error = %v", err)
+	}
+	if store.Exists("l") != 0 {
+		t.Error("trimming every element should delete the key")
+	}
+	if err := store.LTrim("missing", 0, 1); err != nil {
+		t.Errorf("LTrim() of a missing key error = %v", err)
+	}
+}
+
+func TestList_Errors(t *testing.T) {
+	store := newListStore(t)
+	store.SetString("s", "v", 0)
+
+	if _, err := store.RPush("s", values("a"), 0); !goerrors.Is(err, errors.ErrWrongType) {
+		t.Errorf("RPush() on a string error = %v, want ErrWrongType", err)
+	}
+	if _, err := store.LRange("s", 0, -1); !goerrors.Is(err, errors.ErrWrongType) {
+		t.Errorf("LRange() on a string error = %v, want ErrWrongType", err)
+	}
+	if _, err := store.RPush("l", nil, 0); err != errors.ErrValueNil {
+		t.Errorf("RPush() without values error = %v, want ErrValueNil", err)
+	}
+	if _, err := store.LPush("l", [][]byte{[]byte("a"), nil}, 0); err != errors.ErrValueNil {
+		t.Errorf("LPush() with a nil value error = %v, want ErrValueNil", err)
+	}
+	if _, err := store.RPush("", values("a"), 0); err != errors.ErrKeyEmpty {
+		t.Errorf("RPush() with an empty key error = %v, want ErrKeyEmpty", err)
+	}
+	if _, _, err := store.BLPop(context.Background()); err != errors.ErrKeyEmpty {
+		t.Errorf("BLPop() without keys error = %v, want ErrKeyEmpty", err)
+	}
+	if store.Exists("l") != 0 {
+		t.Error("failed pushes created the key")
+	}
+}
+
+func TestList_Expiry(t *testing.T) {
+	store := newListStore(t)
+
+	store.RPush("l", values("a"), time.Hour)
+	store.RPush("l", values("b"), 0)
+	store.LPop("l")
+	if ttl := store.TTL("l"); ttl <= 0 || ttl > time.Hour {
+		t.Errorf("TTL() after writes without expiry = %v, want the original TTL", ttl)
+	}
+}
+
+func TestList_Transaction(t *testing.T) {
+	store := newListStore(t)
+	store.RPush("q", values("a", "b"), 0)
+
+	err := store.Update(func(tx *Tx) error {
+		if _, err := tx.RPush("q", values("c"), 0); err != nil {
+			return err
+		}
+		if _, err := tx.LPop("q"); err != nil {
+			return err
+		}
+		if err := tx.LSet("q", 0, []byte("B")); err != nil {
+			return err
+		}
+		_, err := tx.LPush("done", values("a"), 0)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	expectRange(t, store, "q", 0, -1, "B", "c")
+	expectRange(t, store, "done", 0, -1, "a")
+
+	rollback := goerrors.New("rollback")
+	store.Update(func(tx *Tx) error {
+		tx.RPop("q")
+		tx.LTrim("done", 1, 0)
+		return rollback
+	})
+	expectRange(t, store, "q", 0, -1, "B", "c")
+	expectRange(t, store, "done", 0, -1, "a")
+}
+
+func TestList_BLPop(t *testing.T) {
+	store := newListStore(t)
+	store.RPush("b", values("ready"), 0)
+
+	// An element that is already there is returned right away.
+	if key, v, err := store.BLPop(context.Background(), "a", "b"); err != nil || key != "b" || string(v) != "ready" {
+		t.Errorf("BLPop() = %q, %q, %v, want b ready", key, v, err)
+	}
+
+	type popped struct {
+		key  string
+		elem []byte
+		err  error
+	}
+	result := make(chan popped, 1)
+	go func() {
+		key, elem, err := store.BLPop(context.Background(), "a", "b")
+		result <- popped{key, elem, err}
+	}()
+	time.Sleep(20 * time.Millisecond)
+	store.SetString("other", "v", 0)
+	store.RPush("b", values("job"), 0)
+	select {
+	case r := <-result:
+		if r.err != nil || r.key != "b" || string(r.elem) != "job" {
+			t.Errorf("BLPop() = %q, %q, %v, want b job", r.key, r.elem, r.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("BLPop() was not woken by RPush()")
+	}
+	if store.Exists("b") != 0 {
+		t.Error("BLPop() left the popped element in the list")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := store.BLPop(ctx, "a"); err != context.DeadlineExceeded {
+		t.Errorf("BLPop() after the deadline error = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestList_BLPopConcurrent(t *testing.T) {
+	store := newListStore(t)
+	const workers, jobs = 4, 100
+
+	got := make(chan string, jobs)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for range workers {
+		go func() {
+			for {
+				_, elem, err := store.BLPop(ctx, "jobs")
+				if err != nil {
+					return
+				}
+				got <- string(elem)
+			}
+		}()
+	}
+	for i := range jobs {
+		store.RPush("jobs", values(fmt.Sprint(i)), 0)
+	}
+
+	seen := make(map[string]bool)
+	for range jobs {
+		select {
+		case elem := <-got:
+			if seen[elem] {
+				t.Fatalf("job %s was popped twice", elem)
+			}
+			seen[elem] = true
+		case <-time.After(time.Second):
+			t.Fatalf("only %d of %d jobs were popped", len(seen), jobs)
+		}
+	}
+}
+
+func TestList_BLPopClose(t *testing.T) {
+	store, err := NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	result := make(chan error, 1)
+	go func() {
+		_, _, err := store.BLPop(context.Background(), "q")
+		result <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	store.Close()
+	select {
+	case err := <-result:
+		if err != errors.ErrStoreClosed {
+			t.Errorf("BLPop() error = %v, want ErrStoreClosed", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("BLPop() did not return when the store closed")
+	}
+}
+
+func TestList_Persistence(t *testing.T) {
+	dbFile := tempDBFile(t)
+	cfg := config.Config{DBSave: true, DBFileName: dbFile}
+	store, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	store.RPush("queue", values("job1", "job2", "job3"), 0)
+	store.LPop("queue")
+	store.Sync()
+	store.Close()
+
+	store2, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to re-create store: %v", err)
+	}
+	defer store2.Close()
+	expectRange(t, store2, "queue", 0, -1, "job2", "job3")
+	if dataType, _, _ := store2.Get("queue"); dataType != types.LIST {
+		t.Errorf("type after restart = %v, want List", dataType)
+	}
+	if _, elem, err := store2.BLPop(context.Background(), "queue"); err != nil || string(elem) != "job2" {
+		t.Errorf("BLPop() after restart = %q, %v, want job2", elem, err)
+	}
+}
+
+func TestList_Deque(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	l := newList()
+	var want [][]byte
+	for i := 0; i < 5000; i++ {
+		var c listChange
+		switch rng.IntN(4) {
+		case 0:
+			c.lpush = values(strings.Repeat("h", rng.IntN(200)), fmt.Sprint(i))
+		case 1:
+			c.rpush = values(fmt.Sprint(i))
+		case 2:
+			c.lpop, c.rpop = rng.IntN(3), rng.IntN(3)
+		case 3:
+			if len(want) > 0 {
+				c.index, c.value = rng.IntN(len(want)), []byte(fmt.Sprint("set", i))
+			}
+		}
+		before := slices.Clone(want)
+		if c.value != nil {
+			want[c.index] = c.value
+		}
+		want = want[min(c.lpop, len(want)):]
+		want = want[:len(want)-min(c.rpop, len(want))]
+		for _, elem := range c.lpush {
+			want = append([][]byte{elem}, want...)
+		}
+		want = append(want, c.rpush...)
+
+		undo := l.apply(c)
+		if got := l.slice(0, l.len()); !slices.EqualFunc(got, want, bytes.Equal) {
+			t.Fatalf("step %d: list = %q, want %q", i, got, want)
+		}
+		if rng.IntN(10) == 0 {
+			undo()
+			want = before
+			if got := l.slice(0, l.len()); !slices.EqualFunc(got, want, bytes.Equal) {
+				t.Fatalf("step %d: list after undo = %q, want %q", i, got, want)
+			}
+		}
+	}
+	if data := l.encode(); l.size() != len(data) || !bytes.Equal(data, utils.EncodeList(want)) {
+		t.Errorf("encode() does not match the reference, size %d for %d bytes", l.size(), len(data))
+	}
+}
+
+func TestList_LogsChanges(t *testing.T) {
+	cfg := aofConfig(t)
+	store, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	elems := make([]string, 1000)
+	for i := range elems {
+		elems[i] = fmt.Sprint("job:", i)
+	}
+	store.RPush("queue", values(elems...), 0)
+	live := store.shardFor("queue").lists["queue"]
+	full := int64(live.size())
+	before := store.aof.mark().offset
+	store.LPush("queue", values("urgent"), time.Hour)
+	store.RPop("queue")
+	if size := store.aof.mark().offset - before; 10*size > full {
+		t.Errorf("LPush() and RPop() logged %d bytes for a %d bytes list, want only the changed elements", size, full)
+	}
+	if store.shardFor("queue").lists["queue"] != live {
+		t.Error("a push replaced the live list")
+	}
+	store.LTrim("queue", 1, 500)
+	store.LSet("queue", -1, []byte("last"))
+	store.RPush("gone", values("a"), 0)
+	store.LPop("gone")
+	want, _ := store.LRange("queue", 0, -1)
+	_, _, version, _ := store.GetWithVersion("queue")
+	crash(store)
+
+	store, err = NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to re-create store: %v", err)
+	}
+	defer store.Close()
+	if got, err := store.LRange("queue", 0, -1); err != nil || !slices.EqualFunc(got, want, bytes.Equal) || len(got) != 500 {
+		t.Errorf("LRange() after replay = %d elements, %v, want %d", len(got), err, len(want))
+	}
+	if elem, _ := store.LIndex("queue", -1); string(elem) != "last" {
+		t.Errorf("LIndex(-1) after replay = %q, want last", elem)
+	}
+	if _, _, v, _ := store.GetWithVersion("queue"); v != version {
+		t.Errorf("version after replay = %d, want %d", v, version)
+	}
+	if ttl := store.TTL("queue"); ttl <= 0 || ttl > time.Hour {
+		t.Errorf("TTL() after replay = %v, want the logged expiry", ttl)
+	}
+	if store.Exists("gone") != 0 {
+		t.Error("a list emptied before the crash came back")
+	}
+}
+
+func TestList_Replication(t *testing.T) {
+	primary := newReplStore(t, config.Config{DBSave: false})
+	follower := newReplStore(t, config.Config{DBSave: false})
+	primary.RPush("q", values("a", "b", "c"), 0)
+
+	l := link(t, primary, follower)
+	l.handshake(t)
+	eventually(t, primary, follower)
+
+	primary.LPush("q", values("x", "y"), time.Hour)
+	primary.RPop("q")
+	primary.LSet("q", 1, []byte("X"))
+	primary.LTrim("q", 0, 2)
+	primary.RPush("other", values("1"), 0)
+	eventually(t, primary, follower)
+	expectRange(t, follower, "q", 0, -1, "y", "X", "a")
+	if ttl := follower.TTL("q"); ttl <= 0 {
+		t.Errorf("follower TTL() = %v, want the primary's expiry", ttl)
+	}
+
+	primary.LPop("other")
+	eventually(t, primary, follower)
+}
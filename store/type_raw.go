@@ -11,9 +11,10 @@ func (s *CacheStore) GetRaw(key string) ([]byte, error) {
 	if key == "" {
 		return nil, errors.ErrKeyEmpty
 	}
-	s.mux.RLock()
-	defer s.mux.RUnlock()
-	e, err := s.unsafeGet(key)
+	sh := s.shardFor(key)
+	sh.mux.RLock()
+	defer sh.mux.RUnlock()
+	e, err := sh.unsafeGet(key)
 	if err != nil {
 		return nil, err
 	}
@@ -27,13 +28,16 @@ func (s *CacheStore) GetRaw(key string) ([]byte, error) {
 	return result, nil
 }
 
+// GetRawNoCopy is GetRaw without the copy; see GetNoCopy. The value is only
+// valid until the next write to key, as SetBit modifies it in place.
 func (s *CacheStore) GetRawNoCopy(key string) ([]byte, error) {
 	if key == "" {
 		return nil, errors.ErrKeyEmpty
 	}
-	s.mux.RLock()
-	defer s.mux.RUnlock()
-	e, err := s.unsafeGet(key)
+	sh := s.shardFor(key)
+	sh.mux.RLock()
+	defer sh.mux.RUnlock()
+	e, err := sh.unsafeGet(key)
 	if err != nil {
 		return nil, err
 	}
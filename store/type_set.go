@@ -0,0 +1,347 @@
+package store
+
+import (
+	goerrors "errors"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// A SET is a collection of distinct members, see utils.EncodeSet for its
+// stored form and collection.go for the rules shared by collections. Members
+// are returned sorted.
+
+func (s *CacheStore) viewSet(key string, fn func(data []byte) error) error {
+	return s.viewData(key, types.SET, fn)
+}
+
+// SIsMember reports whether member belongs to the set.
+func (s *CacheStore) SIsMember(key, member string) (bool, error) {
+	found := false
+	err := s.viewSet(key, func(data []byte) error {
+		if data == nil {
+			return nil
+		}
+		var err error
+		found, err = utils.SetContains(data, member)
+		return err
+	})
+	return found, err
+}
+
+// SMembers returns the members of the set, or an empty slice when the key
+// does not exist.
+func (s *CacheStore) SMembers(key string) ([]string, error) {
+	members := []string{}
+	err := s.viewSet(key, func(data []byte) error {
+		if data == nil {
+			return nil
+		}
+		var err error
+		members, err = utils.DecodeSet(data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// SCard returns the number of members of the set.
+func (s *CacheStore) SCard(key string) (int, error) {
+	n := 0
+	err := s.viewSet(key, func(data []byte) error {
+		if data == nil {
+			return nil
+		}
+		var err error
+		n, err = utils.SetLen(data)
+		return err
+	})
+	return n, err
+}
+
+// SRandMember returns random members without removing them. A positive count
+// returns up to count distinct members, a negative one exactly -count
+// members that may repeat, as in Redis.
+func (s *CacheStore) SRandMember(key string, count int) ([]string, error) {
+	picked := []string{}
+	err := s.viewSet(key, func(data []byte) error {
+		if data == nil || count == 0 {
+			return nil
+		}
+		members, err := utils.DecodeSet(data)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			picked = randomMembers(members, count)
+			return nil
+		}
+		picked = make([]string, -count)
+		for i := range picked {
+			picked[i] = members[rand.IntN(len(members))]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return picked, nil
+}
+
+// SAdd adds members to the set and returns how many of them are new.
+func (s *CacheStore) SAdd(key string, members []string, exp time.Duration) (int, error) {
+	return setAdd(s, key, members, exp)
+}
+
+// SRem removes members from the set and returns how many of them existed.
+func (s *CacheStore) SRem(key string, members ...string) (int, error) {
+	return setRemove(s, key, members)
+}
+
+// SPop removes and returns up to count random members.
+func (s *CacheStore) SPop(key string, count int) ([]string, error) {
+	return setPop(s, key, count)
+}
+
+// SUnion returns the members of any of the sets at keys. Missing keys are
+// empty sets.
+func (s *CacheStore) SUnion(keys ...string) ([]string, error) {
+	return s.combineSets(keys, setUnion)
+}
+
+// SInter returns the members that all the sets at keys have in common.
+func (s *CacheStore) SInter(keys ...string) ([]string, error) {
+	return s.combineSets(keys, setInter)
+}
+
+// SDiff returns the members of the first set that none of the others have.
+func (s *CacheStore) SDiff(keys ...string) ([]string, error) {
+	return s.combineSets(keys, setDiff)
+}
+
+// SUnionStore stores the union of the sets at keys in dest, replacing what
+// dest held, and returns its size. Like the other *Store methods it runs as
+// one transaction, so the sets cannot change between reading and storing,
+// and it deletes dest when the result is empty.
+func (s *CacheStore) SUnionStore(dest string, keys []string, exp time.Duration) (int, error) {
+	return s.storeSets(dest, keys, setUnion, exp)
+}
+
+// SInterStore stores the intersection of the sets at keys in dest, see
+// SUnionStore.
+func (s *CacheStore) SInterStore(dest string, keys []string, exp time.Duration) (int, error) {
+	return s.storeSets(dest, keys, setInter, exp)
+}
+
+// SDiffStore stores the difference of the sets at keys in dest, see
+// SUnionStore.
+func (s *CacheStore) SDiffStore(dest string, keys []string, exp time.Duration) (int, error) {
+	return s.storeSets(dest, keys, setDiff, exp)
+}
+
+func (s *CacheStore) combineSets(keys []string, op setOp) ([]string, error) {
+	var members []string
+	err := s.View(func(tx *Tx) error {
+		var err error
+		members, err = combineSets(tx, keys, op)
+		return err
+	})
+	return members, err
+}
+
+func (s *CacheStore) storeSets(dest string, keys []string, op setOp, exp time.Duration) (int, error) {
+	n := 0
+	err := s.Update(func(tx *Tx) error {
+		var err error
+		n, err = storeSets(tx, dest, keys, op, exp)
+		return err
+	})
+	return n, err
+}
+
+// updateSet decodes the set at key, lets fn change its sorted members and
+// stores the result. fn returns errUnchanged when there is nothing to write.
+func updateSet(kv entryModifier, key string, exp time.Duration, fn func(members []string) ([]string, error)) error {
+	return updateData(kv, key, types.SET, exp, func(data []byte) ([]byte, error) {
+		var members []string
+		if data != nil {
+			var err error
+			if members, err = utils.DecodeSet(data); err != nil {
+				return nil, err
+			}
+		}
+		members, err := fn(members)
+		if err != nil {
+			return nil, err
+		}
+		if len(members) == 0 {
+			return nil, nil
+		}
+		return utils.EncodeSet(members), nil
+	})
+}
+
+func setAdd(kv entryModifier, key string, members []string, exp time.Duration) (int, error) {
+	if len(members) == 0 {
+		return 0, errors.ErrMembersEmpty
+	}
+	added := 0
+	err := updateSet(kv, key, exp, func(all []string) ([]string, error) {
+		added = 0
+		for _, member := range members {
+			if i, found := slices.BinarySearch(all, member); !found {
+				all = slices.Insert(all, i, member)
+				added++
+			}
+		}
+		if added == 0 && exp <= 0 {
+			return nil, errUnchanged
+		}
+		return all, nil
+	})
+	return added, err
+}
+
+func setRemove(kv entryModifier, key string, members []string) (int, error) {
+	removed := 0
+	err := updateSet(kv, key, 0, func(all []string) ([]string, error) {
+		removed = 0
+		for _, member := range members {
+			if i, found := slices.BinarySearch(all, member); found {
+				all = slices.Delete(all, i, i+1)
+				removed++
+			}
+		}
+		if removed == 0 {
+			return nil, errUnchanged
+		}
+		return all, nil
+	})
+	return removed, err
+}
+
+func setPop(kv entryModifier, key string, count int) ([]string, error) {
+	if count < 0 {
+		return nil, errors.ErrCountNegative
+	}
+	popped := []string{}
+	err := updateSet(kv, key, 0, func(all []string) ([]string, error) {
+		if len(all) == 0 || count == 0 {
+			return nil, errUnchanged
+		}
+		popped = randomMembers(all, count)
+		for _, member := range popped {
+			i, _ := slices.BinarySearch(all, member)
+			all = slices.Delete(all, i, i+1)
+		}
+		return all, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return popped, nil
+}
+
+// randomMembers returns up to count distinct members in random order.
+func randomMembers(members []string, count int) []string {
+	picked := slices.Clone(members)
+	rand.Shuffle(len(picked), func(i, j int) {
+		picked[i], picked[j] = picked[j], picked[i]
+	})
+	return picked[:min(count, len(picked))]
+}
+
+type setOp int
+
+const (
+	setUnion setOp = iota
+	setInter
+	setDiff
+)
+
+// combineSets applies op to the sets at keys, from left to right.
+func combineSets(tx *Tx, keys []string, op setOp) ([]string, error) {
+	if len(keys) == 0 {
+		return nil, errors.ErrKeyEmpty
+	}
+	var result map[string]struct{}
+	for i, key := range keys {
+		members, err := tx.setMembers(key)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case i == 0:
+			result = make(map[string]struct{}, len(members))
+			for _, member := range members {
+				result[member] = struct{}{}
+			}
+		case op == setUnion:
+			for _, member := range members {
+				result[member] = struct{}{}
+			}
+		case op == setInter:
+			for member := range result {
+				if _, found := slices.BinarySearch(members, member); !found {
+					delete(result, member)
+				}
+			}
+		case op == setDiff:
+			for _, member := range members {
+				delete(result, member)
+			}
+		}
+	}
+	combined := make([]string, 0, len(result))
+	for member := range result {
+		combined = append(combined, member)
+	}
+	slices.Sort(combined)
+	return combined, nil
+}
+
+func storeSets(tx *Tx, dest string, keys []string, op setOp, exp time.Duration) (int, error) {
+	if dest == "" {
+		return 0, errors.ErrKeyEmpty
+	}
+	members, err := combineSets(tx, keys, op)
+	if err != nil {
+		return 0, err
+	}
+	if len(members) == 0 {
+		if tx.Exists(dest) == 0 {
+			return 0, nil
+		}
+		return 0, tx.Delete(dest)
+	}
+	e := entry.NewEntry(types.SET, utils.EncodeSet(members), exp)
+	return len(members), tx.putEntry(dest, &e)
+}
+
+// setMembers returns the members of the set at key, nil when missing.
+func (tx *Tx) setMembers(key string) ([]string, error) {
+	if key == "" {
+		return nil, errors.ErrKeyEmpty
+	}
+	if tx.done {
+		return nil, errors.ErrTxDone
+	}
+	e, err := tx.getEntry(key)
+	if err != nil {
+		if goerrors.Is(err, errors.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if e.Type != types.SET {
+		return nil, errors.ErrTypeMismatch(key, types.SET, e.Type)
+	}
+	return utils.DecodeSet(e.Data)
+}
@@ -0,0 +1,268 @@
+package store
+
+import (
+	goerrors "errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func newSetStore(t *testing.T) *CacheStore {
+	t.Helper()
+	store, err := NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func expectMembers(t *testing.T, name string, got []string, err error, want ...string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s error = %v", name, err)
+	}
+	if want == nil {
+		want = []string{}
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("%s = %q, want %q", name, got, want)
+	}
+}
+
+func TestSet_Basic(t *testing.T) {
+	store := newSetStore(t)
+
+	if added, err := store.SAdd("tags", []string{"go", "db", "go"}, 0); err != nil || added != 2 {
+		t.Fatalf("SAdd() = %d, %v, want 2 new members", added, err)
+	}
+	if added, _ := store.SAdd("tags", []string{"cache", "db"}, 0); added != 1 {
+		t.Errorf("SAdd() of one new and one existing member = %d, want 1", added)
+	}
+	members, err := store.SMembers("tags")
+	expectMembers(t, "SMembers()", members, err, "cache", "db", "go")
+	members, err = store.SMembers("missing")
+	expectMembers(t, "SMembers() of a missing key", members, err)
+
+	if ok, err := store.SIsMember("tags", "db"); err != nil || !ok {
+		t.Errorf("SIsMember(db) = %v, %v, want true", ok, err)
+	}
+	if ok, err := store.SIsMember("tags", "rust"); err != nil || ok {
+		t.Errorf("SIsMember(rust) = %v, %v, want false", ok, err)
+	}
+	if n, err := store.SCard("tags"); err != nil || n != 3 {
+		t.Errorf("SCard() = %d, %v, want 3", n, err)
+	}
+
+	if removed, err := store.SRem("tags", "db", "rust"); err != nil || removed != 1 {
+		t.Errorf("SRem() = %d, %v, want 1", removed, err)
+	}
+	if removed, err := store.SRem("tags", "cache", "go"); err != nil || removed != 2 {
+		t.Errorf("SRem() = %d, %v, want 2", removed, err)
+	}
+	if store.Exists("tags") != 0 {
+		t.Error("removing the last member should delete the key")
+	}
+	if removed, err := store.SRem("missing", "a"); err != nil || removed != 0 {
+		t.Errorf("SRem() of a missing key = %d, %v, want 0", removed, err)
+	}
+}
+
+func TestSet_Random(t *testing.T) {
+	store := newSetStore(t)
+	all := []string{"a", "b", "c", "d", "e"}
+	store.SAdd("s", all, 0)
+
+	picked, err := store.SRandMember("s", 3)
+	distinct := make(map[string]bool)
+	for _, member := range picked {
+		distinct[member] = true
+	}
+	if err != nil || len(picked) != 3 || len(distinct) != 3 {
+		t.Errorf("SRandMember(3) = %q, %v, want 3 distinct members", picked, err)
+	}
+	if picked, _ := store.SRandMember("s", 10); len(picked) != 5 {
+		t.Errorf("SRandMember(10) = %q, want all 5 members", picked)
+	}
+	picked, err = store.SRandMember("s", -8)
+	if err != nil || len(picked) != 8 {
+		t.Errorf("SRandMember(-8) = %q, %v, want 8 members", picked, err)
+	}
+	for _, member := range picked {
+		if !slices.Contains(all, member) {
+			t.Errorf("SRandMember() returned %q, which is no member", member)
+		}
+	}
+	if n, _ := store.SCard("s"); n != 5 {
+		t.Errorf("SRandMember() changed the set to %d members", n)
+	}
+
+	popped, err := store.SPop("s", 2)
+	if err != nil || len(popped) != 2 {
+		t.Fatalf("SPop(2) = %q, %v", popped, err)
+	}
+	for _, member := range popped {
+		if ok, _ := store.SIsMember("s", member); ok {
+			t.Errorf("SPop() left %q in the set", member)
+		}
+	}
+	if popped, _ := store.SPop("s", 10); len(popped) != 3 {
+		t.Errorf("SPop(10) = %q, want the 3 remaining members", popped)
+	}
+	if store.Exists("s") != 0 {
+		t.Error("popping every member should delete the key")
+	}
+	popped, err = store.SPop("s", 1)
+	expectMembers(t, "SPop() of a missing key", popped, err)
+	if _, err := store.SPop("s", -1); err != errors.ErrCountNegative {
+		t.Errorf("SPop(-1) error = %v, want ErrCountNegative", err)
+	}
+}
+
+func TestSet_Algebra(t *testing.T) {
+	store := newSetStore(t)
+	store.SAdd("a", []string{"1", "2", "3", "4"}, 0)
+	store.SAdd("b", []string{"3", "4", "5"}, 0)
+	store.SAdd("c", []string{"4", "6"}, 0)
+
+	members, err := store.SUnion("a", "b", "missing")
+	expectMembers(t, "SUnion()", members, err, "1", "2", "3", "4", "5")
+	members, err = store.SInter("a", "b", "c")
+	expectMembers(t, "SInter()", members, err, "4")
+	members, err = store.SInter("a", "missing")
+	expectMembers(t, "SInter() with a missing key", members, err)
+	members, err = store.SDiff("a", "b", "c")
+	expectMembers(t, "SDiff()", members, err, "1", "2")
+	members, err = store.SDiff("missing", "a")
+	expectMembers(t, "SDiff() of a missing key", members, err)
+
+	store.SetString("dest", "old", time.Hour)
+	if n, err := store.SUnionStore("dest", []string{"b", "c"}, 0); err != nil || n != 4 {
+		t.Errorf("SUnionStore() = %d, %v, want 4", n, err)
+	}
+	members, err = store.SMembers("dest")
+	expectMembers(t, "SMembers() after SUnionStore()", members, err, "3", "4", "5", "6")
+	if ttl := store.TTL("dest"); ttl != TTLNoExpiry {
+		t.Errorf("TTL() after SUnionStore() = %v, want no expiry", ttl)
+	}
+
+	// The destination may be one of the sources.
+	if n, err := store.SInterStore("a", []string{"a", "b"}, time.Minute); err != nil || n != 2 {
+		t.Errorf("SInterStore() = %d, %v, want 2", n, err)
+	}
+	members, err = store.SMembers("a")
+	expectMembers(t, "SMembers() after SInterStore()", members, err, "3", "4")
+	if ttl := store.TTL("a"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("TTL() after SInterStore() = %v, want about a minute", ttl)
+	}
+
+	if n, err := store.SDiffStore("dest", []string{"a", "b"}, 0); err != nil || n != 0 {
+		t.Errorf("SDiffStore() = %d, %v, want 0", n, err)
+	}
+	if store.Exists("dest") != 0 {
+		t.Error("an empty result should delete the destination")
+	}
+}
+
+func TestSet_Errors(t *testing.T) {
+	store := newSetStore(t)
+	store.SetString("str", "v", 0)
+	store.SAdd("a", []string{"1"}, 0)
+
+	if _, err := store.SAdd("str", []string{"x"}, 0); !goerrors.Is(err, errors.ErrWrongType) {
+		t.Errorf("SAdd() on a string error = %v, want ErrWrongType", err)
+	}
+	if _, err := store.SIsMember("str", "x"); !goerrors.Is(err, errors.ErrWrongType) {
+		t.Errorf("SIsMember() on a string error = %v, want ErrWrongType", err)
+	}
+	if _, err := store.SUnion("a", "str"); !goerrors.Is(err, errors.ErrWrongType) {
+		t.Errorf("SUnion() with a string error = %v, want ErrWrongType", err)
+	}
+	if _, err := store.SInterStore("dest", []string{"a", "str"}, 0); !goerrors.Is(err, errors.ErrWrongType) {
+		t.Errorf("SInterStore() with a string error = %v, want ErrWrongType", err)
+	}
+	if store.Exists("dest") != 0 {
+		t.Error("failed SInterStore() created the destination")
+	}
+	if _, err := store.SAdd("s", nil, 0); err != errors.ErrMembersEmpty {
+		t.Errorf("SAdd() without members error = %v, want ErrMembersEmpty", err)
+	}
+	if _, err := store.SAdd("", []string{"x"}, 0); err != errors.ErrKeyEmpty {
+		t.Errorf("SAdd() with an empty key error = %v, want ErrKeyEmpty", err)
+	}
+	if _, err := store.SUnion(); err != errors.ErrKeyEmpty {
+		t.Errorf("SUnion() without keys error = %v, want ErrKeyEmpty", err)
+	}
+	if _, err := store.SUnionStore("", []string{"a"}, 0); err != errors.ErrKeyEmpty {
+		t.Errorf("SUnionStore() with an empty destination error = %v, want ErrKeyEmpty", err)
+	}
+}
+
+func TestSet_Transaction(t *testing.T) {
+	store := newSetStore(t)
+	store.SAdd("a", []string{"1", "2"}, 0)
+
+	err := store.Update(func(tx *Tx) error {
+		if _, err := tx.SAdd("b", []string{"2", "3"}, 0); err != nil {
+			return err
+		}
+		if _, err := tx.SRem("a", "1"); err != nil {
+			return err
+		}
+		// The store sees the writes made earlier in the transaction.
+		n, err := tx.SUnionStore("u", []string{"a", "b"}, 0)
+		if err == nil && n != 2 {
+			t.Errorf("SUnionStore() in a transaction = %d, want 2", n)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	members, err := store.SMembers("u")
+	expectMembers(t, "SMembers()", members, err, "2", "3")
+
+	rollback := goerrors.New("rollback")
+	store.Update(func(tx *Tx) error {
+		tx.SPop("a", 1)
+		tx.SDiffStore("u", []string{"b"}, 0)
+		return rollback
+	})
+	members, err = store.SMembers("a")
+	expectMembers(t, "SMembers() after a rollback", members, err, "2")
+
+	err = store.View(func(tx *Tx) error {
+		_, err := tx.SInterStore("i", []string{"a", "b"}, 0)
+		return err
+	})
+	if err != errors.ErrTxReadOnly {
+		t.Errorf("SInterStore() in View error = %v, want ErrTxReadOnly", err)
+	}
+}
+
+func TestSet_Persistence(t *testing.T) {
+	dbFile := tempDBFile(t)
+	cfg := config.Config{DBSave: true, DBFileName: dbFile}
+	store, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	store.SAdd("seen", []string{"id:1", "id:2", "id:1"}, 0)
+	store.Sync()
+	store.Close()
+
+	store2, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to re-create store: %v", err)
+	}
+	defer store2.Close()
+	members, err := store2.SMembers("seen")
+	expectMembers(t, "SMembers() after restart", members, err, "id:1", "id:2")
+	if dataType, _, _ := store2.Get("seen"); dataType != types.SET {
+		t.Errorf("type after restart = %v, want Set", dataType)
+	}
+}
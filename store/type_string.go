@@ -11,9 +11,10 @@ func (s *CacheStore) GetString(key string) (string, error) {
 	if key == "" {
 		return "", errors.ErrKeyEmpty
 	}
-	s.mux.RLock()
-	defer s.mux.RUnlock()
-	e, err := s.unsafeGet(key)
+	sh := s.shardFor(key)
+	sh.mux.RLock()
+	defer sh.mux.RUnlock()
+	e, err := sh.unsafeGet(key)
 	if err != nil {
 		return "", err
 	}
@@ -12,9 +12,10 @@ func (s *CacheStore) GetTime(key string) (time.Time, error) {
 	if key == "" {
 		return t, errors.ErrKeyEmpty
 	}
-	s.mux.RLock()
-	defer s.mux.RUnlock()
-	e, err := s.unsafeGet(key)
+	sh := s.shardFor(key)
+	sh.mux.RLock()
+	defer sh.mux.RUnlock()
+	e, err := sh.unsafeGet(key)
 	if err != nil {
 		return t, err
 	}
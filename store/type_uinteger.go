@@ -3,6 +3,7 @@ package store
 import (
 	"time"
 
+	"github.com/found-cake/CacheStore/entry"
 	"github.com/found-cake/CacheStore/errors"
 	"github.com/found-cake/CacheStore/utils"
 	"github.com/found-cake/CacheStore/utils/generic"
@@ -85,7 +86,7 @@ func (s *CacheStore) DecrUInt64(key string, delta uint64, exp time.Duration) err
 }
 
 func decrUnsigned[T generic.Unsigned](
-	s *CacheStore,
+	kv entryModifier,
 	key string,
 	delta T,
 	data_type types.DataType,
@@ -94,31 +95,21 @@ func decrUnsigned[T generic.Unsigned](
 	toBinary func(T) []byte,
 	checkUnderflow func(T, T) bool,
 ) error {
-	if key == "" {
-		return errors.ErrKeyEmpty
-	}
-	s.mux.Lock()
-	defer s.mux.Unlock()
-	e, err := s.unsafeGet(key)
-	if err != nil {
-		return errors.ErrNoDataForKey(key)
-	}
-	if e.Type != data_type {
-		return errors.ErrTypeMismatch(key, data_type, e.Type)
-	}
-	value, err := fromBinary(e.Data)
-	if err != nil {
-		return err
-	}
-	if checkUnderflow(value, delta) {
-		return errors.ErrUnsignedUnderflow(key, value, delta)
-	}
-	value -= delta
-	data := toBinary(value)
-	if exp > 0 {
-		s.unsafeSet(key, data_type, data, exp)
-	} else {
-		s.setKeepExp(key, data_type, data, e.Expiry)
-	}
-	return nil
+	return kv.modify(key, func(e entry.Entry, err error) (entry.Entry, error) {
+		if err != nil {
+			return e, errors.ErrNoDataForKey(key)
+		}
+		if e.Type != data_type {
+			return e, errors.ErrTypeMismatch(key, data_type, e.Type)
+		}
+		value, err := fromBinary(e.Data)
+		if err != nil {
+			return e, err
+		}
+		if checkUnderflow(value, delta) {
+			return e, errors.ErrUnsignedUnderflow(key, value, delta)
+		}
+		value -= delta
+		return keepExp(e, data_type, toBinary(value), exp), nil
+	})
 }
@@ -0,0 +1,459 @@
+package store
+
+import (
+	goerrors "errors"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// A ZSET maps members to scores and keeps them ordered by score, then by
+// member. See collection.go for the rules shared by collections. The store
+// keeps every sorted set live, in the skiplist of zset.go, so lookups by
+// member, rank or score take O(log n) and a write only touches the members it
+// changes. The append-only log and the followers receive these members rather
+// than the whole value, which is only encoded, see utils/zset.go, when it is
+// read as bytes, persisted or sent in a snapshot. Transactions change the
+// live sets too, and undo their changes when they do not commit.
+//
+// Ranks count from 0 at the lowest score, or from -1 at the highest when
+// negative. Scores may be infinite but not NaN.
+
+// viewZSet calls fn with the live sorted set at key, nil when missing, while
+// its shard is locked for reading.
+func (s *CacheStore) viewZSet(key string, fn func(z *zset) error) error {
+	if key == "" {
+		return errors.ErrKeyEmpty
+	}
+	sh := s.shardFor(key)
+	sh.mux.RLock()
+	defer sh.mux.RUnlock()
+	z, err := sh.unsafeZSet(key)
+	if err != nil {
+		return err
+	}
+	return fn(z)
+}
+
+// unsafeZSet returns the live sorted set at key, or nil when key is missing.
+func (sh *shard) unsafeZSet(key string) (*zset, error) {
+	e, err := sh.unsafeLookup(key)
+	if err != nil {
+		if goerrors.Is(err, errors.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if e.Type != types.ZSET {
+		return nil, errors.ErrTypeMismatch(key, types.ZSET, e.Type)
+	}
+	z, ok := sh.zsets[key]
+	if !ok {
+		// Only values that failed to decode are not live, see unsafePut.
+		return nil, errors.ErrCorruptValue
+	}
+	return z, nil
+}
+
+// ZScore returns the score of member, or an error wrapping ErrNotFound when
+// the key or the member does not exist.
+func (s *CacheStore) ZScore(key, member string) (float64, error) {
+	var score float64
+	err := s.viewZSet(key, func(z *zset) error {
+		if z == nil {
+			return errors.ErrNoDataForKey(key)
+		}
+		var ok bool
+		if score, ok = z.score(member); !ok {
+			return errors.ErrNoDataForMember(key, member)
+		}
+		return nil
+	})
+	return score, err
+}
+
+// ZRank returns the rank of member by ascending score, or an error wrapping
+// ErrNotFound when the key or the member does not exist.
+func (s *CacheStore) ZRank(key, member string) (int, error) {
+	return s.zsetRank(key, member, false)
+}
+
+// ZRevRank returns the rank of member by descending score, see ZRank.
+func (s *CacheStore) ZRevRank(key, member string) (int, error) {
+	return s.zsetRank(key, member, true)
+}
+
+func (s *CacheStore) zsetRank(key, member string, rev bool) (int, error) {
+	var rank int
+	err := s.viewZSet(key, func(z *zset) error {
+		if z == nil {
+			return errors.ErrNoDataForKey(key)
+		}
+		var ok bool
+		if rank, ok = z.rank(member); !ok {
+			return errors.ErrNoDataForMember(key, member)
+		}
+		if rev {
+			rank = z.len() - 1 - rank
+		}
+		return nil
+	})
+	return rank, err
+}
+
+// ZCard returns the number of members of the sorted set.
+func (s *CacheStore) ZCard(key string) (int, error) {
+	n := 0
+	err := s.viewZSet(key, func(z *zset) error {
+		if z != nil {
+			n = z.len()
+		}
+		return nil
+	})
+	return n, err
+}
+
+// ZRange returns the members ranked from start to stop, both included, by
+// ascending score. Out of range ranks are clamped; a missing key is an empty
+// set.
+func (s *CacheStore) ZRange(key string, start, stop int) ([]utils.ZMember, error) {
+	return s.zsetRange(key, start, stop, false)
+}
+
+// ZRevRange is ZRange by descending score, so ZRevRange(key, 0, 9) returns
+// the ten highest scores.
+func (s *CacheStore) ZRevRange(key string, start, stop int) ([]utils.ZMember, error) {
+	return s.zsetRange(key, start, stop, true)
+}
+
+func (s *CacheStore) zsetRange(key string, start, stop int, rev bool) ([]utils.ZMember, error) {
+	members := []utils.ZMember{}
+	err := s.viewZSet(key, func(z *zset) error {
+		if z == nil {
+			return nil
+		}
+		n := z.len()
+		from, to := listRange(start, stop, n)
+		if rev {
+			from, to = n-to, n-from
+		}
+		members = z.rangeByRank(from, to)
+		if rev {
+			slices.Reverse(members)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// ZRangeByScore returns the members scoring from minScore to maxScore, both
+// included, by ascending score. It skips the first offset of them and returns
+// at most count, or all the others when count is negative. Use math.Inf for
+// open ranges and math.Nextafter for exclusive bounds.
+func (s *CacheStore) ZRangeByScore(key string, minScore, maxScore float64, offset, count int) ([]utils.ZMember, error) {
+	if math.IsNaN(minScore) || math.IsNaN(maxScore) {
+		return nil, errors.ErrScoreNaN
+	}
+	members := []utils.ZMember{}
+	err := s.viewZSet(key, func(z *zset) error {
+		if z == nil || offset < 0 {
+			return nil
+		}
+		from, to := z.scoreRange(minScore, maxScore)
+		from += min(offset, to-from)
+		if count >= 0 {
+			to = from + min(count, to-from)
+		}
+		members = z.rangeByRank(from, to)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// ZAdd adds members to the sorted set or updates their scores, and returns
+// how many of them are new. When a member is given more than once, the last
+// score counts.
+func (s *CacheStore) ZAdd(key string, members []utils.ZMember, exp time.Duration) (int, error) {
+	return zsetAdd(s, key, members, exp)
+}
+
+// ZIncrBy adds delta to the score of member, which starts at 0 when missing,
+// and returns the new score.
+func (s *CacheStore) ZIncrBy(key, member string, delta float64, exp time.Duration) (float64, error) {
+	return zsetIncrBy(s, key, member, delta, exp)
+}
+
+// ZRem removes members and returns how many of them existed.
+func (s *CacheStore) ZRem(key string, members ...string) (int, error) {
+	return zsetRemove(s, key, members)
+}
+
+// ZRemRangeByScore removes the members scoring from minScore to maxScore,
+// both included, and returns how many there were.
+func (s *CacheStore) ZRemRangeByScore(key string, minScore, maxScore float64) (int, error) {
+	return zsetRemoveRangeByScore(s, key, minScore, maxScore)
+}
+
+// zsetUpdate chooses changes to a sorted set, members to set and then
+// members to remove, or returns errUnchanged when there are none.
+type zsetUpdate func(z *zset) (upserts []utils.ZMember, removes []string, err error)
+
+// zsetModifier is the target of sorted set writes: the store, which changes
+// its live sorted sets in place, or a transaction.
+type zsetModifier interface {
+	// updateZSet applies the changes fn chooses to the sorted set at key,
+	// empty when missing. Removing the last member deletes key.
+	updateZSet(key string, exp time.Duration, fn zsetUpdate) error
+}
+
+func (s *CacheStore) updateZSet(key string, exp time.Duration, fn zsetUpdate) error {
+	if key == "" {
+		return errors.ErrKeyEmpty
+	}
+	if s.readOnly.Load() {
+		return errors.ErrReadOnly
+	}
+	sh := s.shardFor(key)
+	sh.mux.Lock()
+	defer sh.mux.Unlock()
+	z, err := sh.unsafeZSet(key)
+	if err != nil {
+		return err
+	}
+	exists := z != nil
+	if !exists {
+		z = newZSet()
+	}
+	upserts, removes, err := fn(z)
+	if err == errUnchanged {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	undo := z.apply(upserts, removes)
+	if z.len() == 0 {
+		if exists {
+			s.unsafeDelete(sh, key)
+		}
+		return nil
+	}
+	e := entry.NewEntry(types.ZSET, nil, exp)
+	if exists {
+		e = keepExp(sh.memorydb[key], types.ZSET, nil, exp)
+	}
+	e.Version = s.nextVersion()
+	evicted, err := sh.unsafePutZSet(key, e, z)
+	if err != nil {
+		undo()
+		return s.unsafePublish(op{key: key}, evicted, err)
+	}
+	if !exists {
+		// A new set is published whole, replacing whatever the log and the
+		// followers hold for key.
+		e.Data = z.encode()
+		return s.unsafePublish(op{kind: opSet, key: key, entry: e}, evicted, nil)
+	}
+	return s.unsafePublish(op{kind: opZSet, key: key, entry: e, zadd: upserts, zrem: removes}, evicted, nil)
+}
+
+// unsafeApplyZSet applies an opZSet to the live sorted set at its key,
+// starting from an empty set when there is none. It reports whether the set
+// was stored; it is removed instead when expired or empty.
+func (sh *shard) unsafeApplyZSet(o op, now int64) (evicted []string, stored bool, err error) {
+	if o.entry.IsExpiredWithUnixMilli(now) {
+		sh.unsafeRemove(o.key)
+		return nil, false, nil
+	}
+	z, ok := sh.zsets[o.key]
+	if !ok {
+		z = newZSet()
+	}
+	undo := z.apply(o.zadd, o.zrem)
+	if z.len() == 0 {
+		sh.unsafeRemove(o.key)
+		return nil, false, nil
+	}
+	if evicted, err = sh.unsafePutZSet(o.key, o.entry, z); err != nil {
+		undo()
+		return evicted, false, err
+	}
+	return evicted, true, nil
+}
+
+// updateZSet applies the changes to the live sorted set at key, staging it
+// in tx until the commit.
+func (tx *Tx) updateZSet(key string, exp time.Duration, fn zsetUpdate) error {
+	if key == "" {
+		return errors.ErrKeyEmpty
+	}
+	if err := tx.checkWrite(key); err != nil {
+		return err
+	}
+	old, z, err := tx.zset(key)
+	if err != nil {
+		return err
+	}
+	exists := z != nil
+	if !exists {
+		z = newZSet()
+	}
+	upserts, removes, err := fn(z)
+	if err == errUnchanged {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	tx.undo = append(tx.undo, z.apply(upserts, removes))
+	if z.len() == 0 {
+		if exists {
+			return tx.putEntry(key, nil)
+		}
+		return nil
+	}
+	e := entry.NewEntry(types.ZSET, nil, exp)
+	if exists {
+		e = keepExp(old, types.ZSET, nil, exp)
+	}
+	if err := tx.putEntry(key, &e); err != nil {
+		return err
+	}
+	tx.zsets[key] = z
+	return nil
+}
+
+// zset returns the entry and the live form of the sorted set at key as tx
+// sees it, or a nil set when key is missing. Sorted sets written whole by tx
+// are decoded.
+func (tx *Tx) zset(key string) (entry.Entry, *zset, error) {
+	if z, ok := tx.zsets[key]; ok && !tx.writes[key].IsExpired() {
+		return *tx.writes[key], z, nil
+	}
+	sh := tx.store.shardFor(key)
+	_, written := tx.writes[key]
+	var e entry.Entry
+	var err error
+	if written {
+		e, err = tx.getEntry(key)
+	} else {
+		e, err = sh.unsafeLookup(key)
+	}
+	if err != nil {
+		if goerrors.Is(err, errors.ErrNotFound) {
+			return entry.Entry{}, nil, nil
+		}
+		return entry.Entry{}, nil, err
+	}
+	if e.Type != types.ZSET {
+		return entry.Entry{}, nil, errors.ErrTypeMismatch(key, types.ZSET, e.Type)
+	}
+	if written {
+		z, err := decodeZSet(e.Data)
+		return e, z, err
+	}
+	z, ok := sh.zsets[key]
+	if !ok {
+		return entry.Entry{}, nil, errors.ErrCorruptValue
+	}
+	return e, z, nil
+}
+
+func zsetAdd(kv zsetModifier, key string, members []utils.ZMember, exp time.Duration) (int, error) {
+	if len(members) == 0 {
+		return 0, errors.ErrMembersEmpty
+	}
+	for _, m := range members {
+		if math.IsNaN(m.Score) {
+			return 0, errors.ErrScoreNaN
+		}
+	}
+	added := 0
+	err := kv.updateZSet(key, exp, func(z *zset) ([]utils.ZMember, []string, error) {
+		added = 0
+		changed := false
+		seen := make(map[string]struct{}, len(members))
+		for _, m := range members {
+			score, ok := z.score(m.Member)
+			if _, dup := seen[m.Member]; !ok && !dup {
+				added++
+			}
+			seen[m.Member] = struct{}{}
+			changed = changed || !ok || score != m.Score
+		}
+		if !changed && exp <= 0 {
+			return nil, nil, errUnchanged
+		}
+		return members, nil, nil
+	})
+	return added, err
+}
+
+func zsetIncrBy(kv zsetModifier, key, member string, delta float64, exp time.Duration) (float64, error) {
+	if math.IsNaN(delta) {
+		return 0, errors.ErrScoreNaN
+	}
+	var result float64
+	err := kv.updateZSet(key, exp, func(z *zset) ([]utils.ZMember, []string, error) {
+		score, _ := z.score(member)
+		result = score + delta
+		if math.IsNaN(result) {
+			return nil, nil, errors.ErrScoreNaN
+		}
+		return []utils.ZMember{{Member: member, Score: result}}, nil, nil
+	})
+	return result, err
+}
+
+func zsetRemove(kv zsetModifier, key string, members []string) (int, error) {
+	removed := 0
+	err := kv.updateZSet(key, 0, func(z *zset) ([]utils.ZMember, []string, error) {
+		removed = 0
+		seen := make(map[string]struct{}, len(members))
+		for _, member := range members {
+			_, ok := z.score(member)
+			if _, dup := seen[member]; ok && !dup {
+				removed++
+			}
+			seen[member] = struct{}{}
+		}
+		if removed == 0 {
+			return nil, nil, errUnchanged
+		}
+		return nil, members, nil
+	})
+	return removed, err
+}
+
+func zsetRemoveRangeByScore(kv zsetModifier, key string, minScore, maxScore float64) (int, error) {
+	if math.IsNaN(minScore) || math.IsNaN(maxScore) {
+		return 0, errors.ErrScoreNaN
+	}
+	removed := 0
+	err := kv.updateZSet(key, 0, func(z *zset) ([]utils.ZMember, []string, error) {
+		members := z.rangeByRank(z.scoreRange(minScore, maxScore))
+		removed = len(members)
+		if removed == 0 {
+			return nil, nil, errUnchanged
+		}
+		removes := make([]string, len(members))
+		for i, m := range members {
+			removes[i] = m.Member
+		}
+		return nil, removes, nil
+	})
+	return removed, err
+}
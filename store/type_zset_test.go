@@ -0,0 +1,495 @@
+package store
+
+import (
+	"bytes"
+	"cmp"
+	goerrors "errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func newZSetStore(t testing.TB) *CacheStore {
+	t.Helper()
+	store, err := NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func zm(member string, score float64) utils.ZMember {
+	return utils.ZMember{Member: member, Score: score}
+}
+
+func expectZMembers(t *testing.T, name string, got []utils.ZMember, err error, want ...utils.ZMember) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s error = %v", name, err)
+	}
+	if want == nil {
+		want = []utils.ZMember{}
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("%s = %v, want %v", name, got, want)
+	}
+}
+
+func TestZSet_Basic(t *testing.T) {
+	store := newZSetStore(t)
+
+	added, err := store.ZAdd("board", []utils.ZMember{zm("alice", 30), zm("bob", 10), zm("carol", 20), zm("bob", 15)}, 0)
+	if err != nil || added != 3 {
+		t.Fatalf("ZAdd() = %d, %v, want 3 new members", added, err)
+	}
+	if added, _ := store.ZAdd("board", []utils.ZMember{zm("dave", 20), zm("alice", 5)}, 0); added != 1 {
+		t.Errorf("ZAdd() of one new and one existing member = %d, want 1", added)
+	}
+	if score, err := store.ZScore("board", "alice"); err != nil || score != 5 {
+		t.Errorf("ZScore() = %v, %v, want 5", score, err)
+	}
+	if _, err := store.ZScore("board", "erin"); !goerrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("ZScore() of a missing member error = %v, want ErrNotFound", err)
+	}
+	if _, err := store.ZScore("missing", "alice"); !goerrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("ZScore() of a missing key error = %v, want ErrNotFound", err)
+	}
+	if n, err := store.ZCard("board"); err != nil || n != 4 {
+		t.Errorf("ZCard() = %d, %v, want 4", n, err)
+	}
+
+	// alice 5, bob 15, carol 20, dave 20
+	if rank, err := store.ZRank("board", "carol"); err != nil || rank != 2 {
+		t.Errorf("ZRank() = %d, %v, want 2", rank, err)
+	}
+	if rank, err := store.ZRevRank("board", "carol"); err != nil || rank != 1 {
+		t.Errorf("ZRevRank() = %d, %v, want 1", rank, err)
+	}
+	if _, err := store.ZRank("board", "erin"); !goerrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("ZRank() of a missing member error = %v, want ErrNotFound", err)
+	}
+
+	if score, err := store.ZIncrBy("board", "bob", 10, 0); err != nil || score != 25 {
+		t.Errorf("ZIncrBy() = %v, %v, want 25", score, err)
+	}
+	if score, err := store.ZIncrBy("board", "erin", -1, 0); err != nil || score != -1 {
+		t.Errorf("ZIncrBy() of a new member = %v, %v, want -1", score, err)
+	}
+	members, err := store.ZRange("board", 0, -1)
+	expectZMembers(t, "ZRange()", members, err,
+		zm("erin", -1), zm("alice", 5), zm("carol", 20), zm("dave", 20), zm("bob", 25))
+
+	if removed, err := store.ZRem("board", "erin", "zoe", "erin"); err != nil || removed != 1 {
+		t.Errorf("ZRem() = %d, %v, want 1", removed, err)
+	}
+	if removed, err := store.ZRemRangeByScore("board", 20, 20); err != nil || removed != 2 {
+		t.Errorf("ZRemRangeByScore() = %d, %v, want 2", removed, err)
+	}
+	if removed, err := store.ZRemRangeByScore("board", 100, 200); err != nil || removed != 0 {
+		t.Errorf("ZRemRangeByScore() of an empty range = %d, %v, want 0", removed, err)
+	}
+	if removed, err := store.ZRem("board", "alice", "bob"); err != nil || removed != 2 {
+		t.Errorf("ZRem() = %d, %v, want 2", removed, err)
+	}
+	if store.Exists("board") != 0 {
+		t.Error("removing the last member should delete the key")
+	}
+}
+
+func TestZSet_Range(t *testing.T) {
+	store := newZSetStore(t)
+	var members []utils.ZMember
+	for i := 0; i < 10; i++ {
+		members = append(members, utils.ZMember{Member: fmt.Sprint("m", i), Score: float64(i)})
+	}
+	members = append(members, zm("low", math.Inf(-1)), zm("high", math.Inf(1)))
+	store.ZAdd("z", members, 0)
+
+	got, err := store.ZRange("z", 1, 3)
+	expectZMembers(t, "ZRange(1, 3)", got, err, members[0], members[1], members[2])
+	got, err = store.ZRange("z", -2, 100)
+	expectZMembers(t, "ZRange(-2, 100)", got, err, members[9], members[11])
+	got, err = store.ZRevRange("z", 0, 2)
+	expectZMembers(t, "ZRevRange(0, 2)", got, err, members[11], members[9], members[8])
+	got, err = store.ZRevRange("z", -1, -1)
+	expectZMembers(t, "ZRevRange(-1, -1)", got, err, members[10])
+	got, err = store.ZRange("z", 5, 2)
+	expectZMembers(t, "ZRange(5, 2)", got, err)
+	got, err = store.ZRange("missing", 0, -1)
+	expectZMembers(t, "ZRange() of a missing key", got, err)
+
+	got, err = store.ZRangeByScore("z", 2, 5, 0, -1)
+	expectZMembers(t, "ZRangeByScore(2, 5)", got, err, members[2:6]...)
+	got, err = store.ZRangeByScore("z", 2, 5, 1, 2)
+	expectZMembers(t, "ZRangeByScore(2, 5) limit 1 2", got, err, members[3:5]...)
+	got, err = store.ZRangeByScore("z", 2, 5, 10, 2)
+	expectZMembers(t, "ZRangeByScore(2, 5) limit 10 2", got, err)
+	got, err = store.ZRangeByScore("z", math.Nextafter(2, math.Inf(1)), math.Nextafter(5, math.Inf(-1)), 0, -1)
+	expectZMembers(t, "ZRangeByScore() with exclusive bounds", got, err, members[3:5]...)
+	got, err = store.ZRangeByScore("z", math.Inf(-1), 0, 0, -1)
+	expectZMembers(t, "ZRangeByScore(-inf, 0)", got, err, members[10], members[0])
+	got, err = store.ZRangeByScore("z", 5, 2, 0, -1)
+	expectZMembers(t, "ZRangeByScore(5, 2)", got, err)
+	if _, err := store.ZRangeByScore("z", math.NaN(), 2, 0, -1); err != errors.ErrScoreNaN {
+		t.Errorf("ZRangeByScore(NaN) error = %v, want ErrScoreNaN", err)
+	}
+
+	if removed, err := store.ZRemRangeByScore("z", math.Inf(-1), 4); err != nil || removed != 6 {
+		t.Errorf("ZRemRangeByScore(-inf, 4) = %d, %v, want 6", removed, err)
+	}
+	if rank, err := store.ZRank("z", "m5"); err != nil || rank != 0 {
+		t.Errorf("ZRank() after ZRemRangeByScore() = %d, %v, want 0", rank, err)
+	}
+}
+
+func TestZSet_Errors(t *testing.T) {
+	store := newZSetStore(t)
+	store.SetString("s", "v", 0)
+
+	if _, err := store.ZAdd("s", []utils.ZMember{zm("a", 1)}, 0); !goerrors.Is(err, errors.ErrWrongType) {
+		t.Errorf("ZAdd() on a string error = %v, want ErrWrongType", err)
+	}
+	if _, err := store.ZRange("s", 0, -1); !goerrors.Is(err, errors.ErrWrongType) {
+		t.Errorf("ZRange() on a string error = %v, want ErrWrongType", err)
+	}
+	if _, err := store.ZAdd("z", nil, 0); err != errors.ErrMembersEmpty {
+		t.Errorf("ZAdd() without members error = %v, want ErrMembersEmpty", err)
+	}
+	if _, err := store.ZAdd("z", []utils.ZMember{zm("a", math.NaN())}, 0); err != errors.ErrScoreNaN {
+		t.Errorf("ZAdd() with a NaN score error = %v, want ErrScoreNaN", err)
+	}
+	store.ZAdd("z", []utils.ZMember{zm("a", math.Inf(1))}, 0)
+	if _, err := store.ZIncrBy("z", "a", math.Inf(-1), 0); err != errors.ErrScoreNaN {
+		t.Errorf("ZIncrBy() to NaN error = %v, want ErrScoreNaN", err)
+	}
+	if score, _ := store.ZScore("z", "a"); !math.IsInf(score, 1) {
+		t.Errorf("failed ZIncrBy() changed the score to %v", score)
+	}
+	if _, err := store.ZAdd("", []utils.ZMember{zm("a", 1)}, 0); err != errors.ErrKeyEmpty {
+		t.Errorf("ZAdd() with an empty key error = %v, want ErrKeyEmpty", err)
+	}
+}
+
+func TestZSet_Expiry(t *testing.T) {
+	store := newZSetStore(t)
+
+	store.ZAdd("z", []utils.ZMember{zm("a", 1)}, time.Hour)
+	store.ZIncrBy("z", "a", 1, 0)
+	store.ZAdd("z", []utils.ZMember{zm("b", 1)}, 0)
+	if ttl := store.TTL("z"); ttl <= 0 || ttl > time.Hour {
+		t.Errorf("TTL() after writes without expiry = %v, want the original TTL", ttl)
+	}
+	store.ZAdd("z", []utils.ZMember{zm("b", 1)}, time.Minute)
+	if ttl := store.TTL("z"); ttl > time.Minute {
+		t.Errorf("TTL() after an unchanged ZAdd() with expiry = %v, want at most a minute", ttl)
+	}
+}
+
+func TestZSet_Transaction(t *testing.T) {
+	store := newZSetStore(t)
+	store.ZAdd("z", []utils.ZMember{zm("a", 1), zm("b", 2), zm("c", 3)}, 0)
+
+	err := store.Update(func(tx *Tx) error {
+		if _, err := tx.ZIncrBy("z", "a", 10, 0); err != nil {
+			return err
+		}
+		if _, err := tx.ZRem("z", "b"); err != nil {
+			return err
+		}
+		if _, err := tx.ZRemRangeByScore("z", 3, 3); err != nil {
+			return err
+		}
+		_, err := tx.ZAdd("z", []utils.ZMember{zm("d", 4)}, 0)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	members, err := store.ZRange("z", 0, -1)
+	expectZMembers(t, "ZRange() after the transaction", members, err, zm("d", 4), zm("a", 11))
+
+	rollback := goerrors.New("rollback")
+	store.Update(func(tx *Tx) error {
+		tx.ZAdd("z", []utils.ZMember{zm("e", 5)}, 0)
+		return rollback
+	})
+	if n, _ := store.ZCard("z"); n != 2 {
+		t.Errorf("a rolled back ZAdd() was applied, ZCard() = %d", n)
+	}
+}
+
+func TestZSet_TransactionStagesLiveSet(t *testing.T) {
+	store := newZSetStore(t)
+	store.ZAdd("z", []utils.ZMember{zm("a", 1), zm("b", 2)}, 0)
+	live := store.shardFor("z").zsets["z"]
+
+	err := store.Update(func(tx *Tx) error {
+		tx.ZAdd("z", []utils.ZMember{zm("c", 3)}, 0)
+		dataType, data, err := tx.Get("z")
+		if err != nil || dataType != types.ZSET {
+			return fmt.Errorf("Get() = %v, %v", dataType, err)
+		}
+		members, err := utils.DecodeZSet(data)
+		expectZMembers(t, "Get() in the transaction", members, err, zm("a", 1), zm("b", 2), zm("c", 3))
+
+		// A set written whole replaces the staged one.
+		tx.Set("y", types.ZSET, utils.EncodeZSet([]utils.ZMember{zm("x", 1)}), 0)
+		tx.ZAdd("y", []utils.ZMember{zm("w", 0)}, 0)
+		tx.ZAdd("s", []utils.ZMember{zm("x", 1)}, 0)
+		return tx.Set("s", types.STRING, []byte("v"), 0)
+	})
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if store.shardFor("z").zsets["z"] != live {
+		t.Error("the transaction replaced the live sorted set")
+	}
+	members, err := store.ZRange("z", 0, -1)
+	expectZMembers(t, "ZRange(z)", members, err, zm("a", 1), zm("b", 2), zm("c", 3))
+	members, err = store.ZRange("y", 0, -1)
+	expectZMembers(t, "ZRange(y)", members, err, zm("w", 0), zm("x", 1))
+	if v, err := store.GetString("s"); err != nil || v != "v" {
+		t.Errorf("GetString(s) = %q, %v, want the later Set", v, err)
+	}
+	if _, ok := store.shardFor("s").zsets["s"]; ok {
+		t.Error("a sorted set overwritten in the transaction is still live")
+	}
+
+	// A panicking transaction leaves the live set unchanged too.
+	func() {
+		defer func() { recover() }()
+		store.Update(func(tx *Tx) error {
+			tx.ZRem("z", "a", "b")
+			panic("boom")
+		})
+	}()
+	members, err = store.ZRange("z", 0, -1)
+	expectZMembers(t, "ZRange(z) after a panic", members, err, zm("a", 1), zm("b", 2), zm("c", 3))
+}
+
+func TestZSet_Persistence(t *testing.T) {
+	dbFile := tempDBFile(t)
+	cfg := config.Config{DBSave: true, DBFileName: dbFile}
+	store, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	members := make([]utils.ZMember, 1000)
+	for i := range members {
+		members[i] = utils.ZMember{Member: fmt.Sprint("player:", i), Score: float64(i % 100)}
+	}
+	store.ZAdd("board", members, 0)
+	store.ZIncrBy("board", "player:7", 1000, 0)
+	store.Sync()
+	store.Close()
+
+	store2, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to re-create store: %v", err)
+	}
+	defer store2.Close()
+	if n, err := store2.ZCard("board"); err != nil || n != 1000 {
+		t.Errorf("ZCard() after restart = %d, %v, want 1000", n, err)
+	}
+	top, err := store2.ZRevRange("board", 0, 0)
+	expectZMembers(t, "ZRevRange() after restart", top, err, zm("player:7", 1007))
+	if dataType, _, _ := store2.Get("board"); dataType != types.ZSET {
+		t.Errorf("type after restart = %v, want Sorted Set", dataType)
+	}
+}
+
+func TestZSet_Skiplist(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	z := newZSet()
+	want := map[string]float64{}
+	for i := 0; i < 5000; i++ {
+		member := fmt.Sprint("m", rng.IntN(500))
+		if rng.IntN(3) == 0 {
+			if _, ok := want[member]; z.remove(member) != ok {
+				t.Fatalf("remove(%q) disagrees with the reference", member)
+			}
+			delete(want, member)
+			continue
+		}
+		score := float64(rng.IntN(50))
+		if _, ok := want[member]; z.set(member, score) == ok {
+			t.Fatalf("set(%q) disagrees with the reference", member)
+		}
+		want[member] = score
+	}
+
+	sorted := make([]utils.ZMember, 0, len(want))
+	for member, score := range want {
+		sorted = append(sorted, zm(member, score))
+	}
+	slices.SortFunc(sorted, func(a, b utils.ZMember) int {
+		return cmp.Or(cmp.Compare(a.Score, b.Score), cmp.Compare(a.Member, b.Member))
+	})
+	if got := z.rangeByRank(0, z.len()); !slices.Equal(got, sorted) {
+		t.Fatalf("rangeByRank() over all members = %v, want %v", got, sorted)
+	}
+	for i, m := range sorted {
+		if rank, ok := z.rank(m.Member); !ok || rank != i {
+			t.Fatalf("rank(%q) = %d, %v, want %d", m.Member, rank, ok, i)
+		}
+		if got := z.rangeByRank(i, min(i+3, len(sorted))); !slices.Equal(got, sorted[i:min(i+3, len(sorted))]) {
+			t.Fatalf("rangeByRank(%d) = %v", i, got)
+		}
+	}
+	from, to := z.scoreRange(10, 20)
+	for i, m := range sorted {
+		if inside := i >= from && i < to; inside != (m.Score >= 10 && m.Score <= 20) {
+			t.Fatalf("scoreRange(10, 20) = %d, %d, wrong for %v at %d", from, to, m, i)
+		}
+	}
+	if data := z.encode(); z.size != len(data) || !bytes.Equal(data, utils.EncodeZSet(sorted)) {
+		t.Errorf("encode() does not match the reference, size %d for %d bytes", z.size, len(data))
+	}
+}
+
+func TestZSet_LogsMemberChanges(t *testing.T) {
+	cfg := aofConfig(t)
+	store, err := NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	members := make([]utils.ZMember, 1000)
+	for i := range members {
+		members[i] = zm(fmt.Sprint("player:", i), float64(i))
+	}
+	store.ZAdd("board", members, 0)
+	before := store.aof.mark().offset
+	store.ZIncrBy("board", "player:7", 5000, time.Hour)
+	if size := store.aof.mark().offset - before; size > 64 {
+		t.Errorf("ZIncrBy() logged %d bytes, want only the changed member", size)
+	}
+	store.ZRem("board", "player:1", "player:2")
+	store.ZRemRangeByScore("board", 900, math.Inf(1))
+	store.ZAdd("gone", []utils.ZMember{zm("a", 1)}, 0)
+	store.ZRem("gone", "a")
+	want, _ := store.ZRange("board", 0, -1)
+	_, _, version, _ := store.GetWithVersion("board")
+	crash(store)
+
+	store, err = NewCacheStore(cfg)
+	if err != nil {
+		t.Fatalf("Failed to re-create store: %v", err)
+	}
+	defer store.Close()
+	got, err := store.ZRange("board", 0, -1)
+	expectZMembers(t, "ZRange() after replay", got, err, want...)
+	if _, _, v, _ := store.GetWithVersion("board"); v != version {
+		t.Errorf("version after replay = %d, want %d", v, version)
+	}
+	if ttl := store.TTL("board"); ttl <= 0 || ttl > time.Hour {
+		t.Errorf("TTL() after replay = %v, want the logged expiry", ttl)
+	}
+	if store.Exists("gone") != 0 {
+		t.Error("a sorted set emptied before the crash came back")
+	}
+}
+
+func TestZSet_Replication(t *testing.T) {
+	primary := newReplStore(t, config.Config{DBSave: false})
+	follower := newReplStore(t, config.Config{DBSave: false})
+	primary.ZAdd("board", []utils.ZMember{zm("a", 1), zm("b", 2)}, 0)
+
+	l := link(t, primary, follower)
+	l.handshake(t)
+	eventually(t, primary, follower)
+
+	primary.ZIncrBy("board", "a", 10, 0)
+	primary.ZAdd("board", []utils.ZMember{zm("c", 3)}, time.Hour)
+	primary.ZRem("board", "b")
+	primary.ZAdd("queue", []utils.ZMember{zm("job", 1)}, 0)
+	eventually(t, primary, follower)
+	members, err := follower.ZRange("board", 0, -1)
+	expectZMembers(t, "follower ZRange()", members, err, zm("c", 3), zm("a", 11))
+	if ttl := follower.TTL("board"); ttl <= 0 {
+		t.Errorf("follower TTL() = %v, want the primary's expiry", ttl)
+	}
+
+	primary.ZRemRangeByScore("queue", math.Inf(-1), math.Inf(1))
+	eventually(t, primary, follower)
+}
+
+func TestZSet_CacheFull(t *testing.T) {
+	// A member of one byte takes 17 bytes, the key and the member count 5.
+	store := newEvictionStore(t, config.Config{MaxBytes: 80})
+	if _, err := store.ZAdd("z", []utils.ZMember{zm("a", 1), zm("b", 2), zm("c", 3), zm("d", 4)}, 0); err != nil {
+		t.Fatalf("ZAdd() of 73 bytes error = %v", err)
+	}
+	want := errors.ErrEntryTooLarge("z", 90, 80)
+	if _, err := store.ZAdd("z", []utils.ZMember{zm("e", 5)}, 0); err == nil || err.Error() != want.Error() {
+		t.Errorf("ZAdd() past MaxBytes error = %v, want %v", err, want)
+	}
+	if _, err := store.ZIncrBy("z", "a", 10, 0); err != nil {
+		t.Errorf("ZIncrBy() within MaxBytes error = %v", err)
+	}
+	err := store.Update(func(tx *Tx) error {
+		if _, err := tx.ZRem("z", "b"); err != nil {
+			return err
+		}
+		return tx.Set("big", types.RAW, make([]byte, 100), 0)
+	})
+	if err == nil {
+		t.Error("Update() storing a value over MaxBytes succeeded")
+	}
+	members, err := store.ZRange("z", 0, -1)
+	expectZMembers(t, "ZRange() after the failed writes", members, err, zm("b", 2), zm("c", 3), zm("d", 4), zm("a", 11))
+	if _, err := store.ZRem("z", "d"); err != nil {
+		t.Errorf("ZRem() after the rolled back commit error = %v", err)
+	}
+}
+
+// newLargeZSet returns a store with a sorted set of n members at "board".
+func newLargeZSet(b *testing.B, n int) *CacheStore {
+	store := newZSetStore(b)
+	members := make([]utils.ZMember, n)
+	for i := range members {
+		members[i] = utils.ZMember{Member: fmt.Sprint("player:", i), Score: float64(i)}
+	}
+	if _, err := store.ZAdd("board", members, 0); err != nil {
+		b.Fatalf("ZAdd() error = %v", err)
+	}
+	return store
+}
+
+func BenchmarkZSet_Rank100k(b *testing.B) {
+	store := newLargeZSet(b, 100_000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.ZRank("board", fmt.Sprint("player:", i%100_000)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkZSet_Top10Of100k(b *testing.B) {
+	store := newLargeZSet(b, 100_000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.ZRevRange("board", 0, 9); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkZSet_IncrBy100k(b *testing.B) {
+	store := newLargeZSet(b, 100_000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.ZIncrBy("board", fmt.Sprint("player:", i%100_000), 1, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"time"
+
+	"github.com/found-cake/CacheStore/entry"
+	"github.com/found-cake/CacheStore/errors"
+)
+
+// Typed is a type-safe view over a CacheStore. Every value written through it
+// is encoded with its Codec and every read checks the stored DataType before
+// decoding, so callers never deal with raw bytes.
+type Typed[T any] struct {
+	store *CacheStore
+	codec Codec[T]
+}
+
+func NewTyped[T any](store *CacheStore, codec Codec[T]) *Typed[T] {
+	return &Typed[T]{
+		store: store,
+		codec: codec,
+	}
+}
+
+type TypedItem[T any] struct {
+	Key    string
+	Value  T
+	Expiry time.Duration
+}
+
+type TypedResult[T any] struct {
+	Key   string
+	Value T
+	Error error
+}
+
+func (t *Typed[T]) decode(key string, e entry.Entry) (T, error) {
+	if e.Type != t.codec.DataType() {
+		var zero T
+		return zero, errors.ErrTypeMismatch(key, t.codec.DataType(), e.Type)
+	}
+	return t.codec.Decode(e.Data)
+}
+
+func (t *Typed[T]) Get(key string) (T, error) {
+	var zero T
+	dataType, data, err := t.store.Get(key)
+	if err != nil {
+		return zero, err
+	}
+	return t.decode(key, entry.Entry{Type: dataType, Data: data})
+}
+
+func (t *Typed[T]) Set(key string, value T, exp time.Duration) error {
+	data, err := t.codec.Encode(value)
+	if err != nil {
+		return err
+	}
+	return t.store.Set(key, t.codec.DataType(), data, exp)
+}
+
+// GetOrSet returns the stored value when key exists, otherwise it stores
+// value. Both steps happen under the same lock. loaded reports whether the
+// returned value was already in the cache.
+func (t *Typed[T]) GetOrSet(key string, value T, exp time.Duration) (actual T, loaded bool, err error) {
+	if key == "" {
+		return actual, false, errors.ErrKeyEmpty
+	}
+	data, err := t.codec.Encode(value)
+	if err != nil {
+		return actual, false, err
+	}
+	if data == nil {
+		return actual, false, errors.ErrValueNil
+	}
+	if !t.codec.DataType().IsKnown() {
+		return actual, false, errors.ErrUnknownDataType(t.codec.DataType())
+	}
+	s := t.store
+	if s.readOnly.Load() {
+		return actual, false, errors.ErrReadOnly
+	}
+
+	sh := s.shardFor(key)
+	sh.mux.Lock()
+	defer sh.mux.Unlock()
+	if e, err := sh.unsafeGet(key); err == nil {
+		actual, err = t.decode(key, e)
+		return actual, true, err
+	}
+	if err := s.unsafeSet(sh, key, t.codec.DataType(), data, exp); err != nil {
+		return actual, false, err
+	}
+	return value, false, nil
+}
+
+func (t *Typed[T]) MGet(keys ...string) []TypedResult[T] {
+	batch := t.store.MGet(keys...)
+	if batch == nil {
+		return nil
+	}
+	results := make([]TypedResult[T], len(batch))
+	for i, r := range batch {
+		results[i].Key = r.Key
+		if r.Error != nil {
+			results[i].Error = r.Error
+			continue
+		}
+		results[i].Value, results[i].Error = t.decode(r.Key, entry.Entry{Type: r.Type, Data: r.Value})
+	}
+	return results
+}
+
+// MSet encodes every item and writes the encodable ones in a single MSet.
+// The returned errors are index-aligned with items.
+func (t *Typed[T]) MSet(items ...TypedItem[T]) []error {
+	if len(items) == 0 {
+		return nil
+	}
+	errs := make([]error, len(items))
+	batch := make([]BatchItem, 0, len(items))
+	index := make([]int, 0, len(items))
+	for i, item := range items {
+		data, err := t.codec.Encode(item.Value)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		batch = append(batch, NewItem(item.Key, t.codec.DataType(), data, item.Expiry))
+		index = append(index, i)
+	}
+	for j, err := range t.store.MSet(batch...) {
+		errs[index[j]] = err
+	}
+	return errs
+}
+
+func (t *Typed[T]) Delete(key string) error {
+	return t.store.Delete(key)
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// Codec converts values of type T to and from the binary representation
+// stored in the cache under a single DataType.
+type Codec[T any] interface {
+	DataType() types.DataType
+	Encode(value T) ([]byte, error)
+	Decode(data []byte) (T, error)
+}
+
+type funcCodec[T any] struct {
+	dataType types.DataType
+	encode   func(T) ([]byte, error)
+	decode   func([]byte) (T, error)
+}
+
+func (c funcCodec[T]) DataType() types.DataType {
+	return c.dataType
+}
+
+func (c funcCodec[T]) Encode(value T) ([]byte, error) {
+	return c.encode(value)
+}
+
+func (c funcCodec[T]) Decode(data []byte) (T, error) {
+	return c.decode(data)
+}
+
+// NewCodec builds a Codec from a pair of functions.
+func NewCodec[T any](dataType types.DataType, encode func(T) ([]byte, error), decode func([]byte) (T, error)) Codec[T] {
+	return funcCodec[T]{
+		dataType: dataType,
+		encode:   encode,
+		decode:   decode,
+	}
+}
+
+func infallible[T any](encode func(T) []byte) func(T) ([]byte, error) {
+	return func(value T) ([]byte, error) {
+		return encode(value), nil
+	}
+}
+
+// Codecs for the built-in data types. They use the same encodings as the
+// typed Get/Set methods of CacheStore, so both APIs can be mixed freely.
+var (
+	RawCodec = NewCodec(types.RAW,
+		func(value []byte) ([]byte, error) {
+			if value == nil {
+				return nil, errors.ErrValueNil
+			}
+			return value, nil
+		},
+		func(data []byte) ([]byte, error) {
+			result := make([]byte, len(data))
+			copy(result, data)
+			return result, nil
+		},
+	)
+	StringCodec = NewCodec(types.STRING,
+		func(value string) ([]byte, error) { return []byte(value), nil },
+		func(data []byte) (string, error) { return string(data), nil },
+	)
+	BoolCodec = NewCodec(types.BOOLEAN,
+		func(value bool) ([]byte, error) {
+			if value {
+				return []byte{1}, nil
+			}
+			return []byte{0}, nil
+		},
+		func(data []byte) (bool, error) { return len(data) > 0 && data[0] == 1, nil },
+	)
+	Int16Codec   = NewCodec(types.INT16, infallible(utils.Int16toBinary), utils.Binary2Int16)
+	Int32Codec   = NewCodec(types.INT32, infallible(utils.Int32toBinary), utils.Binary2Int32)
+	Int64Codec   = NewCodec(types.INT64, infallible(utils.Int64toBinary), utils.Binary2Int64)
+	UInt16Codec  = NewCodec(types.UINT16, infallible(utils.UInt16toBinary), utils.Binary2UInt16)
+	UInt32Codec  = NewCodec(types.UINT32, infallible(utils.UInt32toBinary), utils.Binary2UInt32)
+	UInt64Codec  = NewCodec(types.UINT64, infallible(utils.UInt64toBinary), utils.Binary2UInt64)
+	Float32Codec = NewCodec(types.FLOAT32, infallible(utils.Float32toBinary), utils.Binary2Float32)
+	Float64Codec = NewCodec(types.FLOAT64, infallible(utils.Float64toBinary), utils.Binary2Float64)
+	TimeCodec    = NewCodec(types.TIME,
+		func(value time.Time) ([]byte, error) { return value.MarshalBinary() },
+		func(data []byte) (time.Time, error) {
+			var t time.Time
+			err := t.UnmarshalBinary(data)
+			return t, err
+		},
+	)
+)
+
+// HashCodec stores field maps as HASH values, see HSet.
+var HashCodec = NewCodec(types.HASH, infallible(utils.EncodeHash), utils.DecodeHash)
+
+// ListCodec stores slices as LIST values, see RPush.
+var ListCodec = NewCodec(types.LIST, infallible(utils.EncodeList), utils.DecodeList)
+
+// SetCodec stores members as SET values, see SAdd. Duplicates are dropped
+// and members are read back sorted.
+var SetCodec = NewCodec(types.SET, infallible(utils.EncodeSet), utils.DecodeSet)
+
+// ZSetCodec stores scored members as ZSET values, see ZAdd. Members are read
+// back by score.
+var ZSetCodec = NewCodec(types.ZSET, infallible(utils.EncodeZSet), utils.DecodeZSet)
+
+// JSONCodec stores values of type T as JSON documents.
+func JSONCodec[T any]() Codec[T] {
+	return NewCodec(types.JSON,
+		func(value T) ([]byte, error) { return json.Marshal(value) },
+		func(data []byte) (T, error) {
+			var value T
+			err := json.Unmarshal(data, &value)
+			return value, err
+		},
+	)
+}
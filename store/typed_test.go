@@ -0,0 +1,151 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/config"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+type typedProfile struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestTyped_GetSet(t *testing.T) {
+	store, err := NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	ints := NewTyped(store, Int64Codec)
+	if err := ints.Set("counter", 42, time.Hour); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if v, err := ints.Get("counter"); err != nil || v != 42 {
+		t.Errorf("Get() = %v, %v, want 42", v, err)
+	}
+	if v, err := store.GetInt64("counter"); err != nil || v != 42 {
+		t.Errorf("GetInt64() = %v, %v, want 42", v, err)
+	}
+
+	store.SetString("name", "alice", 0)
+	if _, err := ints.Get("name"); err == nil {
+		t.Error("Get() expected type mismatch error")
+	}
+	if _, err := ints.Get("missing"); err == nil {
+		t.Error("Get() expected missing key error")
+	}
+
+	profiles := NewTyped(store, JSONCodec[typedProfile]())
+	want := typedProfile{Name: "bob", Age: 30}
+	if err := profiles.Set("profile", want, 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	var viaStore typedProfile
+	if err := store.GetJSON("profile", &viaStore); err != nil || viaStore != want {
+		t.Errorf("GetJSON() = %+v, %v", viaStore, err)
+	}
+	if got, err := profiles.Get("profile"); err != nil || got != want {
+		t.Errorf("Get() = %+v, %v", got, err)
+	}
+
+	times := NewTyped(store, TimeCodec)
+	now := time.Now()
+	times.Set("now", now, 0)
+	if got, err := times.Get("now"); err != nil || !got.Equal(now) {
+		t.Errorf("Get() = %v, %v, want %v", got, err, now)
+	}
+
+	if err := ints.Delete("counter"); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if store.Exists("counter") != 0 {
+		t.Error("counter should be deleted")
+	}
+}
+
+func TestTyped_GetOrSet(t *testing.T) {
+	store, err := NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	strs := NewTyped(store, StringCodec)
+	v, loaded, err := strs.GetOrSet("k", "first", 0)
+	if err != nil || loaded || v != "first" {
+		t.Errorf("GetOrSet() = %q, %v, %v, want first, false", v, loaded, err)
+	}
+	v, loaded, err = strs.GetOrSet("k", "second", 0)
+	if err != nil || !loaded || v != "first" {
+		t.Errorf("GetOrSet() = %q, %v, %v, want first, true", v, loaded, err)
+	}
+
+	store.SetBool("flag", true, 0)
+	if _, _, err := strs.GetOrSet("flag", "x", 0); err == nil {
+		t.Error("GetOrSet() expected type mismatch error")
+	}
+	if _, _, err := strs.GetOrSet("", "x", 0); err == nil {
+		t.Error("GetOrSet() expected empty key error")
+	}
+
+	unknown := NewTyped(store, NewCodec(types.DataType(255), StringCodec.Encode, StringCodec.Decode))
+	if _, _, err := unknown.GetOrSet("u", "x", 0); err == nil {
+		t.Error("GetOrSet() expected unknown data type error")
+	}
+	if store.Exists("u") != 0 {
+		t.Error("GetOrSet() stored a value of unknown type")
+	}
+}
+
+func TestTyped_Batch(t *testing.T) {
+	store, err := NewCacheStore(config.Config{DBSave: false, Shards: 4})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	floats := NewTyped(store, Float64Codec)
+	errs := floats.MSet(
+		TypedItem[float64]{Key: "a", Value: 1.5},
+		TypedItem[float64]{Key: "", Value: 2},
+		TypedItem[float64]{Key: "c", Value: 3.25, Expiry: time.Hour},
+	)
+	if errs[0] != nil || errs[1] == nil || errs[2] != nil {
+		t.Errorf("MSet() errors = %v", errs)
+	}
+
+	store.Set("raw", types.RAW, []byte{1, 2}, 0)
+	results := floats.MGet("a", "c", "missing", "raw")
+	if results[0].Value != 1.5 || results[0].Error != nil {
+		t.Errorf("MGet()[0] = %+v", results[0])
+	}
+	if results[1].Value != 3.25 || results[1].Error != nil {
+		t.Errorf("MGet()[1] = %+v", results[1])
+	}
+	if results[2].Error == nil || results[3].Error == nil {
+		t.Errorf("MGet() expected errors for missing and mismatched keys: %+v", results[2:])
+	}
+}
+
+func TestTyped_RawCodecCopies(t *testing.T) {
+	store, err := NewCacheStore(config.Config{DBSave: false})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	raws := NewTyped(store, RawCodec)
+	if err := raws.Set("nil", nil, 0); err == nil {
+		t.Error("Set() expected error for nil value")
+	}
+	raws.Set("bytes", []byte{1, 2, 3}, 0)
+	got, _ := raws.Get("bytes")
+	got[0] = 9
+	if again, _ := raws.Get("bytes"); again[0] != 1 {
+		t.Error("Get() must return a copy")
+	}
+}
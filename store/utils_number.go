@@ -10,24 +10,66 @@ import (
 	"github.com/found-cake/CacheStore/utils/types"
 )
 
-func (s *CacheStore) setKeepExp(key string, dataType types.DataType, value []byte, expiry int64) {
-	s.memorydb[key] = entry.Entry{
-		Type:   dataType,
-		Data:   value,
-		Expiry: expiry,
+// entryModifier is the target of read-modify-write operations: the store
+// itself, which locks the shard of key, or a transaction.
+type entryModifier interface {
+	modify(key string, fn func(old entry.Entry, err error) (entry.Entry, error)) error
+	// modifyOrDelete is modify where fn returns nil to delete key.
+	modifyOrDelete(key string, fn func(old entry.Entry, err error) (*entry.Entry, error)) error
+}
+
+// modify passes the current entry of key, or the lookup error, to fn and
+// stores what it returns. Nothing is written when fn fails.
+func (s *CacheStore) modify(key string, fn func(old entry.Entry, err error) (entry.Entry, error)) error {
+	return s.modifyOrDelete(key, func(old entry.Entry, err error) (*entry.Entry, error) {
+		e, err := fn(old, err)
+		if err != nil {
+			return nil, err
+		}
+		return &e, nil
+	})
+}
+
+func (s *CacheStore) modifyOrDelete(key string, fn func(old entry.Entry, err error) (*entry.Entry, error)) error {
+	if key == "" {
+		return errors.ErrKeyEmpty
 	}
-	if s.dirty != nil {
-		s.dirty.set(key)
+	if s.readOnly.Load() {
+		return errors.ErrReadOnly
+	}
+	sh := s.shardFor(key)
+	sh.mux.Lock()
+	defer sh.mux.Unlock()
+	e, err := fn(sh.unsafeGet(key))
+	if err != nil {
+		return err
+	}
+	if e == nil {
+		s.unsafeDelete(sh, key)
+		return nil
+	}
+	return s.unsafeSetEntry(sh, key, *e)
+}
+
+// keepExp replaces the value of old while keeping its expiry deadlines, unless
+// exp is positive, in which case the entry expires after exp instead.
+func keepExp(old entry.Entry, dataType types.DataType, value []byte, exp time.Duration) entry.Entry {
+	if exp > 0 {
+		return entry.NewEntry(dataType, value, exp)
 	}
+	old.Type = dataType
+	old.Data = value
+	return old
 }
 
 func (s *CacheStore) getNum16(key string, expected types.DataType) (uint16, error) {
 	if key == "" {
 		return 0, errors.ErrKeyEmpty
 	}
-	s.mux.RLock()
-	defer s.mux.RUnlock()
-	e, err := s.unsafeGet(key)
+	sh := s.shardFor(key)
+	sh.mux.RLock()
+	defer sh.mux.RUnlock()
+	e, err := sh.unsafeGet(key)
 	if err != nil {
 		return 0, err
 	}
@@ -41,9 +83,10 @@ func (s *CacheStore) getNum32(key string, expected types.DataType) (uint32, erro
 	if key == "" {
 		return 0, errors.ErrKeyEmpty
 	}
-	s.mux.RLock()
-	defer s.mux.RUnlock()
-	e, err := s.unsafeGet(key)
+	sh := s.shardFor(key)
+	sh.mux.RLock()
+	defer sh.mux.RUnlock()
+	e, err := sh.unsafeGet(key)
 	if err != nil {
 		return 0, err
 	}
@@ -57,9 +100,10 @@ func (s *CacheStore) getNum64(key string, expected types.DataType) (uint64, erro
 	if key == "" {
 		return 0, errors.ErrKeyEmpty
 	}
-	s.mux.RLock()
-	defer s.mux.RUnlock()
-	e, err := s.unsafeGet(key)
+	sh := s.shardFor(key)
+	sh.mux.RLock()
+	defer sh.mux.RUnlock()
+	e, err := sh.unsafeGet(key)
 	if err != nil {
 		return 0, err
 	}
@@ -70,7 +114,7 @@ func (s *CacheStore) getNum64(key string, expected types.DataType) (uint64, erro
 }
 
 func incrNumber[T generic.Numberic](
-	s *CacheStore,
+	kv entryModifier,
 	key string,
 	delta T,
 	data_type types.DataType,
@@ -80,36 +124,25 @@ func incrNumber[T generic.Numberic](
 	checkOverFlow func(T, T) bool,
 	checkFloatSpesial func(T) bool,
 ) error {
-	if key == "" {
-		return errors.ErrKeyEmpty
-	}
-	s.mux.Lock()
-	defer s.mux.Unlock()
-	e, err := s.unsafeGet(key)
-	if err != nil {
-		data := toBinary(delta)
-		s.unsafeSet(key, data_type, data, exp)
-		return nil
-	}
-	if e.Type != data_type {
-		return errors.ErrTypeMismatch(key, data_type, e.Type)
-	}
-	value, err := fromBinary(e.Data)
-	if err != nil {
-		return err
-	}
-	if checkOverFlow(value, delta) {
-		return errors.ErrValueOverflow(key, data_type, value, delta)
-	}
-	value += delta
-	data := toBinary(value)
-	if checkFloatSpesial != nil && checkFloatSpesial(value) {
-		return errors.ErrFloatSpecial
-	}
-	if exp > 0 {
-		s.unsafeSet(key, data_type, data, exp)
-	} else {
-		s.setKeepExp(key, data_type, data, e.Expiry)
-	}
-	return nil
+	return kv.modify(key, func(e entry.Entry, err error) (entry.Entry, error) {
+		if err != nil {
+			return entry.NewEntry(data_type, toBinary(delta), exp), nil
+		}
+		if e.Type != data_type {
+			return e, errors.ErrTypeMismatch(key, data_type, e.Type)
+		}
+		value, err := fromBinary(e.Data)
+		if err != nil {
+			return e, err
+		}
+		if checkOverFlow(value, delta) {
+			return e, errors.ErrValueOverflow(key, data_type, value, delta)
+		}
+		value += delta
+		data := toBinary(value)
+		if checkFloatSpesial != nil && checkFloatSpesial(value) {
+			return e, errors.ErrFloatSpecial
+		}
+		return keepExp(e, data_type, data, exp), nil
+	})
 }
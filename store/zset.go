@@ -0,0 +1,285 @@
+package store
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"sync"
+
+	"github.com/found-cake/CacheStore/utils"
+)
+
+// zsetMaxLevel bounds the skiplist height, enough for 2^32 members at the
+// level probability of 1/4.
+const zsetMaxLevel = 16
+
+// zset is the live form of a sorted set, as in Redis: a map from members to
+// scores, and a skiplist ordered by score, then member, whose links count the
+// nodes they skip so that ranks take O(log n) too. It is only encoded, see
+// utils.EncodeZSet, when its bytes are read, persisted or replicated.
+//
+// It is guarded by the lock of its shard. Readers holding that lock for
+// reading may share the cached encoding, which has a lock of its own.
+type zset struct {
+	scores map[string]float64
+	head   *zsetNode
+	level  int
+	// size is the length of the encoded form.
+	size int
+
+	mux     sync.Mutex
+	encoded []byte
+}
+
+type zsetNode struct {
+	member string
+	score  float64
+	next   []zsetLink
+}
+
+type zsetLink struct {
+	node *zsetNode
+	// span is the number of nodes the link moves forward, the difference
+	// between their ranks.
+	span int
+}
+
+func newZSet() *zset {
+	return &zset{
+		scores: make(map[string]float64),
+		head:   &zsetNode{next: make([]zsetLink, zsetMaxLevel)},
+		level:  1,
+		size:   4,
+	}
+}
+
+// decodeZSet builds the live form of an encoded sorted set.
+func decodeZSet(data []byte) (*zset, error) {
+	members, err := utils.DecodeZSet(data)
+	if err != nil {
+		return nil, err
+	}
+	z := newZSet()
+	for _, m := range members {
+		z.set(m.Member, m.Score)
+	}
+	if len(data) == z.size {
+		z.encoded = data
+	}
+	return z, nil
+}
+
+// encode returns the encoded form, which is kept until the next change and
+// must not be modified.
+func (z *zset) encode() []byte {
+	z.mux.Lock()
+	defer z.mux.Unlock()
+	if z.encoded == nil {
+		z.encoded = utils.EncodeZSet(z.rangeByRank(0, z.len()))
+	}
+	return z.encoded
+}
+
+func (z *zset) len() int {
+	return len(z.scores)
+}
+
+// before reports whether n sorts before score and member.
+func (n *zsetNode) before(score float64, member string) bool {
+	return cmp.Or(cmp.Compare(n.score, score), cmp.Compare(n.member, member)) < 0
+}
+
+func zsetRandomLevel() int {
+	level := 1
+	for level < zsetMaxLevel && rand.IntN(4) == 0 {
+		level++
+	}
+	return level
+}
+
+// memberSize is what a member adds to the encoded form: two index entries
+// and a record holding its score.
+func memberSize(member string) int {
+	return 16 + len(member)
+}
+
+func (z *zset) score(member string) (float64, bool) {
+	score, ok := z.scores[member]
+	return score, ok
+}
+
+// set adds member or changes its score, and reports whether it is new.
+func (z *zset) set(member string, score float64) bool {
+	old, ok := z.scores[member]
+	if ok {
+		if old == score {
+			return false
+		}
+		z.unlink(member, old)
+	} else {
+		z.size += memberSize(member)
+	}
+	z.scores[member] = score
+	z.link(member, score)
+	z.encoded = nil
+	return !ok
+}
+
+// remove removes member and reports whether it existed.
+func (z *zset) remove(member string) bool {
+	score, ok := z.scores[member]
+	if !ok {
+		return false
+	}
+	z.unlink(member, score)
+	delete(z.scores, member)
+	z.size -= memberSize(member)
+	z.encoded = nil
+	return true
+}
+
+func (z *zset) link(member string, score float64) {
+	var update [zsetMaxLevel]*zsetNode
+	var rank [zsetMaxLevel]int
+	x := z.head
+	for i := z.level - 1; i >= 0; i-- {
+		if i < z.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.next[i].node != nil && x.next[i].node.before(score, member) {
+			rank[i] += x.next[i].span
+			x = x.next[i].node
+		}
+		update[i] = x
+	}
+
+	level := zsetRandomLevel()
+	for i := z.level; i < level; i++ {
+		update[i] = z.head
+		z.head.next[i].span = z.len() - 1
+	}
+	z.level = max(z.level, level)
+
+	n := &zsetNode{member: member, score: score, next: make([]zsetLink, level)}
+	for i := 0; i < level; i++ {
+		n.next[i].node = update[i].next[i].node
+		update[i].next[i].node = n
+		n.next[i].span = update[i].next[i].span - (rank[0] - rank[i])
+		update[i].next[i].span = rank[0] - rank[i] + 1
+	}
+	for i := level; i < z.level; i++ {
+		update[i].next[i].span++
+	}
+}
+
+func (z *zset) unlink(member string, score float64) {
+	var update [zsetMaxLevel]*zsetNode
+	x := z.head
+	for i := z.level - 1; i >= 0; i-- {
+		for x.next[i].node != nil && x.next[i].node.before(score, member) {
+			x = x.next[i].node
+		}
+		update[i] = x
+	}
+	n := x.next[0].node
+	for i := 0; i < z.level; i++ {
+		if update[i].next[i].node == n {
+			update[i].next[i].span += n.next[i].span - 1
+			update[i].next[i].node = n.next[i].node
+		} else {
+			update[i].next[i].span--
+		}
+	}
+	for z.level > 1 && z.head.next[z.level-1].node == nil {
+		z.level--
+	}
+}
+
+// rank returns the rank of member, counting from 0 at the lowest score.
+func (z *zset) rank(member string) (int, bool) {
+	score, ok := z.scores[member]
+	if !ok {
+		return 0, false
+	}
+	rank := 0
+	x := z.head
+	for i := z.level - 1; i >= 0; i-- {
+		for x.next[i].node != nil && x.next[i].node.before(score, member) {
+			rank += x.next[i].span
+			x = x.next[i].node
+		}
+	}
+	return rank, true
+}
+
+// scoreRank returns the number of members scoring below score, or at most
+// score when orEqual is true.
+func (z *zset) scoreRank(score float64, orEqual bool) int {
+	rank := 0
+	x := z.head
+	for i := z.level - 1; i >= 0; i-- {
+		for n := x.next[i].node; n != nil && (n.score < score || orEqual && n.score == score); n = x.next[i].node {
+			rank += x.next[i].span
+			x = n
+		}
+	}
+	return rank
+}
+
+// rangeByRank returns the members ranked from start to stop, stop excluded.
+// The range must be within bounds.
+func (z *zset) rangeByRank(start, stop int) []utils.ZMember {
+	members := make([]utils.ZMember, 0, stop-start)
+	if start == stop {
+		return members
+	}
+	// Walk down to the node ranked start, counting ranks from 1.
+	traversed := 0
+	x := z.head
+	for i := z.level - 1; i >= 0 && traversed <= start; i-- {
+		for x.next[i].node != nil && traversed+x.next[i].span <= start+1 {
+			traversed += x.next[i].span
+			x = x.next[i].node
+		}
+	}
+	for ; len(members) < stop-start; x = x.next[0].node {
+		members = append(members, utils.ZMember{Member: x.member, Score: x.score})
+	}
+	return members
+}
+
+// scoreRange returns the ranks of the members scoring from minScore to
+// maxScore as slice bounds.
+func (z *zset) scoreRange(minScore, maxScore float64) (int, int) {
+	from := z.scoreRank(minScore, false)
+	return from, max(from, z.scoreRank(maxScore, true))
+}
+
+// apply sets upserts, then removes removes, and returns a func undoing both.
+func (z *zset) apply(upserts []utils.ZMember, removes []string) (undo func()) {
+	type prior struct {
+		member string
+		score  float64
+		ok     bool
+	}
+	priors := make([]prior, 0, len(upserts)+len(removes))
+	for _, m := range upserts {
+		score, ok := z.scores[m.Member]
+		priors = append(priors, prior{m.Member, score, ok})
+		z.set(m.Member, m.Score)
+	}
+	for _, member := range removes {
+		if score, ok := z.scores[member]; ok {
+			priors = append(priors, prior{member, score, true})
+			z.remove(member)
+		}
+	}
+	return func() {
+		for i := len(priors) - 1; i >= 0; i-- {
+			if p := priors[i]; p.ok {
+				z.set(p.member, p.score)
+			} else {
+				z.remove(p.member)
+			}
+		}
+	}
+}
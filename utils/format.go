@@ -0,0 +1,240 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/found-cake/CacheStore/errors"
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+// FormatValue renders stored data as text: numbers in decimal, booleans as
+// true/false, times in RFC 3339, strings and JSON documents as they are.
+// Hashes are JSON objects mapping fields to their values as strings, lists
+// and sets JSON arrays of strings, and sorted sets JSON arrays of
+// {"member": ..., "score": ...} objects by score. RAW and user defined types, which have no
+// textual form, are base64 encoded.
+func FormatValue(dataType types.DataType, data []byte) (string, error) {
+	switch dataType {
+	case types.BOOLEAN:
+		return strconv.FormatBool(len(data) > 0 && data[0] == 1), nil
+	case types.INT16:
+		v, err := Binary2Int16(data)
+		return strconv.FormatInt(int64(v), 10), err
+	case types.INT32:
+		v, err := Binary2Int32(data)
+		return strconv.FormatInt(int64(v), 10), err
+	case types.INT64:
+		v, err := Binary2Int64(data)
+		return strconv.FormatInt(v, 10), err
+	case types.UINT16:
+		v, err := Binary2UInt16(data)
+		return strconv.FormatUint(uint64(v), 10), err
+	case types.UINT32:
+		v, err := Binary2UInt32(data)
+		return strconv.FormatUint(uint64(v), 10), err
+	case types.UINT64:
+		v, err := Binary2UInt64(data)
+		return strconv.FormatUint(v, 10), err
+	case types.FLOAT32:
+		v, err := Binary2Float32(data)
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), err
+	case types.FLOAT64:
+		v, err := Binary2Float64(data)
+		return strconv.FormatFloat(v, 'g', -1, 64), err
+	case types.STRING, types.JSON:
+		return string(data), nil
+	case types.TIME:
+		var t time.Time
+		if err := t.UnmarshalBinary(data); err != nil {
+			return "", err
+		}
+		return t.Format(time.RFC3339Nano), nil
+	case types.HASH:
+		fields, err := DecodeHash(data)
+		if err != nil {
+			return "", err
+		}
+		text := make(map[string]string, len(fields))
+		for field, value := range fields {
+			text[field] = string(value)
+		}
+		b, err := json.Marshal(text)
+		return string(b), err
+	case types.LIST:
+		elems, err := DecodeList(data)
+		if err != nil {
+			return "", err
+		}
+		text := make([]string, len(elems))
+		for i, elem := range elems {
+			text[i] = string(elem)
+		}
+		b, err := json.Marshal(text)
+		return string(b), err
+	case types.SET:
+		members, err := DecodeSet(data)
+		if err != nil {
+			return "", err
+		}
+		b, err := json.Marshal(members)
+		return string(b), err
+	case types.ZSET:
+		members, err := DecodeZSet(data)
+		if err != nil {
+			return "", err
+		}
+		text := make([]jsonZMember, len(members))
+		for i, m := range members {
+			text[i] = jsonZMember{m.Member, jsonScore(m.Score)}
+		}
+		b, err := json.Marshal(text)
+		return string(b), err
+	default:
+		if !dataType.IsKnown() {
+			return "", errors.ErrUnknownDataType(dataType)
+		}
+		return base64.StdEncoding.EncodeToString(data), nil
+	}
+}
+
+// ParseValue is the inverse of FormatValue. It validates text against
+// dataType, so out of range numbers and malformed JSON are rejected.
+func ParseValue(dataType types.DataType, text string) ([]byte, error) {
+	switch dataType {
+	case types.BOOLEAN:
+		v, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, err
+		}
+		if v {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case types.INT16:
+		v, err := strconv.ParseInt(text, 10, 16)
+		return Int16toBinary(int16(v)), err
+	case types.INT32:
+		v, err := strconv.ParseInt(text, 10, 32)
+		return Int32toBinary(int32(v)), err
+	case types.INT64:
+		v, err := strconv.ParseInt(text, 10, 64)
+		return Int64toBinary(v), err
+	case types.UINT16:
+		v, err := strconv.ParseUint(text, 10, 16)
+		return UInt16toBinary(uint16(v)), err
+	case types.UINT32:
+		v, err := strconv.ParseUint(text, 10, 32)
+		return UInt32toBinary(uint32(v)), err
+	case types.UINT64:
+		v, err := strconv.ParseUint(text, 10, 64)
+		return UInt64toBinary(v), err
+	case types.FLOAT32:
+		v, err := strconv.ParseFloat(text, 32)
+		if err == nil && CheckFloat32Special(float32(v)) {
+			err = errors.ErrFloatSpecial
+		}
+		return Float32toBinary(float32(v)), err
+	case types.FLOAT64:
+		v, err := strconv.ParseFloat(text, 64)
+		if err == nil && CheckFloat64Special(v) {
+			err = errors.ErrFloatSpecial
+		}
+		return Float64toBinary(v), err
+	case types.STRING:
+		return []byte(text), nil
+	case types.JSON:
+		if !json.Valid([]byte(text)) {
+			return nil, errors.ErrInvalidJSON
+		}
+		return []byte(text), nil
+	case types.TIME:
+		t, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return nil, err
+		}
+		return t.MarshalBinary()
+	case types.HASH:
+		var values map[string]string
+		if err := json.Unmarshal([]byte(text), &values); err != nil {
+			return nil, err
+		}
+		fields := make(map[string][]byte, len(values))
+		for field, value := range values {
+			fields[field] = []byte(value)
+		}
+		return EncodeHash(fields), nil
+	case types.LIST:
+		var values []string
+		if err := json.Unmarshal([]byte(text), &values); err != nil {
+			return nil, err
+		}
+		elems := make([][]byte, len(values))
+		for i, value := range values {
+			elems[i] = []byte(value)
+		}
+		return EncodeList(elems), nil
+	case types.SET:
+		var members []string
+		if err := json.Unmarshal([]byte(text), &members); err != nil {
+			return nil, err
+		}
+		return EncodeSet(members), nil
+	case types.ZSET:
+		var values []jsonZMember
+		if err := json.Unmarshal([]byte(text), &values); err != nil {
+			return nil, err
+		}
+		members := make([]ZMember, len(values))
+		for i, m := range values {
+			members[i] = ZMember{m.Member, float64(m.Score)}
+		}
+		return EncodeZSet(members), nil
+	default:
+		if !dataType.IsKnown() {
+			return nil, errors.ErrUnknownDataType(dataType)
+		}
+		return base64.StdEncoding.DecodeString(text)
+	}
+}
+
+// jsonZMember is the JSON form of a sorted set member.
+type jsonZMember struct {
+	Member string    `json:"member"`
+	Score  jsonScore `json:"score"`
+}
+
+// jsonScore is a score in JSON. Infinite scores, which are no JSON numbers,
+// are written as the strings "inf" and "-inf".
+type jsonScore float64
+
+func (s jsonScore) MarshalJSON() ([]byte, error) {
+	switch {
+	case math.IsInf(float64(s), 1):
+		return []byte(`"inf"`), nil
+	case math.IsInf(float64(s), -1):
+		return []byte(`"-inf"`), nil
+	}
+	return json.Marshal(float64(s))
+}
+
+func (s *jsonScore) UnmarshalJSON(b []byte) error {
+	var f float64
+	if err := json.Unmarshal(b, &f); err == nil {
+		*s = jsonScore(f)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(b, &text); err != nil {
+		return err
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil || !math.IsInf(f, 0) {
+		return errors.ErrScoreNaN
+	}
+	*s = jsonScore(f)
+	return nil
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/found-cake/CacheStore/utils/types"
+)
+
+func TestFormatParseValue_RoundTrip(t *testing.T) {
+	now, _ := time.Now().MarshalBinary()
+	tests := []struct {
+		dataType types.DataType
+		data     []byte
+		text     string
+	}{
+		{types.BOOLEAN, []byte{1}, "true"},
+		{types.INT16, Int16toBinary(-12), "-12"},
+		{types.INT32, Int32toBinary(1 << 20), "1048576"},
+		{types.INT64, Int64toBinary(-1 << 40), "-1099511627776"},
+		{types.UINT16, UInt16toBinary(65535), "65535"},
+		{types.UINT32, UInt32toBinary(7), "7"},
+		{types.UINT64, UInt64toBinary(1 << 63), "9223372036854775808"},
+		{types.FLOAT32, Float32toBinary(1.5), "1.5"},
+		{types.FLOAT64, Float64toBinary(-0.25), "-0.25"},
+		{types.STRING, []byte("hello, world"), "hello, world"},
+		{types.JSON, []byte(`{"a":[1,2]}`), `{"a":[1,2]}`},
+		{types.RAW, []byte{0, 255, 10}, "AP8K"},
+		{types.TIME, now, ""},
+		{types.HASH, EncodeHash(map[string][]byte{"name": []byte("alice"), "age": []byte("30")}), `{"age":"30","name":"alice"}`},
+		{types.LIST, EncodeList([][]byte{[]byte("job-1"), []byte(""), []byte("job-2")}), `["job-1","","job-2"]`},
+		{types.SET, EncodeSet([]string{"go", "db"}), `["db","go"]`},
+		{types.ZSET, EncodeZSet([]ZMember{{"bob", 2.5}, {"alice", math.Inf(1)}, {"carol", -1}}), `[{"member":"carol","score":-1},{"member":"bob","score":2.5},{"member":"alice","score":"inf"}]`},
+	}
+	for _, tt := range tests {
+		text, err := FormatValue(tt.dataType, tt.data)
+		if err != nil {
+			t.Errorf("FormatValue(%s) error = %v", tt.dataType, err)
+			continue
+		}
+		if tt.text != "" && text != tt.text {
+			t.Errorf("FormatValue(%s) = %q, want %q", tt.dataType, text, tt.text)
+		}
+		data, err := ParseValue(tt.dataType, text)
+		if err != nil {
+			t.Errorf("ParseValue(%s, %q) error = %v", tt.dataType, text, err)
+			continue
+		}
+		if tt.dataType == types.TIME {
+			var want, got time.Time
+			want.UnmarshalBinary(tt.data)
+			got.UnmarshalBinary(data)
+			if !got.Equal(want) {
+				t.Errorf("time round trip = %v, want %v", got, want)
+			}
+			continue
+		}
+		if !bytes.Equal(data, tt.data) {
+			t.Errorf("ParseValue(%s, %q) = %v, want %v", tt.dataType, text, data, tt.data)
+		}
+	}
+}
+
+func TestParseValue_Invalid(t *testing.T) {
+	tests := []struct {
+		dataType types.DataType
+		text     string
+	}{
+		{types.BOOLEAN, "maybe"},
+		{types.INT16, "40000"},
+		{types.UINT32, "-1"},
+		{types.FLOAT64, "NaN"},
+		{types.JSON, "{"},
+		{types.TIME, "yesterday"},
+		{types.RAW, "not base64!"},
+		{types.HASH, `{"age":30}`},
+		{types.LIST, `["a",1]`},
+		{types.SET, `{"a":1}`},
+		{types.ZSET, `[{"member":"a","score":"nan"}]`},
+		{types.ZSET, `[{"member":"a","score":"high"}]`},
+		{types.DataType(200), "AA=="},
+	}
+	for _, tt := range tests {
+		if _, err := ParseValue(tt.dataType, tt.text); err == nil {
+			t.Errorf("ParseValue(%s, %q) should fail", tt.dataType, tt.text)
+		}
+	}
+}
+
+func TestParseDataType(t *testing.T) {
+	for _, name := range []string{"Integer64", "integer64", "Unsigned Integer16", "unsignedinteger16", "JSON"} {
+		if _, ok := types.ParseDataType(name); !ok {
+			t.Errorf("ParseDataType(%q) failed", name)
+		}
+	}
+	if dt, _ := types.ParseDataType("Unsigned Integer16"); dt != types.UINT16 {
+		t.Errorf("ParseDataType() = %v, want UINT16", dt)
+	}
+	if _, ok := types.ParseDataType("Unknown"); ok {
+		t.Error("ParseDataType(Unknown) should fail")
+	}
+}
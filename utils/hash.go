@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"encoding/binary"
+	"slices"
+
+	"github.com/found-cake/CacheStore/errors"
+)
+
+// EncodeHash returns the stored form of a hash: the number of fields, then
+// every field and its value, each prefixed with its length. Counts and
+// lengths are uvarints. Fields are sorted, so equal hashes always encode to
+// the same bytes.
+func EncodeHash(fields map[string][]byte) []byte {
+	names := make([]string, 0, len(fields))
+	size := binary.MaxVarintLen64
+	for name, value := range fields {
+		names = append(names, name)
+		size += 2*binary.MaxVarintLen64 + len(name) + len(value)
+	}
+	slices.Sort(names)
+
+	data := make([]byte, 0, size)
+	data = binary.AppendUvarint(data, uint64(len(names)))
+	for _, name := range names {
+		data = appendBytes(data, []byte(name))
+		data = appendBytes(data, fields[name])
+	}
+	return data
+}
+
+// DecodeHash is the inverse of EncodeHash. The values do not share memory
+// with data.
+func DecodeHash(data []byte) (map[string][]byte, error) {
+	n, data, err := readCount(data)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string][]byte, n)
+	for i := 0; i < n; i++ {
+		var name, value []byte
+		if name, data, err = readBytes(data); err != nil {
+			return nil, err
+		}
+		if value, data, err = readBytes(data); err != nil {
+			return nil, err
+		}
+		fields[string(name)] = slices.Clone(value)
+	}
+	if len(data) != 0 {
+		return nil, errors.ErrCorruptValue
+	}
+	return fields, nil
+}
+
+// HashField returns the value of one field of an encoded hash without
+// decoding the others. The value shares memory with data.
+func HashField(data []byte, field string) ([]byte, bool, error) {
+	n, data, err := readCount(data)
+	if err != nil {
+		return nil, false, err
+	}
+	for i := 0; i < n; i++ {
+		var name, value []byte
+		if name, data, err = readBytes(data); err != nil {
+			return nil, false, err
+		}
+		if value, data, err = readBytes(data); err != nil {
+			return nil, false, err
+		}
+		if string(name) == field {
+			return value, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// HashLen returns the number of fields of an encoded hash.
+func HashLen(data []byte) (int, error) {
+	n, _, err := readCount(data)
+	return n, err
+}
+
+func appendBytes(data, b []byte) []byte {
+	data = binary.AppendUvarint(data, uint64(len(b)))
+	return append(data, b...)
+}
+
+func readCount(data []byte) (int, []byte, error) {
+	n, size := binary.Uvarint(data)
+	// Every element takes at least one byte, which bounds a valid count.
+	if size <= 0 || n > uint64(len(data)-size) {
+		return 0, nil, errors.ErrCorruptValue
+	}
+	return int(n), data[size:], nil
+}
+
+func readBytes(data []byte) ([]byte, []byte, error) {
+	n, size := binary.Uvarint(data)
+	if size <= 0 || n > uint64(len(data)-size) {
+		return nil, nil, errors.ErrCorruptValue
+	}
+	end := size + int(n)
+	return data[size:end:end], data[end:], nil
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/found-cake/CacheStore/errors"
+)
+
+func TestHashEncoding_RoundTrip(t *testing.T) {
+	fields := map[string][]byte{"name": []byte("alice"), "": []byte("empty name"), "bin": {0, 255}, "none": {}}
+	data := EncodeHash(fields)
+
+	got, err := DecodeHash(data)
+	if err != nil {
+		t.Fatalf("DecodeHash() error = %v", err)
+	}
+	if len(got) != len(fields) {
+		t.Fatalf("DecodeHash() returned %d fields, want %d", len(got), len(fields))
+	}
+	for field, value := range fields {
+		if !bytes.Equal(got[field], value) {
+			t.Errorf("field %q = %v, want %v", field, got[field], value)
+		}
+	}
+	if n, err := HashLen(data); err != nil || n != len(fields) {
+		t.Errorf("HashLen() = %d, %v, want %d", n, err, len(fields))
+	}
+	if v, ok, err := HashField(data, "bin"); err != nil || !ok || !bytes.Equal(v, []byte{0, 255}) {
+		t.Errorf("HashField(bin) = %v, %v, %v", v, ok, err)
+	}
+	if _, ok, err := HashField(data, "missing"); err != nil || ok {
+		t.Errorf("HashField(missing) = %v, %v, want not found", ok, err)
+	}
+}
+
+func TestHashEncoding_Stable(t *testing.T) {
+	a := map[string][]byte{}
+	b := map[string][]byte{}
+	for i := 0; i < 50; i++ {
+		field := string(rune('a'+i%26)) + string(rune('A'+i))
+		a[field] = []byte(field)
+	}
+	for field, value := range a {
+		b[field] = value
+	}
+	if !bytes.Equal(EncodeHash(a), EncodeHash(b)) {
+		t.Error("equal hashes encode differently")
+	}
+}
+
+func TestHashEncoding_Corrupt(t *testing.T) {
+	data := EncodeHash(map[string][]byte{"field": []byte("value")})
+	for _, bad := range [][]byte{nil, data[:len(data)-1], append(data, 0), {0xff}} {
+		if _, err := DecodeHash(bad); err != errors.ErrCorruptValue {
+			t.Errorf("DecodeHash(%v) error = %v, want ErrCorruptValue", bad, err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"encoding/binary"
+	"slices"
+
+	"github.com/found-cake/CacheStore/errors"
+)
+
+// EncodeList returns the stored form of a list: the number of elements, then
+// every element prefixed with its length, like EncodeHash.
+func EncodeList(elems [][]byte) []byte {
+	size := binary.MaxVarintLen64
+	for _, elem := range elems {
+		size += binary.MaxVarintLen64 + len(elem)
+	}
+	data := make([]byte, 0, size)
+	data = binary.AppendUvarint(data, uint64(len(elems)))
+	for _, elem := range elems {
+		data = appendBytes(data, elem)
+	}
+	return data
+}
+
+// DecodeList is the inverse of EncodeList. The elements do not share memory
+// with data.
+func DecodeList(data []byte) ([][]byte, error) {
+	n, data, err := readCount(data)
+	if err != nil {
+		return nil, err
+	}
+	elems := make([][]byte, n)
+	for i := range elems {
+		var elem []byte
+		if elem, data, err = readBytes(data); err != nil {
+			return nil, err
+		}
+		elems[i] = slices.Clone(elem)
+	}
+	if len(data) != 0 {
+		return nil, errors.ErrCorruptValue
+	}
+	return elems, nil
+}
+
+// ListLen returns the number of elements of an encoded list.
+func ListLen(data []byte) (int, error) {
+	n, _, err := readCount(data)
+	return n, err
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/found-cake/CacheStore/errors"
+)
+
+func TestListEncoding_RoundTrip(t *testing.T) {
+	elems := [][]byte{[]byte("a"), {}, {0, 255}, []byte("a")}
+	data := EncodeList(elems)
+
+	got, err := DecodeList(data)
+	if err != nil {
+		t.Fatalf("DecodeList() error = %v", err)
+	}
+	if len(got) != len(elems) {
+		t.Fatalf("DecodeList() returned %d elements, want %d", len(got), len(elems))
+	}
+	for i := range elems {
+		if !bytes.Equal(got[i], elems[i]) {
+			t.Errorf("element %d = %v, want %v", i, got[i], elems[i])
+		}
+	}
+	if n, err := ListLen(data); err != nil || n != len(elems) {
+		t.Errorf("ListLen() = %d, %v, want %d", n, err, len(elems))
+	}
+	if _, err := DecodeList(data[:len(data)-1]); err != errors.ErrCorruptValue {
+		t.Errorf("DecodeList() of truncated data error = %v, want ErrCorruptValue", err)
+	}
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"slices"
+
+	"github.com/found-cake/CacheStore/errors"
+)
+
+// EncodeSet returns the stored form of a set: its members sorted and without
+// duplicates, encoded like EncodeList, so equal sets always encode to the
+// same bytes.
+func EncodeSet(members []string) []byte {
+	sorted := slices.Clone(members)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+	elems := make([][]byte, len(sorted))
+	for i, member := range sorted {
+		elems[i] = []byte(member)
+	}
+	return EncodeList(elems)
+}
+
+// DecodeSet is the inverse of EncodeSet. It returns the members sorted.
+func DecodeSet(data []byte) ([]string, error) {
+	n, data, err := readCount(data)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]string, n)
+	for i := range members {
+		var member []byte
+		if member, data, err = readBytes(data); err != nil {
+			return nil, err
+		}
+		members[i] = string(member)
+	}
+	if len(data) != 0 {
+		return nil, errors.ErrCorruptValue
+	}
+	return members, nil
+}
+
+// SetContains reports whether an encoded set has member without decoding
+// the others.
+func SetContains(data []byte, member string) (bool, error) {
+	n, data, err := readCount(data)
+	if err != nil {
+		return false, err
+	}
+	for i := 0; i < n; i++ {
+		var b []byte
+		if b, data, err = readBytes(data); err != nil {
+			return false, err
+		}
+		if string(b) >= member {
+			// Members are sorted, so there is no need to look further.
+			return string(b) == member, nil
+		}
+	}
+	return false, nil
+}
+
+// SetLen returns the number of members of an encoded set.
+func SetLen(data []byte) (int, error) {
+	n, _, err := readCount(data)
+	return n, err
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/found-cake/CacheStore/errors"
+)
+
+func TestSetEncoding_RoundTrip(t *testing.T) {
+	data := EncodeSet([]string{"go", "", "db", "go", "\xff"})
+	if !slices.Equal(data, EncodeSet([]string{"\xff", "db", "", "go"})) {
+		t.Error("EncodeSet() depends on the order or duplicates of members")
+	}
+
+	got, err := DecodeSet(data)
+	if err != nil {
+		t.Fatalf("DecodeSet() error = %v", err)
+	}
+	if want := []string{"", "db", "go", "\xff"}; !slices.Equal(got, want) {
+		t.Errorf("DecodeSet() = %q, want %q", got, want)
+	}
+	for _, member := range got {
+		if ok, err := SetContains(data, member); err != nil || !ok {
+			t.Errorf("SetContains(%q) = %v, %v, want true", member, ok, err)
+		}
+	}
+	for _, member := range []string{"a", "dc", "zz"} {
+		if ok, err := SetContains(data, member); err != nil || ok {
+			t.Errorf("SetContains(%q) = %v, %v, want false", member, ok, err)
+		}
+	}
+	if n, err := SetLen(data); err != nil || n != 4 {
+		t.Errorf("SetLen() = %d, %v, want 4", n, err)
+	}
+	if _, err := DecodeSet(data[:len(data)-1]); err != errors.ErrCorruptValue {
+		t.Errorf("DecodeSet() of truncated data error = %v, want ErrCorruptValue", err)
+	}
+}
@@ -0,0 +1,86 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	UserTypeMin DataType = 128 // First DataType available to Register
+	UserTypeMax DataType = 255 // Last DataType available to Register
+)
+
+// Definition describes an application defined DataType.
+//
+// Encode converts a Go value into the bytes stored in the cache and Decode
+// converts them back. Decode must not retain data.
+type Definition struct {
+	Name   string
+	Encode func(value any) ([]byte, error)
+	Decode func(data []byte) (any, error)
+}
+
+var (
+	ErrNotUserType   = fmt.Errorf("user defined data types must be in range %d ~ %d", UserTypeMin, UserTypeMax)
+	ErrTypeNameEmpty = errors.New("data type name cannot be empty")
+	ErrTypeCodecNil  = errors.New("data type encode and decode cannot be null")
+)
+
+var (
+	registryMux sync.RWMutex
+	registry    = make(map[DataType]Definition)
+)
+
+// Register makes a user defined DataType known to the cache. It is meant to
+// be called during program initialization, before any store is opened.
+func Register(t DataType, def Definition) error {
+	if !t.IsUserDefined() {
+		return ErrNotUserType
+	}
+	if def.Name == "" {
+		return ErrTypeNameEmpty
+	}
+	if def.Encode == nil || def.Decode == nil {
+		return ErrTypeCodecNil
+	}
+
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	if old, ok := registry[t]; ok {
+		return fmt.Errorf("data type %d is already registered as %s", t, old.Name)
+	}
+	registry[t] = def
+	return nil
+}
+
+// Unregister removes a user defined DataType. It is mostly useful in tests.
+func Unregister(t DataType) {
+	registryMux.Lock()
+	defer registryMux.Unlock()
+	delete(registry, t)
+}
+
+func Lookup(t DataType) (Definition, bool) {
+	registryMux.RLock()
+	defer registryMux.RUnlock()
+	def, ok := registry[t]
+	return def, ok
+}
+
+func (t DataType) IsBuiltin() bool {
+	return t <= lastBuiltin
+}
+
+func (t DataType) IsUserDefined() bool {
+	return t >= UserTypeMin
+}
+
+// IsKnown reports whether t is a built-in or a registered DataType.
+func (t DataType) IsKnown() bool {
+	if t.IsBuiltin() {
+		return true
+	}
+	_, ok := Lookup(t)
+	return ok
+}
@@ -1,5 +1,7 @@
 package types
 
+import "strings"
+
 type DataType uint8
 
 const (
@@ -17,6 +19,12 @@ const (
 	STRING
 	TIME
 	JSON
+	HASH
+	LIST
+	SET
+	ZSET
+
+	lastBuiltin = ZSET
 )
 
 func (t DataType) String() string {
@@ -47,7 +55,46 @@ func (t DataType) String() string {
 		return "Time"
 	case JSON:
 		return "Json"
+	case HASH:
+		return "Hash"
+	case LIST:
+		return "List"
+	case SET:
+		return "Set"
+	case ZSET:
+		return "Sorted Set"
 	default:
+		if def, ok := Lookup(t); ok {
+			return def.Name
+		}
 		return "Unknown"
 	}
 }
+
+// ParseDataType returns the DataType named name, as returned by String.
+// Matching ignores case and spaces, so "uint16" does not match but
+// "unsignedinteger16" and "Unsigned Integer16" do. Registered types are
+// matched by their Definition name.
+func ParseDataType(name string) (DataType, bool) {
+	want := normalizeName(name)
+	if want == "" {
+		return UNKNOWN, false
+	}
+	for t := RAW; t <= lastBuiltin; t++ {
+		if normalizeName(t.String()) == want {
+			return t, true
+		}
+	}
+	registryMux.RLock()
+	defer registryMux.RUnlock()
+	for t, def := range registry {
+		if normalizeName(def.Name) == want {
+			return t, true
+		}
+	}
+	return UNKNOWN, false
+}
+
+func normalizeName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, " ", ""))
+}
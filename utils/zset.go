@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"cmp"
+	"encoding/binary"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/found-cake/CacheStore/errors"
+)
+
+// ZMember is a member of a sorted set and its score.
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// The stored form of a sorted set is:
+//
+//	count    uint32
+//	offsets  count × uint32   start of every record, records sorted by member
+//	byScore  count × uint32   record numbers sorted by score, then member
+//	records  count × (score float64, member bytes)
+//
+// All integers are big endian and offsets are relative to the first record.
+// A member ends where the next record starts, and ranks are positions in
+// byScore.
+type zsetView struct {
+	n       int
+	offsets []byte
+	byScore []byte
+	records []byte
+}
+
+func parseZSet(data []byte) (zsetView, error) {
+	if len(data) < 4 {
+		return zsetView{}, errors.ErrCorruptValue
+	}
+	n := uint64(binary.BigEndian.Uint32(data))
+	// Every member takes 8 bytes of index and at least 8 bytes of record.
+	if uint64(len(data)-4) < 16*n {
+		return zsetView{}, errors.ErrCorruptValue
+	}
+	index := 4 + 4*int(n)
+	return zsetView{
+		n:       int(n),
+		offsets: data[4:index],
+		byScore: data[index : index+4*int(n)],
+		records: data[index+4*int(n):],
+	}, nil
+}
+
+// start returns where record i starts, or the end of the records for i == n.
+func (z zsetView) start(i int) uint32 {
+	if i == z.n {
+		return uint32(len(z.records))
+	}
+	return binary.BigEndian.Uint32(z.offsets[4*i:])
+}
+
+// record returns the member and score of record i, in member order.
+func (z zsetView) record(i int) ([]byte, float64, error) {
+	start, end := z.start(i), z.start(i+1)
+	if end > uint32(len(z.records)) || start > end || end-start < 8 {
+		return nil, 0, errors.ErrCorruptValue
+	}
+	score := math.Float64frombits(binary.BigEndian.Uint64(z.records[start:]))
+	return z.records[start+8 : end : end], score, nil
+}
+
+// ranked returns the record number of the member at rank.
+func (z zsetView) ranked(rank int) (int, error) {
+	i := int(binary.BigEndian.Uint32(z.byScore[4*rank:]))
+	if i >= z.n {
+		return 0, errors.ErrCorruptValue
+	}
+	return i, nil
+}
+
+// EncodeZSet returns the stored form of a sorted set. When a member is given
+// more than once, the last score counts.
+func EncodeZSet(members []ZMember) []byte {
+	scores := make(map[string]float64, len(members))
+	for _, m := range members {
+		scores[m.Member] = m.Score
+	}
+	records := make([]ZMember, 0, len(scores))
+	size := 0
+	for member, score := range scores {
+		records = append(records, ZMember{member, score})
+		size += 8 + len(member)
+	}
+	slices.SortFunc(records, func(a, b ZMember) int {
+		return strings.Compare(a.Member, b.Member)
+	})
+	byScore := make([]int, len(records))
+	for i := range byScore {
+		byScore[i] = i
+	}
+	slices.SortFunc(byScore, func(i, j int) int {
+		a, b := records[i], records[j]
+		return cmp.Or(cmp.Compare(a.Score, b.Score), strings.Compare(a.Member, b.Member))
+	})
+
+	n := len(records)
+	out := make([]byte, 4+8*n, 4+8*n+size)
+	binary.BigEndian.PutUint32(out, uint32(n))
+	base := len(out)
+	for i, m := range records {
+		binary.BigEndian.PutUint32(out[4+4*i:], uint32(len(out)-base))
+		out = binary.BigEndian.AppendUint64(out, math.Float64bits(m.Score))
+		out = append(out, m.Member...)
+	}
+	for rank, i := range byScore {
+		binary.BigEndian.PutUint32(out[4+4*n+4*rank:], uint32(i))
+	}
+	return out
+}
+
+// DecodeZSet returns the members of an encoded sorted set by score.
+func DecodeZSet(data []byte) ([]ZMember, error) {
+	z, err := parseZSet(data)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]ZMember, 0, z.n)
+	for rank := 0; rank < z.n; rank++ {
+		i, err := z.ranked(rank)
+		if err != nil {
+			return nil, err
+		}
+		m, score, err := z.record(i)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, ZMember{Member: string(m), Score: score})
+	}
+	return members, nil
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/found-cake/CacheStore/errors"
+)
+
+func TestZSetEncoding_Lookups(t *testing.T) {
+	data := EncodeZSet([]ZMember{
+		{"carol", 30}, {"alice", 10}, {"bob", 20}, {"dave", 20}, {"erin", math.Inf(-1)}, {"alice", 25},
+	})
+
+	members, err := DecodeZSet(data)
+	if err != nil {
+		t.Fatalf("DecodeZSet() error = %v", err)
+	}
+	want := []ZMember{{"erin", math.Inf(-1)}, {"bob", 20}, {"dave", 20}, {"alice", 25}, {"carol", 30}}
+	if !slices.Equal(members, want) {
+		t.Errorf("DecodeZSet() = %v, want %v", members, want)
+	}
+	if !slices.Equal(EncodeZSet(members), data) {
+		t.Error("EncodeZSet(DecodeZSet()) differs from the encoded set")
+	}
+}
+
+func TestZSetEncoding_Corrupt(t *testing.T) {
+	data := EncodeZSet([]ZMember{{"a", 1}, {"b", 2}})
+	for _, bad := range [][]byte{nil, {0, 0}, data[:len(data)-2], data[:20]} {
+		if _, err := DecodeZSet(bad); err != errors.ErrCorruptValue {
+			t.Errorf("DecodeZSet(%v) error = %v, want ErrCorruptValue", bad, err)
+		}
+	}
+	// A record number out of range in the score index.
+	bad := slices.Clone(data)
+	bad[4+8+3] = 9
+	if _, err := DecodeZSet(bad); err != errors.ErrCorruptValue {
+		t.Errorf("DecodeZSet() with a bad index error = %v, want ErrCorruptValue", err)
+	}
+}